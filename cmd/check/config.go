@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the --config file format, mirroring the conditions supported by
+// the oci_structure_test data source. JSON is accepted too, since it's a subset
+// of YAML.
+//
+//	files:
+//	  /etc/passwd: {regex: "nonroot", mode: "0644"}
+//	  /etc/shadow: {optional: true}
+//	  /etc/ssl/certs/ca-certificates.crt: {regex: "BEGIN", max_size: 5242880}
+//	  /usr/bin/app: {regex: "v1\\.2", max_size: 52428800, allow_binary: true}
+//	dirs:
+//	  /tmp: {mode: "1777"}
+//	  /var/lib: {exists_only: true}
+//	permissions:
+//	  /usr: {override: [/usr/lib/writable]}
+//	env:
+//	  PATH: /usr/local/bin:/usr/bin
+type config struct {
+	Files map[string]fileConfig `yaml:"files"`
+	Dirs  map[string]struct {
+		Mode       string `yaml:"mode"`
+		Recursive  bool   `yaml:"recursive"`
+		ExistsOnly bool   `yaml:"exists_only"`
+	} `yaml:"dirs"`
+	Permissions map[string]struct {
+		Override []string `yaml:"override"`
+	} `yaml:"permissions"`
+	Env map[string]string `yaml:"env"`
+}
+
+type fileConfig struct {
+	Regex       string `yaml:"regex"`
+	Mode        string `yaml:"mode"`
+	Optional    bool   `yaml:"optional"`
+	MaxSize     int64  `yaml:"max_size"`
+	AllowBinary bool   `yaml:"allow_binary"`
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// addFlags adds the conditions from the --file and --env flags to the config.
+func (c *config) addFlags(files, envs []string) {
+	for _, f := range files {
+		if c.Files == nil {
+			c.Files = map[string]fileConfig{}
+		}
+		path, regex, _ := strings.Cut(f, "=")
+		c.Files[path] = fileConfig{Regex: regex}
+	}
+	for _, e := range envs {
+		if c.Env == nil {
+			c.Env = map[string]string{}
+		}
+		k, v, _ := strings.Cut(e, "=")
+		c.Env[k] = v
+	}
+}
+
+// check is a named condition, reported as a single result.
+type check struct {
+	name string
+	cond structure.Condition
+}
+
+// checks returns the conditions described by the config.
+func (c *config) checks() ([]check, error) {
+	var checks []check
+	if len(c.Files) > 0 {
+		fc := structure.FilesCondition{Want: map[string]structure.File{}}
+		for path, f := range c.Files {
+			if _, err := regexp.Compile(f.Regex); err != nil {
+				return nil, fmt.Errorf("files %q: invalid regex: %w", path, err)
+			}
+			mode, err := parseMode(f.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("files %q: %w", path, err)
+			}
+			if f.MaxSize < 0 {
+				return nil, fmt.Errorf("files %q: max_size must not be negative", path)
+			}
+			fc.Want[path] = structure.File{Regex: f.Regex, Mode: mode, Optional: f.Optional, MaxSize: f.MaxSize, AllowBinary: f.AllowBinary}
+		}
+		checks = append(checks, check{"files", fc})
+	}
+	if len(c.Dirs) > 0 {
+		dc := structure.DirsCondition{Want: map[string]structure.Dir{}}
+		for path, d := range c.Dirs {
+			if d.ExistsOnly && (d.Mode != "" || d.Recursive) {
+				return nil, fmt.Errorf("dirs %q: exists_only can't be combined with mode or recursive", path)
+			}
+			mode, err := parseMode(d.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("dirs %q: %w", path, err)
+			}
+			dc.Want[path] = structure.Dir{Mode: mode, Recursive: d.Recursive, ExistsOnly: d.ExistsOnly}
+		}
+		checks = append(checks, check{"dirs", dc})
+	}
+	if len(c.Permissions) > 0 {
+		pc := structure.PermissionsCondition{Want: map[string]structure.Permission{}}
+		for path, p := range c.Permissions {
+			pc.Want[path] = structure.Permission{Override: p.Override}
+		}
+		checks = append(checks, check{"permissions", pc})
+	}
+	if len(c.Env) > 0 {
+		checks = append(checks, check{"env", structure.EnvCondition{Want: c.Env}})
+	}
+	return checks, nil
+}
+
+// parseMode parses an optional mode string, returning nil if it's empty.
+func parseMode(s string) (*fs.FileMode, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mode, err := structure.ParseMode(s)
+	if err != nil {
+		return nil, err
+	}
+	return &mode, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
+)
+
+func TestConfig(t *testing.T) {
+	for _, c := range []struct {
+		desc, config string
+		wantChecks   int
+		wantErr      string
+	}{{
+		desc: "yaml",
+		config: `
+files:
+  /etc/passwd: {regex: "nonroot", mode: "0644"}
+  /etc/shadow: {optional: true}
+dirs:
+  /tmp: {mode: "1777"}
+permissions:
+  /usr: {override: [/usr/lib]}
+env:
+  PATH: /usr/bin
+`,
+		wantChecks: 4,
+	}, {
+		desc:       "json",
+		config:     `{"env": {"PATH": "/usr/bin"}, "files": {"/etc/passwd": {}}}`,
+		wantChecks: 2,
+	}, {
+		desc:    "bad mode",
+		config:  `dirs: {/tmp: {mode: "0999"}}`,
+		wantErr: `dirs "/tmp": invalid mode "0999"`,
+	}, {
+		desc:       "regex limits",
+		config:     `files: {/etc/bundle.pem: {regex: "BEGIN", max_size: 5242880}, /usr/bin/app: {regex: "v1", allow_binary: true}}`,
+		wantChecks: 1,
+	}, {
+		desc:    "negative max_size",
+		config:  `files: {/etc/bundle.pem: {regex: "BEGIN", max_size: -1}}`,
+		wantErr: `files "/etc/bundle.pem": max_size must not be negative`,
+	}, {
+		desc:       "dir exists only",
+		config:     `dirs: {/var/lib: {exists_only: true}}`,
+		wantChecks: 1,
+	}, {
+		desc:    "dir exists only with mode",
+		config:  `dirs: {/var/lib: {exists_only: true, mode: "0755"}}`,
+		wantErr: `dirs "/var/lib": exists_only can't be combined with mode or recursive`,
+	}, {
+		desc:    "bad regex",
+		config:  `files: {/etc/passwd: {regex: "("}}`,
+		wantErr: `files "/etc/passwd": invalid regex`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(c.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := loadConfig(path)
+			if err != nil {
+				t.Fatalf("loadConfig: %v", err)
+			}
+			checks, err := cfg.checks()
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("conditions: %v", err)
+			}
+			if len(checks) != c.wantChecks {
+				t.Errorf("got %d checks, want %d", len(checks), c.wantChecks)
+			}
+		})
+	}
+
+	cfg, err := loadConfig(filepath.Join("testdata", "missing.yaml"))
+	if err == nil {
+		t.Errorf("expected an error for a missing config, got %+v", cfg)
+	}
+
+	// Modes are parsed, including special bits.
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`files: {/usr/bin/su: {mode: "4755"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := cfg.checks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, _ := checks[0].cond.(structure.FilesCondition)
+	if m := fc.Want["/usr/bin/su"].Mode; m == nil || structure.FormatMode(*m) != "4755" {
+		t.Errorf("got mode %v, want 4755", m)
+	}
+}
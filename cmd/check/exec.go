@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-oci/internal/provider"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+)
+
+func newExecCmd() *cobra.Command {
+	var script, scriptFile, driver, workingDir, envFile, proto string
+	var envs []string
+	var freePorts int64
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "exec IMAGE",
+		Short: "Run an exec test script against an image, like oci_exec_test",
+		Long: `Run an exec test script against an image, with the same environment as the
+oci_exec_test data source: IMAGE_NAME (the image by digest), IMAGE_REGISTRY,
+IMAGE_REPOSITORY, IMAGE_ENTRYPOINT, IMAGE_CMD, IMAGE_USER, IMAGE_EXPOSED_PORTS,
+FREE_PORT and FREE_PORT_0..N, and TEST_ARTIFACTS.
+
+IMAGE is a registry reference; tags are resolved to a digest first.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case script != "" && scriptFile != "":
+				return errors.New("only one of --script and --script-file may be set")
+			case scriptFile != "":
+				b, err := os.ReadFile(scriptFile)
+				if err != nil {
+					return fmt.Errorf("failed to read script: %v", err)
+				}
+				script = string(b)
+			case script == "":
+				return errors.New("one of --script or --script-file is required")
+			}
+
+			ref, err := name.ParseReference(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to parse reference: %v", err)
+			}
+			desc, err := remote.Get(ref, remote.WithContext(cmd.Context()), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+			if err != nil {
+				return fmt.Errorf("failed to fetch image: %v", err)
+			}
+			image := args[0]
+			if _, ok := ref.(name.Digest); !ok {
+				image = ref.Context().Digest(desc.Digest.String()).String()
+			}
+
+			// For an index, this resolves the image for the default platform.
+			img, err := desc.Image()
+			if err != nil {
+				return fmt.Errorf("failed to fetch image: %v", err)
+			}
+			cf, err := img.ConfigFile()
+			if err != nil {
+				return fmt.Errorf("failed to fetch image config: %v", err)
+			}
+
+			artifacts, err := os.MkdirTemp("", "oci-exec-test-")
+			if err != nil {
+				return fmt.Errorf("failed to create artifacts directory: %v", err)
+			}
+			defer os.RemoveAll(artifacts)
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			code, err := provider.RunExecTest(ctx, provider.ExecTest{
+				Image:         image,
+				Config:        cf,
+				Script:        script,
+				Driver:        driver,
+				WorkingDir:    workingDir,
+				ArtifactsDir:  artifacts,
+				FreePorts:     freePorts,
+				FreePortProto: proto,
+				EnvFile:       envFile,
+				Env:           envs,
+				Stdout:        cmd.OutOrStdout(),
+				Stderr:        cmd.ErrOrStderr(),
+			})
+			switch {
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				fmt.Fprintf(cmd.ErrOrStderr(), "Test for %s timed out after %s\n", image, timeout)
+				return errFailed
+			case err != nil:
+				return fmt.Errorf("failed to run test: %v", err)
+			case code != 0:
+				fmt.Fprintf(cmd.ErrOrStderr(), "Test for %s failed with exit code %d\n", image, code)
+				return errFailed
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&script, "script", "", "Script to run")
+	cmd.Flags().StringVar(&scriptFile, "script-file", "", "File containing the script to run")
+	cmd.Flags().StringVar(&driver, "driver", "host", "Driver to run the script with: host, docker or k8s")
+	cmd.Flags().StringVar(&workingDir, "working-dir", "", "Working directory for the script")
+	cmd.Flags().StringSliceVarP(&envs, "env", "e", nil, `Environment variables to set (e.g., "FOO=bar")`)
+	cmd.Flags().StringVar(&envFile, "env-file", "", "File of NAME=value environment variables to set")
+	cmd.Flags().Int64Var(&freePorts, "free-ports", 1, "Number of free ports to allocate")
+	cmd.Flags().StringVar(&proto, "free-port-protocol", "tcp", "Protocol of the free ports: tcp or udp")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Timeout for the script")
+	return cmd
+}
@@ -1,67 +1,104 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 
-	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
-	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// exitFailed is the exit code when one or more conditions fail.
+	exitFailed = 1
+	// exitError is the exit code when the check can't be run at all, e.g.
+	// because of invalid flags or config, or the image can't be fetched.
+	exitError = 2
+)
+
+// version is reported in the User-Agent of registry requests.
+var version = "dev"
+
+// errFailed is returned when the check ran but one or more conditions failed.
+var errFailed = errors.New("image does not match conditions")
+
 func main() {
-	var files, envs []string
-	var platform string
+	var files, envs, platforms []string
+	var ignore []string
+	var configFile, output, ignoreFile string
+	var warnOnly bool
 
 	cmd := &cobra.Command{
-		Use:          "check",
-		Short:        "Check a container image for compliance with a set of conditions",
-		Args:         cobra.ExactArgs(1),
-		SilenceUsage: true,
+		Use:   "check IMAGE",
+		Short: "Check a container image for compliance with a set of conditions",
+		Long: `Check a container image for compliance with a set of conditions.
+
+IMAGE is a registry reference, or one of:
+
+  docker-archive:/path/to/image.tar  a "docker save" tarball
+  oci-layout:/path/to/layout         an OCI image layout directory
+  daemon:image:tag                   an image in the local Docker daemon`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ref, err := name.ParseReference(args[0])
-			if err != nil {
-				return fmt.Errorf("failed to parse reference: %v", err)
+			report, ok := reporters[output]
+			if !ok {
+				return fmt.Errorf("unknown output format %q", output)
 			}
-			plat, err := v1.ParsePlatform(platform)
+
+			pol, err := newPolicy(ignore, ignoreFile, warnOnly)
 			if err != nil {
-				return fmt.Errorf("failed to parse platform: %v", err)
+				return fmt.Errorf("failed to load ignore patterns: %v", err)
+			}
+
+			cfg := &config{}
+			if configFile != "" {
+				if cfg, err = loadConfig(configFile); err != nil {
+					return fmt.Errorf("failed to load config: %v", err)
+				}
 			}
-			img, err := remote.Image(ref,
-				remote.WithAuthFromKeychain(authn.DefaultKeychain),
-				remote.WithPlatform(*plat),
-			)
+			cfg.addFlags(files, envs)
+			checks, err := cfg.checks()
 			if err != nil {
-				return fmt.Errorf("failed to fetch image: %v", err)
+				return fmt.Errorf("invalid config: %v", err)
 			}
 
-			var conds structure.Conditions
-			fc := structure.FilesCondition{Want: map[string]structure.File{}}
-			for _, f := range files {
-				path, regex, _ := strings.Cut(f, "=")
-				fc.Want[path] = structure.File{Regex: regexp.MustCompile(regex).String()}
+			targets, cleanup, err := resolve(cmd.Context(), args[0], platforms)
+			defer cleanup()
+			if err != nil {
+				return err
 			}
-			conds = append(conds, fc)
 
-			ec := structure.EnvCondition{Want: map[string]string{}}
-			for _, e := range envs {
-				k, v, _ := strings.Cut(e, "=")
-				ec.Want[k] = v
+			var results []result
+			for _, t := range targets {
+				results = append(results, run(checks, t)...)
 			}
-			conds = append(conds, ec)
-
-			return conds.Check(img)
+			pol.apply(results)
+			if err := report(cmd.OutOrStdout(), args[0], results); err != nil {
+				return fmt.Errorf("failed to write results: %v", err)
+			}
+			if failed(results) {
+				return errFailed
+			}
+			return nil
 		},
 	}
 	cmd.Flags().StringSliceVarP(&files, "file", "f", nil, `Files to check (e.g., "/etc/passwd=.*nonroot:.*" or "/etc/passwd" to check existence only)`)
 	cmd.Flags().StringSliceVarP(&envs, "env", "e", nil, `Environment variables to check (e.g., "PATH=/usr/local/bin")`)
-	cmd.Flags().StringVar(&platform, "platform", "linux/amd64", "Platform to check (e.g., linux/amd64)")
+	cmd.Flags().StringVar(&configFile, "config", "", "YAML or JSON file of conditions to check, with files, dirs, permissions and env sections")
+	cmd.Flags().StringSliceVar(&platforms, "platform", []string{"linux/amd64"}, `Platforms to check in an index (e.g., linux/amd64), or "all" for every platform`)
+	cmd.Flags().StringSliceVar(&ignore, "ignore", nil, "Regular expressions matching failures to ignore")
+	cmd.Flags().StringVar(&ignoreFile, "ignore-file", "", "File of regular expressions matching failures to ignore, one per line")
+	cmd.Flags().BoolVar(&warnOnly, "warn-only", false, "Report failures as warnings without failing the check")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: "+strings.Join(formats(), ", "))
+	cmd.AddCommand(newExecCmd(), newAppendCmd(), newTagCmd(), newCopyCmd())
 	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+		if !errors.Is(err, errFailed) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitError)
+		}
+		os.Exit(exitFailed)
 	}
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// result is the outcome of a single check.
+type result struct {
+	Condition string `json:"condition"`
+	Platform  string `json:"platform,omitempty"`
+	Passed    bool   `json:"passed"`
+	// Warning is set if the result failed, but only as a warning.
+	Warning  bool     `json:"warning,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+	// Ignored holds failures that were ignored by --ignore patterns.
+	Ignored []string `json:"ignored,omitempty"`
+}
+
+// name returns the condition name, qualified by the platform if there is one.
+func (r result) name() string {
+	if r.Platform == "" {
+		return r.Condition
+	}
+	return fmt.Sprintf("%s (%s)", r.Condition, r.Platform)
+}
+
+// run runs each check against the target image.
+func run(checks []check, t target) []result {
+	results := make([]result, 0, len(checks))
+	for _, c := range checks {
+		r := result{Condition: c.name, Platform: t.platform, Passed: true}
+		if err := c.cond.Check(t.img); err != nil {
+			r.Passed = false
+			r.Failures = failures(err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// failures flattens joined errors into their messages.
+func failures(err error) []string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, err := range joined.Unwrap() {
+		out = append(out, failures(err)...)
+	}
+	return out
+}
+
+// reporters write results in each supported --output format.
+var reporters = map[string]func(w io.Writer, ref string, results []result) error{
+	"text":  writeText,
+	"json":  writeJSON,
+	"junit": writeJUnit,
+	"sarif": writeSARIF,
+}
+
+// formats returns the supported --output formats.
+func formats() []string {
+	var out []string
+	for f := range reporters {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// failed reports whether any result failed, other than as a warning.
+func failed(results []result) bool {
+	for _, r := range results {
+		if !r.Passed && !r.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+func writeText(w io.Writer, ref string, results []result) error {
+	for _, r := range results {
+		status := "FAIL"
+		switch {
+		case r.Passed:
+			status = "PASS"
+		case r.Warning:
+			status = "WARN"
+		}
+		line := fmt.Sprintf("%s %s", status, r.name())
+		if len(r.Ignored) > 0 {
+			line += fmt.Sprintf(" (%d ignored)", len(r.Ignored))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, f := range r.Failures {
+			if _, err := fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(f, "\n", "\n  ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, ref string, results []result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Image   string   `json:"image"`
+		Passed  bool     `json:"passed"`
+		Results []result `json:"results"`
+	}{ref, !failed(results), results})
+}
+
+func writeJUnit(w io.Writer, ref string, results []result) error {
+	type failure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+	type testcase struct {
+		Name      string   `xml:"name,attr"`
+		Classname string   `xml:"classname,attr"`
+		Failure   *failure `xml:"failure,omitempty"`
+		SystemOut string   `xml:"system-out,omitempty"`
+	}
+	type testsuite struct {
+		XMLName   xml.Name   `xml:"testsuite"`
+		Name      string     `xml:"name,attr"`
+		Tests     int        `xml:"tests,attr"`
+		Failures  int        `xml:"failures,attr"`
+		Testcases []testcase `xml:"testcase"`
+	}
+	suite := testsuite{Name: ref, Tests: len(results)}
+	for _, r := range results {
+		tc := testcase{Name: r.name(), Classname: ref}
+		switch {
+		case r.Warning:
+			// Warnings don't fail the suite, but are kept in the output.
+			tc.SystemOut = "warning: " + strings.Join(r.Failures, "\nwarning: ")
+		case !r.Passed:
+			suite.Failures++
+			tc.Failure = &failure{
+				Message: fmt.Sprintf("%d failure(s)", len(r.Failures)),
+				Text:    strings.Join(r.Failures, "\n"),
+			}
+		}
+		suite.Testcases = append(suite.Testcases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeSARIF writes a SARIF 2.1.0 log with a rule per condition and a result
+// per failure, located at the image reference and platform. Warnings have the
+// "warning" level, and ignored failures are marked as suppressed.
+func writeSARIF(w io.Writer, ref string, results []result) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	type logicalLocation struct {
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+	type location struct {
+		LogicalLocations []logicalLocation `json:"logicalLocations"`
+	}
+	type suppression struct {
+		Kind string `json:"kind"`
+	}
+	type sarifResult struct {
+		RuleID       string        `json:"ruleId"`
+		Level        string        `json:"level"`
+		Message      message       `json:"message"`
+		Locations    []location    `json:"locations"`
+		Suppressions []suppression `json:"suppressions,omitempty"`
+	}
+
+	rules := []rule{}
+	sresults := []sarifResult{}
+	seen := map[string]bool{}
+	for _, r := range results {
+		if !seen[r.Condition] {
+			seen[r.Condition] = true
+			rules = append(rules, rule{
+				ID:               r.Condition,
+				ShortDescription: message{fmt.Sprintf("Image %s conditions", r.Condition)},
+			})
+		}
+		loc := ref
+		if r.Platform != "" {
+			loc = fmt.Sprintf("%s (%s)", ref, r.Platform)
+		}
+		locations := []location{{
+			LogicalLocations: []logicalLocation{{FullyQualifiedName: loc, Kind: "image"}},
+		}}
+		level := "error"
+		if r.Warning {
+			level = "warning"
+		}
+		for _, f := range r.Failures {
+			sresults = append(sresults, sarifResult{
+				RuleID:    r.Condition,
+				Level:     level,
+				Message:   message{f},
+				Locations: locations,
+			})
+		}
+		// Ignored failures are reported as externally suppressed.
+		for _, f := range r.Ignored {
+			sresults = append(sresults, sarifResult{
+				RuleID:       r.Condition,
+				Level:        level,
+				Message:      message{f},
+				Locations:    locations,
+				Suppressions: []suppression{{Kind: "external"}},
+			})
+		}
+	}
+
+	log := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{
+				"driver": map[string]interface{}{
+					"name":           "check",
+					"informationUri": "https://github.com/chainguard-dev/terraform-provider-oci",
+					"rules":          rules,
+				},
+			},
+			"results": sresults,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+)
+
+type fakeCondition struct{ err error }
+
+func (c fakeCondition) Check(v1.Image) error { return c.err }
+
+func TestReporters(t *testing.T) {
+	results := run([]check{
+		{"env", fakeCondition{}},
+		{"files", fakeCondition{errors.Join(errors.New("file a not found"), errors.New("file b not found"))}},
+	}, target{"linux/amd64", empty.Image})
+
+	if !results[0].Passed || results[1].Passed {
+		t.Fatalf("got results %+v", results)
+	}
+	if got := results[1].Failures; len(got) != 2 || got[0] != "file a not found" {
+		t.Errorf("got failures %q", got)
+	}
+
+	for _, format := range formats() {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := reporters[format](&buf, "example.com/repo@sha256:abc", results); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.Contains(out, "file b not found") {
+				t.Errorf("output missing failure:\n%s", out)
+			}
+
+			switch format {
+			case "json", "sarif":
+				var v map[string]interface{}
+				if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+					t.Errorf("invalid JSON: %v\n%s", err, out)
+				}
+			case "junit":
+				var v struct {
+					Tests    int `xml:"tests,attr"`
+					Failures int `xml:"failures,attr"`
+				}
+				if err := xml.Unmarshal(buf.Bytes(), &v); err != nil {
+					t.Errorf("invalid XML: %v\n%s", err, out)
+				}
+				if v.Tests != 2 || v.Failures != 1 {
+					t.Errorf("got %d tests and %d failures, want 2 and 1", v.Tests, v.Failures)
+				}
+			}
+		})
+	}
+}
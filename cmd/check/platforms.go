@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// target is an image to check, and the platform it was selected for.
+type target struct {
+	platform string
+	img      v1.Image
+}
+
+// imageTarget returns the target for a single image, whatever its platform.
+func imageTarget(img v1.Image) ([]target, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	var platform string
+	if p := cf.Platform(); p != nil {
+		platform = p.String()
+	}
+	return []target{{platform, img}}, nil
+}
+
+// indexTargets returns the image in idx for each of platforms, or every image
+// with a platform if platforms includes "all".
+func indexTargets(idx v1.ImageIndex, platforms []string) ([]target, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var want []v1.Platform
+	all := false
+	for _, p := range platforms {
+		if p == "all" {
+			all = true
+			continue
+		}
+		plat, err := v1.ParsePlatform(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse platform: %v", err)
+		}
+		want = append(want, *plat)
+	}
+
+	var targets []target
+	if all {
+		for _, desc := range im.Manifests {
+			// Skip nested indexes, and attestation manifests which have an
+			// unknown platform.
+			if !desc.MediaType.IsImage() || desc.Platform == nil || desc.Platform.OS == "unknown" {
+				continue
+			}
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, target{desc.Platform.String(), img})
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("index has no platform images")
+		}
+		return targets, nil
+	}
+
+	for _, plat := range want {
+		found := false
+		for _, desc := range im.Manifests {
+			if !desc.MediaType.IsImage() || desc.Platform == nil || !desc.Platform.Satisfies(plat) {
+				continue
+			}
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, target{desc.Platform.String(), img})
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("no image found for platform %s", plat)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestIndexTargets(t *testing.T) {
+	var adds []mutate.IndexAddendum
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "unknown", Architecture: "unknown"},
+	} {
+		img, err := random.Image(100, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &p}})
+	}
+	idx := mutate.AppendManifests(empty.Index, adds...)
+
+	for _, c := range []struct {
+		platforms []string
+		want      []string
+		wantErr   bool
+	}{
+		{platforms: []string{"linux/amd64"}, want: []string{"linux/amd64"}},
+		{platforms: []string{"linux/arm64", "linux/amd64"}, want: []string{"linux/arm64", "linux/amd64"}},
+		{platforms: []string{"all"}, want: []string{"linux/amd64", "linux/arm64"}},
+		{platforms: []string{"linux/s390x"}, wantErr: true},
+		{platforms: []string{"linux/amd64/v8/x"}, wantErr: true},
+	} {
+		targets, err := indexTargets(idx, c.platforms)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", c.platforms)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", c.platforms, err)
+			continue
+		}
+		var got []string
+		for _, t := range targets {
+			got = append(got, t.platform)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%v: got %v, want %v", c.platforms, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%v: got %v, want %v", c.platforms, got, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// policy decides which failures fail the check.
+type policy struct {
+	// ignore matches failure messages that are known and tolerated.
+	ignore []*regexp.Regexp
+	// warnOnly reports failures as warnings, without failing the check.
+	warnOnly bool
+}
+
+// newPolicy returns a policy ignoring failures that match any of the regular
+// expressions in patterns or in ignoreFile, which has one per line and may
+// contain blank lines and # comments.
+func newPolicy(patterns []string, ignoreFile string, warnOnly bool) (*policy, error) {
+	if ignoreFile != "" {
+		b, err := os.ReadFile(ignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			patterns = append(patterns, line)
+		}
+	}
+	p := &policy{warnOnly: warnOnly}
+	for _, s := range patterns {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %v", s, err)
+		}
+		p.ignore = append(p.ignore, re)
+	}
+	return p, nil
+}
+
+// apply moves ignored failures out of each result's failures, and marks any
+// remaining failures as warnings if the policy is warn-only.
+func (p *policy) apply(results []result) {
+	for i := range results {
+		r := &results[i]
+		var kept []string
+		for _, f := range r.Failures {
+			if p.ignored(f) {
+				r.Ignored = append(r.Ignored, f)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		r.Failures = kept
+		r.Passed = len(kept) == 0
+		r.Warning = !r.Passed && p.warnOnly
+	}
+}
+
+func (p *policy) ignored(failure string) bool {
+	for _, re := range p.ignore {
+		if re.MatchString(failure) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	ignoreFile := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(ignoreFile, []byte("# known failures\n\n^file \"/etc/shadow\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newResults := func() []result {
+		return []result{
+			{Condition: "files", Failures: []string{`file "/etc/shadow" not found`, `file "/etc/passwd" not found`}},
+			{Condition: "env", Failures: []string{`env "FOO" does not match "bar" (got "")`}},
+			{Condition: "dirs", Passed: true},
+		}
+	}
+
+	p, err := newPolicy([]string{`env "FOO"`}, ignoreFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := newResults()
+	p.apply(results)
+	if r := results[0]; r.Passed || len(r.Failures) != 1 || len(r.Ignored) != 1 {
+		t.Errorf("files: got %+v", r)
+	}
+	if r := results[1]; !r.Passed || len(r.Ignored) != 1 {
+		t.Errorf("env: got %+v", r)
+	}
+	if !failed(results) {
+		t.Error("expected the check to fail")
+	}
+
+	p, err = newPolicy(nil, ignoreFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = newResults()
+	p.apply(results)
+	if r := results[0]; r.Passed || !r.Warning {
+		t.Errorf("files: got %+v", r)
+	}
+	if r := results[2]; !r.Passed || r.Warning {
+		t.Errorf("dirs: got %+v", r)
+	}
+	if failed(results) {
+		t.Error("expected warnings not to fail the check")
+	}
+
+	if _, err := newPolicy([]string{"("}, "", false); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+	if _, err := newPolicy(nil, filepath.Join(t.TempDir(), "missing"), false); err == nil {
+		t.Error("expected an error for a missing ignore file")
+	}
+}
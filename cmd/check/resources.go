@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/terraform-provider-oci/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+// The append, tag and copy subcommands run the same code as the provider's
+// resources, so their behavior can be reproduced outside of Terraform.
+
+func newAppendCmd() *cobra.Command {
+	var files, contents, insecure []string
+
+	cmd := &cobra.Command{
+		Use:   "append BASE",
+		Short: "Append a layer of files to an image, like oci_append",
+		Long: `Append a layer of files to an image, or to each image in an index, like the
+oci_append resource, and push the result to the base image's repository. The
+result is printed by digest.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			layer := map[string]provider.AppendFile{}
+			for _, f := range files {
+				path, src, ok := strings.Cut(f, "=")
+				if !ok {
+					return fmt.Errorf("invalid --file %q, expected PATH=LOCAL_FILE", f)
+				}
+				layer[path] = provider.AppendFile{Path: src}
+			}
+			for _, c := range contents {
+				path, s, ok := strings.Cut(c, "=")
+				if !ok {
+					return fmt.Errorf("invalid --contents %q, expected PATH=CONTENTS", c)
+				}
+				layer[path] = provider.AppendFile{Contents: s}
+			}
+			if len(layer) == 0 {
+				return fmt.Errorf("at least one --file or --contents is required")
+			}
+
+			d, err := provider.NewCLIOpts(version, insecure).Append(cmd.Context(), args[0], []map[string]provider.AppendFile{layer})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), d)
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVarP(&files, "file", "f", nil, `Local files to add (e.g., "/usr/bin/app=./app")`)
+	cmd.Flags().StringSliceVar(&contents, "contents", nil, `Files to add with literal contents (e.g., "/etc/motd=hello")`)
+	cmd.Flags().StringSliceVar(&insecure, "insecure-registry", nil, "Registries to access over plain HTTP")
+	return cmd
+}
+
+func newTagCmd() *cobra.Command {
+	var insecure []string
+
+	cmd := &cobra.Command{
+		Use:          "tag DIGEST TAG",
+		Short:        "Tag an image by digest, like oci_tag",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref, err := provider.NewCLIOpts(version, insecure).Tag(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), ref)
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&insecure, "insecure-registry", nil, "Registries to access over plain HTTP")
+	return cmd
+}
+
+func newCopyCmd() *cobra.Command {
+	var insecure []string
+
+	cmd := &cobra.Command{
+		Use:   "copy SRC DST",
+		Short: "Copy an image or index to another repository or tag",
+		Long: `Copy an image or index to another repository, by digest, or to a tag. The
+copy is printed by digest.`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := provider.NewCLIOpts(version, insecure).Copy(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), d)
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&insecure, "insecure-registry", nil, "Registries to access over plain HTTP")
+	return cmd
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// Prefixes of image sources other than a registry.
+const (
+	dockerArchivePrefix = "docker-archive:"
+	ociLayoutPrefix     = "oci-layout:"
+	daemonPrefix        = "daemon:"
+)
+
+// resolve returns the images to check for src, which is a registry reference
+// or one of:
+//
+//	docker-archive:/path/to/image.tar  a `docker save` tarball
+//	oci-layout:/path/to/layout         an OCI image layout directory
+//	daemon:image:tag                   an image in the local Docker daemon
+//
+// The returned cleanup func must be called once the images are no longer
+// needed.
+func resolve(ctx context.Context, src string, platforms []string) ([]target, func(), error) {
+	noop := func() {}
+	switch {
+	case strings.HasPrefix(src, dockerArchivePrefix):
+		img, err := tarball.ImageFromPath(strings.TrimPrefix(src, dockerArchivePrefix), nil)
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to read docker archive: %v", err)
+		}
+		targets, err := imageTarget(img)
+		return targets, noop, err
+
+	case strings.HasPrefix(src, ociLayoutPrefix):
+		idx, err := layout.ImageIndexFromPath(strings.TrimPrefix(src, ociLayoutPrefix))
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to read OCI layout: %v", err)
+		}
+		targets, err := layoutTargets(idx, platforms)
+		return targets, noop, err
+
+	case strings.HasPrefix(src, daemonPrefix):
+		path, err := daemonSave(ctx, strings.TrimPrefix(src, daemonPrefix))
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to save image from daemon: %v", err)
+		}
+		cleanup := func() { os.Remove(path) }
+		img, err := tarball.ImageFromPath(path, nil)
+		if err != nil {
+			cleanup()
+			return nil, noop, fmt.Errorf("failed to read image from daemon: %v", err)
+		}
+		targets, err := imageTarget(img)
+		if err != nil {
+			cleanup()
+			return nil, noop, err
+		}
+		return targets, cleanup, nil
+	}
+
+	ref, err := name.ParseReference(src)
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to parse reference: %v", err)
+	}
+	desc, err := remote.Get(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to fetch image: %v", err)
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to fetch index: %v", err)
+		}
+		targets, err := indexTargets(idx, platforms)
+		return targets, noop, err
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to fetch image: %v", err)
+	}
+	targets, err := imageTarget(img)
+	return targets, noop, err
+}
+
+// layoutTargets returns the images to check in an OCI layout. A layout whose
+// index holds a single image or index is checked as that image or index, and
+// otherwise the layout's index is checked as a multi-platform index.
+func layoutTargets(idx v1.ImageIndex, platforms []string) ([]target, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	if len(im.Manifests) != 1 {
+		return indexTargets(idx, platforms)
+	}
+	desc := im.Manifests[0]
+	switch {
+	case desc.MediaType.IsIndex():
+		child, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		return indexTargets(child, platforms)
+	case desc.MediaType.IsImage():
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		return imageTarget(img)
+	default:
+		return nil, fmt.Errorf("unsupported media type %s", desc.MediaType)
+	}
+}
+
+// daemonSave saves ref from the Docker daemon at DOCKER_HOST to a temporary
+// `docker save` tarball, returning its path.
+func daemonSave(ctx context.Context, ref string) (string, error) {
+	client, base, err := dockerClient()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/images/get?names="+url.QueryEscape(ref), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	f, err := os.CreateTemp("", "check-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// dockerClient returns an HTTP client and base URL for the Docker Engine API
+// at DOCKER_HOST, defaulting to the local unix socket.
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid DOCKER_HOST %q: %v", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", u.Path)
+			},
+		}}, "http://docker", nil
+	case "tcp", "http":
+		return http.DefaultClient, "http://" + u.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DOCKER_HOST scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestResolveLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := name.MustParseReference("example.com/repo:tag").(name.Tag)
+	archive := filepath.Join(dir, "image.tar")
+	if err := tarball.WriteToFile(archive, tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// A layout holding a single image.
+	imgLayout := filepath.Join(dir, "image-layout")
+	if _, err := layout.Write(imgLayout, mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})); err != nil {
+		t.Fatal(err)
+	}
+
+	// A layout holding a multi-platform index.
+	platIndex := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}},
+	})
+	indexLayout := filepath.Join(dir, "index-layout")
+	if _, err := layout.Write(indexLayout, mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: platIndex})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve `docker save` output for the archive from a fake daemon.
+	sock := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/get" || r.URL.Query().Get("names") != "example.com/repo:tag" {
+			http.Error(w, "no such image", http.StatusNotFound)
+			return
+		}
+		http.ServeFile(w, r, archive)
+	})}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+	t.Setenv("DOCKER_HOST", "unix://"+sock)
+
+	for _, src := range []string{
+		"docker-archive:" + archive,
+		"oci-layout:" + imgLayout,
+		"oci-layout:" + indexLayout,
+		"daemon:example.com/repo:tag",
+	} {
+		targets, cleanup, err := resolve(ctx, src, []string{"linux/arm64"})
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			cleanup()
+			continue
+		}
+		if len(targets) != 1 {
+			t.Errorf("%s: got %d targets, want 1", src, len(targets))
+		} else if got, err := targets[0].img.Digest(); err != nil || got != wantDigest {
+			t.Errorf("%s: got digest %v (%v), want %v", src, got, err, wantDigest)
+		}
+		cleanup()
+	}
+
+	for _, src := range []string{
+		"docker-archive:" + filepath.Join(dir, "missing.tar"),
+		"oci-layout:" + filepath.Join(dir, "missing"),
+		"daemon:example.com/repo:missing",
+	} {
+		if _, cleanup, err := resolve(ctx, src, []string{"linux/arm64"}); err == nil {
+			t.Errorf("%s: expected an error", src)
+			cleanup()
+		}
+	}
+
+	// The daemon's tarball is removed by cleanup.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	_, cleanup, err := resolve(ctx, "daemon:example.com/repo:tag", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Errorf("expected temp dir to be empty after cleanup, got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &AccessorFunction{}
+
+func NewRegistryFunction() function.Function {
+	return &AccessorFunction{
+		name:        "registry",
+		summary:     "Returns the registry of an OCI reference.",
+		description: "Returns the registry, e.g. `cgr.dev`, like the `registry` that `parse` returns. References without a registry are on `index.docker.io`.",
+		get: func(_ string, ref name.Reference) (string, bool) {
+			return ref.Context().RegistryStr(), true
+		},
+	}
+}
+
+func NewRepoFunction() function.Function {
+	return &AccessorFunction{
+		name:        "repo",
+		summary:     "Returns the repository of an OCI reference.",
+		description: "Returns the repository without the registry, e.g. `chainguard/static`, like the `repo` that `parse` returns.",
+		get: func(_ string, ref name.Reference) (string, bool) {
+			return ref.Context().RepositoryStr(), true
+		},
+	}
+}
+
+func NewTagFunction() function.Function {
+	return &AccessorFunction{
+		name:        "tag",
+		summary:     "Returns the tag of an OCI reference.",
+		description: "Returns the tag, like the `tag` that `parse` returns, or null if the reference only has a digest. References with neither have the tag `latest`.",
+		get:         refTag,
+	}
+}
+
+func NewDigestOfFunction() function.Function {
+	return &AccessorFunction{
+		name:        "digest_of",
+		summary:     "Returns the digest of an OCI reference.",
+		description: "Returns the digest, e.g. `sha256:...`, like the `digest` that `parse` returns, or null if the reference only has a tag. The registry isn't contacted; use `digest` to resolve a tag.",
+		get: func(_ string, ref name.Reference) (string, bool) {
+			d, ok := ref.(name.Digest)
+			return d.DigestStr(), ok
+		},
+	}
+}
+
+// AccessorFunction defines the implementation of a function that returns one
+// of the parts of an OCI reference that `parse` returns.
+type AccessorFunction struct {
+	name, summary, description string
+	// get returns the part of ref, parsed from input, or false if it
+	// doesn't have one.
+	get func(input string, ref name.Reference) (string, bool)
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *AccessorFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = s.name
+}
+
+// Definition should return the definition for the function.
+func (s *AccessorFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     s.summary,
+		Description: s.description + " Invalid references are an error.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *AccessorFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	ref, err := name.ParseReference(input)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	result := types.StringNull()
+	if v, ok := s.get(input, ref); ok {
+		result = types.StringValue(v)
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, result))
+}
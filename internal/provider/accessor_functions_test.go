@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccessorFunctions(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `
+locals {
+  digest = "cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234"
+  both   = "cgr.dev/foo/sample:v1@sha256:1234567890123456789012345678901234567890123456789012345678901234"
+  tag    = "ubuntu"
+}
+output "registry" { value = provider::oci::registry(local.digest) }
+output "repo" { value = provider::oci::repo(local.digest) }
+output "digest_tag" { value = provider::oci::tag(local.digest) }
+output "digest_digest" { value = provider::oci::digest_of(local.digest) }
+output "both_tag" { value = provider::oci::tag(local.both) }
+output "both_digest" { value = provider::oci::digest_of(local.both) }
+output "tag_registry" { value = provider::oci::registry(local.tag) }
+output "tag_repo" { value = provider::oci::repo(local.tag) }
+output "tag_tag" { value = provider::oci::tag(local.tag) }
+output "tag_digest" { value = provider::oci::digest_of(local.tag) }
+`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("registry", knownvalue.StringExact("cgr.dev")),
+				statecheck.ExpectKnownOutputValue("repo", knownvalue.StringExact("foo/sample")),
+				statecheck.ExpectKnownOutputValue("digest_tag", knownvalue.Null()),
+				statecheck.ExpectKnownOutputValue("digest_digest", knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234")),
+				statecheck.ExpectKnownOutputValue("both_tag", knownvalue.StringExact("v1")),
+				statecheck.ExpectKnownOutputValue("both_digest", knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234")),
+				statecheck.ExpectKnownOutputValue("tag_registry", knownvalue.StringExact("index.docker.io")),
+				statecheck.ExpectKnownOutputValue("tag_repo", knownvalue.StringExact("library/ubuntu")),
+				statecheck.ExpectKnownOutputValue("tag_tag", knownvalue.StringExact("latest")),
+				statecheck.ExpectKnownOutputValue("tag_digest", knownvalue.Null()),
+			},
+		}},
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config:      `output "repo" { value = provider::oci::repo("") }`,
+			ExpectError: regexp.MustCompile("Failed to parse OCI reference"),
+		}},
+	})
+}
+
+func TestRefTag(t *testing.T) {
+	for input, want := range map[string]string{
+		"ubuntu":                "latest",
+		"cgr.dev/foo/sample:v1": "v1",
+		"localhost:5000/foo:v1": "v1",
+		"cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234":    "",
+		"cgr.dev/foo/sample:v1@sha256:1234567890123456789012345678901234567890123456789012345678901234": "v1",
+		"localhost:5000/foo@sha256:1234567890123456789012345678901234567890123456789012345678901234":    "",
+		"localhost:5000/foo:v2@sha256:1234567890123456789012345678901234567890123456789012345678901234": "v2",
+	} {
+		ref, err := name.ParseReference(input)
+		if err != nil {
+			t.Fatalf("ParseReference(%q): %v", input, err)
+		}
+		got, ok := refTag(input, ref)
+		if got != want || ok != (want != "") {
+			t.Errorf("refTag(%q) = %q, %t; want %q", input, got, ok, want)
+		}
+	}
+}
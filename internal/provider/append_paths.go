@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// layerPathErrors returns an error for each of the paths of the files in a
+// layer that isn't clean, or that conflicts with another: two paths that name
+// the same file, or a file that is also the parent directory of another.
+// These would otherwise make a layer that extracts differently, or not at
+// all, depending on the runtime.
+func layerPathErrors(paths []string) []error {
+	var errs []error
+	// Files by their cleaned, absolute path.
+	files := make(map[string]string, len(paths))
+	for _, p := range slices.Sorted(slices.Values(paths)) {
+		abs := "/" + strings.TrimPrefix(p, "/")
+		switch clean := path.Clean(abs); {
+		case clean == "/":
+			errs = append(errs, fmt.Errorf("path %q must name a file", p))
+			continue
+		case slices.Contains(strings.Split(p, "/"), ".."):
+			errs = append(errs, fmt.Errorf("path %q must not contain %q", p, ".."))
+			continue
+		case clean != abs:
+			errs = append(errs, fmt.Errorf("path %q is not clean, use %q", p, clean))
+			continue
+		case files[clean] != "":
+			errs = append(errs, fmt.Errorf("paths %q and %q are the same file", files[clean], p))
+			continue
+		}
+		files[abs] = p
+	}
+
+	for _, abs := range slices.Sorted(maps.Keys(files)) {
+		for dir := path.Dir(abs); dir != "/"; dir = path.Dir(dir) {
+			if p, ok := files[dir]; ok {
+				errs = append(errs, fmt.Errorf("path %q is a file, so it can't also be the parent directory of %q", p, files[abs]))
+			}
+		}
+	}
+	return errs
+}
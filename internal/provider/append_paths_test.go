@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayerPathErrors(t *testing.T) {
+	for _, c := range []struct {
+		desc  string
+		paths []string
+		want  []string
+	}{{
+		desc:  "valid",
+		paths: []string{"/usr/bin/foo", "/usr/bin/bar", "etc/passwd", "/usr/lib/foo"},
+	}, {
+		desc:  "root",
+		paths: []string{"/", ""},
+		want:  []string{`path "" must name a file`, `path "/" must name a file`},
+	}, {
+		desc:  "dot dot",
+		paths: []string{"/usr/../etc/passwd"},
+		want:  []string{`must not contain ".."`},
+	}, {
+		desc:  "not clean",
+		paths: []string{"/usr//bin/foo", "/usr/./bin/bar", "/etc/"},
+		want:  []string{`use "/etc"`, `use "/usr/bin/bar"`, `use "/usr/bin/foo"`},
+	}, {
+		desc:  "duplicate",
+		paths: []string{"/etc/passwd", "etc/passwd"},
+		want:  []string{`paths "/etc/passwd" and "etc/passwd" are the same file`},
+	}, {
+		desc:  "file and directory",
+		paths: []string{"/usr/bin", "/usr/bin/foo", "/usr/bin/foo/bar"},
+		want: []string{
+			`path "/usr/bin" is a file, so it can't also be the parent directory of "/usr/bin/foo"`,
+			`path "/usr/bin/foo" is a file, so it can't also be the parent directory of "/usr/bin/foo/bar"`,
+			`path "/usr/bin" is a file, so it can't also be the parent directory of "/usr/bin/foo/bar"`,
+		},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			errs := layerPathErrors(c.paths)
+			if len(errs) != len(c.want) {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), len(c.want), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), c.want[i]) {
+					t.Errorf("error %d: got %q, want it to contain %q", i, err, c.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// inTotoMediaType is the media type of in-toto statements, and the
+	// artifact type of the provenance attached to appended images.
+	inTotoMediaType = "application/vnd.in-toto+json"
+	// predicateTypeAnnotation records the predicate type of an attached
+	// statement, so that clients can pick it out of the referrers.
+	predicateTypeAnnotation = "in-toto.io/predicate-type"
+
+	slsaProvenanceV1 = "https://slsa.dev/provenance/v1"
+	// appendBuildType is the SLSA build type of images built by oci_append.
+	appendBuildType = "https://github.com/chainguard-dev/terraform-provider-oci/oci_append@v1"
+)
+
+// appendProvenance records what an appended image is built from, as
+// appendLayers builds it, for its SLSA provenance. Its methods do nothing on a
+// nil *appendProvenance, so that callers needn't check whether provenance is
+// wanted.
+type appendProvenance struct {
+	builderID string
+
+	baseImage string
+	base      *slsaResource
+	layers    []provenanceLayer
+	config    *appendConfig
+	// downloads are the files downloaded from URLs, and the layers copied
+	// from other images.
+	downloads []slsaResource
+}
+
+// provenanceLayer records an appended layer, as the files added to it, keyed
+// by path, or the layer of another image that it copies.
+type provenanceLayer struct {
+	Files     map[string]provenanceFile `json:"files,omitempty"`
+	FromImage *provenanceFromImage      `json:"from_image,omitempty"`
+}
+
+// provenanceFile is the SHA-256 digest of an added file, or the file that it
+// is a hardlink to.
+type provenanceFile struct {
+	SHA256   string `json:"sha256,omitempty"`
+	Hardlink string `json:"hardlink,omitempty"`
+}
+
+type provenanceFromImage struct {
+	Ref         string `json:"ref"`
+	LayerDigest string `json:"layer_digest"`
+}
+
+// slsaResource is a SLSA ResourceDescriptor.
+type slsaResource struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+func ociResource(repo name.Repository, h v1.Hash) slsaResource {
+	return slsaResource{URI: "oci://" + repo.Name(), Digest: map[string]string{h.Algorithm: h.Hex}}
+}
+
+// setBase records the base image, as configured, and what it resolved to.
+func (p *appendProvenance) setBase(base string, ref name.Reference, h v1.Hash) {
+	if p == nil {
+		return
+	}
+	r := ociResource(ref.Context(), h)
+	p.baseImage, p.base = base, &r
+}
+
+// setLayers records the number of appended layers, before they're built.
+func (p *appendProvenance) setLayers(n int) {
+	if p == nil {
+		return
+	}
+	p.layers = make([]provenanceLayer, n)
+}
+
+// addFile records a file added to layer i.
+func (p *appendProvenance) addFile(i int, path string, f provenanceFile) {
+	if p == nil {
+		return
+	}
+	if p.layers[i].Files == nil {
+		p.layers[i].Files = map[string]provenanceFile{}
+	}
+	p.layers[i].Files[path] = f
+}
+
+// addDownload records a file in layer i downloaded from url.
+func (p *appendProvenance) addDownload(i int, path, url, sha256 string) {
+	if p == nil {
+		return
+	}
+	p.addFile(i, path, provenanceFile{SHA256: sha256})
+	p.downloads = append(p.downloads, slsaResource{URI: url, Digest: map[string]string{"sha256": sha256}})
+}
+
+// addFromImage records that layer i is the layer h of the image src.
+func (p *appendProvenance) addFromImage(i int, src string, h v1.Hash) {
+	if p == nil {
+		return
+	}
+	p.layers[i].FromImage = &provenanceFromImage{Ref: src, LayerDigest: h.String()}
+	// Whether the registry is insecure doesn't change the repository's name.
+	if ref, err := name.ParseReference(src); err == nil {
+		p.downloads = append(p.downloads, ociResource(ref.Context(), h))
+	}
+}
+
+// statement returns the in-toto statement of the SLSA provenance of the image
+// d. It is the same each time the same image is built, so that attaching it
+// again doesn't add another referrer.
+func (p *appendProvenance) statement(d name.Digest) ([]byte, error) {
+	if p.base == nil {
+		return nil, fmt.Errorf("base image of %s wasn't recorded", d)
+	}
+	deps := []slsaResource{*p.base}
+	downloads := slices.Clone(p.downloads)
+	slices.SortFunc(downloads, func(a, b slsaResource) int {
+		return cmp.Or(strings.Compare(a.URI, b.URI), strings.Compare(a.Digest["sha256"], b.Digest["sha256"]))
+	})
+	deps = append(deps, slices.CompactFunc(downloads, func(a, b slsaResource) bool {
+		return a.URI == b.URI && a.Digest["sha256"] == b.Digest["sha256"]
+	})...)
+
+	alg, hex, _ := strings.Cut(d.DigestStr(), ":")
+	return json.Marshal(map[string]any{
+		"_type": "https://in-toto.io/Statement/v1",
+		"subject": []map[string]any{{
+			"name":   d.Context().Name(),
+			"digest": map[string]string{alg: hex},
+		}},
+		"predicateType": slsaProvenanceV1,
+		"predicate": map[string]any{
+			"buildDefinition": map[string]any{
+				"buildType": appendBuildType,
+				"externalParameters": map[string]any{
+					"base_image": p.baseImage,
+					"layers":     p.layers,
+					"config":     p.config,
+				},
+				"resolvedDependencies": deps,
+			},
+			"runDetails": map[string]any{
+				"builder": map[string]string{"id": p.builderID},
+			},
+		},
+	})
+}
+
+// attachProvenance pushes the provenance of d, recorded in prov as d was
+// built, as an OCI referrer of d, and returns the referrer's digest.
+// Registries without the referrers API list it in the fallback tag instead.
+func (p *ProviderOpts) attachProvenance(ctx context.Context, d name.Digest, prov *appendProvenance) (name.Digest, error) {
+	statement, err := prov.statement(d)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	subject, err := remote.Head(d, p.withContext(ctx)...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("fetching %s: %w", d, err)
+	}
+
+	img := mutate.ConfigMediaType(mutate.MediaType(empty.Image, ggcrtypes.OCIManifestSchema1), inTotoMediaType)
+	img, err = mutate.Append(img, mutate.Addendum{Layer: static.NewLayer(statement, inTotoMediaType)})
+	if err != nil {
+		return name.Digest{}, err
+	}
+	img, _ = mutate.Annotations(img, map[string]string{predicateTypeAnnotation: slsaProvenanceV1}).(v1.Image)
+	img, _ = mutate.Subject(img, v1.Descriptor{MediaType: subject.MediaType, Size: subject.Size, Digest: subject.Digest}).(v1.Image)
+
+	dig, err := img.Digest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	att := d.Context().Digest(dig.String())
+	if err := retryTransient(ctx, func(ctx context.Context) error {
+		return remote.Write(att, img, p.withContext(ctx)...)
+	}); err != nil {
+		return name.Digest{}, fmt.Errorf("pushing provenance: %w", err)
+	}
+	return att, nil
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestAttachProvenance(t *testing.T) {
+	for _, c := range []struct {
+		desc string
+		opts []ocitesting.RegistryOption
+	}{
+		{"referrers API", []ocitesting.RegistryOption{ocitesting.WithReferrers()}},
+		{"referrers tag", nil},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			repo, cleanup := ocitesting.SetupRepository(t, "test", c.opts...)
+			defer cleanup()
+
+			base := repo.Tag("base")
+			img, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatalf("failed to create image: %v", err)
+			}
+			if err := remote.Write(base, img); err != nil {
+				t.Fatalf("failed to write image: %v", err)
+			}
+			baseDigest, err := img.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			baseLayers, err := img.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			baseLayer, err := baseLayers[0].Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user := "nonroot"
+			ls := []appendLayer{{Files: map[string]appendFile{
+				"/a.txt": {Contents: types.StringValue("a")},
+				"/b.txt": {Hardlink: types.StringValue("/a.txt")},
+			}}, {FromImage: &appendFromImage{Ref: types.StringValue(base.String()), LayerIndex: types.Int64Value(0), LayerDigest: types.StringNull()}}}
+			p := &ProviderOpts{}
+			prov := &appendProvenance{builderID: "https://example.com/builder", config: &appendConfig{User: &user}}
+			opts := appendOptions{compressionLevel: 6, config: prov.config, provenance: prov}
+			d, _, diags := p.appendLayers(context.Background(), base.String(), false, ls, opts)
+			if diags.HasError() {
+				t.Fatalf("appendLayers: %v", diags)
+			}
+
+			att, err := p.attachProvenance(context.Background(), *d, prov)
+			if err != nil {
+				t.Fatalf("attachProvenance: %v", err)
+			}
+			// Attaching the same provenance again doesn't add another referrer.
+			if again, err := p.attachProvenance(context.Background(), *d, prov); err != nil || again != att {
+				t.Errorf("attachProvenance again = %s, %v; want %s", again, err, att)
+			}
+
+			idx, err := remote.Referrers(*d)
+			if err != nil {
+				t.Fatalf("Referrers: %v", err)
+			}
+			im, err := idx.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(im.Manifests) != 1 {
+				t.Fatalf("got %d referrers, want 1", len(im.Manifests))
+			}
+			if got := im.Manifests[0]; got.Digest.String() != att.DigestStr() || got.ArtifactType != inTotoMediaType {
+				t.Errorf("got referrer %+v, want %s of type %s", got, att, inTotoMediaType)
+			}
+
+			attImg, err := remote.Image(att)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := attImg.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Annotations[predicateTypeAnnotation]; got != slsaProvenanceV1 {
+				t.Errorf("got predicate type annotation %q, want %q", got, slsaProvenanceV1)
+			}
+			layers, err := attImg.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := layers[0].Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var statement struct {
+				Subject []struct {
+					Name   string            `json:"name"`
+					Digest map[string]string `json:"digest"`
+				} `json:"subject"`
+				PredicateType string `json:"predicateType"`
+				Predicate     struct {
+					BuildDefinition struct {
+						BuildType          string `json:"buildType"`
+						ExternalParameters struct {
+							BaseImage string            `json:"base_image"`
+							Layers    []provenanceLayer `json:"layers"`
+							Config    appendConfig      `json:"config"`
+						} `json:"externalParameters"`
+						ResolvedDependencies []slsaResource `json:"resolvedDependencies"`
+					} `json:"buildDefinition"`
+					RunDetails struct {
+						Builder struct {
+							ID string `json:"id"`
+						} `json:"builder"`
+					} `json:"runDetails"`
+				} `json:"predicate"`
+			}
+			if err := json.Unmarshal(b, &statement); err != nil {
+				t.Fatalf("failed to parse statement: %v", err)
+			}
+			h, _ := v1.NewHash(d.DigestStr())
+			if len(statement.Subject) != 1 || statement.Subject[0].Name != repo.Name() || statement.Subject[0].Digest["sha256"] != h.Hex {
+				t.Errorf("got subject %+v, want %s", statement.Subject, d)
+			}
+			if statement.PredicateType != slsaProvenanceV1 {
+				t.Errorf("got predicate type %q", statement.PredicateType)
+			}
+			bd := statement.Predicate.BuildDefinition
+			if bd.BuildType != appendBuildType {
+				t.Errorf("got build type %q", bd.BuildType)
+			}
+			if got := statement.Predicate.RunDetails.Builder.ID; got != prov.builderID {
+				t.Errorf("got builder %q, want %q", got, prov.builderID)
+			}
+			params := bd.ExternalParameters
+			if params.BaseImage != base.String() {
+				t.Errorf("got base image %q, want %q", params.BaseImage, base)
+			}
+			if params.Config.User == nil || *params.Config.User != user {
+				t.Errorf("got config %+v, want user %s", params.Config, user)
+			}
+			sum := sha256.Sum256([]byte("a"))
+			if len(params.Layers) != 2 {
+				t.Fatalf("got %d layers, want 2", len(params.Layers))
+			}
+			files := params.Layers[0].Files
+			if got := files["/a.txt"].SHA256; got != hex.EncodeToString(sum[:]) {
+				t.Errorf("got /a.txt sha256 %q, want %x", got, sum)
+			}
+			if got := files["/b.txt"].Hardlink; got != "/a.txt" {
+				t.Errorf("got /b.txt hardlink %q, want /a.txt", got)
+			}
+			if got := params.Layers[1].FromImage; got == nil || got.LayerDigest != baseLayer.String() {
+				t.Errorf("got from_image %+v, want layer %s", got, baseLayer)
+			}
+			want := []slsaResource{
+				{URI: "oci://" + repo.Name(), Digest: map[string]string{"sha256": baseDigest.Hex}},
+				{URI: "oci://" + repo.Name(), Digest: map[string]string{"sha256": baseLayer.Hex}},
+			}
+			if len(bd.ResolvedDependencies) != len(want) {
+				t.Fatalf("got dependencies %+v, want %+v", bd.ResolvedDependencies, want)
+			}
+			for i, dep := range bd.ResolvedDependencies {
+				if dep.URI != want[i].URI || dep.Digest["sha256"] != want[i].Digest["sha256"] {
+					t.Errorf("got dependency %d %+v, want %+v", i, dep, want[i])
+				}
+			}
+		})
+	}
+}
@@ -5,11 +5,24 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/containerd/stargz-snapshotter/estargz"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
@@ -17,23 +30,34 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var (
-	_ resource.Resource                = &AppendResource{}
-	_ resource.ResourceWithImportState = &AppendResource{}
+	_ resource.Resource                   = &AppendResource{}
+	_ resource.ResourceWithImportState    = &AppendResource{}
+	_ resource.ResourceWithValidateConfig = &AppendResource{}
+	_ resource.ResourceWithModifyPlan     = &AppendResource{}
 )
 
+// defaultAppendBase is the base image used when base_image isn't set.
+const defaultAppendBase = "cgr.dev/chainguard/static:latest"
+
 func NewAppendResource() resource.Resource {
 	return &AppendResource{}
 }
@@ -48,8 +72,22 @@ type AppendResourceModel struct {
 	Id       types.String `tfsdk:"id"`
 	ImageRef types.String `tfsdk:"image_ref"`
 
-	BaseImage types.String `tfsdk:"base_image"`
-	Layers    types.List   `tfsdk:"layers"`
+	BaseImage              types.String `tfsdk:"base_image"`
+	BaseImageDigest        types.String `tfsdk:"base_image_digest"`
+	CurrentBaseImageDigest types.String `tfsdk:"current_base_image_digest"`
+	ResolveBaseToDigest    types.Bool   `tfsdk:"resolve_base_to_digest"`
+	ReplaceOnBaseChange    types.Bool   `tfsdk:"replace_on_base_change"`
+	AllowUnpinnedBase      types.Bool   `tfsdk:"allow_unpinned_base"`
+	VerifyBase             types.Object `tfsdk:"verify_base"`
+	Layers                 types.List   `tfsdk:"layers"`
+	LayerDigests           types.List   `tfsdk:"layer_digests"`
+	Config                 types.Object `tfsdk:"config"`
+	CompressionLevel       types.Int64  `tfsdk:"compression_level"`
+	MediaTypes             types.String `tfsdk:"media_types"`
+	Estargz                types.Bool   `tfsdk:"estargz"`
+	Provenance             types.Object `tfsdk:"provenance"`
+	ProvenanceRef          types.String `tfsdk:"provenance_ref"`
+	Insecure               types.Bool   `tfsdk:"insecure"`
 }
 
 func (r *AppendResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -64,23 +102,84 @@ func (r *AppendResource) Schema(ctx context.Context, req resource.SchemaRequest,
 				MarkdownDescription: "Base image to append layers to.",
 				Optional:            true,
 				Computed:            true,
-				Default:             stringdefault.StaticString("cgr.dev/chainguard/static:latest"),
+				Default:             stringdefault.StaticString(defaultAppendBase),
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"resolve_base_to_digest": schema.BoolAttribute{
+				MarkdownDescription: "If true, resolve `base_image` to a digest when the image is created and record it in `base_image_digest`, so that the image is rebuilt from the same base until it is replaced.",
+				Optional:            true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"replace_on_base_change": schema.BoolAttribute{
+				MarkdownDescription: "If true, replace the image when `base_image` no longer resolves to `base_image_digest`, so that it is rebuilt on the new base. Otherwise a warning is shown in the plan. Requires `resolve_base_to_digest`.",
+				Optional:            true,
+			},
+			"allow_unpinned_base": schema.BoolAttribute{
+				MarkdownDescription: "If true, don't warn when `base_image` is a tag rather than a digest.",
+				Optional:            true,
+			},
+			"verify_base": schema.SingleNestedAttribute{
+				MarkdownDescription: "Verify the [cosign](https://github.com/sigstore/cosign) signatures of the base image before appending to it, and fail if none of them is valid. " +
+					"Signatures are verified with `key`, or keyless with a certificate issued by `ca_cert` to `identity` by `issuer`. " +
+					"Keyless signatures must have a transparency log bundle signed by `rekor_public_key`, and their certificates are checked at the time the log recorded. " +
+					"The base is verified when the image is created or updated, and when it's refreshed only if the base resolves to a different digest than was verified.",
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"key": schema.StringAttribute{
+						MarkdownDescription: "PEM-encoded public key that the base image must be signed with. Exactly one of `key` and `ca_cert` must be set.",
+						Optional:            true,
+					},
+					"ca_cert": schema.StringAttribute{
+						MarkdownDescription: "PEM-encoded root certificates, e.g. Fulcio's, that keyless signing certificates must be issued by.",
+						Optional:            true,
+					},
+					"identity": schema.StringAttribute{
+						MarkdownDescription: "Email or URI that keyless signing certificates must be issued to. Required with `ca_cert`.",
+						Optional:            true,
+					},
+					"issuer": schema.StringAttribute{
+						MarkdownDescription: "OIDC issuer that keyless signing certificates must be issued by, e.g. `https://token.actions.githubusercontent.com`. Required with `ca_cert`.",
+						Optional:            true,
+					},
+					"rekor_public_key": schema.StringAttribute{
+						MarkdownDescription: "PEM-encoded public key of the Rekor transparency log that keyless signatures must be logged in. Defaults to the public-good instance at `rekor.sigstore.dev`.",
+						Optional:            true,
+					},
+					"insecure_ignore_tlog": schema.BoolAttribute{
+						MarkdownDescription: "If true, trust the time in keyless signatures' transparency log bundles without verifying that the log signed them. Anyone who can sign with an expired certificate can then forge it.",
+						Optional:            true,
+					},
+				},
+			},
+			"base_image_digest": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The base image by digest, if `resolve_base_to_digest` is set.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"current_base_image_digest": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The digest `base_image` resolved to when the resource was last read, if `resolve_base_to_digest` is set. This differs from `base_image_digest` if the base has changed since the image was created.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
 			"layers": schema.ListNestedAttribute{
-				MarkdownDescription: "Layers to append to the base image.",
-				Optional:            false,
-				Required:            true,
+				MarkdownDescription: "Layers to append to the base image. May be empty if `config` is set, to only change the config.",
+				Optional:            true,
 				PlanModifiers: []planmodifier.List{
 					listplanmodifier.RequiresReplace(),
 				},
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
 						"files": schema.MapNestedAttribute{
-							MarkdownDescription: "Files to add to the layer.",
-							Required:            true,
+							MarkdownDescription: "Files to add to the layer, keyed by clean path. A path can't name the same file as another, or be the parent directory of another. Exactly one of `files` and `from_image` must be set.",
+							Optional:            true,
 							NestedObject: schema.NestedAttributeObject{
 								Attributes: map[string]schema.Attribute{
 									"contents": schema.StringAttribute{
@@ -91,18 +190,166 @@ func (r *AppendResource) Schema(ctx context.Context, req resource.SchemaRequest,
 										MarkdownDescription: "Path to a file.",
 										Optional:            true,
 									},
+									"hardlink": schema.StringAttribute{
+										MarkdownDescription: "Path of another file in the same layer to hardlink this file to, e.g. for multi-call binaries like busybox.",
+										Optional:            true,
+									},
+									"url": schema.StringAttribute{
+										MarkdownDescription: "Absolute `http` or `https` URL to download the file from when the image is built. Downloaded files are executable (mode 0755), and may be at most 1 GiB. They are fetched with the provider's `tls` and proxy settings, and kept in its `blob_cache`, if any, so that refreshes don't download them again. Requires `sha256`.",
+										Optional:            true,
+										Validators:          []validator.String{validators.URLValidator{Schemes: []string{"http", "https"}}},
+									},
+									"sha256": schema.StringAttribute{
+										MarkdownDescription: "Hex-encoded SHA-256 digest that the file downloaded from `url` must have.",
+										Optional:            true,
+									},
+									"xattrs": schema.MapAttribute{
+										MarkdownDescription: "Extended attributes to set on the file, keyed by name (e.g. `security.capability`), with base64-encoded values. They are written as PAX records.",
+										ElementType:         basetypes.StringType{},
+										Optional:            true,
+									},
+									"headers": schema.MapAttribute{
+										MarkdownDescription: "HTTP headers to send when downloading from `url`, e.g. `Authorization`.",
+										ElementType:         basetypes.StringType{},
+										Optional:            true,
+										Sensitive:           true,
+									},
 									// TODO: Add support for file mode.
 									// TODO: Add support for symlinks.
 									// TODO: Add support for deletion / whiteouts.
 								},
 							},
 						},
+						"from_image": schema.SingleNestedAttribute{
+							MarkdownDescription: "Copy a layer of another image, rather than building one from `files`. The layer is mounted rather than uploaded again if the image is in the same registry. If `ref` is an index, the layer is taken from its linux/amd64 image.",
+							Optional:            true,
+							Attributes: map[string]schema.Attribute{
+								"ref": schema.StringAttribute{
+									MarkdownDescription: "Image to copy the layer from.",
+									Required:            true,
+								},
+								"layer_index": schema.Int64Attribute{
+									MarkdownDescription: "Index of the layer to copy, starting from 0 for the base layer. Exactly one of `layer_index` and `layer_digest` must be set.",
+									Optional:            true,
+									Validators:          []validator.Int64{positiveIntValidator{}},
+								},
+								"layer_digest": schema.StringAttribute{
+									MarkdownDescription: "Digest of the (compressed) layer to copy.",
+									Optional:            true,
+								},
+							},
+						},
+						"history": schema.SingleNestedAttribute{
+							MarkdownDescription: "History entry to record for the layer in the image config.",
+							Optional:            true,
+							Attributes: map[string]schema.Attribute{
+								"created_by": schema.StringAttribute{
+									MarkdownDescription: "Command that created the layer (default is `terraform-provider-oci: oci_append`).",
+									Optional:            true,
+								},
+								"comment": schema.StringAttribute{
+									MarkdownDescription: "Comment describing the layer.",
+									Optional:            true,
+								},
+								"author": schema.StringAttribute{
+									MarkdownDescription: "Author of the layer.",
+									Optional:            true,
+								},
+							},
+						},
+					},
+				},
+			},
+			"compression_level": schema.Int64Attribute{
+				MarkdownDescription: "Gzip compression level of the appended layers, from 0 (no compression) to 9 (best compression). Defaults to gzip's default level.",
+				Optional:            true,
+				Validators:          []validator.Int64{intRangeValidator{min: 0, max: 9}},
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"config": schema.SingleNestedAttribute{
+				MarkdownDescription: "Config to set on the resulting image, or each image in the resulting index. Unset fields are kept from the base.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"env": schema.ListAttribute{
+						MarkdownDescription: "Environment variables to set, as `KEY=VALUE`. Each replaces the variable of the same name in the base, if there is one.",
+						Optional:            true,
+						ElementType:         types.StringType,
+					},
+					"user": schema.StringAttribute{
+						MarkdownDescription: "User to run as.",
+						Optional:            true,
+					},
+					"working_dir": schema.StringAttribute{
+						MarkdownDescription: "Working directory.",
+						Optional:            true,
+					},
+					"entrypoint": schema.ListAttribute{
+						MarkdownDescription: "Entrypoint.",
+						Optional:            true,
+						ElementType:         types.StringType,
+					},
+					"cmd": schema.ListAttribute{
+						MarkdownDescription: "Default arguments to the entrypoint.",
+						Optional:            true,
+						ElementType:         types.StringType,
+					},
+				},
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.RequiresReplace(),
+				},
+			},
+			"estargz": schema.BoolAttribute{
+				MarkdownDescription: "If true, build the appended layers as [eStargz](https://github.com/containerd/stargz-snapshotter/blob/main/docs/estargz.md), with their table of contents digest and uncompressed size in the layer annotations, so that runtimes that support it can pull them lazily. eStargz layers can still be pulled as ordinary gzip layers.",
+				Optional:            true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"media_types": schema.StringAttribute{
+				MarkdownDescription: "Media types of the resulting manifests, configs and layers: `oci` or `docker`. The base image is converted if it uses the other kind. Defaults to the kind of the base image.",
+				Optional:            true,
+				Validators:          []validator.String{oneOfValidator{values: []string{"oci", "docker"}}},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"provenance": schema.SingleNestedAttribute{
+				MarkdownDescription: "Generate [SLSA v1 provenance](https://slsa.dev/spec/v1.0/provenance) for the image, recording its base image, the SHA-256 digests of the added files, the copied layers and the config, and attach it to the image as an in-toto statement in an [OCI referrer](https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers). The provenance isn't signed.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"builder_id": schema.StringAttribute{
+						MarkdownDescription: "URI identifying what built the image, e.g. the CI workflow that runs Terraform, recorded as the provenance's `runDetails.builder.id`.",
+						Required:            true,
 					},
 				},
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.RequiresReplace(),
+				},
+			},
+			"provenance_ref": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The attached provenance by digest, if `provenance` is set.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
 			},
 			"image_ref": schema.StringAttribute{
 				Computed:            true,
-				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}@sha256:deadbeef).",
+				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}@sha256:deadbeef). It is known at plan time if `layer_digests` is and `base_image` is pinned by digest.",
+			},
+			"layer_digests": schema.ListAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "The digests of the appended layers, in order. They are known at plan time if every file in every layer is known and set by `contents` or `hardlink`.",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"id": schema.StringAttribute{
 				Computed:            true,
@@ -115,6 +362,174 @@ func (r *AppendResource) Schema(ctx context.Context, req resource.SchemaRequest,
 	}
 }
 
+func (r *AppendResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data *AppendResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if data.ReplaceOnBaseChange.ValueBool() && !data.ResolveBaseToDigest.IsUnknown() && !data.ResolveBaseToDigest.ValueBool() {
+		resp.Diagnostics.AddAttributeError(path.Root("replace_on_base_change"), "Invalid replace_on_base_change", "replace_on_base_change requires resolve_base_to_digest to be set")
+	}
+	if !data.VerifyBase.IsNull() && !data.VerifyBase.IsUnknown() {
+		var vb appendVerifyBase
+		resp.Diagnostics.Append(data.VerifyBase.As(ctx, &vb, basetypes.ObjectAsOptions{UnhandledUnknownAsEmpty: true})...)
+		if err := vb.validate(); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("verify_base"), "Invalid verify_base", err.Error())
+		}
+	}
+	if len(data.Layers.Elements()) == 0 && !data.Layers.IsUnknown() && data.Config.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("layers"), "Missing layers", "At least one layer is required, unless config is set")
+	}
+	if !data.Layers.IsUnknown() {
+		for i, l := range data.Layers.Elements() {
+			obj, ok := l.(types.Object)
+			if !ok || obj.IsUnknown() {
+				continue
+			}
+			files, ok := obj.Attributes()["files"].(types.Map)
+			if !ok || files.IsUnknown() {
+				continue
+			}
+			for _, err := range layerPathErrors(slices.Collect(maps.Keys(files.Elements()))) {
+				resp.Diagnostics.AddAttributeError(path.Root("layers").AtListIndex(i).AtName("files"), "Invalid file path", fmt.Sprintf("Invalid file path in layer %d: %s", i, err))
+			}
+		}
+	}
+	if data.BaseImage.IsUnknown() || data.AllowUnpinnedBase.ValueBool() || data.ResolveBaseToDigest.ValueBool() {
+		return
+	}
+
+	base := defaultAppendBase
+	if !data.BaseImage.IsNull() {
+		base = data.BaseImage.ValueString()
+	}
+	ref, err := name.ParseReference(base)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("base_image"), "Invalid base image", fmt.Sprintf("Unable to parse base image %q, got error: %s", base, err))
+		return
+	}
+	if _, ok := ref.(name.Digest); !ok {
+		resp.Diagnostics.AddAttributeWarning(path.Root("base_image"), "Base image is not pinned",
+			fmt.Sprintf("Base image %q is a tag, so the appended image changes whenever the tag moves. "+
+				"Pin it by digest, set resolve_base_to_digest to record the digest it resolves to, or set allow_unpinned_base to silence this warning.", base))
+	}
+}
+
+// ModifyPlan replaces the image, or warns, if its base has moved since it was
+// created, as found by Read. It then previews the digests of the result, if
+// they can be known before it is built.
+func (r *AppendResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	var plan *AppendResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !req.State.Raw.IsNull() {
+		var state *AppendResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		r.planBaseChange(state, plan, resp)
+	}
+	r.previewDigests(ctx, plan)
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// planBaseChange replaces the image, or warns, if its base has moved.
+func (r *AppendResource) planBaseChange(state, plan *AppendResourceModel, resp *resource.ModifyPlanResponse) {
+	if !plan.ResolveBaseToDigest.ValueBool() || state.CurrentBaseImageDigest.ValueString() == "" ||
+		state.CurrentBaseImageDigest.Equal(state.BaseImageDigest) {
+		return
+	}
+
+	if !plan.ReplaceOnBaseChange.ValueBool() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("base_image"), "Base image has changed",
+			fmt.Sprintf("Base image %q was %s when the image was created, and is now %s. "+
+				"Set replace_on_base_change to rebuild the image on the new base.",
+				state.BaseImage.ValueString(), state.BaseImageDigest.ValueString(), state.CurrentBaseImageDigest.ValueString()))
+		return
+	}
+
+	// Rebuild on the new base, which is resolved again by Create.
+	plan.BaseImageDigest = types.StringUnknown()
+	plan.CurrentBaseImageDigest = types.StringUnknown()
+	plan.Id = types.StringUnknown()
+	plan.ImageRef = types.StringUnknown()
+	resp.RequiresReplace = append(resp.RequiresReplace, path.Root("base_image_digest"))
+}
+
+// previewDigests sets the unknown layer_digests of plan, and its image_ref if
+// the base is pinned by digest, by building the image without pushing it. This
+// is only done if every file is known and set by contents or hardlink, since
+// local files and downloads may change before the image is built. Anything
+// that can't be previewed is left unknown.
+func (r *AppendResource) previewDigests(ctx context.Context, plan *AppendResourceModel) {
+	if !plan.LayerDigests.IsUnknown() && !plan.ImageRef.IsUnknown() {
+		return
+	}
+	if plan.BaseImage.IsUnknown() || plan.Insecure.IsUnknown() || plan.CompressionLevel.IsUnknown() ||
+		plan.MediaTypes.IsUnknown() || plan.Estargz.IsUnknown() {
+		return
+	}
+	for _, a := range []attr.Value{plan.Layers, plan.Config} {
+		if v, err := a.ToTerraformValue(ctx); err != nil || !v.IsFullyKnown() {
+			return
+		}
+	}
+	var ls []appendLayer
+	if diags := plan.Layers.ElementsAs(ctx, &ls, false); diags.HasError() {
+		return
+	}
+	for _, l := range ls {
+		if l.FromImage != nil || len(l.Files) == 0 {
+			return
+		}
+		for _, f := range l.Files {
+			if f.Path.ValueString() != "" || f.URL.ValueString() != "" {
+				return
+			}
+		}
+	}
+	opts, diags := plan.appendOptions(ctx)
+	if diags.HasError() {
+		return
+	}
+	opts.dryRun = true
+
+	base := plan.BaseImage.ValueString()
+	if _, err := name.NewDigest(base, r.popts.nameOptions(base, plan.Insecure.ValueBool())...); err != nil {
+		// Only the layers can be previewed.
+		adds, diags := r.popts.buildLayers(ctx, ls, plan.Insecure.ValueBool(), ggcrtypes.OCILayer, opts)
+		if diags.HasError() {
+			return
+		}
+		hs := make([]v1.Hash, 0, len(adds))
+		for _, add := range adds {
+			h, err := add.Layer.Digest()
+			if err != nil {
+				return
+			}
+			hs = append(hs, h)
+		}
+		plan.LayerDigests = layerDigestsValue(hs)
+		return
+	}
+
+	d, hs, diags := r.popts.appendLayers(ctx, base, plan.Insecure.ValueBool(), ls, opts)
+	if diags.HasError() {
+		tflog.Debug(ctx, "unable to preview image digest", map[string]interface{}{"base": base, "error": diags.Errors()[0].Detail()})
+		return
+	}
+	plan.LayerDigests = layerDigestsValue(hs)
+	plan.Id = types.StringValue(d.String())
+	plan.ImageRef = types.StringValue(d.String())
+}
+
 func (r *AppendResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
 	// Prevent panic if the provider has not been configured.
 	if req.ProviderData == nil {
@@ -136,13 +551,14 @@ func (r *AppendResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	digest, diag := r.doAppend(ctx, data)
+	digest, verified, diag := r.doAppend(ctx, data, "")
 	if diag.HasError() {
 		resp.Diagnostics.Append(diag...)
 		return
 	}
 	data.Id = types.StringValue(digest.String())
 	data.ImageRef = types.StringValue(digest.String())
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, verifiedBaseKey, verifiedBaseValue(verified))...)
 
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -155,7 +571,18 @@ func (r *AppendResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	digest, diag := r.doAppend(ctx, data)
+	// Don't verify the base again unless it has changed since it was.
+	b, diags := req.Private.GetKey(ctx, verifiedBaseKey)
+	resp.Diagnostics.Append(diags...)
+	var verified string
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &verified); err != nil {
+			resp.Diagnostics.AddError("Invalid private state", fmt.Sprintf("Unable to parse %s, got error: %s", verifiedBaseKey, err))
+			return
+		}
+	}
+
+	digest, verified, diag := r.doAppend(ctx, data, verified)
 	if diag.HasError() {
 		resp.Diagnostics.Append(diag...)
 		return
@@ -163,6 +590,18 @@ func (r *AppendResource) Read(ctx context.Context, req resource.ReadRequest, res
 
 	data.Id = types.StringValue(digest.String())
 	data.ImageRef = types.StringValue(digest.String())
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, verifiedBaseKey, verifiedBaseValue(verified))...)
+
+	// Record what the base resolves to now, so that ModifyPlan can tell if it
+	// has moved.
+	if data.ResolveBaseToDigest.ValueBool() {
+		current, diags := r.popts.resolveDigest(ctx, data.BaseImage.ValueString(), data.Insecure.ValueBool())
+		if diags.HasError() {
+			resp.Diagnostics.AddWarning("Unable to resolve base image", fmt.Sprintf("Unable to check whether base image %q has changed: %s", data.BaseImage.ValueString(), diags.Errors()[0].Detail()))
+		} else {
+			data.CurrentBaseImageDigest = types.StringValue(current.String())
+		}
+	}
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -175,7 +614,7 @@ func (r *AppendResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 
-	digest, diag := r.doAppend(ctx, data)
+	digest, verified, diag := r.doAppend(ctx, data, "")
 	if diag.HasError() {
 		resp.Diagnostics.Append(diag...)
 		return
@@ -183,6 +622,7 @@ func (r *AppendResource) Update(ctx context.Context, req resource.UpdateRequest,
 
 	data.Id = types.StringValue(digest.String())
 	data.ImageRef = types.StringValue(digest.String())
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, verifiedBaseKey, verifiedBaseValue(verified))...)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -201,54 +641,645 @@ func (r *AppendResource) ImportState(ctx context.Context, req resource.ImportSta
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
-func (r *AppendResource) doAppend(ctx context.Context, data *AppendResourceModel) (*name.Digest, diag.Diagnostics) {
-	baseref, err := name.ParseReference(data.BaseImage.ValueString())
+// verifiedBaseKey is the key in the private state of the base image digest
+// that verify_base last verified.
+const verifiedBaseKey = "verified_base"
+
+// verifiedBaseValue returns the private state value of the verified base
+// digest d, or none if there isn't one.
+func verifiedBaseValue(d string) []byte {
+	if d == "" {
+		return nil
+	}
+	b, _ := json.Marshal(d)
+	return b
+}
+
+// doAppend appends the layers of data to its base and returns the image's
+// digest, and the digest of the base if verify_base verified it. A base that
+// resolves to verified isn't verified again.
+func (r *AppendResource) doAppend(ctx context.Context, data *AppendResourceModel, verified string) (*name.Digest, string, diag.Diagnostics) {
+	var ls []appendLayer
+	if diag := data.Layers.ElementsAs(ctx, &ls, false); diag.HasError() {
+		return nil, "", diag.Errors()
+	}
+	opts, diags := data.appendOptions(ctx)
+	if diags.HasError() {
+		return nil, "", diags
+	}
+
+	base := data.BaseImage.ValueString()
+	if !data.ResolveBaseToDigest.ValueBool() {
+		data.BaseImageDigest = types.StringNull()
+		data.CurrentBaseImageDigest = types.StringNull()
+	} else {
+		// Resolve the base once, and then keep appending to the same digest.
+		if data.BaseImageDigest.ValueString() == "" {
+			d, diags := r.popts.resolveDigest(ctx, base, data.Insecure.ValueBool())
+			if diags.HasError() {
+				return nil, "", diags
+			}
+			data.BaseImageDigest = types.StringValue(d.String())
+		}
+		if data.CurrentBaseImageDigest.ValueString() == "" {
+			data.CurrentBaseImageDigest = data.BaseImageDigest
+		}
+		base = data.BaseImageDigest.ValueString()
+	}
+
+	var verifiedBase string
+	if !data.VerifyBase.IsNull() {
+		var vb appendVerifyBase
+		if diags := data.VerifyBase.As(ctx, &vb, basetypes.ObjectAsOptions{}); diags.HasError() {
+			return nil, "", diags
+		}
+		// Build on the digest that was verified, even if the tag moves.
+		d, diags := r.popts.verifyBase(ctx, base, data.Insecure.ValueBool(), vb, verified)
+		if diags.HasError() {
+			return nil, "", diags
+		}
+		base = d.String()
+		verifiedBase = base
+	}
+
+	if !data.Provenance.IsNull() {
+		var pc appendProvenanceConfig
+		if diags := data.Provenance.As(ctx, &pc, basetypes.ObjectAsOptions{}); diags.HasError() {
+			return nil, "", diags
+		}
+		opts.provenance = &appendProvenance{builderID: pc.BuilderID.ValueString(), config: opts.config}
+	}
+
+	d, layerDigests, diags := r.popts.appendLayers(ctx, base, data.Insecure.ValueBool(), ls, opts)
+	if diags.HasError() {
+		return nil, "", diags
+	}
+	data.LayerDigests = layerDigestsValue(layerDigests)
+
+	data.ProvenanceRef = types.StringNull()
+	if opts.provenance != nil {
+		att, err := r.popts.attachProvenance(ctx, *d, opts.provenance)
+		if err != nil {
+			return nil, "", []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to attach provenance", fmt.Sprintf("Unable to attach provenance to %s, got error: %s", d, err))}
+		}
+		data.ProvenanceRef = types.StringValue(att.String())
+	}
+	return d, verifiedBase, diags
+}
+
+// appendOptions returns the options to append the layers with.
+func (m *AppendResourceModel) appendOptions(ctx context.Context) (appendOptions, diag.Diagnostics) {
+	opts := appendOptions{
+		compressionLevel: gzip.DefaultCompression,
+		mediaTypes:       m.MediaTypes.ValueString(),
+		estargz:          m.Estargz.ValueBool(),
+	}
+	if !m.CompressionLevel.IsNull() {
+		opts.compressionLevel = int(m.CompressionLevel.ValueInt64())
+	}
+	if !m.Config.IsNull() {
+		opts.config = &appendConfig{}
+		if diags := m.Config.As(ctx, opts.config, basetypes.ObjectAsOptions{}); diags.HasError() {
+			return opts, diags
+		}
+	}
+	return opts, nil
+}
+
+// layerDigestsValue returns hs as a list of strings.
+func layerDigestsValue(hs []v1.Hash) types.List {
+	vals := make([]attr.Value, 0, len(hs))
+	for _, h := range hs {
+		vals = append(vals, types.StringValue(h.String()))
+	}
+	return types.ListValueMust(types.StringType, vals)
+}
+
+// appendProvenanceConfig configures the provenance of the appended image.
+type appendProvenanceConfig struct {
+	BuilderID types.String `tfsdk:"builder_id"`
+}
+
+// appendVerifyBase configures how the signatures of the base image are
+// verified: with Key, or keyless with a certificate issued by CACert.
+type appendVerifyBase struct {
+	Key      types.String `tfsdk:"key"`
+	CACert   types.String `tfsdk:"ca_cert"`
+	Identity types.String `tfsdk:"identity"`
+	Issuer   types.String `tfsdk:"issuer"`
+
+	RekorPublicKey     types.String `tfsdk:"rekor_public_key"`
+	InsecureIgnoreTlog types.Bool   `tfsdk:"insecure_ignore_tlog"`
+}
+
+// validate checks that v sets a key, or a CA and an identity, that parse.
+// Unknown values aren't checked.
+func (v appendVerifyBase) validate() error {
+	if v.Key.IsUnknown() || v.CACert.IsUnknown() || v.RekorPublicKey.IsUnknown() {
+		return nil
+	}
+	if v.Key.IsNull() == v.CACert.IsNull() {
+		return errors.New("exactly one of key and ca_cert must be set")
+	}
+	if !v.Key.IsNull() {
+		if !v.Identity.IsNull() || !v.Issuer.IsNull() || !v.RekorPublicKey.IsNull() || !v.InsecureIgnoreTlog.IsNull() {
+			return errors.New("identity, issuer, rekor_public_key and insecure_ignore_tlog can only be set with ca_cert")
+		}
+		_, err := v.verifier()
+		return err
+	}
+	if v.Identity.IsNull() || v.Issuer.IsNull() {
+		return errors.New("identity and issuer are required with ca_cert")
+	}
+	_, err := v.verifier()
+	return err
+}
+
+// verifier returns the verifier of the base image's signatures.
+func (v appendVerifyBase) verifier() (cosignVerifier, error) {
+	if !v.Key.IsNull() {
+		key, err := parsePublicKey([]byte(v.Key.ValueString()))
+		if err != nil {
+			return cosignVerifier{}, fmt.Errorf("parsing key: %w", err)
+		}
+		return cosignVerifier{key: key, hash: crypto.SHA256}, nil
+	}
+	certs, err := parseCertificates([]byte(v.CACert.ValueString()))
+	if err != nil {
+		return cosignVerifier{}, fmt.Errorf("parsing ca_cert: %w", err)
+	}
+	if len(certs) == 0 {
+		return cosignVerifier{}, errors.New("no certificates in ca_cert")
+	}
+	roots := x509.NewCertPool()
+	for _, c := range certs {
+		roots.AddCert(c)
+	}
+	tlog, err := newCosignTlog(v.RekorPublicKey.ValueString(), v.InsecureIgnoreTlog.ValueBool())
+	if err != nil {
+		return cosignVerifier{}, err
+	}
+	return cosignVerifier{roots: roots, identities: []cosignIdentity{{
+		Subject: v.Identity.ValueString(),
+		Issuer:  v.Issuer.ValueString(),
+	}}, tlog: tlog}, nil
+}
+
+// verifyBase resolves base to a digest, and checks that it has a cosign
+// signature that vb verifies, unless it's the digest verified already.
+func (p *ProviderOpts) verifyBase(ctx context.Context, base string, insecure bool, vb appendVerifyBase, verified string) (name.Digest, diag.Diagnostics) {
+	d, diags := p.resolveDigest(ctx, base, insecure)
+	if diags.HasError() || d.String() == verified {
+		return d, diags
+	}
+	v, err := vb.verifier()
+	if err != nil {
+		return d, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid verify_base", err.Error())}
+	}
+	sigs, err := p.cosignEntries(ctx, d, "sig")
+	if err != nil {
+		return d, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch base image signatures", fmt.Sprintf("Unable to fetch signatures of base image %s, got error: %s", d, err))}
+	}
+	if err := verifyAnySignature(v, sigs, d); err != nil {
+		return d, []diag.Diagnostic{diag.NewErrorDiagnostic("Base image verification failed", fmt.Sprintf("Unable to verify base image %q (%s), got error: %s", base, d, err))}
+	}
+	return d, nil
+}
+
+// appendLayer is a layer to append, either built from Files, keyed by the
+// path of each file in the layer, or copied from another image.
+type appendLayer struct {
+	Files     map[string]appendFile `tfsdk:"files"`
+	FromImage *appendFromImage      `tfsdk:"from_image"`
+	History   *appendHistory        `tfsdk:"history"`
+}
+
+// appendHistory is the history entry for an appended layer.
+type appendHistory struct {
+	CreatedBy types.String `tfsdk:"created_by"`
+	Comment   types.String `tfsdk:"comment"`
+	Author    types.String `tfsdk:"author"`
+}
+
+// history returns the history entry for l, created by createdBy unless
+// configured otherwise.
+func (l appendLayer) history(createdBy string) v1.History {
+	if l.History == nil {
+		return v1.History{CreatedBy: createdBy}
+	}
+	if !l.History.CreatedBy.IsNull() {
+		createdBy = l.History.CreatedBy.ValueString()
+	}
+	return v1.History{
+		CreatedBy: createdBy,
+		Comment:   l.History.Comment.ValueString(),
+		Author:    l.History.Author.ValueString(),
+	}
+}
+
+// appendFromImage is a layer of another image, by index or digest.
+type appendFromImage struct {
+	Ref         types.String `tfsdk:"ref"`
+	LayerIndex  types.Int64  `tfsdk:"layer_index"`
+	LayerDigest types.String `tfsdk:"layer_digest"`
+}
+
+// appendFile is a file to add to a layer, with either literal Contents, the
+// contents of the local file at Path, or the contents downloaded from URL, or
+// a Hardlink to another file in the layer.
+type appendFile struct {
+	Contents types.String      `tfsdk:"contents"`
+	Path     types.String      `tfsdk:"path"`
+	Hardlink types.String      `tfsdk:"hardlink"`
+	URL      types.String      `tfsdk:"url"`
+	SHA256   types.String      `tfsdk:"sha256"`
+	Headers  map[string]string `tfsdk:"headers"`
+	Xattrs   map[string]string `tfsdk:"xattrs"`
+}
+
+// appendOptions configures how appendLayers builds the result.
+type appendOptions struct {
+	// compressionLevel is the gzip level of the layers.
+	compressionLevel int
+	// mediaTypes is "oci" or "docker" to convert the result to those media
+	// types, or empty to use the media types of the base.
+	mediaTypes string
+	// estargz builds the layers as eStargz, for lazy pulling.
+	estargz bool
+	// config, if set, is applied to the config of each resulting image.
+	config *appendConfig
+	// dryRun builds the result without pushing it, to preview its digest.
+	dryRun bool
+	// provenance, if set, records what the result is built from.
+	provenance *appendProvenance
+}
+
+// appendConfig is the config to set on the appended images. Only the fields
+// that are set replace those of the base.
+type appendConfig struct {
+	Env        []string `tfsdk:"env" json:"env,omitempty"`
+	User       *string  `tfsdk:"user" json:"user,omitempty"`
+	WorkingDir *string  `tfsdk:"working_dir" json:"working_dir,omitempty"`
+	Entrypoint []string `tfsdk:"entrypoint" json:"entrypoint,omitempty"`
+	Cmd        []string `tfsdk:"cmd" json:"cmd,omitempty"`
+}
+
+// apply returns cfg with c applied to it.
+func (c *appendConfig) apply(cfg v1.Config) v1.Config {
+	for _, e := range c.Env {
+		key, _, _ := strings.Cut(e, "=")
+		cfg.Env = slices.DeleteFunc(slices.Clone(cfg.Env), func(b string) bool {
+			k, _, _ := strings.Cut(b, "=")
+			return k == key
+		})
+		cfg.Env = append(cfg.Env, e)
+	}
+	if c.User != nil {
+		cfg.User = *c.User
+	}
+	if c.WorkingDir != nil {
+		cfg.WorkingDir = *c.WorkingDir
+	}
+	if c.Entrypoint != nil {
+		cfg.Entrypoint = c.Entrypoint
+	}
+	if c.Cmd != nil {
+		cfg.Cmd = c.Cmd
+	}
+	return cfg
+}
+
+// withConfig returns img with opts' config applied, if it has one.
+func (opts appendOptions) withConfig(img v1.Image) (v1.Image, error) {
+	if opts.config == nil {
+		return img, nil
+	}
+	cf, err := img.ConfigFile()
 	if err != nil {
-		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to parse base image", fmt.Sprintf("Unable to parse base image %q, got error: %s", data.BaseImage.ValueString(), err))}
+		return nil, err
 	}
+	return mutate.Config(img, opts.config.apply(cf.Config))
+}
 
-	ropts := r.popts.withContext(ctx)
+// compressLayer returns a layer of the uncompressed tarball tb, compressed as
+// configured by opts, and any annotations the layer's descriptor needs.
+func compressLayer(tb []byte, opts appendOptions) (v1.Layer, map[string]string, error) {
+	var (
+		b           bytes.Buffer
+		annotations map[string]string
+	)
+	if opts.estargz {
+		blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(tb), 0, int64(len(tb))), estargz.WithCompression(newEstargzCompression(opts.compressionLevel)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("building estargz: %w", err)
+		}
+		defer blob.Close()
+		if _, err := io.Copy(&b, blob); err != nil {
+			return nil, nil, fmt.Errorf("building estargz: %w", err)
+		}
+		annotations = map[string]string{
+			estargz.TOCJSONDigestAnnotation:         blob.TOCDigest().String(),
+			estargz.StoreUncompressedSizeAnnotation: strconv.Itoa(len(tb)),
+		}
+	} else {
+		zw, err := gzip.NewWriterLevel(&b, opts.compressionLevel)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Leave out the modification time and OS, so the same contents always
+		// compress to the same digest.
+		zw.Header = gzip.Header{OS: 255}
+		if _, err := zw.Write(tb); err != nil {
+			return nil, nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b.Bytes())), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return layer, annotations, nil
+}
+
+// appendLayers appends ls to the base image (or each image in the base index),
+// sets any config in opts, and pushes the result to the base image's
+// repository, returning its digest and the digests of the appended layers.
+func (p *ProviderOpts) appendLayers(ctx context.Context, base string, insecure bool, ls []appendLayer, opts appendOptions) (*name.Digest, []v1.Hash, diag.Diagnostics) {
+	if len(ls) == 0 && opts.config == nil {
+		return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Nothing to append", "At least one layer must be appended, unless config is set")}
+	}
+	baseref, err := name.ParseReference(base, p.nameOptions(base, insecure)...)
+	if err != nil {
+		return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to parse base image", fmt.Sprintf("Unable to parse base image %q, got error: %s", base, err))}
+	}
+
+	ropts := p.withContext(ctx)
 
 	desc, err := remote.Get(baseref, ropts...)
 	if err != nil {
-		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch base image", fmt.Sprintf("Unable to fetch base image %q, got error: %s", data.BaseImage.ValueString(), err))}
+		return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch base image", fmt.Sprintf("Unable to fetch base image %q, got error: %s", base, err))}
 	}
+	opts.provenance.setBase(base, baseref, desc.Digest)
 
-	var ls []struct {
-		Files map[string]struct {
-			Contents types.String `tfsdk:"contents"`
-			Path     types.String `tfsdk:"path"`
-		} `tfsdk:"files"`
+	docker := desc.MediaType == ggcrtypes.DockerManifestSchema2 || desc.MediaType == ggcrtypes.DockerManifestList
+	switch opts.mediaTypes {
+	case "oci":
+		docker = false
+	case "docker":
+		docker = true
 	}
-	if diag := data.Layers.ElementsAs(ctx, &ls, false); diag.HasError() {
-		return nil, diag.Errors()
+	layerType := ggcrtypes.OCILayer
+	if docker {
+		layerType = ggcrtypes.DockerLayer
 	}
 
-	adds := []mutate.Addendum{}
-	for _, l := range ls {
+	adds, diags := p.buildLayers(ctx, ls, insecure, layerType, opts)
+	if diags.HasError() {
+		return nil, nil, diags
+	}
+	layerDigests := make([]v1.Hash, 0, len(adds))
+	for _, add := range adds {
+		h, err := add.Layer.Digest()
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get layer digest", fmt.Sprintf("Unable to get layer digest, got error: %s", err))}
+		}
+		layerDigests = append(layerDigests, h)
+	}
+
+	var d name.Digest
+
+	if desc.MediaType.IsIndex() {
+		baseidx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read image index", fmt.Sprintf("Unable to read image index for ref %q, got error: %s", base, err))}
+		}
+		baseidx = p.cacheIndex(baseidx)
+
+		baseimf, err := baseidx.IndexManifest()
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read image index manifest", fmt.Sprintf("Unable to read image index manifest for ref %q, got error: %s", base, err))}
+		}
+
+		var idx v1.ImageIndex = empty.Index
+		if docker {
+			idx = mutate.IndexMediaType(idx, ggcrtypes.DockerManifestList)
+		}
+
+		// append to each manifest in the index
+		for _, manifest := range baseimf.Manifests {
+			baseimg, err := baseidx.Image(manifest.Digest)
+			if err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to load image", fmt.Sprintf("Unable to load image for ref %q, got error: %s", base, err))}
+			}
+			imgadds := adds
+			if opts.mediaTypes != "" {
+				if baseimg, err = convertImage(baseimg, docker); err != nil {
+					return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to convert image", fmt.Sprintf("Unable to convert image %s to %s media types, got error: %s", manifest.Digest, opts.mediaTypes, err))}
+				}
+			} else if childDocker := manifest.MediaType == ggcrtypes.DockerManifestSchema2; childDocker != docker {
+				// Match the media types of each image, which may differ
+				// from the index's.
+				if imgadds, err = convertAddenda(adds, childDocker); err != nil {
+					return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to convert layer", fmt.Sprintf("Unable to convert layers for image %s, got error: %s", manifest.Digest, err))}
+				}
+			}
+
+			img, err := mutate.Append(baseimg, imgadds...)
+			if err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to append layers", fmt.Sprintf("Unable to append layers, got error: %s", err))}
+			}
+			if img, err = opts.withConfig(img); err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to set config", fmt.Sprintf("Unable to set config of image %s, got error: %s", manifest.Digest, err))}
+			}
+
+			imgdig, err := img.Digest()
+			if err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get image digest", fmt.Sprintf("Unable to get image digest, got error: %s", err))}
+			}
+			imgmt, err := img.MediaType()
+			if err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get image media type", fmt.Sprintf("Unable to get image media type, got error: %s", err))}
+			}
+
+			if !opts.dryRun {
+				if err := retryTransient(ctx, func(ctx context.Context) error {
+					return remote.Write(baseref.Context().Digest(imgdig.String()), img, p.withContext(ctx)...)
+				}); err != nil {
+					return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push image", fmt.Sprintf("Unable to push image, got error: %s", err))}
+				}
+			}
+
+			// Update the index with the new image
+			idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+				Add: img,
+				Descriptor: v1.Descriptor{
+					MediaType:    imgmt,
+					URLs:         manifest.URLs,
+					Annotations:  manifest.Annotations,
+					Platform:     manifest.Platform,
+					ArtifactType: manifest.ArtifactType,
+				},
+			})
+		}
+
+		dig, err := idx.Digest()
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get index digest", fmt.Sprintf("Unable to get index digest, got error: %s", err))}
+		}
+
+		d = baseref.Context().Digest(dig.String())
+		if !opts.dryRun {
+			if err := retryTransient(ctx, func(ctx context.Context) error {
+				return remote.WriteIndex(d, idx, p.withContext(ctx)...)
+			}); err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push index", fmt.Sprintf("Unable to push index, got error: %s", err))}
+			}
+		}
+
+	} else if desc.MediaType.IsImage() {
+		baseimg, err := remote.Image(baseref, ropts...)
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch base image", fmt.Sprintf("Unable to fetch base image %q, got error: %s", base, err))}
+		}
+		baseimg = p.cacheImage(baseimg)
+		if opts.mediaTypes != "" {
+			if baseimg, err = convertImage(baseimg, docker); err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to convert image", fmt.Sprintf("Unable to convert base image %q to %s media types, got error: %s", base, opts.mediaTypes, err))}
+			}
+		}
+
+		img, err := mutate.Append(baseimg, adds...)
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to append layers", fmt.Sprintf("Unable to append layers, got error: %s", err))}
+		}
+		if img, err = opts.withConfig(img); err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to set config", fmt.Sprintf("Unable to set config of base image %q, got error: %s", base, err))}
+		}
+
+		dig, err := img.Digest()
+		if err != nil {
+			return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get image digest", fmt.Sprintf("Unable to get image digest, got error: %s", err))}
+		}
+
+		d = baseref.Context().Digest(dig.String())
+		if !opts.dryRun {
+			if err := retryTransient(ctx, func(ctx context.Context) error {
+				return remote.Write(d, img, p.withContext(ctx)...)
+			}); err != nil {
+				return nil, nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push image", fmt.Sprintf("Unable to push image, got error: %s", err))}
+			}
+		}
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a resource")
+
+	return &d, layerDigests, nil
+}
+
+// buildLayers builds ls into layers of layerType, to append to an image.
+func (p *ProviderOpts) buildLayers(ctx context.Context, ls []appendLayer, insecure bool, layerType ggcrtypes.MediaType, opts appendOptions) ([]mutate.Addendum, diag.Diagnostics) {
+	docker := layerType == ggcrtypes.DockerLayer
+	adds := make([]mutate.Addendum, 0, len(ls))
+	opts.provenance.setLayers(len(ls))
+	for i, l := range ls {
+		if l.FromImage != nil {
+			if len(l.Files) > 0 {
+				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid layer", fmt.Sprintf("Layer %d sets both files and from_image", i))}
+			}
+			layer, diags := p.fromImageLayer(ctx, l.FromImage, insecure)
+			if diags.HasError() {
+				return nil, diags
+			}
+			mt, err := layer.MediaType()
+			if err != nil {
+				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read layer", fmt.Sprintf("Unable to read layer media type, got error: %s", err))}
+			}
+			if mt, err = convertLayerMediaType(mt, docker); err != nil {
+				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to convert layer", fmt.Sprintf("Unable to convert layer from %q, got error: %s", l.FromImage.Ref.ValueString(), err))}
+			}
+			if opts.provenance != nil {
+				h, err := layer.Digest()
+				if err != nil {
+					return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get layer digest", fmt.Sprintf("Unable to get layer digest of %q, got error: %s", l.FromImage.Ref.ValueString(), err))}
+				}
+				opts.provenance.addFromImage(i, l.FromImage.Ref.ValueString(), h)
+			}
+			adds = append(adds, mutate.Addendum{
+				Layer:     layer,
+				History:   l.history("terraform-provider-oci: oci_append from " + l.FromImage.Ref.ValueString()),
+				MediaType: mt,
+			})
+			continue
+		}
+		if len(l.Files) == 0 {
+			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid layer", fmt.Sprintf("Layer %d must set one of files or from_image", i))}
+		}
+
+		if err := errors.Join(layerPathErrors(slices.Collect(maps.Keys(l.Files)))...); err != nil {
+			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid file path", fmt.Sprintf("Invalid file path in layer %d: %s", i, err))}
+		}
+
 		var b bytes.Buffer
-		zw := gzip.NewWriter(&b)
-		tw := tar.NewWriter(zw)
-		for name, f := range l.Files {
+		tw := tar.NewWriter(&b)
+		// Write hardlinks after the files they link to.
+		names := slices.Sorted(maps.Keys(l.Files))
+		slices.SortStableFunc(names, func(a, b string) int {
+			return cmpBool(l.Files[a].Hardlink.ValueString() != "", l.Files[b].Hardlink.ValueString() != "")
+		})
+		for _, name := range names {
+			f := l.Files[name]
+			if target := f.Hardlink.ValueString(); target != "" {
+				if tf, ok := l.Files[target]; !ok || tf.Hardlink.ValueString() != "" {
+					return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid hardlink", fmt.Sprintf("Hardlink %q must link to another file in the same layer that isn't a hardlink, got %q", name, target))}
+				}
+				if err := tw.WriteHeader(&tar.Header{
+					Typeflag: tar.TypeLink,
+					Name:     name,
+					Linkname: target,
+				}); err != nil {
+					return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to write tar contents", fmt.Sprintf("Unable to write hardlink %q, got error: %s", name, err))}
+				}
+				opts.provenance.addFile(i, name, provenanceFile{Hardlink: target})
+				continue
+			}
 			var (
 				size   int64
 				mode   int64
 				datarc io.ReadCloser
 			)
 
+			pax, err := xattrRecords(f.Xattrs)
+			if err != nil {
+				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid xattrs", fmt.Sprintf("Invalid xattrs for %q, got error: %s", name, err))}
+			}
+
 			write := func(rc io.ReadCloser) error {
 				defer rc.Close()
 				if err := tw.WriteHeader(&tar.Header{
-					Name: name,
-					Size: size,
-					Mode: mode,
+					Name:       name,
+					Size:       size,
+					Mode:       mode,
+					PAXRecords: pax,
 				}); err != nil {
 					return fmt.Errorf("unable to write tar header: %w", err)
 				}
 
-				if _, err := io.CopyN(tw, rc, size); err != nil {
+				h := sha256.New()
+				if _, err := io.CopyN(io.MultiWriter(tw, h), rc, size); err != nil {
 					return fmt.Errorf("unable to write tar contents: %w", err)
 				}
+				if f.URL.ValueString() != "" {
+					opts.provenance.addDownload(i, name, f.URL.ValueString(), hex.EncodeToString(h.Sum(nil)))
+				} else {
+					opts.provenance.addFile(i, name, provenanceFile{SHA256: hex.EncodeToString(h.Sum(nil))})
+				}
 				return nil
 			}
 
@@ -277,8 +1308,17 @@ func (r *AppendResource) doAppend(ctx context.Context, data *AppendResourceModel
 				}
 				datarc = fr
 
+			} else if f.URL.ValueString() != "" {
+				b, err := p.download(ctx, f.URL.ValueString(), f.Headers, f.SHA256.ValueString())
+				if err != nil {
+					return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to download file", fmt.Sprintf("Unable to download %q for %q, got error: %s", f.URL.ValueString(), name, err))}
+				}
+				size = int64(len(b))
+				mode = 0755
+				datarc = io.NopCloser(bytes.NewReader(b))
+
 			} else {
-				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("No file contents or path specified", fmt.Sprintf("No file contents or path specified for %q", name))}
+				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("No file contents or path specified", fmt.Sprintf("No file contents, path or url specified for %q", name))}
 			}
 
 			if err := write(datarc); err != nil {
@@ -288,108 +1328,230 @@ func (r *AppendResource) doAppend(ctx context.Context, data *AppendResourceModel
 		if err := tw.Close(); err != nil {
 			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to close tar writer", fmt.Sprintf("Unable to close tar writer, got error: %s", err))}
 		}
-		if err := zw.Close(); err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to close gzip writer", fmt.Sprintf("Unable to close gzip writer, got error: %s", err))}
-		}
 
-		l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
-			return io.NopCloser(bytes.NewBuffer(b.Bytes())), nil
-		})
+		layer, annotations, err := compressLayer(b.Bytes(), opts)
 		if err != nil {
 			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to create layer", fmt.Sprintf("Unable to create layer, got error: %s", err))}
 		}
 
 		adds = append(adds, mutate.Addendum{
-			Layer:     l,
-			History:   v1.History{CreatedBy: "terraform-provider-oci: oci_append"},
-			MediaType: ggcrtypes.OCILayer,
+			Layer:       layer,
+			History:     l.history("terraform-provider-oci: oci_append"),
+			MediaType:   layerType,
+			Annotations: annotations,
 		})
 	}
+	return adds, nil
+}
 
-	var d name.Digest
+// fromImageLayer returns the layer of another image that f refers to. The
+// layer is read lazily, so it can be mounted rather than copied when it is
+// pushed to the same registry.
+func (p *ProviderOpts) fromImageLayer(ctx context.Context, f *appendFromImage, insecure bool) (v1.Layer, diag.Diagnostics) {
+	src := f.Ref.ValueString()
+	if f.LayerIndex.IsNull() == f.LayerDigest.IsNull() {
+		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid from_image", fmt.Sprintf("Exactly one of layer_index and layer_digest must be set for %q", src))}
+	}
+	ref, err := name.ParseReference(src, p.nameOptions(src, insecure)...)
+	if err != nil {
+		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to parse image", fmt.Sprintf("Unable to parse image %q, got error: %s", src, err))}
+	}
+	img, err := remote.Image(ref, p.withContext(ctx)...)
+	if err != nil {
+		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch image", fmt.Sprintf("Unable to fetch image %q, got error: %s", src, err))}
+	}
 
-	if desc.MediaType.IsIndex() {
-		baseidx, err := desc.ImageIndex()
+	if !f.LayerDigest.IsNull() {
+		h, err := v1.NewHash(f.LayerDigest.ValueString())
 		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read image index", fmt.Sprintf("Unable to read image index for ref %q, got error: %s", data.BaseImage.ValueString(), err))}
+			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid layer digest", fmt.Sprintf("Invalid layer digest %q, got error: %s", f.LayerDigest.ValueString(), err))}
 		}
-
-		baseimf, err := baseidx.IndexManifest()
+		l, err := img.LayerByDigest(h)
 		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read image index manifest", fmt.Sprintf("Unable to read image index manifest for ref %q, got error: %s", data.BaseImage.ValueString(), err))}
+			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to find layer", fmt.Sprintf("Unable to find layer %s in %q, got error: %s", h, src, err))}
 		}
+		return l, nil
+	}
 
-		var idx v1.ImageIndex = empty.Index
-
-		// append to each manifest in the index
-		for _, manifest := range baseimf.Manifests {
-			baseimg, err := baseidx.Image(manifest.Digest)
-			if err != nil {
-				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to load image", fmt.Sprintf("Unable to load image for ref %q, got error: %s", data.BaseImage.ValueString(), err))}
-			}
-
-			img, err := mutate.Append(baseimg, adds...)
-			if err != nil {
-				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to append layers", fmt.Sprintf("Unable to append layers, got error: %s", err))}
-			}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to read layers", fmt.Sprintf("Unable to read layers of %q, got error: %s", src, err))}
+	}
+	i := f.LayerIndex.ValueInt64()
+	if i < 0 || i >= int64(len(layers)) {
+		return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid layer index", fmt.Sprintf("Layer index %d is out of range for %q, which has %d layers", i, src, len(layers)))}
+	}
+	return layers[i], nil
+}
 
-			imgdig, err := img.Digest()
-			if err != nil {
-				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get image digest", fmt.Sprintf("Unable to get image digest, got error: %s", err))}
-			}
+// maxDownloadSize is the size of the largest file that a url may download.
+const maxDownloadSize = 1 << 30
 
-			if err := remote.Write(baseref.Context().Digest(imgdig.String()), img, ropts...); err != nil {
-				return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push image", fmt.Sprintf("Unable to push image, got error: %s", err))}
-			}
+// download returns the contents of url, fetched with headers, after checking
+// that they have the hex-encoded SHA-256 digest want. Downloads are kept in
+// the blob cache, if there is one, so that refreshes needn't fetch them again.
+func (p *ProviderOpts) download(ctx context.Context, url string, headers map[string]string, want string) ([]byte, error) {
+	if want == "" {
+		return nil, errors.New("sha256 must be set for url")
+	}
+	h, err := v1.NewHash("sha256:" + strings.ToLower(strings.TrimPrefix(want, "sha256:")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sha256 %q: %w", want, err)
+	}
+	if p.blobCache != nil {
+		if b, err := p.blobCache.getBlob(h); err == nil {
+			return b, nil
+		}
+	}
 
-			// Update the index with the new image
-			idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
-				Add: img,
-				Descriptor: v1.Descriptor{
-					MediaType:    manifest.MediaType,
-					URLs:         manifest.URLs,
-					Annotations:  manifest.Annotations,
-					Platform:     manifest.Platform,
-					ArtifactType: manifest.ArtifactType,
-				},
-			})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Transport: p.transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var buf bytes.Buffer
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(&buf, hasher), io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > maxDownloadSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxDownloadSize)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != h.Hex {
+		return nil, fmt.Errorf("got sha256 %s, want %s", got, want)
+	}
+	if p.blobCache != nil {
+		if err := p.blobCache.putBlob(h, buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("caching download: %w", err)
 		}
+	}
+	return buf.Bytes(), nil
+}
 
-		dig, err := idx.Digest()
+// xattrRecords returns the PAX records for xattrs, keyed by attribute name
+// with base64-encoded values.
+func xattrRecords(xattrs map[string]string) (map[string]string, error) {
+	if len(xattrs) == 0 {
+		return nil, nil
+	}
+	pax := make(map[string]string, len(xattrs))
+	for k, v := range xattrs {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return nil, fmt.Errorf("invalid xattr name %q", k)
+		}
+		b, err := base64.StdEncoding.DecodeString(v)
 		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get index digest", fmt.Sprintf("Unable to get index digest, got error: %s", err))}
+			return nil, fmt.Errorf("xattr %s must be base64-encoded: %w", k, err)
 		}
+		pax["SCHILY.xattr."+k] = string(b)
+	}
+	return pax, nil
+}
 
-		d = baseref.Context().Digest(dig.String())
-		if err := remote.WriteIndex(d, idx, ropts...); err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push index", fmt.Sprintf("Unable to push index, got error: %s", err))}
-		}
+// cmpBool orders false before true.
+func cmpBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
 
-	} else if desc.MediaType.IsImage() {
-		baseimg, err := remote.Image(baseref, ropts...)
-		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to fetch base image", fmt.Sprintf("Unable to fetch base image %q, got error: %s", data.BaseImage.ValueString(), err))}
+// layerMediaTypes maps OCI layer media types to their Docker equivalents.
+var layerMediaTypes = map[ggcrtypes.MediaType]ggcrtypes.MediaType{
+	ggcrtypes.OCILayer:             ggcrtypes.DockerLayer,
+	ggcrtypes.OCIUncompressedLayer: ggcrtypes.DockerUncompressedLayer,
+	ggcrtypes.OCIRestrictedLayer:   ggcrtypes.DockerForeignLayer,
+}
+
+// convertLayerMediaType returns the Docker or OCI equivalent of the layer
+// media type mt.
+func convertLayerMediaType(mt ggcrtypes.MediaType, docker bool) (ggcrtypes.MediaType, error) {
+	for oci, dkr := range layerMediaTypes {
+		if mt == oci || mt == dkr {
+			if docker {
+				return dkr, nil
+			}
+			return oci, nil
 		}
+	}
+	return "", fmt.Errorf("layer media type %s has no Docker and OCI equivalents", mt)
+}
 
-		img, err := mutate.Append(baseimg, adds...)
+// convertAddenda returns a copy of adds with Docker or OCI layer media types.
+func convertAddenda(adds []mutate.Addendum, docker bool) ([]mutate.Addendum, error) {
+	out := make([]mutate.Addendum, 0, len(adds))
+	for _, add := range adds {
+		mt, err := convertLayerMediaType(add.MediaType, docker)
 		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to append layers", fmt.Sprintf("Unable to append layers, got error: %s", err))}
+			return nil, err
 		}
+		add.MediaType = mt
+		out = append(out, add)
+	}
+	return out, nil
+}
 
-		dig, err := img.Digest()
+// convertImage returns img with Docker or OCI media types for its manifest,
+// config and layers. The layers themselves aren't changed, so this doesn't
+// change any diff IDs or layer digests.
+func convertImage(img v1.Image, docker bool) (v1.Image, error) {
+	manifestType, configType := ggcrtypes.OCIManifestSchema1, ggcrtypes.OCIConfigJSON
+	if docker {
+		manifestType, configType = ggcrtypes.DockerManifestSchema2, ggcrtypes.DockerConfigJSON
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	convert := m.MediaType != manifestType || m.Config.MediaType != configType
+	adds := make([]mutate.Addendum, 0, len(m.Layers))
+	for _, desc := range m.Layers {
+		mt, err := convertLayerMediaType(desc.MediaType, docker)
 		if err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to get image digest", fmt.Sprintf("Unable to get image digest, got error: %s", err))}
+			return nil, err
 		}
-
-		d = baseref.Context().Digest(dig.String())
-		if err := remote.Write(d, img, r.popts.withContext(ctx)...); err != nil {
-			return nil, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to push image", fmt.Sprintf("Unable to push image, got error: %s", err))}
+		convert = convert || mt != desc.MediaType
+		l, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, err
 		}
+		adds = append(adds, mutate.Addendum{Layer: l, MediaType: mt, Annotations: desc.Annotations, URLs: desc.URLs})
+	}
+	if !convert {
+		return img, nil
 	}
 
-	// Write logs using the tflog package
-	// Documentation: https://terraform.io/plugin/log
-	tflog.Trace(ctx, "created a resource")
-
-	return &d, []diag.Diagnostic{}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	// Rebuild the image from scratch with the new media types, and then
+	// restore its config, whose diff IDs and history still apply.
+	out := mutate.ConfigMediaType(mutate.MediaType(empty.Image, manifestType), configType)
+	if out, err = mutate.Append(out, adds...); err != nil {
+		return nil, err
+	}
+	if out, err = mutate.ConfigFile(out, cf); err != nil {
+		return nil, err
+	}
+	if len(m.Annotations) > 0 {
+		out, _ = mutate.Annotations(out, m.Annotations).(v1.Image)
+	}
+	return out, nil
 }
@@ -2,11 +2,19 @@ package provider
 
 import (
 	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
@@ -17,9 +25,15 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
 )
 
 func TestAccAppendResource(t *testing.T) {
@@ -385,3 +399,1193 @@ resource "oci_append" "test" {
 		},
 	})
 }
+
+func TestAccAppendResource_ResolveBaseToDigest(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	ref := repo.Tag("latest")
+	img1, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img1); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d1, err := img1.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	config := func(replace bool) string {
+		return fmt.Sprintf(`resource "oci_append" "test" {
+  base_image             = %q
+  resolve_base_to_digest = true
+  replace_on_base_change = %t
+  layers = [{
+    files = {
+      "/usr/local/test.txt" = { contents = "hello world" }
+    }
+  }]
+}`, ref, replace)
+	}
+
+	var (
+		imageRef string
+		d2       v1.Hash
+	)
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: config(false),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_append.test", "base_image", ref.String()),
+				resource.TestCheckResourceAttr("oci_append.test", "base_image_digest", repo.Digest(d1.String()).String()),
+				resource.TestCheckResourceAttr("oci_append.test", "current_base_image_digest", repo.Digest(d1.String()).String()),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					imageRef = s.RootModule().Resources["oci_append.test"].Primary.Attributes["image_ref"]
+					return nil
+				}),
+			),
+		}, {
+			// Moving the base tag doesn't change the image, since it's
+			// appended to the recorded base digest, but the move is recorded.
+			PreConfig: func() {
+				img2, err := random.Image(1024, 1)
+				if err != nil {
+					t.Fatalf("failed to create image: %v", err)
+				}
+				if err := remote.Write(ref, img2); err != nil {
+					t.Fatalf("failed to write image: %v", err)
+				}
+				if d2, err = img2.Digest(); err != nil {
+					t.Fatalf("failed to get image digest: %v", err)
+				}
+			},
+			Config: config(false),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_append.test", "base_image_digest", repo.Digest(d1.String()).String()),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					return resource.TestCheckResourceAttr("oci_append.test", "current_base_image_digest", repo.Digest(d2.String()).String())(s)
+				}),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					if got := s.RootModule().Resources["oci_append.test"].Primary.Attributes["image_ref"]; got != imageRef {
+						return fmt.Errorf("got image_ref %s, want %s", got, imageRef)
+					}
+					return nil
+				}),
+			),
+		}, {
+			// With replace_on_base_change, the image is rebuilt on the new base.
+			Config: config(true),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					want := repo.Digest(d2.String()).String()
+					attrs := s.RootModule().Resources["oci_append.test"].Primary.Attributes
+					if got := attrs["base_image_digest"]; got != want {
+						return fmt.Errorf("got base_image_digest %s, want %s", got, want)
+					}
+					if got := attrs["image_ref"]; got == imageRef {
+						return fmt.Errorf("image_ref %s didn't change", got)
+					}
+					return nil
+				}),
+			),
+		}},
+	})
+}
+
+func TestAppendLayersReproducible(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	ls := []appendLayer{{Files: map[string]appendFile{
+		"/a.txt": {Contents: types.StringValue("a")},
+		"/b.txt": {Contents: types.StringValue("b")},
+		"/c.txt": {Contents: types.StringValue("c")},
+	}}}
+	p := &ProviderOpts{}
+	digest := func(level int) string {
+		t.Helper()
+		d, _, diags := p.appendLayers(context.Background(), base.String(), false, ls, appendOptions{compressionLevel: level})
+		if diags.HasError() {
+			t.Fatalf("appendLayers: %v", diags)
+		}
+		return d.String()
+	}
+
+	// Files are written in order, with no timestamps in the gzip header.
+	want := digest(gzip.DefaultCompression)
+	for i := 0; i < 5; i++ {
+		if got := digest(gzip.DefaultCompression); got != want {
+			t.Fatalf("got digest %s, want %s", got, want)
+		}
+	}
+	if got := digest(gzip.NoCompression); got == want {
+		t.Errorf("got the same digest %s with no compression", got)
+	}
+}
+
+func TestAccAppendResource_FromImage(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	src := repo.Tag("src")
+	srcImg, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(src, srcImg); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	srcLayers, err := srcImg.Layers()
+	if err != nil {
+		t.Fatalf("failed to get layers: %v", err)
+	}
+	d1, err := srcLayers[1].Digest()
+	if err != nil {
+		t.Fatalf("failed to get layer digest: %v", err)
+	}
+	d2, err := srcLayers[2].Digest()
+	if err != nil {
+		t.Fatalf("failed to get layer digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+  base_image = %q
+  layers = [
+    { from_image = { ref = %q, layer_index = 1 } },
+    { from_image = { ref = %q, layer_digest = %q } },
+    { files = { "/usr/local/test.txt" = { contents = "hello world" } } },
+  ]
+}`, base, src, src, d2),
+			Check: resource.TestCheckFunc(func(s *terraform.State) error {
+				rs := s.RootModule().Resources["oci_append.test"]
+				img, err := crane.Pull(rs.Primary.Attributes["image_ref"])
+				if err != nil {
+					return fmt.Errorf("failed to pull image: %v", err)
+				}
+				ls, err := img.Layers()
+				if err != nil {
+					return fmt.Errorf("failed to get layers: %v", err)
+				}
+				if len(ls) != 4 {
+					return fmt.Errorf("expected 4 layers, got %d", len(ls))
+				}
+				for i, want := range []v1.Hash{d1, d2} {
+					got, err := ls[i+1].Digest()
+					if err != nil {
+						return fmt.Errorf("failed to get layer digest: %v", err)
+					}
+					if got != want {
+						return fmt.Errorf("layer %d: got digest %s, want %s", i+1, got, want)
+					}
+				}
+				return nil
+			}),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+  base_image = %q
+  layers = [{ from_image = { ref = %q, layer_index = 3 } }]
+}`, base, src),
+			ExpectError: regexp.MustCompile("Layer index 3 is out of range"),
+		}},
+	})
+}
+
+func TestDownload(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "hello world")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	headers := map[string]string{"Authorization": "Bearer token"}
+	const sum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	// Downloads use the provider's transport, which trusts the server.
+	p := &ProviderOpts{transport: srv.Client().Transport}
+	b, err := p.download(ctx, srv.URL, headers, sum)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if string(b) != "hello world" {
+		t.Errorf("got %q, want %q", b, "hello world")
+	}
+	if _, err := p.download(ctx, srv.URL, headers, "sha256:"+sum); err != nil {
+		t.Errorf("download with sha256: prefix: %v", err)
+	}
+	if _, err := (&ProviderOpts{}).download(ctx, srv.URL, headers, sum); err == nil {
+		t.Error("download without the provider's transport: want certificate error")
+	}
+
+	// With a blob cache, each file is only downloaded once.
+	p.blobCache = newBlobCache(t.TempDir(), 0)
+	mu.Lock()
+	requests = 0
+	mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if b, err := p.download(ctx, srv.URL, headers, sum); err != nil || string(b) != "hello world" {
+			t.Fatalf("download = %q, %v", b, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+
+	for _, c := range []struct {
+		desc    string
+		headers map[string]string
+		sum     string
+		wantErr string
+	}{
+		{"wrong sum", headers, strings.Repeat("0", 64), "got sha256 " + sum},
+		{"no sum", headers, "", "sha256 must be set"},
+		{"no auth", nil, strings.Repeat("1", 64), "401 Unauthorized"},
+		{"invalid sum", headers, "not-a-sum", "invalid sha256"},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			if _, err := p.download(ctx, srv.URL, c.headers, c.sum); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("got error %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccAppendResource_URL(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello world")
+	}))
+	defer srv.Close()
+
+	config := func(url, sum string) string {
+		return fmt.Sprintf(`resource "oci_append" "test" {
+  base_image = %q
+  layers = [{
+    files = {
+      "/usr/local/bin/hello" = { url = %q, sha256 = %q }
+    }
+  }]
+}`, base, url, sum)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config:      config("file:///etc/passwd", strings.Repeat("0", 64)),
+			ExpectError: regexp.MustCompile("Invalid url"),
+		}, {
+			Config:      config("/hello", strings.Repeat("0", 64)),
+			ExpectError: regexp.MustCompile("Invalid url"),
+		}, {
+			Config:      config(srv.URL, strings.Repeat("0", 64)),
+			ExpectError: regexp.MustCompile("Unable to download file"),
+		}, {
+			Config: config(srv.URL, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"),
+			Check: resource.TestCheckFunc(func(s *terraform.State) error {
+				rs := s.RootModule().Resources["oci_append.test"]
+				img, err := crane.Pull(rs.Primary.Attributes["image_ref"])
+				if err != nil {
+					return fmt.Errorf("failed to pull image: %v", err)
+				}
+				ls, err := img.Layers()
+				if err != nil {
+					return fmt.Errorf("failed to get layers: %v", err)
+				}
+				rc, err := ls[len(ls)-1].Uncompressed()
+				if err != nil {
+					return fmt.Errorf("failed to get layer contents: %v", err)
+				}
+				defer rc.Close()
+				tr := tar.NewReader(rc)
+				hdr, err := tr.Next()
+				if err != nil {
+					return fmt.Errorf("failed to read next header: %v", err)
+				}
+				if hdr.Name != "/usr/local/bin/hello" || hdr.Mode != 0755 {
+					return fmt.Errorf("got %s with mode %o, want /usr/local/bin/hello with mode 755", hdr.Name, hdr.Mode)
+				}
+				if b, err := io.ReadAll(tr); err != nil || string(b) != "hello world" {
+					return fmt.Errorf("got contents %q (%v), want %q", b, err, "hello world")
+				}
+				return nil
+			}),
+		}},
+	})
+}
+
+func TestAppendLayersXattrs(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	// cap_net_bind_service=+ep, as set by setcap.
+	capability := []byte{1, 0, 0, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	p := &ProviderOpts{}
+	d, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{{Files: map[string]appendFile{
+		"/usr/bin/server": {
+			Contents: types.StringValue("#!/bin/sh"),
+			Xattrs:   map[string]string{"security.capability": base64.StdEncoding.EncodeToString(capability)},
+		},
+	}}}, appendOptions{compressionLevel: gzip.DefaultCompression})
+	if diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+
+	got, err := remote.Image(d)
+	if err != nil {
+		t.Fatalf("failed to pull image: %v", err)
+	}
+	ls, err := got.Layers()
+	if err != nil {
+		t.Fatalf("failed to get layers: %v", err)
+	}
+	rc, err := ls[len(ls)-1].Uncompressed()
+	if err != nil {
+		t.Fatalf("failed to get layer contents: %v", err)
+	}
+	defer rc.Close()
+	hdr, err := tar.NewReader(rc).Next()
+	if err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+	if v := hdr.PAXRecords["SCHILY.xattr.security.capability"]; v != string(capability) {
+		t.Errorf("got security.capability %x, want %x", v, capability)
+	}
+
+	for _, xattrs := range []map[string]string{
+		{"security.capability": "not base64!"},
+		{"bad=name": ""},
+	} {
+		if _, err := xattrRecords(xattrs); err == nil {
+			t.Errorf("expected an error for %v", xattrs)
+		}
+	}
+}
+
+func TestAppendLayersHardlinks(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	p := &ProviderOpts{}
+	opts := appendOptions{compressionLevel: gzip.DefaultCompression}
+	d, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{{Files: map[string]appendFile{
+		"/bin/ash":     {Hardlink: types.StringValue("/bin/busybox")},
+		"/bin/busybox": {Contents: types.StringValue("busybox")},
+		"/bin/ls":      {Hardlink: types.StringValue("/bin/busybox")},
+	}}}, opts)
+	if diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+
+	got, err := remote.Image(d)
+	if err != nil {
+		t.Fatalf("failed to pull image: %v", err)
+	}
+	ls, err := got.Layers()
+	if err != nil {
+		t.Fatalf("failed to get layers: %v", err)
+	}
+	rc, err := ls[len(ls)-1].Uncompressed()
+	if err != nil {
+		t.Fatalf("failed to get layer contents: %v", err)
+	}
+	defer rc.Close()
+
+	// The file comes before the links to it.
+	var entries []string
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read header: %v", err)
+		}
+		entries = append(entries, fmt.Sprintf("%c %s %s", hdr.Typeflag, hdr.Name, hdr.Linkname))
+	}
+	want := []string{"0 /bin/busybox ", "1 /bin/ash /bin/busybox", "1 /bin/ls /bin/busybox"}
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Errorf("got entries %q, want %q", entries, want)
+	}
+
+	for _, files := range []map[string]appendFile{
+		{"/bin/ls": {Hardlink: types.StringValue("/bin/missing")}},
+		{"/bin/ls": {Hardlink: types.StringValue("/bin/ash")}, "/bin/ash": {Hardlink: types.StringValue("/bin/ls")}},
+	} {
+		if _, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{{Files: files}}, opts); !diags.HasError() {
+			t.Errorf("expected an error for %v", files)
+		}
+	}
+}
+
+func TestAppendLayersMediaTypes(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	// random images and indexes have Docker media types.
+	base := repo.Tag("image")
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	baseIdx := repo.Tag("index")
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	idx = mutate.IndexMediaType(idx, ggcrtypes.DockerManifestList)
+	if err := remote.WriteIndex(baseIdx, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	ls := []appendLayer{{Files: map[string]appendFile{"/a.txt": {Contents: types.StringValue("a")}}}}
+	p := &ProviderOpts{}
+
+	checkImage := func(t *testing.T, img v1.Image, docker bool) {
+		t.Helper()
+		if err := validate.Image(img); err != nil {
+			t.Fatalf("invalid image: %v", err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatalf("failed to get manifest: %v", err)
+		}
+		want := []ggcrtypes.MediaType{ggcrtypes.OCIManifestSchema1, ggcrtypes.OCIConfigJSON, ggcrtypes.OCILayer}
+		if docker {
+			want = []ggcrtypes.MediaType{ggcrtypes.DockerManifestSchema2, ggcrtypes.DockerConfigJSON, ggcrtypes.DockerLayer}
+		}
+		if m.MediaType != want[0] || m.Config.MediaType != want[1] {
+			t.Errorf("got manifest %s and config %s, want %s and %s", m.MediaType, m.Config.MediaType, want[0], want[1])
+		}
+		for i, l := range m.Layers {
+			if l.MediaType != want[2] {
+				t.Errorf("layer %d: got %s, want %s", i, l.MediaType, want[2])
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		mediaTypes string
+		docker     bool
+	}{{"", true}, {"docker", true}, {"oci", false}} {
+		t.Run("image "+c.mediaTypes, func(t *testing.T) {
+			d, _, diags := p.appendLayers(context.Background(), base.String(), false, ls, appendOptions{compressionLevel: gzip.DefaultCompression, mediaTypes: c.mediaTypes})
+			if diags.HasError() {
+				t.Fatalf("appendLayers: %v", diags)
+			}
+			got, err := remote.Image(d)
+			if err != nil {
+				t.Fatalf("failed to pull image: %v", err)
+			}
+			checkImage(t, got, c.docker)
+
+			// Converting media types doesn't change the filesystem.
+			want, err := img.ConfigFile()
+			if err != nil {
+				t.Fatalf("failed to get config: %v", err)
+			}
+			cf, err := got.ConfigFile()
+			if err != nil {
+				t.Fatalf("failed to get config: %v", err)
+			}
+			if len(cf.RootFS.DiffIDs) != 3 || cf.RootFS.DiffIDs[1] != want.RootFS.DiffIDs[1] {
+				t.Errorf("got diff IDs %v, want %v and one more", cf.RootFS.DiffIDs, want.RootFS.DiffIDs)
+			}
+		})
+
+		t.Run("index "+c.mediaTypes, func(t *testing.T) {
+			d, _, diags := p.appendLayers(context.Background(), baseIdx.String(), false, ls, appendOptions{compressionLevel: gzip.DefaultCompression, mediaTypes: c.mediaTypes})
+			if diags.HasError() {
+				t.Fatalf("appendLayers: %v", diags)
+			}
+			got, err := remote.Index(d)
+			if err != nil {
+				t.Fatalf("failed to pull index: %v", err)
+			}
+			if err := validate.Index(got); err != nil {
+				t.Fatalf("invalid index: %v", err)
+			}
+			im, err := got.IndexManifest()
+			if err != nil {
+				t.Fatalf("failed to get index manifest: %v", err)
+			}
+			if want := ggcrtypes.DockerManifestList; (im.MediaType == want) != c.docker {
+				t.Errorf("got index %s", im.MediaType)
+			}
+			for _, desc := range im.Manifests {
+				child, err := got.Image(desc.Digest)
+				if err != nil {
+					t.Fatalf("failed to get image: %v", err)
+				}
+				checkImage(t, child, c.docker)
+			}
+		})
+	}
+}
+
+func TestAppendLayersHistory(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	files := map[string]appendFile{"/a.txt": {Contents: types.StringValue("a")}}
+	p := &ProviderOpts{}
+	d, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{
+		{Files: files},
+		{Files: files, History: &appendHistory{
+			CreatedBy: types.StringValue("make install"),
+			Comment:   types.StringValue("build step 3"),
+			Author:    types.StringValue("ci@example.com"),
+		}},
+		{Files: files, History: &appendHistory{Comment: types.StringValue("just a comment")}},
+	}, appendOptions{compressionLevel: gzip.DefaultCompression})
+	if diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+
+	got, err := remote.Image(d)
+	if err != nil {
+		t.Fatalf("failed to pull image: %v", err)
+	}
+	cf, err := got.ConfigFile()
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	want := []v1.History{
+		{CreatedBy: "terraform-provider-oci: oci_append"},
+		{CreatedBy: "make install", Comment: "build step 3", Author: "ci@example.com"},
+		{CreatedBy: "terraform-provider-oci: oci_append", Comment: "just a comment"},
+	}
+	if h := cf.History[len(cf.History)-3:]; fmt.Sprint(h) != fmt.Sprint(want) {
+		t.Errorf("got history %+v, want %+v", h, want)
+	}
+}
+
+func TestAppendLayersEstargz(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	p := &ProviderOpts{}
+	d, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{{Files: map[string]appendFile{
+		"/usr/local/test.txt": {Contents: types.StringValue("hello world")},
+	}}}, appendOptions{compressionLevel: gzip.DefaultCompression, estargz: true})
+	if diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+
+	got, err := remote.Image(d)
+	if err != nil {
+		t.Fatalf("failed to pull image: %v", err)
+	}
+	if err := validate.Image(got); err != nil {
+		t.Fatalf("invalid image: %v", err)
+	}
+	m, err := got.Manifest()
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+	desc := m.Layers[len(m.Layers)-1]
+	if _, ok := desc.Annotations["containerd.io/snapshot/stargz/toc.digest"]; !ok {
+		t.Errorf("missing TOC digest annotation, got %v", desc.Annotations)
+	}
+
+	// The layer is still a valid gzipped tarball.
+	ls, err := got.Layers()
+	if err != nil {
+		t.Fatalf("failed to get layers: %v", err)
+	}
+	rc, err := ls[len(ls)-1].Uncompressed()
+	if err != nil {
+		t.Fatalf("failed to get layer contents: %v", err)
+	}
+	defer rc.Close()
+	var names []string
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read header: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !slices.Contains(names, "usr/local/test.txt") && !slices.Contains(names, "/usr/local/test.txt") {
+		t.Errorf("got entries %q, want usr/local/test.txt", names)
+	}
+}
+
+func TestAppendLayersDryRun(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	base := repo.Digest(dig.String())
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	ls := []appendLayer{{Files: map[string]appendFile{
+		"/a.txt": {Contents: types.StringValue("a")},
+		"/b.txt": {Hardlink: types.StringValue("/a.txt")},
+	}}}
+	p := &ProviderOpts{}
+	for _, opts := range []appendOptions{
+		{compressionLevel: gzip.DefaultCompression},
+		{compressionLevel: gzip.BestCompression, estargz: true},
+	} {
+		dry := opts
+		dry.dryRun = true
+		preview, previewLayers, diags := p.appendLayers(context.Background(), base.String(), false, ls, dry)
+		if diags.HasError() {
+			t.Fatalf("appendLayers: %v", diags)
+		}
+		if _, err := remote.Head(preview); err == nil {
+			t.Errorf("dry run pushed %s", preview)
+		}
+
+		// The preview is what's pushed.
+		d, layers, diags := p.appendLayers(context.Background(), base.String(), false, ls, opts)
+		if diags.HasError() {
+			t.Fatalf("appendLayers: %v", diags)
+		}
+		if d.String() != preview.String() {
+			t.Errorf("estargz=%t: got digest %s, previewed %s", opts.estargz, d, preview)
+		}
+		if !slices.Equal(layers, previewLayers) {
+			t.Errorf("estargz=%t: got layer digests %v, previewed %v", opts.estargz, layers, previewLayers)
+		}
+		if len(layers) != 1 {
+			t.Errorf("got %d layer digests, want 1", len(layers))
+		}
+	}
+}
+
+func TestAccAppendResource_PlanDigests(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	base := repo.Digest(dig.String())
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			// With a pinned base, everything is known at plan time.
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  layers = [{
+			    files = {
+			      "/usr/local/test.txt" = { contents = "hello world" }
+			    }
+			  }]
+			}`, base),
+			ConfigPlanChecks: resource.ConfigPlanChecks{
+				PreApply: []plancheck.PlanCheck{
+					plancheck.ExpectKnownValue("oci_append.test", tfjsonpath.New("image_ref"), knownvalue.StringRegexp(regexp.MustCompile(`/test@sha256:[0-9a-f]{64}$`))),
+					plancheck.ExpectKnownValue("oci_append.test", tfjsonpath.New("layer_digests"), knownvalue.ListSizeExact(1)),
+				},
+			},
+		}, {
+			// With a tag, only the layers are.
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  allow_unpinned_base = true
+			  layers = [{
+			    files = {
+			      "/usr/local/test.txt" = { contents = "hello again" }
+			    }
+			  }]
+			}`, repo.Tag("latest")),
+			PreConfig: func() {
+				if err := remote.Write(repo.Tag("latest"), img); err != nil {
+					t.Fatalf("failed to write image: %v", err)
+				}
+			},
+			ConfigPlanChecks: resource.ConfigPlanChecks{
+				PreApply: []plancheck.PlanCheck{
+					plancheck.ExpectUnknownValue("oci_append.test", tfjsonpath.New("image_ref")),
+					plancheck.ExpectKnownValue("oci_append.test", tfjsonpath.New("layer_digests"), knownvalue.ListSizeExact(1)),
+				},
+			},
+		}},
+	})
+}
+
+func TestAppendLayersConfig(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	cf.Config.Env = []string{"PATH=/bin", "A=1"}
+	cf.Config.User = "root"
+	cf.Config.Cmd = []string{"sh"}
+	if img, err = mutate.ConfigFile(img, cf); err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	// Only the fields that are set replace the base's.
+	m := AppendResourceModel{
+		Config: types.ObjectValueMust(map[string]attr.Type{
+			"env":         types.ListType{ElemType: types.StringType},
+			"user":        types.StringType,
+			"working_dir": types.StringType,
+			"entrypoint":  types.ListType{ElemType: types.StringType},
+			"cmd":         types.ListType{ElemType: types.StringType},
+		}, map[string]attr.Value{
+			"env":         types.ListValueMust(types.StringType, []attr.Value{types.StringValue("A=2"), types.StringValue("B=3")}),
+			"user":        types.StringValue("nonroot"),
+			"working_dir": types.StringNull(),
+			"entrypoint":  types.ListValueMust(types.StringType, []attr.Value{}),
+			"cmd":         types.ListNull(types.StringType),
+		}),
+	}
+	opts, diags := m.appendOptions(context.Background())
+	if diags.HasError() {
+		t.Fatalf("appendOptions: %v", diags)
+	}
+
+	p := &ProviderOpts{}
+	d, layers, diags := p.appendLayers(context.Background(), base.String(), false, nil, opts)
+	if diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+	if len(layers) != 0 {
+		t.Errorf("got %d layer digests, want 0", len(layers))
+	}
+
+	got, err := remote.Image(d)
+	if err != nil {
+		t.Fatalf("failed to pull image: %v", err)
+	}
+	if ls, err := got.Layers(); err != nil || len(ls) != 1 {
+		t.Errorf("got %d layers (%v), want 1", len(ls), err)
+	}
+	gotcf, err := got.ConfigFile()
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	if want := []string{"PATH=/bin", "A=2", "B=3"}; !slices.Equal(gotcf.Config.Env, want) {
+		t.Errorf("got env %v, want %v", gotcf.Config.Env, want)
+	}
+	if gotcf.Config.User != "nonroot" {
+		t.Errorf("got user %q, want nonroot", gotcf.Config.User)
+	}
+	if len(gotcf.Config.Entrypoint) != 0 {
+		t.Errorf("got entrypoint %v, want none", gotcf.Config.Entrypoint)
+	}
+	if want := []string{"sh"}; !slices.Equal(gotcf.Config.Cmd, want) {
+		t.Errorf("got cmd %v, want %v", gotcf.Config.Cmd, want)
+	}
+
+	// Without a config, there has to be something to append.
+	if _, _, diags := p.appendLayers(context.Background(), base.String(), false, nil, appendOptions{compressionLevel: gzip.DefaultCompression}); !diags.HasError() {
+		t.Error("appendLayers with nothing to append succeeded")
+	}
+}
+
+func TestAccAppendResource_ConfigOnly(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  allow_unpinned_base = true
+			}`, base),
+			ExpectError: regexp.MustCompile(`At least one layer is required`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  allow_unpinned_base = true
+			  layers = []
+			  config = {
+			    user = "nonroot"
+			    env  = ["FOO=bar"]
+			  }
+			}`, base),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_append.test", "layer_digests.#", "0"),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					img, err := crane.Pull(s.RootModule().Resources["oci_append.test"].Primary.Attributes["image_ref"])
+					if err != nil {
+						return fmt.Errorf("failed to pull image: %v", err)
+					}
+					if ls, err := img.Layers(); err != nil || len(ls) != 1 {
+						return fmt.Errorf("got %d layers (%v), want 1", len(ls), err)
+					}
+					cf, err := img.ConfigFile()
+					if err != nil {
+						return fmt.Errorf("failed to get config: %v", err)
+					}
+					if cf.Config.User != "nonroot" {
+						return fmt.Errorf("got user %q, want nonroot", cf.Config.User)
+					}
+					if !slices.Contains(cf.Config.Env, "FOO=bar") {
+						return fmt.Errorf("got env %v, want FOO=bar", cf.Config.Env)
+					}
+					return nil
+				}),
+			),
+		}},
+	})
+}
+
+func TestAccAppendResource_Provenance(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test", ocitesting.WithReferrers())
+	defer cleanup()
+
+	base := repo.Tag("base")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(base, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  allow_unpinned_base = true
+			  layers = [{
+			    files = {
+			      "/usr/local/test.txt" = { contents = "hello world" }
+			    }
+			  }]
+			  provenance = {
+			    builder_id = "https://example.com/builder"
+			  }
+			}`, base),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestMatchResourceAttr("oci_append.test", "provenance_ref", regexp.MustCompile(`@sha256:`)),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["oci_append.test"].Primary.Attributes
+					d, err := name.NewDigest(attrs["image_ref"])
+					if err != nil {
+						return err
+					}
+					idx, err := remote.Referrers(d)
+					if err != nil {
+						return fmt.Errorf("failed to list referrers: %v", err)
+					}
+					im, err := idx.IndexManifest()
+					if err != nil {
+						return err
+					}
+					if len(im.Manifests) != 1 || !strings.HasSuffix(attrs["provenance_ref"], im.Manifests[0].Digest.String()) {
+						return fmt.Errorf("got referrers %+v, want %s", im.Manifests, attrs["provenance_ref"])
+					}
+					return nil
+				}),
+			),
+		}},
+	})
+}
+
+func TestVerifyBase(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	keySigner, pub := newKeySigner(t)
+	_, otherPub := newKeySigner(t)
+	fulcio := newTestFulcio(t)
+	issuer := "https://accounts.example.com"
+
+	signed := pushRandomImage(t, repo)
+	keySigner.signImage(t, signed)
+	fulcio.signer(t, "me@example.com", issuer).signImage(t, signed)
+	if err := remote.Tag(repo.Tag("signed"), mustGet(t, signed)); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := pushRandomImage(t, repo)
+
+	for _, c := range []struct {
+		desc    string
+		base    string
+		vb      appendVerifyBase
+		wantErr string
+	}{{
+		desc: "key",
+		base: repo.Tag("signed").String(),
+		vb:   appendVerifyBase{Key: types.StringValue(pub)},
+	}, {
+		desc:    "wrong key",
+		base:    signed.String(),
+		vb:      appendVerifyBase{Key: types.StringValue(otherPub)},
+		wantErr: "no valid signatures found",
+	}, {
+		desc:    "unsigned",
+		base:    unsigned.String(),
+		vb:      appendVerifyBase{Key: types.StringValue(pub)},
+		wantErr: "no signatures found",
+	}, {
+		desc: "keyless",
+		base: signed.String(),
+		vb:   appendVerifyBase{CACert: types.StringValue(fulcio.pem), Identity: types.StringValue("me@example.com"), Issuer: types.StringValue(issuer), RekorPublicKey: types.StringValue(fulcio.rekor.pem)},
+	}, {
+		desc:    "keyless untrusted log",
+		base:    signed.String(),
+		vb:      appendVerifyBase{CACert: types.StringValue(fulcio.pem), Identity: types.StringValue("me@example.com"), Issuer: types.StringValue(issuer)},
+		wantErr: "not the trusted log",
+	}, {
+		desc:    "keyless wrong identity",
+		base:    signed.String(),
+		vb:      appendVerifyBase{CACert: types.StringValue(fulcio.pem), Identity: types.StringValue("you@example.com"), Issuer: types.StringValue(issuer), RekorPublicKey: types.StringValue(fulcio.rekor.pem)},
+		wantErr: "doesn't match any identity",
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			for _, v := range []*types.String{&c.vb.Key, &c.vb.CACert, &c.vb.Identity, &c.vb.Issuer, &c.vb.RekorPublicKey} {
+				if v.ValueString() == "" {
+					*v = types.StringNull()
+				}
+			}
+			if err := c.vb.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			d, diags := (&ProviderOpts{}).verifyBase(context.Background(), c.base, false, c.vb, "")
+			if c.wantErr == "" {
+				if diags.HasError() {
+					t.Fatalf("verifyBase: %v", diags)
+				}
+				if d != signed {
+					t.Errorf("got digest %s, want %s", d, signed)
+				}
+				return
+			}
+			if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), c.wantErr) {
+				t.Errorf("got %v, want error %q", diags, c.wantErr)
+			}
+		})
+	}
+
+	// A base that was verified already isn't verified again, e.g. on every
+	// refresh, but a base that has changed since is.
+	vb := appendVerifyBase{Key: types.StringValue(pub), CACert: types.StringNull(), Identity: types.StringNull(), Issuer: types.StringNull(), RekorPublicKey: types.StringNull()}
+	if d, diags := (&ProviderOpts{}).verifyBase(context.Background(), unsigned.String(), false, vb, unsigned.String()); diags.HasError() || d != unsigned {
+		t.Errorf("verifyBase of a verified base = %s, %v", d, diags)
+	}
+	if _, diags := (&ProviderOpts{}).verifyBase(context.Background(), unsigned.String(), false, vb, signed.String()); !diags.HasError() {
+		t.Error("verifyBase of a changed base: want error")
+	}
+}
+
+func TestAppendVerifyBaseValidate(t *testing.T) {
+	_, pub := newKeySigner(t)
+	fulcio := newTestFulcio(t)
+	str := func(s string) types.String {
+		if s == "" {
+			return types.StringNull()
+		}
+		return types.StringValue(s)
+	}
+	for _, c := range []struct {
+		key, caCert, identity, issuer, rekor string
+		wantErr                              string
+	}{
+		{key: pub},
+		{caCert: fulcio.pem, identity: "me@example.com", issuer: "https://accounts.example.com"},
+		{wantErr: "exactly one of key and ca_cert"},
+		{key: pub, caCert: fulcio.pem, wantErr: "exactly one of key and ca_cert"},
+		{key: pub, identity: "me@example.com", wantErr: "can only be set with ca_cert"},
+		{caCert: fulcio.pem, identity: "me@example.com", wantErr: "required with ca_cert"},
+		{key: "not a key", wantErr: "parsing key"},
+		{caCert: "not a cert", identity: "me@example.com", issuer: "https://accounts.example.com", wantErr: "no certificates"},
+		{caCert: fulcio.pem, identity: "me@example.com", issuer: "https://accounts.example.com", rekor: fulcio.rekor.pem},
+		{caCert: fulcio.pem, identity: "me@example.com", issuer: "https://accounts.example.com", rekor: "not a key", wantErr: "parsing Rekor public key"},
+		{key: pub, rekor: fulcio.rekor.pem, wantErr: "can only be set with ca_cert"},
+	} {
+		err := appendVerifyBase{Key: str(c.key), CACert: str(c.caCert), Identity: str(c.identity), Issuer: str(c.issuer), RekorPublicKey: str(c.rekor)}.validate()
+		if c.wantErr == "" && err != nil {
+			t.Errorf("validate(%+v): %v", c, err)
+		} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Errorf("validate(%+v) = %v, want %q", c, err, c.wantErr)
+		}
+	}
+}
+
+func TestAccAppendResource_VerifyBase(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	signer, pub := newKeySigner(t)
+	_, otherPub := newKeySigner(t)
+	base := pushRandomImage(t, repo)
+	signer.signImage(t, base)
+
+	config := func(key string) string {
+		return fmt.Sprintf(`resource "oci_append" "test" {
+		  base_image = %q
+		  layers = [{
+		    files = {
+		      "/usr/local/test.txt" = { contents = "hello world" }
+		    }
+		  }]
+		  verify_base = {
+		    key = %q
+		  }
+		}`, base, key)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config:      config(otherPub),
+			ExpectError: regexp.MustCompile(`Base image verification failed`),
+		}, {
+			Config: config(pub),
+			Check:  resource.TestMatchResourceAttr("oci_append.test", "image_ref", regexp.MustCompile(regexp.QuoteMeta(repo.String())+`@sha256:`)),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_append" "test" {
+			  base_image = %q
+			  layers = [{
+			    files = {
+			      "/usr/local/test.txt" = { contents = "hello world" }
+			    }
+			  }]
+			  verify_base = {
+			    key      = %q
+			    identity = "me@example.com"
+			  }
+			}`, base, pub),
+			ExpectError: regexp.MustCompile(`can only be set with ca_cert`),
+		}},
+	})
+}
+
+func mustGet(t *testing.T, ref name.Reference) *remote.Descriptor {
+	t.Helper()
+	desc, err := remote.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
@@ -0,0 +1,421 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+var (
+	_ resource.Resource                   = &ArtifactResource{}
+	_ resource.ResourceWithImportState    = &ArtifactResource{}
+	_ resource.ResourceWithValidateConfig = &ArtifactResource{}
+)
+
+const (
+	// emptyConfigMediaType is the media type of the empty config of artifacts
+	// that have no config of their own.
+	emptyConfigMediaType = "application/vnd.oci.empty.v1+json"
+	// defaultBlobMediaType is the media type of artifact files without one.
+	defaultBlobMediaType = "application/octet-stream"
+	// titleAnnotation names the file that a layer holds, as ORAS does.
+	titleAnnotation = "org.opencontainers.image.title"
+)
+
+func NewArtifactResource() resource.Resource {
+	return &ArtifactResource{}
+}
+
+// ArtifactResource defines the resource implementation.
+type ArtifactResource struct {
+	popts ProviderOpts
+}
+
+// ArtifactResourceModel describes the resource data model.
+type ArtifactResourceModel struct {
+	Id       types.String `tfsdk:"id"`
+	ImageRef types.String `tfsdk:"image_ref"`
+
+	Repo            types.String `tfsdk:"repo"`
+	Tag             types.String `tfsdk:"tag"`
+	ArtifactType    types.String `tfsdk:"artifact_type"`
+	ConfigMediaType types.String `tfsdk:"config_media_type"`
+	Config          types.String `tfsdk:"config"`
+	Files           types.List   `tfsdk:"files"`
+	Annotations     types.Map    `tfsdk:"annotations"`
+	Insecure        types.Bool   `tfsdk:"insecure"`
+}
+
+// artifactFile is a file of an artifact, with either literal Contents or the
+// contents of the local file at Path.
+type artifactFile struct {
+	Contents  types.String `tfsdk:"contents"`
+	Path      types.String `tfsdk:"path"`
+	MediaType types.String `tfsdk:"media_type"`
+	Title     types.String `tfsdk:"title"`
+}
+
+func (r *ArtifactResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_artifact"
+}
+
+func (r *ArtifactResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Push an [OCI artifact](https://github.com/opencontainers/image-spec/blob/main/manifest.md#guidelines-for-artifact-usage) that isn't an image, such as a WASM module, Helm chart or policy bundle, as a manifest with an `artifactType`, an optional config, and a layer for each file.",
+		Attributes: map[string]schema.Attribute{
+			"repo": schema.StringAttribute{
+				MarkdownDescription: "Repository to push the artifact to.",
+				Required:            true,
+				Validators:          []validator.String{validators.RepoValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"tag": schema.StringAttribute{
+				MarkdownDescription: "Tag to push the artifact to, in addition to its digest.",
+				Optional:            true,
+				Validators:          []validator.String{validators.TagValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"artifact_type": schema.StringAttribute{
+				MarkdownDescription: "Media type of the artifact, recorded as the manifest's `artifactType`, e.g. `application/vnd.wasm.config.v0+json`.",
+				Required:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"config_media_type": schema.StringAttribute{
+				MarkdownDescription: "Media type of `config`. Required if `config` is set.",
+				Optional:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"config": schema.StringAttribute{
+				MarkdownDescription: "Contents of the artifact's config. If unset, the config is the empty JSON object, with media type `" + emptyConfigMediaType + "`.",
+				Optional:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"files": schema.ListNestedAttribute{
+				MarkdownDescription: "Files of the artifact, each pushed as a layer of the manifest, in order.",
+				Required:            true,
+				PlanModifiers:       []planmodifier.List{listplanmodifier.RequiresReplace()},
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"contents": schema.StringAttribute{
+							MarkdownDescription: "Content of the file.",
+							Optional:            true,
+						},
+						"path": schema.StringAttribute{
+							MarkdownDescription: "Path to a local file. Exactly one of `contents` and `path` must be set.",
+							Optional:            true,
+						},
+						"media_type": schema.StringAttribute{
+							MarkdownDescription: "Media type of the file (default is `" + defaultBlobMediaType + "`).",
+							Optional:            true,
+						},
+						"title": schema.StringAttribute{
+							MarkdownDescription: "File name to record in the layer's `" + titleAnnotation + "` annotation. Defaults to the base name of `path`.",
+							Optional:            true,
+						},
+					},
+				},
+			},
+			"annotations": schema.MapAttribute{
+				MarkdownDescription: "Annotations to set on the manifest, keyed in reverse-DNS notation (e.g. `org.opencontainers.image.source`).",
+				Optional:            true,
+				ElementType:         basetypes.StringType{},
+				Validators:          []validator.Map{validators.LabelValidator{}},
+				PlanModifiers:       []planmodifier.Map{mapplanmodifier.RequiresReplace()},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"image_ref": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}@sha256:deadbeef).",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}@sha256:deadbeef).",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *ArtifactResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data *ArtifactResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !data.Config.IsNull() && data.ConfigMediaType.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("config_media_type"), "Missing config_media_type", "config_media_type is required if config is set")
+	}
+	if data.Files.IsUnknown() {
+		return
+	}
+	if len(data.Files.Elements()) == 0 {
+		resp.Diagnostics.AddAttributeError(path.Root("files"), "Missing files", "At least one file is required")
+	}
+	var files []artifactFile
+	resp.Diagnostics.Append(data.Files.ElementsAs(ctx, &files, true)...)
+	for i, f := range files {
+		if f.Contents.IsUnknown() || f.Path.IsUnknown() {
+			continue
+		}
+		if f.Contents.IsNull() == f.Path.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("files").AtListIndex(i), "Invalid file", fmt.Sprintf("Exactly one of contents and path must be set for file %d", i))
+		}
+	}
+}
+
+func (r *ArtifactResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	r.popts = *popts
+}
+
+func (r *ArtifactResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *ArtifactResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := r.doPush(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to push artifact", fmt.Sprintf("Unable to push artifact to %s, got error: %s", data.Repo.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ArtifactResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *ArtifactResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Check that the artifact is still there, without reading the files,
+	// which may have changed. If it's gone, it's pushed again.
+	ref := data.Id.ValueString()
+	d, err := name.NewDigest(ref, r.popts.nameOptions(ref, data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", ref, err))
+		return
+	}
+	err = retryTransient(ctx, func(ctx context.Context) error {
+		_, err := remote.Head(d, r.popts.withContext(ctx)...)
+		return err
+	})
+	if isNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("Unable to read artifact", fmt.Sprintf("Unable to read artifact %s, got error: %s", d, err))
+		return
+	}
+	data.ImageRef = data.Id
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ArtifactResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Everything but insecure requires replacement, so there's nothing to
+	// push.
+	var data *ArtifactResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ArtifactResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// TODO: optionally delete the artifact when the resource is deleted.
+	resp.Diagnostics.Append(req.State.Get(ctx, &ArtifactResourceModel{})...)
+}
+
+func (r *ArtifactResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+// artifactManifest is an OCI image manifest with an artifactType, which
+// v1.Manifest doesn't have.
+type artifactManifest struct {
+	SchemaVersion int64               `json:"schemaVersion"`
+	MediaType     ggcrtypes.MediaType `json:"mediaType"`
+	ArtifactType  string              `json:"artifactType,omitempty"`
+	Config        v1.Descriptor       `json:"config"`
+	Layers        []v1.Descriptor     `json:"layers"`
+	Annotations   map[string]string   `json:"annotations,omitempty"`
+}
+
+// rawManifest is a manifest to put, as is.
+type rawManifest struct {
+	raw       []byte
+	mediaType ggcrtypes.MediaType
+}
+
+func (m rawManifest) RawManifest() ([]byte, error)            { return m.raw, nil }
+func (m rawManifest) MediaType() (ggcrtypes.MediaType, error) { return m.mediaType, nil }
+
+func (r *ArtifactResource) doPush(ctx context.Context, data *ArtifactResourceModel) (name.Digest, error) {
+	repo, err := name.NewRepository(data.Repo.ValueString(), r.popts.nameOptions(data.Repo.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("parsing repo: %w", err)
+	}
+	var files []artifactFile
+	if diags := data.Files.ElementsAs(ctx, &files, false); diags.HasError() {
+		return name.Digest{}, fmt.Errorf("reading files: %s", diags.Errors()[0].Detail())
+	}
+	annotations := map[string]string{}
+	if diags := data.Annotations.ElementsAs(ctx, &annotations, false); diags.HasError() {
+		return name.Digest{}, fmt.Errorf("reading annotations: %s", diags.Errors()[0].Detail())
+	}
+	config := []byte("{}")
+	configType := ggcrtypes.MediaType(emptyConfigMediaType)
+	if !data.Config.IsNull() {
+		config, configType = []byte(data.Config.ValueString()), ggcrtypes.MediaType(data.ConfigMediaType.ValueString())
+	}
+	return r.popts.pushArtifact(ctx, repo, data.Tag.ValueString(), data.ArtifactType.ValueString(), configType, config, files, annotations)
+}
+
+// pushArtifact pushes an artifact of artifactType, with config and a layer for
+// each of files, to repo, and to tag if it isn't empty, and returns its
+// digest.
+func (p *ProviderOpts) pushArtifact(ctx context.Context, repo name.Repository, tag, artifactType string, configType ggcrtypes.MediaType, config []byte, files []artifactFile, annotations map[string]string) (name.Digest, error) {
+	cfg := static.NewLayer(config, configType)
+	cfgDesc, err := descriptorOf(cfg)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	blobs := []v1.Layer{cfg}
+	m := artifactManifest{
+		SchemaVersion: 2,
+		MediaType:     ggcrtypes.OCIManifestSchema1,
+		ArtifactType:  artifactType,
+		Config:        cfgDesc,
+		Layers:        []v1.Descriptor{},
+	}
+	if len(annotations) > 0 {
+		m.Annotations = annotations
+	}
+	if len(files) == 0 {
+		return name.Digest{}, errors.New("at least one file is required")
+	}
+	for i, f := range files {
+		var b []byte
+		title := f.Title.ValueString()
+		switch {
+		case !f.Contents.IsNull() && !f.Path.IsNull():
+			return name.Digest{}, fmt.Errorf("file %d sets both contents and path", i)
+		case !f.Contents.IsNull():
+			b = []byte(f.Contents.ValueString())
+		case !f.Path.IsNull():
+			if b, err = os.ReadFile(f.Path.ValueString()); err != nil {
+				return name.Digest{}, fmt.Errorf("reading file %d: %w", i, err)
+			}
+			if title == "" {
+				title = filepath.Base(f.Path.ValueString())
+			}
+		default:
+			return name.Digest{}, fmt.Errorf("file %d sets neither contents nor path", i)
+		}
+		mt := ggcrtypes.MediaType(defaultBlobMediaType)
+		if f.MediaType.ValueString() != "" {
+			mt = ggcrtypes.MediaType(f.MediaType.ValueString())
+		}
+		blob := static.NewLayer(b, mt)
+		desc, err := descriptorOf(blob)
+		if err != nil {
+			return name.Digest{}, err
+		}
+		if title != "" {
+			desc.Annotations = map[string]string{titleAnnotation: title}
+		}
+		blobs = append(blobs, blob)
+		m.Layers = append(m.Layers, desc)
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return name.Digest{}, err
+	}
+	d := repo.Digest(h.String())
+
+	for _, blob := range blobs {
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.WriteLayer(repo, blob, p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing blob: %w", err)
+		}
+	}
+	refs := []name.Reference{d}
+	if tag != "" {
+		refs = append(refs, repo.Tag(tag))
+	}
+	for _, ref := range refs {
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.Put(ref, rawManifest{raw: raw, mediaType: m.MediaType}, p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing manifest to %s: %w", ref, err)
+		}
+	}
+	return d, nil
+}
+
+// descriptorOf returns the descriptor of the blob l.
+func descriptorOf(l v1.Layer) (v1.Descriptor, error) {
+	h, err := l.Digest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	mt, err := l.MediaType()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return v1.Descriptor{MediaType: mt, Size: size, Digest: h}, nil
+}
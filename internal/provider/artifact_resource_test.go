@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestPushArtifact(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	wasm := filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(wasm, []byte("\x00asm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []artifactFile{{
+		Path:      types.StringValue(wasm),
+		MediaType: types.StringValue("application/vnd.wasm.content.layer.v1+wasm"),
+	}, {
+		Contents:  types.StringValue("hello"),
+		MediaType: types.StringNull(),
+		Title:     types.StringValue("hello.txt"),
+	}}
+	p := &ProviderOpts{}
+	d, err := p.pushArtifact(context.Background(), repo, "v1", "application/vnd.wasm.config.v0+json", emptyConfigMediaType, []byte("{}"), files, map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatalf("pushArtifact: %v", err)
+	}
+	// Pushing the same artifact again gives the same digest.
+	if again, err := p.pushArtifact(context.Background(), repo, "", "application/vnd.wasm.config.v0+json", emptyConfigMediaType, []byte("{}"), files, map[string]string{"foo": "bar"}); err != nil || again != d {
+		t.Errorf("pushArtifact again = %s, %v; want %s", again, err, d)
+	}
+
+	desc, err := remote.Get(repo.Tag("v1"))
+	if err != nil {
+		t.Fatalf("failed to get tag: %v", err)
+	}
+	if desc.Digest.String() != d.DigestStr() {
+		t.Errorf("tag is %s, want %s", desc.Digest, d.DigestStr())
+	}
+	if desc.MediaType != ggcrtypes.OCIManifestSchema1 {
+		t.Errorf("got media type %s", desc.MediaType)
+	}
+	var m artifactManifest
+	if err := json.Unmarshal(desc.Manifest, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != "application/vnd.wasm.config.v0+json" || m.Config.MediaType != emptyConfigMediaType || m.Config.Size != 2 {
+		t.Errorf("got manifest %s", desc.Manifest)
+	}
+	if m.Annotations["foo"] != "bar" {
+		t.Errorf("got annotations %v", m.Annotations)
+	}
+	if len(m.Layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(m.Layers))
+	}
+	for i, want := range []struct {
+		mt, title, contents string
+	}{
+		{"application/vnd.wasm.content.layer.v1+wasm", "module.wasm", "\x00asm"},
+		{defaultBlobMediaType, "hello.txt", "hello"},
+	} {
+		l := m.Layers[i]
+		if string(l.MediaType) != want.mt || l.Annotations[titleAnnotation] != want.title {
+			t.Errorf("layer %d = %+v, want %s titled %s", i, l, want.mt, want.title)
+		}
+		blob, err := remote.Layer(repo.Digest(l.Digest.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := blob.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want.contents {
+			t.Errorf("layer %d has contents %q, want %q", i, b, want.contents)
+		}
+	}
+
+	if _, err := p.pushArtifact(context.Background(), repo, "", "application/x", emptyConfigMediaType, []byte("{}"), nil, nil); err == nil {
+		t.Error("pushArtifact without files: want error")
+	}
+}
+
+func TestAccArtifactResource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_artifact" "test" {
+			  repo          = %q
+			  artifact_type = "application/vnd.example.policy.v1"
+			  config        = "{}"
+			  files = [{ contents = "package main" }]
+			}`, repo),
+			ExpectError: regexp.MustCompile(`config_media_type is required if config is set`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_artifact" "test" {
+			  repo          = %q
+			  artifact_type = "application/vnd.example.policy.v1"
+			  files = [{ contents = "package main", path = "main.rego" }]
+			}`, repo),
+			ExpectError: regexp.MustCompile(`Exactly one of contents and path must be set`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_artifact" "test" {
+			  repo          = %q
+			  artifact_type = "application/vnd.example.policy.v1"
+			  files         = [{ contents = "package main" }]
+			  annotations   = { "Not A Key" = "x" }
+			}`, repo),
+			ExpectError: regexp.MustCompile(`Invalid label key`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_artifact" "test" {
+			  repo              = %q
+			  tag               = "latest"
+			  artifact_type     = "application/vnd.example.policy.v1"
+			  config_media_type = "application/vnd.example.policy.config.v1+json"
+			  config            = jsonencode({ name = "main" })
+			  files = [{
+			    contents   = "package main"
+			    media_type = "application/vnd.example.policy.layer.v1.rego"
+			    title      = "main.rego"
+			  }]
+			}`, repo),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestMatchResourceAttr("oci_artifact.test", "image_ref", regexp.MustCompile(regexp.QuoteMeta(repo.String())+`@sha256:`)),
+				resource.TestCheckFunc(func(s *terraform.State) error {
+					ref := s.RootModule().Resources["oci_artifact.test"].Primary.Attributes["image_ref"]
+					desc, err := remote.Get(repo.Tag("latest"))
+					if err != nil {
+						return fmt.Errorf("failed to get tag: %v", err)
+					}
+					d, err := name.NewDigest(ref)
+					if err != nil {
+						return err
+					}
+					if desc.Digest.String() != d.DigestStr() {
+						return fmt.Errorf("tag is %s, want %s", desc.Digest, d.DigestStr())
+					}
+					return nil
+				}),
+			),
+		}, {
+			ResourceName:      "oci_artifact.test",
+			ImportState:       true,
+			ImportStateVerify: true,
+			ImportStateVerifyIgnore: []string{
+				"repo", "tag", "artifact_type", "config_media_type", "config", "files", "annotations",
+			},
+		}},
+	})
+}
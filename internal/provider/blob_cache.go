@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+)
+
+// blobCache is an on-disk layer cache that evicts the least recently used
+// blobs once the cache grows past maxBytes.
+type blobCache struct {
+	cache.Cache
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex
+}
+
+var _ cache.Cache = &blobCache{}
+
+// newBlobCache returns a layer cache in dir. If maxBytes is zero, the cache
+// grows without bound.
+func newBlobCache(dir string, maxBytes int64) *blobCache {
+	return &blobCache{
+		Cache:    cache.NewFilesystemCache(dir),
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+}
+
+// Get returns the cached layer with hash h, marking it as recently used.
+func (c *blobCache) Get(h v1.Hash) (v1.Layer, error) {
+	l, err := c.Cache.Get(h)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(c.dir, h.String()), now, now)
+	return l, nil
+}
+
+// Put returns a layer that is written to the cache as it's read, first making
+// room for it by evicting old blobs.
+func (c *blobCache) Put(l v1.Layer) (v1.Layer, error) {
+	if c.maxBytes > 0 {
+		size, err := l.Size()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.prune(c.maxBytes - size); err != nil {
+			return nil, err
+		}
+	}
+	return c.Cache.Put(l)
+}
+
+// getBlob returns the contents of the cached blob with hash h, marking it as
+// recently used. Blobs are named by the hash of their contents, so these may
+// be a cached layer's or a download's.
+func (c *blobCache) getBlob(h v1.Hash) ([]byte, error) {
+	p := filepath.Join(c.dir, h.String())
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return b, nil
+}
+
+// putBlob caches b, whose hash is h, first making room for it by evicting old
+// blobs.
+func (c *blobCache) putBlob(h v1.Hash, b []byte) error {
+	if c.maxBytes > 0 {
+		if err := c.prune(c.maxBytes - int64(len(b))); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	// Write and rename, so that a blob is never read half-written.
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(c.dir, h.String()))
+}
+
+// prune removes the least recently used blobs until the cache holds at most
+// target bytes.
+func (c *blobCache) prune(target int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	type blob struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var blobs []blob
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, blob{filepath.Join(c.dir, e.Name()), fi.Size(), fi.ModTime()})
+		total += fi.Size()
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].modTime.Before(blobs[j].modTime) })
+	for _, b := range blobs {
+		if total <= target {
+			break
+		}
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= b.size
+	}
+	return nil
+}
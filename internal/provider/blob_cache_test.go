@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/cache"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestBlobCache(t *testing.T) {
+	dir := t.TempDir()
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := cache.Image(img, newBlobCache(dir, 0)).Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range ls {
+		rc, err := l.Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, rc); err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d cached blobs, want 3", len(entries))
+	}
+
+	// Cached layers are served from disk.
+	d, err := ls[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newBlobCache(dir, 0).Get(d); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+
+	// Adding a layer to a full cache evicts old blobs to make room.
+	size, err := ls[0].Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := newBlobCache(dir, 2*size)
+	l, err := random.Layer(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := small.Put(l); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 1 {
+		t.Errorf("got %d cached blobs after pruning, want at most 1", len(entries))
+	}
+}
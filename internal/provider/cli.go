@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// NewCLIOpts returns provider options for running resource logic outside of
+// Terraform, as the check CLI does. Credentials come from the default
+// keychain, and insecureRegistries are accessed over plain HTTP.
+func NewCLIOpts(version string, insecureRegistries []string) *ProviderOpts {
+	opts := &ProviderOpts{
+		ropts: []remote.Option{
+			remote.WithAuthFromKeychain(authn.DefaultKeychain),
+			remote.WithTransport(retryAfterTransport{next: remote.DefaultTransport}),
+			remote.WithUserAgent(userAgent(version, "")),
+		},
+	}
+	if len(insecureRegistries) > 0 {
+		opts.insecureRegistries = map[string]bool{}
+		for _, r := range insecureRegistries {
+			opts.insecureRegistries[r] = true
+		}
+	}
+	return opts
+}
+
+// AppendFile is a file to add to a layer with Append, with either literal
+// Contents or the contents of the local file at Path.
+type AppendFile struct {
+	Contents string
+	Path     string
+}
+
+// Append appends a layer for each of layers, keyed by the path of each file in
+// the layer, to base as the oci_append resource does, and returns the digest
+// of the result.
+func (p *ProviderOpts) Append(ctx context.Context, base string, layers []map[string]AppendFile) (name.Digest, error) {
+	ls := make([]appendLayer, 0, len(layers))
+	for _, l := range layers {
+		files := make(map[string]appendFile, len(l))
+		for path, f := range l {
+			files[path] = appendFile{
+				Contents: types.StringValue(f.Contents),
+				Path:     types.StringValue(f.Path),
+			}
+		}
+		ls = append(ls, appendLayer{Files: files})
+	}
+	d, _, diags := p.appendLayers(ctx, base, false, ls, appendOptions{compressionLevel: gzip.DefaultCompression})
+	if err := diagsError(diags); err != nil {
+		return name.Digest{}, err
+	}
+	return *d, nil
+}
+
+// Tag points tag at digestRef as the oci_tag resource does, and returns the
+// tagged reference by digest.
+func (p *ProviderOpts) Tag(ctx context.Context, digestRef, tag string) (string, error) {
+	return p.tag(ctx, digestRef, tag, false, false)
+}
+
+// Copy copies the image or index src to dst, a repository or tag, and returns
+// the copy by digest.
+func (p *ProviderOpts) Copy(ctx context.Context, src, dst string) (name.Digest, error) {
+	srcRef, err := name.ParseReference(src, p.nameOptions(src, false)...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("parsing source: %w", err)
+	}
+	ropts := p.withContext(ctx)
+	desc, err := remote.Get(srcRef, ropts...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("fetching %s: %w", src, err)
+	}
+
+	// A repository destination is pushed by digest, and anything else (a tag)
+	// as is.
+	var dstRef name.Reference
+	if repo, err := name.NewRepository(dst, p.nameOptions(dst, false)...); err == nil {
+		dstRef = repo.Digest(desc.Digest.String())
+	} else if dstRef, err = name.NewTag(dst, p.nameOptions(dst, false)...); err != nil {
+		return name.Digest{}, fmt.Errorf("parsing destination: %w", err)
+	}
+
+	switch {
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return name.Digest{}, fmt.Errorf("reading index %s: %w", src, err)
+		}
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.WriteIndex(dstRef, p.cacheIndex(idx), p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing index: %w", err)
+		}
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return name.Digest{}, fmt.Errorf("reading image %s: %w", src, err)
+		}
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.Write(dstRef, p.cacheImage(img), p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing image: %w", err)
+		}
+	default:
+		return name.Digest{}, fmt.Errorf("unsupported media type %s", desc.MediaType)
+	}
+	return dstRef.Context().Digest(desc.Digest.String()), nil
+}
+
+// diagsError returns the errors in diags as an error, or nil if there are none.
+func diagsError(diags diag.Diagnostics) error {
+	var errs []error
+	for _, d := range diags.Errors() {
+		errs = append(errs, fmt.Errorf("%s: %s", d.Summary(), d.Detail()))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCLIOpts(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	idx, err := random.Index(100, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	base := repo.Tag("base")
+	if err := remote.WriteIndex(base, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	opts := NewCLIOpts("test", nil)
+
+	appended, err := opts.Append(ctx, base.String(), []map[string]AppendFile{{
+		"/usr/local/test.txt": {Contents: "hello"},
+	}})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	got, err := remote.Index(appended)
+	if err != nil {
+		t.Fatalf("failed to fetch appended index: %v", err)
+	}
+	im, err := got.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range im.Manifests {
+		img, err := got.Image(m.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(cf.History); n == 0 || cf.History[n-1].CreatedBy != "terraform-provider-oci: oci_append" {
+			t.Errorf("got history %+v, want an oci_append entry", cf.History)
+		}
+	}
+
+	tagged, err := opts.Tag(ctx, appended.String(), "appended")
+	if err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if want := repo.Tag("appended").String() + "@" + appended.DigestStr(); tagged != want {
+		t.Errorf("Tag: got %q, want %q", tagged, want)
+	}
+
+	dst, err := name.NewRepository(repo.RegistryStr() + "/copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{dst.String(), dst.Tag("copied").String()} {
+		copied, err := opts.Copy(ctx, tagged, to)
+		if err != nil {
+			t.Fatalf("Copy(%q): %v", to, err)
+		}
+		if copied.String() != dst.Digest(appended.DigestStr()).String() {
+			t.Errorf("Copy(%q): got %s, want %s", to, copied, dst.Digest(appended.DigestStr()))
+		}
+		if _, err := remote.Head(copied); err != nil {
+			t.Errorf("Copy(%q): copy not found: %v", to, err)
+		}
+	}
+	if _, err := remote.Head(dst.Tag("copied")); err != nil {
+		t.Errorf("copied tag not found: %v", err)
+	}
+
+	if _, err := opts.Append(ctx, base.String(), []map[string]AppendFile{{"/empty": {}}}); err == nil || !strings.Contains(err.Error(), "No file contents or path specified") {
+		t.Errorf("Append: got error %v, want missing contents error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &ConfigJSONFunction{}
+
+// NewConfigJSONFunction returns the config_json function, which fetches image
+// configs with the options returned by popts.
+func NewConfigJSONFunction(popts func() *ProviderOpts) function.Function {
+	return &ConfigJSONFunction{popts: popts}
+}
+
+// ConfigJSONFunction defines the function implementation.
+type ConfigJSONFunction struct {
+	popts func() *ProviderOpts
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *ConfigJSONFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "config_json"
+}
+
+// Definition should return the definition for the function.
+func (s *ConfigJSONFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Fetches the raw config of an OCI image.",
+		Description: "Returns the image config blob exactly as served by the registry, as a JSON string for use with `jsondecode`. The reference must be an image, not an index.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string to fetch.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *ConfigJSONFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	popts := s.popts()
+	ref, err := name.ParseReference(input, popts.nameOptions(input, false)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	desc, err := remote.Get(ref, popts.withContext(ctx)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to get image: %v", err))
+		return
+	}
+	if !desc.MediaType.IsImage() {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Reference %s is a %s, not an image", ref, desc.MediaType))
+		return
+	}
+	img, err := desc.Image()
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse image: %v", err))
+		return
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to get config: %v", err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, string(cfg)))
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestConfigJSONFunction(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	ref := repo.Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	img, err = mutate.Config(img, v1.Config{User: "nobody"})
+	if err != nil {
+		t.Fatalf("failed to mutate image: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	idx, err := random.Index(1024, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	if err := remote.WriteIndex(repo.Tag("index"), idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`output "user" { value = jsondecode(provider::oci::config_json(%q)).config.User }`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("user", knownvalue.StringExact("nobody")),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "config" { value = provider::oci::config_json(%q) }`, repo.Tag("index")),
+			ExpectError: regexp.MustCompile("not an image"),
+		}},
+	})
+}
@@ -0,0 +1,546 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Annotations that cosign sets on the layers of signature and attestation
+// images.
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// Extensions of Fulcio certificates holding the OIDC issuer of the identity
+// they were issued to: the deprecated raw string, and its DER-encoded successor.
+var (
+	fulcioIssuerV1OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	fulcioIssuerV2OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// cosignEntry is a signature or attestation that cosign attached to an image.
+type cosignEntry struct {
+	// payload is what was signed: a simple signing payload for signatures,
+	// or a DSSE envelope for attestations.
+	payload []byte
+	// sig is the signature of a signature's payload. Attestations carry
+	// their signatures in their envelope.
+	sig []byte
+	// cert and chain are the signing certificate and its chain, for keyless
+	// signatures.
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+	// bundle is the transparency log's promise that the entry was logged,
+	// if it has one.
+	bundle *rekorBundle
+}
+
+// rekorBundle is the bundle that cosign attaches to entries it uploaded to a
+// Rekor transparency log: the log's signed entry timestamp (SET) over the
+// payload, which describes the logged entry.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// cosignTag returns the tag that cosign attaches d's signatures ("sig") or
+// attestations ("att") to.
+func cosignTag(d name.Digest, suffix string) name.Tag {
+	return d.Context().Tag(strings.Replace(d.DigestStr(), ":", "-", 1) + "." + suffix)
+}
+
+// cosignEntries returns the entries that cosign attached to d with suffix, or
+// none if there aren't any.
+func (p *ProviderOpts) cosignEntries(ctx context.Context, d name.Digest, suffix string) ([]cosignEntry, error) {
+	t := cosignTag(d, suffix)
+	img, err := remote.Image(t, p.withContext(ctx)...)
+	if isNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", t, err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest of %s: %w", t, err)
+	}
+	var entries []cosignEntry
+	for _, desc := range m.Layers {
+		l, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s of %s: %w", desc.Digest, t, err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s of %s: %w", desc.Digest, t, err)
+		}
+		payload, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s of %s: %w", desc.Digest, t, err)
+		}
+		e, err := newCosignEntry(payload, desc.Annotations)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s of %s: %w", desc.Digest, t, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// newCosignEntry returns the entry with payload, described by the annotations
+// of its layer.
+func newCosignEntry(payload []byte, annotations map[string]string) (cosignEntry, error) {
+	e := cosignEntry{payload: payload}
+	if s, ok := annotations[cosignSignatureAnnotation]; ok && s != "" {
+		sig, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return e, fmt.Errorf("decoding signature: %w", err)
+		}
+		e.sig = sig
+	}
+	if s := annotations[cosignCertificateAnnotation]; s != "" {
+		certs, err := parseCertificates([]byte(s))
+		if err != nil || len(certs) == 0 {
+			return e, fmt.Errorf("parsing certificate: %v", err)
+		}
+		e.cert = certs[0]
+	}
+	if s := annotations[cosignChainAnnotation]; s != "" {
+		chain, err := parseCertificates([]byte(s))
+		if err != nil {
+			return e, fmt.Errorf("parsing certificate chain: %w", err)
+		}
+		e.chain = chain
+	}
+	if s := annotations[cosignBundleAnnotation]; s != "" {
+		e.bundle = &rekorBundle{}
+		if err := json.Unmarshal([]byte(s), e.bundle); err != nil {
+			return e, fmt.Errorf("parsing bundle: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// parseCertificates parses the PEM-encoded certificates in b.
+func parseCertificates(b []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// parsePublicKey parses a PEM-encoded public key.
+func parsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM-encoded key found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// cosignIdentity is an identity that keyless signatures may be issued to.
+// Each of the issuer and subject is matched exactly, or by a regexp.
+type cosignIdentity struct {
+	Issuer        string `yaml:"issuer"`
+	Subject       string `yaml:"subject"`
+	IssuerRegExp  string `yaml:"issuerRegExp"`
+	SubjectRegExp string `yaml:"subjectRegExp"`
+}
+
+func (id cosignIdentity) matches(issuer string, subjects []string) (bool, error) {
+	ok, err := matchIdentity(id.Issuer, id.IssuerRegExp, []string{issuer})
+	if err != nil || !ok {
+		return false, err
+	}
+	return matchIdentity(id.Subject, id.SubjectRegExp, subjects)
+}
+
+func matchIdentity(exact, re string, got []string) (bool, error) {
+	if exact != "" {
+		return slices.Contains(got, exact), nil
+	}
+	if re == "" {
+		return true, nil
+	}
+	r, err := regexp.Compile(re)
+	if err != nil {
+		return false, fmt.Errorf("invalid regexp %q: %w", re, err)
+	}
+	return slices.ContainsFunc(got, r.MatchString), nil
+}
+
+// defaultRekorPublicKey is the public key of the public-good Rekor instance
+// at rekor.sigstore.dev.
+const defaultRekorPublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2G2Y+2tabdTV5BcGiBIx0a9fAFwr
+kBbmLSGtks4L3qX6yYY0zufBnhC8Ur/iy55GhWP/9A/bY2LhC30M9+RYtw==
+-----END PUBLIC KEY-----
+`
+
+// cosignTlog is the transparency log that keyless entries must have been
+// logged in. The zero value is the public-good Rekor instance.
+type cosignTlog struct {
+	key crypto.PublicKey
+	// insecureIgnore trusts the time in an entry's bundle without checking
+	// that the log signed it.
+	insecureIgnore bool
+}
+
+// newCosignTlog returns the log with the PEM-encoded public key, or the
+// public-good instance if it's empty.
+func newCosignTlog(key string, insecureIgnore bool) (cosignTlog, error) {
+	if key == "" {
+		return cosignTlog{insecureIgnore: insecureIgnore}, nil
+	}
+	k, err := parsePublicKey([]byte(key))
+	if err != nil {
+		return cosignTlog{}, fmt.Errorf("parsing Rekor public key: %w", err)
+	}
+	if _, ok := k.(*ecdsa.PublicKey); !ok {
+		return cosignTlog{}, fmt.Errorf("unsupported Rekor key type %T", k)
+	}
+	return cosignTlog{key: k, insecureIgnore: insecureIgnore}, nil
+}
+
+// verifyBundle checks that e's bundle is the log's promise that e, with
+// signature sig of msg, was logged, and returns when it was logged.
+func (l cosignTlog) verifyBundle(e cosignEntry, msg, sig []byte) (time.Time, error) {
+	b := e.bundle
+	if b == nil {
+		return time.Time{}, errors.New("keyless entry has no transparency log bundle")
+	}
+	integratedAt := time.Unix(b.Payload.IntegratedTime, 0)
+	if l.insecureIgnore {
+		return integratedAt, nil
+	}
+
+	key := l.key
+	if key == nil {
+		k, err := parsePublicKey([]byte(defaultRekorPublicKey))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing Rekor public key: %w", err)
+		}
+		key = k
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("marshaling Rekor public key: %w", err)
+	}
+	if id := sha256.Sum256(der); b.Payload.LogID != hex.EncodeToString(id[:]) {
+		return time.Time{}, fmt.Errorf("bundle is from log %q, not the trusted log", b.Payload.LogID)
+	}
+	// The SET signs the canonical JSON of the payload: sorted keys, no
+	// whitespace and no HTML escaping.
+	var canonical bytes.Buffer
+	enc := json.NewEncoder(&canonical)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(map[string]any{
+		"body":           b.Payload.Body,
+		"integratedTime": b.Payload.IntegratedTime,
+		"logIndex":       b.Payload.LogIndex,
+		"logID":          b.Payload.LogID,
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("encoding bundle payload: %w", err)
+	}
+	if err := verifySignature(key, crypto.SHA256, bytes.TrimSuffix(canonical.Bytes(), []byte("\n")), b.SignedEntryTimestamp); err != nil {
+		return time.Time{}, fmt.Errorf("verifying bundle: %w", err)
+	}
+
+	// The logged entry must be this one: by this certificate, of this
+	// payload or message, or with this signature.
+	body, err := base64.StdEncoding.DecodeString(b.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding bundle body: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return time.Time{}, fmt.Errorf("parsing bundle body: %w", err)
+	}
+	payloadHash, msgHash := sha256.Sum256(e.payload), sha256.Sum256(msg)
+	sigB64 := base64.StdEncoding.EncodeToString(sig)
+	var hasCert, hasEntry bool
+	for _, s := range jsonStrings(v) {
+		switch s {
+		case hex.EncodeToString(payloadHash[:]), hex.EncodeToString(msgHash[:]), sigB64:
+			hasEntry = true
+			continue
+		}
+		if pemCert, err := base64.StdEncoding.DecodeString(s); err == nil {
+			certs, err := parseCertificates(pemCert)
+			hasCert = hasCert || (err == nil && len(certs) > 0 && certs[0].Equal(e.cert))
+		}
+	}
+	if !hasCert || !hasEntry {
+		return time.Time{}, errors.New("bundle is for another entry")
+	}
+	return integratedAt, nil
+}
+
+// jsonStrings returns the strings in the decoded JSON value v.
+func jsonStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var ss []string
+		for _, e := range v {
+			ss = append(ss, jsonStrings(e)...)
+		}
+		return ss
+	case map[string]any:
+		var ss []string
+		for _, e := range v {
+			ss = append(ss, jsonStrings(e)...)
+		}
+		return ss
+	}
+	return nil
+}
+
+// cosignVerifier verifies cosign entries, either with a public key, or with a
+// certificate issued to one of identities by roots and logged in tlog.
+type cosignVerifier struct {
+	key  crypto.PublicKey
+	hash crypto.Hash
+
+	roots      *x509.CertPool
+	identities []cosignIdentity
+	tlog       cosignTlog
+}
+
+// verify checks that sig is a signature of msg by an entry's signer.
+//
+// For keyless entries, the certificate is checked at the time the
+// transparency log promises the entry was logged, since Fulcio certificates
+// only last minutes.
+func (v cosignVerifier) verify(e cosignEntry, msg, sig []byte) error {
+	if v.key != nil {
+		return verifySignature(v.key, v.hash, msg, sig)
+	}
+	if e.cert == nil {
+		return errors.New("entry has no certificate")
+	}
+	signedAt, err := v.tlog.verifyBundle(e, msg, sig)
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range e.chain {
+		intermediates.AddCert(c)
+	}
+	if _, err := e.cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("verifying certificate: %w", err)
+	}
+	issuer, err := certIssuer(e.cert)
+	if err != nil {
+		return err
+	}
+	subjects := slices.Clone(e.cert.EmailAddresses)
+	for _, u := range e.cert.URIs {
+		subjects = append(subjects, u.String())
+	}
+	matched := len(v.identities) == 0
+	for _, id := range v.identities {
+		ok, err := id.matches(issuer, subjects)
+		if err != nil {
+			return err
+		}
+		matched = matched || ok
+	}
+	if !matched {
+		return fmt.Errorf("certificate for %v from issuer %q doesn't match any identity", subjects, issuer)
+	}
+	return verifySignature(e.cert.PublicKey, crypto.SHA256, msg, sig)
+}
+
+// certIssuer returns the OIDC issuer of a Fulcio certificate.
+func certIssuer(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerV2OID):
+			var s string
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &s, "utf8"); err != nil {
+				return "", fmt.Errorf("parsing certificate issuer: %w", err)
+			}
+			return s, nil
+		case ext.Id.Equal(fulcioIssuerV1OID):
+			return string(ext.Value), nil
+		}
+	}
+	return "", errors.New("certificate has no OIDC issuer")
+}
+
+// verifySignature checks that sig is key's signature of msg, hashed with h
+// unless key is Ed25519.
+func verifySignature(key crypto.PublicKey, h crypto.Hash, msg, sig []byte) error {
+	if h == 0 {
+		h = crypto.SHA256
+	}
+	if k, ok := key.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(k, msg, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	hh := h.New()
+	hh.Write(msg)
+	digest := hh.Sum(nil)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, h, digest, sig); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// verifySignatureEntry checks that e is a signature of d by v's signer.
+func verifySignatureEntry(v cosignVerifier, e cosignEntry, d name.Digest) error {
+	var payload struct {
+		Critical struct {
+			Image struct {
+				Digest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(e.payload, &payload); err != nil {
+		return fmt.Errorf("parsing signature payload: %w", err)
+	}
+	if payload.Critical.Image.Digest != d.DigestStr() {
+		return fmt.Errorf("signature is for %s", payload.Critical.Image.Digest)
+	}
+	return v.verify(e, e.payload, e.sig)
+}
+
+// verifyAnySignature checks that at least one of sigs is a signature of d by
+// v's signer.
+func verifyAnySignature(v cosignVerifier, sigs []cosignEntry, d name.Digest) error {
+	if len(sigs) == 0 {
+		return errors.New("no signatures found")
+	}
+	var errs []error
+	for _, e := range sigs {
+		err := verifySignatureEntry(v, e, d)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("no valid signatures found: %w", errors.Join(errs...))
+}
+
+// verifyAttestationEntry checks that e is an attestation of d by v's signer,
+// and returns its predicate type.
+func verifyAttestationEntry(v cosignVerifier, e cosignEntry, d name.Digest) (string, error) {
+	var env struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+	if err := json.Unmarshal(e.payload, &env); err != nil {
+		return "", fmt.Errorf("parsing attestation envelope: %w", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return "", fmt.Errorf("decoding attestation payload: %w", err)
+	}
+	// DSSE signs the pre-authentication encoding of the payload.
+	var pae bytes.Buffer
+	fmt.Fprintf(&pae, "DSSEv1 %d %s %d ", len(env.PayloadType), env.PayloadType, len(body))
+	pae.Write(body)
+	var errs []error
+	verified := false
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("decoding attestation signature: %w", err))
+			continue
+		}
+		if err := v.verify(e, pae.Bytes(), sig); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		verified = true
+		break
+	}
+	if !verified {
+		if len(errs) == 0 {
+			return "", errors.New("attestation has no signatures")
+		}
+		return "", errors.Join(errs...)
+	}
+
+	var statement struct {
+		PredicateType string `json:"predicateType"`
+		Subject       []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if err := json.Unmarshal(body, &statement); err != nil {
+		return "", fmt.Errorf("parsing attestation statement: %w", err)
+	}
+	alg, hex, _ := strings.Cut(d.DigestStr(), ":")
+	about := false
+	for _, s := range statement.Subject {
+		about = about || s.Digest[alg] == hex
+	}
+	if !about {
+		return "", fmt.Errorf("attestation isn't about %s", d.DigestStr())
+	}
+	return statement.PredicateType, nil
+}
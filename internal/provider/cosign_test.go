@@ -0,0 +1,400 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// testSigner signs like cosign, with a key, or with a certificate from a test
+// Fulcio.
+type testSigner struct {
+	key *ecdsa.PrivateKey
+	// annotations are added to each signature and attestation, e.g. the
+	// certificate of keyless signers.
+	annotations map[string]string
+	// rekor logs the entries of keyless signers at signedAt, and bundles
+	// its promise with each.
+	rekor    *testRekor
+	signedAt time.Time
+}
+
+func newKeySigner(t *testing.T) (testSigner, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{key: key}, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// testRekor is a transparency log for keyless test signers, which signs
+// bundles but doesn't keep a log.
+type testRekor struct {
+	key *ecdsa.PrivateKey
+	// pem is the log's public key, for rekor_public_key.
+	pem string
+}
+
+func newTestRekor(t *testing.T) *testRekor {
+	t.Helper()
+	s, pub := newKeySigner(t)
+	return &testRekor{key: s.key, pem: pub}
+}
+
+// bundle returns a bundle, as cosign annotates entries with, that promises
+// that the signature sig of payload with the PEM-encoded cert was logged at
+// integratedTime.
+func (r *testRekor) bundle(t *testing.T, payload, sig []byte, cert string, integratedTime time.Time) string {
+	t.Helper()
+	h := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data": map[string]any{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(h[:])}},
+			"signature": map[string]any{
+				"content":   base64.StdEncoding.EncodeToString(sig),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(cert))},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&r.key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := sha256.Sum256(der)
+	bp := map[string]any{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": integratedTime.Unix(),
+		"logIndex":       1,
+		"logID":          hex.EncodeToString(logID[:]),
+	}
+	// Like Rekor, sign the canonical JSON of the payload.
+	canonical, err := json.Marshal(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]any{
+		"SignedEntryTimestamp": testSigner{key: r.key}.sign(t, canonical),
+		"Payload":              bp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// testFulcio is a certificate authority for keyless test signers, which log
+// their entries in rekor.
+type testFulcio struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	// pem is the root certificate, for a policy's ca-cert.
+	pem   string
+	rekor *testRekor
+}
+
+func newTestFulcio(t *testing.T) testFulcio {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testFulcio{key: key, cert: cert, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), rekor: newTestRekor(t)}
+}
+
+// signer returns a keyless signer for subject, an email or URI, from issuer,
+// with a 10 minute certificate that expired an hour ago, as if it signed then.
+func (f testFulcio) signer(t *testing.T, subject, issuer string) testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerExt, err := asn1.MarshalWithParams(issuer, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedAt := time.Now().Add(-65 * time.Minute)
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-5 * time.Minute),
+		NotAfter:        signedAt.Add(5 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: fulcioIssuerV2OID, Value: issuerExt}},
+	}
+	if u, err := url.Parse(subject); err == nil && u.Scheme != "" {
+		tmpl.URIs = []*url.URL{u}
+	} else {
+		tmpl.EmailAddresses = []string{subject}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, f.cert, &key.PublicKey, f.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{key: key, annotations: map[string]string{
+		cosignCertificateAnnotation: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}, rekor: f.rekor, signedAt: signedAt}
+}
+
+func (s testSigner) sign(t *testing.T, msg []byte) []byte {
+	t.Helper()
+	h := sha256.Sum256(msg)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// entryAnnotations returns the annotations of an entry with payload and
+// signature sig by s, with a bundle if s is keyless.
+func (s testSigner) entryAnnotations(t *testing.T, payload, sig []byte) map[string]string {
+	t.Helper()
+	annotations := map[string]string{}
+	for k, v := range s.annotations {
+		annotations[k] = v
+	}
+	if s.rekor != nil {
+		annotations[cosignBundleAnnotation] = s.rekor.bundle(t, payload, sig, s.annotations[cosignCertificateAnnotation], s.signedAt)
+	}
+	return annotations
+}
+
+// attach appends a layer with payload and annotations to the image at d's
+// cosign tag with suffix, creating it if need be.
+func attach(t *testing.T, d name.Digest, suffix string, payload []byte, mt ggcrtypes.MediaType, annotations map[string]string) {
+	t.Helper()
+	tag := cosignTag(d, suffix)
+	var img v1.Image = empty.Image
+	if existing, err := remote.Image(tag); err == nil {
+		img = existing
+	}
+	img, err := mutate.Append(img, mutate.Addendum{Layer: static.NewLayer(payload, mt), Annotations: annotations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signImage attaches a cosign signature of d by s.
+func (s testSigner) signImage(t *testing.T, d name.Digest) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, d.Context().Name(), d.DigestStr()))
+	sig := s.sign(t, payload)
+	annotations := s.entryAnnotations(t, payload, sig)
+	annotations[cosignSignatureAnnotation] = base64.StdEncoding.EncodeToString(sig)
+	attach(t, d, "sig", payload, "application/vnd.dev.cosign.simplesigning.v1+json", annotations)
+}
+
+// attestImage attaches a cosign attestation of d by s, with predicate.
+func (s testSigner) attestImage(t *testing.T, d name.Digest, predicateType string, predicate any) {
+	t.Helper()
+	alg, hex, _ := strings.Cut(d.DigestStr(), ":")
+	statement, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": predicateType,
+		"subject":       []map[string]any{{"name": d.Context().Name(), "digest": map[string]string{alg: hex}}},
+		"predicate":     predicate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const payloadType = "application/vnd.in-toto+json"
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(statement), statement)
+	sig := s.sign(t, []byte(pae))
+	env, err := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := s.entryAnnotations(t, env, sig)
+	annotations["predicateType"] = predicateType
+	attach(t, d, "att", env, "application/vnd.dsse.envelope.v1+json", annotations)
+}
+
+// pushRandomImage pushes a random image to repo and returns its digest.
+func pushRandomImage(t *testing.T, repo name.Repository) name.Digest {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := repo.Digest(dig.String())
+	if err := remote.Write(d, img); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestVerifySignature(t *testing.T) {
+	signer, pub := newKeySigner(t)
+	key, err := parsePublicKey([]byte(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := newKeySigner(t)
+
+	msg := []byte("hello")
+	if err := verifySignature(key, crypto.SHA256, msg, signer.sign(t, msg)); err != nil {
+		t.Errorf("verifySignature: %v", err)
+	}
+	if err := verifySignature(key, crypto.SHA256, []byte("goodbye"), signer.sign(t, msg)); err == nil {
+		t.Error("verifySignature of another message: want error")
+	}
+	if err := verifySignature(key, crypto.SHA256, msg, other.sign(t, msg)); err == nil {
+		t.Error("verifySignature by another key: want error")
+	}
+}
+
+func TestCosignIdentity(t *testing.T) {
+	for _, c := range []struct {
+		id   cosignIdentity
+		want bool
+	}{
+		{cosignIdentity{Issuer: "https://accounts.example.com", Subject: "me@example.com"}, true},
+		{cosignIdentity{Issuer: "https://accounts.example.com", Subject: "you@example.com"}, false},
+		{cosignIdentity{IssuerRegExp: `^https://accounts\.`, SubjectRegExp: `@example\.com$`}, true},
+		{cosignIdentity{Issuer: "https://token.example.com"}, false},
+		{cosignIdentity{}, true},
+	} {
+		got, err := c.id.matches("https://accounts.example.com", []string{"me@example.com"})
+		if err != nil {
+			t.Errorf("%+v: %v", c.id, err)
+		}
+		if got != c.want {
+			t.Errorf("%+v matches = %t, want %t", c.id, got, c.want)
+		}
+	}
+	if _, err := (cosignIdentity{SubjectRegExp: "("}).matches("", nil); err == nil {
+		t.Error("invalid regexp: want error")
+	}
+}
+
+func TestVerifyBundle(t *testing.T) {
+	fulcio := newTestFulcio(t)
+	signer := fulcio.signer(t, "me@example.com", "https://accounts.example.com")
+	tlog := cosignTlog{key: &fulcio.rekor.key.PublicKey}
+
+	msg := []byte("hello")
+	sig := signer.sign(t, msg)
+	entry := func(t *testing.T, bundle string) cosignEntry {
+		t.Helper()
+		annotations := signer.entryAnnotations(t, msg, sig)
+		annotations[cosignBundleAnnotation] = bundle
+		if bundle == "" {
+			delete(annotations, cosignBundleAnnotation)
+		}
+		e, err := newCosignEntry(msg, annotations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	valid := fulcio.rekor.bundle(t, msg, sig, signer.annotations[cosignCertificateAnnotation], signer.signedAt)
+	var tampered map[string]any
+	if err := json.Unmarshal([]byte(valid), &tampered); err != nil {
+		t.Fatal(err)
+	}
+	tampered["Payload"].(map[string]any)["integratedTime"] = time.Now().Unix()
+	forged, err := json.Marshal(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc    string
+		bundle  string
+		tlog    cosignTlog
+		wantErr string
+	}{
+		{desc: "valid", bundle: valid, tlog: tlog},
+		{desc: "no bundle", tlog: tlog, wantErr: "no transparency log bundle"},
+		{desc: "untrusted log", bundle: valid, wantErr: "not the trusted log"},
+		{desc: "forged time", bundle: string(forged), tlog: tlog, wantErr: "verifying bundle"},
+		{desc: "another entry", bundle: fulcio.rekor.bundle(t, []byte("goodbye"), sig, "", signer.signedAt), tlog: tlog, wantErr: "bundle is for another entry"},
+		{desc: "insecure ignore", bundle: valid, tlog: cosignTlog{insecureIgnore: true}},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := c.tlog.verifyBundle(entry(t, c.bundle), msg, sig)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Errorf("got error %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyBundle: %v", err)
+			}
+			if !got.Equal(signer.signedAt.Truncate(time.Second)) {
+				t.Errorf("got time %s, want %s", got, signer.signedAt)
+			}
+		})
+	}
+
+	if _, err := newCosignTlog(fulcio.rekor.pem, false); err != nil {
+		t.Errorf("newCosignTlog: %v", err)
+	}
+	if _, err := newCosignTlog("not a key", false); err == nil {
+		t.Error("newCosignTlog of an invalid key: want error")
+	}
+}
@@ -0,0 +1,309 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ resource.Resource = &CreatedAtResource{}
+
+// defaultCreatedAt is the time images are normalized to when created_at isn't
+// set, the Unix epoch, as SOURCE_DATE_EPOCH=0 builds use.
+const defaultCreatedAt = "1970-01-01T00:00:00Z"
+
+func NewCreatedAtResource() resource.Resource {
+	return &CreatedAtResource{}
+}
+
+// CreatedAtResource defines the resource implementation.
+type CreatedAtResource struct {
+	popts ProviderOpts
+}
+
+// CreatedAtResourceModel describes the resource data model.
+type CreatedAtResourceModel struct {
+	Id       types.String `tfsdk:"id"`
+	ImageRef types.String `tfsdk:"image_ref"`
+
+	BaseImage types.String `tfsdk:"base_image"`
+	CreatedAt types.String `tfsdk:"created_at"`
+	Insecure  types.Bool   `tfsdk:"insecure"`
+}
+
+func (r *CreatedAtResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_created_at"
+}
+
+func (r *CreatedAtResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Set the `created` time, and the time of every history entry, in an image's config to a fixed time, and push the normalized image, so that third-party images can be reproducible inputs. For an index, each image in it is normalized, and attestation manifests are pointed at the normalized images. Layers aren't changed.",
+		Attributes: map[string]schema.Attribute{
+			"base_image": schema.StringAttribute{
+				MarkdownDescription: "Image or index ref by digest to normalize.",
+				Required:            true,
+				Validators:          []validator.String{validators.DigestValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"created_at": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("Time to set, in RFC 3339 format (default `%s`).", defaultCreatedAt),
+				Optional:            true,
+				Computed:            true,
+				Default:             stringdefault.StaticString(defaultCreatedAt),
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"image_ref": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified image ref by digest (e.g. {repo}@sha256:deadbeef), in the repository of `base_image`.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified image ref by digest (e.g. {repo}@sha256:deadbeef), in the repository of `base_image`.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *CreatedAtResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	r.popts = *popts
+}
+
+func (r *CreatedAtResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *CreatedAtResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := r.doNormalize(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to normalize image", fmt.Sprintf("Unable to normalize image %s, got error: %s", data.BaseImage.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *CreatedAtResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *CreatedAtResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Normalizing is deterministic, so this pushes the same image again if
+	// it was deleted, like oci_append does.
+	d, err := r.doNormalize(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to normalize image", fmt.Sprintf("Unable to normalize image %s, got error: %s", data.BaseImage.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *CreatedAtResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *CreatedAtResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := r.doNormalize(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to normalize image", fmt.Sprintf("Unable to normalize image %s, got error: %s", data.BaseImage.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *CreatedAtResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	resp.Diagnostics.Append(req.State.Get(ctx, &CreatedAtResourceModel{})...)
+}
+
+func (r *CreatedAtResource) doNormalize(ctx context.Context, data *CreatedAtResourceModel) (name.Digest, error) {
+	ref, err := name.NewDigest(data.BaseImage.ValueString(), r.popts.nameOptions(data.BaseImage.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("invalid base image: %w", err)
+	}
+	createdAt := defaultCreatedAt
+	if !data.CreatedAt.IsNull() && !data.CreatedAt.IsUnknown() {
+		createdAt = data.CreatedAt.ValueString()
+	}
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("invalid created_at: %w", err)
+	}
+	return r.popts.normalizeCreated(ctx, ref, t)
+}
+
+// normalizeCreated pushes a copy of the image or index ref with the created
+// times of its config and history set to t, to the same repository, and
+// returns its ref by digest.
+func (p *ProviderOpts) normalizeCreated(ctx context.Context, ref name.Digest, t time.Time) (name.Digest, error) {
+	var desc *remote.Descriptor
+	if err := retryTransient(ctx, func(ctx context.Context) (err error) {
+		desc, err = remote.Get(ref, p.withContext(ctx)...)
+		return err
+	}); err != nil {
+		return name.Digest{}, err
+	}
+
+	switch {
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		if img, err = withCreated(img, t); err != nil {
+			return name.Digest{}, err
+		}
+		dig, err := img.Digest()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		d := ref.Context().Digest(dig.String())
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.Write(d, img, p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing %s: %w", d, err)
+		}
+		return d, nil
+
+	case desc.MediaType.IsIndex():
+		base, err := desc.ImageIndex()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		idx, err := indexWithCreated(base, t)
+		if err != nil {
+			return name.Digest{}, err
+		}
+		dig, err := idx.Digest()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		d := ref.Context().Digest(dig.String())
+		if err := retryTransient(ctx, func(ctx context.Context) error {
+			return remote.WriteIndex(d, idx, p.withContext(ctx)...)
+		}); err != nil {
+			return name.Digest{}, fmt.Errorf("pushing %s: %w", d, err)
+		}
+		return d, nil
+
+	default:
+		return name.Digest{}, fmt.Errorf("unsupported media type %s", desc.MediaType)
+	}
+}
+
+// withCreated returns img with the created times of its config and history
+// set to t.
+func withCreated(img v1.Image, t time.Time) (v1.Image, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cf = cf.DeepCopy()
+	cf.Created = v1.Time{Time: t}
+	for i := range cf.History {
+		cf.History[i].Created = v1.Time{Time: t}
+	}
+	return mutate.ConfigFile(img, cf)
+}
+
+// indexWithCreated returns base with the created times of each of its images
+// set to t, keeping the index's media type and annotations, and each
+// manifest's descriptor. Attestation manifests that refer to an image are
+// pointed at its normalized digest. Nested indexes are kept as they are.
+func indexWithCreated(base v1.ImageIndex, t time.Time) (v1.ImageIndex, error) {
+	m, err := base.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	adds := make([]mutate.IndexAddendum, len(m.Manifests))
+	normalized := map[string]string{}
+	for i, desc := range m.Manifests {
+		if !desc.MediaType.IsImage() {
+			child, err := base.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+			adds[i] = mutate.IndexAddendum{Add: child, Descriptor: desc}
+			continue
+		}
+		img, err := base.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if img, err = withCreated(img, t); err != nil {
+			return nil, err
+		}
+		dig, err := img.Digest()
+		if err != nil {
+			return nil, err
+		}
+		normalized[desc.Digest.String()] = dig.String()
+		// The new descriptor's digest and size come from the image.
+		desc.Digest, desc.Size, desc.Data = v1.Hash{}, 0, nil
+		adds[i] = mutate.IndexAddendum{Add: img, Descriptor: desc}
+	}
+	for i := range adds {
+		ann := adds[i].Descriptor.Annotations
+		if d, ok := normalized[ann[dockerReferenceDigestAnnotation]]; ok {
+			ann = maps.Clone(ann)
+			ann[dockerReferenceDigestAnnotation] = d
+			adds[i].Descriptor.Annotations = ann
+		}
+	}
+
+	var idx v1.ImageIndex = empty.Index
+	if m.MediaType != "" {
+		idx = mutate.IndexMediaType(idx, m.MediaType)
+	}
+	if len(m.Annotations) > 0 {
+		idx = mutate.Annotations(idx, m.Annotations).(v1.ImageIndex)
+	}
+	return mutate.AppendManifests(idx, adds...), nil
+}
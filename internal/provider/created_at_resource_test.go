@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestNormalizeCreated(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf = cf.DeepCopy()
+	now := v1.Time{Time: time.Now().UTC().Truncate(time.Second)}
+	cf.Created = now
+	cf.History = []v1.History{{Created: now, CreatedBy: "COPY a /"}, {Created: now, CreatedBy: "COPY b /"}}
+	if img, err = mutate.ConfigFile(img, cf); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	p := &ProviderOpts{}
+	epoch := time.Unix(0, 0).UTC()
+	got, err := p.normalizeCreated(context.Background(), ref, epoch)
+	if err != nil {
+		t.Fatalf("normalizeCreated: %v", err)
+	}
+	if got.Context() != ref.Context() || got.DigestStr() == ref.DigestStr() {
+		t.Errorf("got %s, want a new digest in %s", got, ref.Context())
+	}
+	// It's deterministic.
+	if again, err := p.normalizeCreated(context.Background(), ref, epoch); err != nil || again != got {
+		t.Errorf("normalizing again = %s, %v; want %s", again, err, got)
+	}
+
+	normalized, err := remote.Image(got)
+	if err != nil {
+		t.Fatalf("failed to get normalized image: %v", err)
+	}
+	ncf, err := normalized.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ncf.Created.Equal(epoch) {
+		t.Errorf("created = %s, want %s", ncf.Created, epoch)
+	}
+	for i, h := range ncf.History {
+		if !h.Created.Equal(epoch) || h.CreatedBy != cf.History[i].CreatedBy {
+			t.Errorf("history %d = %+v, want %s created at %s", i, h, cf.History[i].CreatedBy, epoch)
+		}
+	}
+	wantLayers, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLayers, err := normalized.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(gotLayers.Layers) != fmt.Sprint(wantLayers.Layers) {
+		t.Errorf("layers changed: got %v, want %v", gotLayers.Layers, wantLayers.Layers)
+	}
+}
+
+func TestNormalizeCreatedIndex(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	idx := multiPlatformIndex(t)
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	p := &ProviderOpts{}
+	epoch := time.Unix(0, 0).UTC()
+	got, err := p.normalizeCreated(context.Background(), ref, epoch)
+	if err != nil {
+		t.Fatalf("normalizeCreated: %v", err)
+	}
+	normalized, err := remote.Index(got)
+	if err != nil {
+		t.Fatalf("failed to get normalized index: %v", err)
+	}
+	m, err := normalized.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Annotations["org.opencontainers.image.source"]; got != "https://example.com/app" {
+		t.Errorf("index annotation = %q, want it kept", got)
+	}
+	if len(m.Manifests) != 6 {
+		t.Fatalf("got %d manifests, want 6", len(m.Manifests))
+	}
+	for i, desc := range m.Manifests {
+		img, err := normalized.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cf.Created.Equal(epoch) {
+			t.Errorf("manifest %d created = %s, want %s", i, cf.Created, epoch)
+		}
+		// Each attestation follows the image it's about.
+		if ref, ok := desc.Annotations[dockerReferenceDigestAnnotation]; ok && ref != m.Manifests[i-1].Digest.String() {
+			t.Errorf("attestation %d refers to %s, want %s", i, ref, m.Manifests[i-1].Digest)
+		}
+	}
+}
+
+func TestAccCreatedAtResource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_created_at" "test" {
+  base_image = %q
+}`, ref),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_created_at.test", "created_at", "1970-01-01T00:00:00Z"),
+				resource.TestMatchResourceAttr("oci_created_at.test", "image_ref", regexp.MustCompile(`^`+regexp.QuoteMeta(repo.String())+`@sha256:`)),
+				resource.TestCheckResourceAttrPair("oci_created_at.test", "id", "oci_created_at.test", "image_ref"),
+			),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_created_at" "test" {
+  base_image = %q
+  created_at = "yesterday"
+}`, ref),
+			ExpectError: regexp.MustCompile(`invalid created_at`),
+		}},
+	})
+}
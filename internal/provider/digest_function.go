@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &DigestFunction{}
+
+// NewDigestFunction returns the digest function, which resolves tags with the
+// options returned by popts.
+func NewDigestFunction(popts func() *ProviderOpts) function.Function {
+	return &DigestFunction{popts: popts}
+}
+
+// DigestFunction defines the function implementation.
+type DigestFunction struct {
+	popts func() *ProviderOpts
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *DigestFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "digest"
+}
+
+// Definition should return the definition for the function.
+func (s *DigestFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Resolves an OCI reference to its digest.",
+		Description: "Resolves a tag to the digest it currently points to with a HEAD request, returning the `sha256:...` string. References that already include a digest are returned without a request.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string to resolve.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *DigestFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	popts := s.popts()
+	ref, err := name.ParseReference(input, popts.nameOptions(input, false)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	if d, ok := ref.(name.Digest); ok {
+		resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, d.DigestStr()))
+		return
+	}
+
+	desc, err := remote.Head(ref, popts.withContext(ctx)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to resolve %s: %v", ref, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, desc.Digest.String()))
+}
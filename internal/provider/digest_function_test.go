@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestDigestFunction(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	ref := repo.Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`
+output "by_tag" { value = provider::oci::digest(%q) }
+output "by_digest" { value = provider::oci::digest("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("by_tag", knownvalue.StringExact(d.String())),
+				statecheck.ExpectKnownOutputValue("by_digest", knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234")),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "missing" { value = provider::oci::digest(%q) }`, repo.Tag("missing")),
+			ExpectError: regexp.MustCompile("Failed to resolve"),
+		}},
+	})
+}
+
+func TestDigestFunction_Retries(t *testing.T) {
+	// Every pull request fails once, with a 500 or a timeout, and succeeds
+	// when retried.
+	reg, stats, cleanup := ocitesting.SetupFaultyRegistry(t, ocitesting.Faults{
+		ServerErrors:  0.5,
+		Timeouts:      0.5,
+		Timeout:       100 * time.Millisecond,
+		Methods:       []string{http.MethodGet, http.MethodHead},
+		MaxPerRequest: 1,
+	})
+	defer cleanup()
+
+	ref := reg.Repo("test").Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`output "digest" { value = provider::oci::digest(%q) }`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact(d.String())),
+			},
+		}},
+	})
+	if stats.Injected() == 0 {
+		t.Error("expected faults to be injected")
+	}
+}
@@ -0,0 +1,351 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ resource.Resource = &DockerLoadResource{}
+
+func NewDockerLoadResource() resource.Resource {
+	return &DockerLoadResource{}
+}
+
+// DockerLoadResource defines the resource implementation.
+type DockerLoadResource struct {
+	popts ProviderOpts
+}
+
+// DockerLoadResourceModel describes the resource data model.
+type DockerLoadResourceModel struct {
+	Id      types.String `tfsdk:"id"`
+	ImageId types.String `tfsdk:"image_id"`
+
+	DigestRef   types.String `tfsdk:"digest_ref"`
+	Tag         types.String `tfsdk:"tag"`
+	Platform    types.String `tfsdk:"platform"`
+	ArchivePath types.String `tfsdk:"archive_path"`
+	Insecure    types.Bool   `tfsdk:"insecure"`
+}
+
+func (r *DockerLoadResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_docker_load"
+}
+
+func (r *DockerLoadResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Load an image by digest into the local Docker daemon with `docker load`, or write it to a `docker save` tarball, for local tools like Docker Compose to use the exact image. Destroying the resource removes the tag, or the image if it isn't tagged, or the tarball.",
+		Attributes: map[string]schema.Attribute{
+			"digest_ref": schema.StringAttribute{
+				MarkdownDescription: "Image ref by digest to load.",
+				Required:            true,
+				Validators:          []validator.String{validators.DigestValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"tag": schema.StringAttribute{
+				MarkdownDescription: "Name to load the image as, e.g. `myapp:test`. If it isn't set, the image is loaded untagged, and can be used by its `image_id`.",
+				Optional:            true,
+				Validators:          []validator.String{validators.RefValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"platform": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("If `digest_ref` is an index, the platform of the image in it to load (default `%s`).", defaultLoadPlatform()),
+				Optional:            true,
+				Validators:          []validator.String{validators.PlatformValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"archive_path": schema.StringAttribute{
+				MarkdownDescription: "If set, write the image to a tarball at this path, as `docker save` would, instead of loading it.",
+				Optional:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"image_id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "ID of the loaded image, as `docker images` shows it. For a tarball, this is the digest of the image's config, which is the ID that the classic Docker image store gives it.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The fully-qualified image ref by digest that was loaded, which is the image for `platform` if `digest_ref` is an index.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *DockerLoadResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	r.popts = *popts
+}
+
+func (r *DockerLoadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *DockerLoadResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.doLoad(ctx, data); err != nil {
+		resp.Diagnostics.AddError("Unable to load image", fmt.Sprintf("Unable to load image %s, got error: %s", data.DigestRef.ValueString(), err))
+		return
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *DockerLoadResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *DockerLoadResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// If the tarball or image is gone, it's loaded again.
+	if p := data.ArchivePath.ValueString(); p != "" {
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			resp.State.RemoveResource(ctx)
+			return
+		} else if err != nil {
+			resp.Diagnostics.AddError("Unable to read tarball", fmt.Sprintf("Unable to read tarball %s, got error: %s", p, err))
+			return
+		}
+	} else {
+		ref := data.ImageId.ValueString()
+		if !data.Tag.IsNull() {
+			ref = data.Tag.ValueString()
+		}
+		id, err := dockerImageID(ctx, ref)
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to read image", fmt.Sprintf("Unable to inspect image %s, got error: %s", ref, err))
+			return
+		}
+		if id != data.ImageId.ValueString() {
+			// The image is gone, or the tag has been moved.
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *DockerLoadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Everything but insecure requires replacement, so there's nothing to
+	// load.
+	var data *DockerLoadResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *DockerLoadResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *DockerLoadResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if p := data.ArchivePath.ValueString(); p != "" {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			resp.Diagnostics.AddError("Unable to remove tarball", fmt.Sprintf("Unable to remove tarball %s, got error: %s", p, err))
+		}
+		return
+	}
+	ref := data.ImageId.ValueString()
+	if !data.Tag.IsNull() {
+		ref = data.Tag.ValueString()
+	}
+	if err := dockerRemove(ctx, ref); err != nil {
+		resp.Diagnostics.AddError("Unable to remove image", fmt.Sprintf("Unable to remove image %s, got error: %s", ref, err))
+	}
+}
+
+func (r *DockerLoadResource) doLoad(ctx context.Context, data *DockerLoadResourceModel) error {
+	d, err := name.NewDigest(data.DigestRef.ValueString(), r.popts.nameOptions(data.DigestRef.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		return fmt.Errorf("digest_ref must be a digest reference: %w", err)
+	}
+	platform := defaultLoadPlatform()
+	if !data.Platform.IsNull() {
+		if platform, err = v1.ParsePlatform(data.Platform.ValueString()); err != nil {
+			return fmt.Errorf("invalid platform: %w", err)
+		}
+	}
+	// A digest is written without a tag, so the image is loaded untagged.
+	var ref name.Reference = d
+	if !data.Tag.IsNull() {
+		if ref, err = name.NewTag(data.Tag.ValueString()); err != nil {
+			return fmt.Errorf("invalid tag: %w", err)
+		}
+	}
+
+	desc, err := remote.Get(d, append(r.popts.withContext(ctx), remote.WithPlatform(*platform))...)
+	if err != nil {
+		return fmt.Errorf("error fetching digest: %w", err)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	data.Id = types.StringValue(d.Context().Digest(dig.String()).String())
+	img = r.popts.cacheImage(img)
+
+	if p := data.ArchivePath.ValueString(); p != "" {
+		if err := writeDockerArchive(p, ref, img); err != nil {
+			return err
+		}
+		cfg, err := img.ConfigName()
+		if err != nil {
+			return err
+		}
+		data.ImageId = types.StringValue(cfg.String())
+		return nil
+	}
+
+	id, err := dockerLoad(ctx, ref, img)
+	if err != nil {
+		return err
+	}
+	data.ImageId = types.StringValue(id)
+	return nil
+}
+
+// defaultLoadPlatform is the platform of images loaded from an index, unless
+// another is asked for: Linux on this machine's architecture, as Docker runs
+// containers on it.
+func defaultLoadPlatform() *v1.Platform {
+	return &v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+}
+
+// writeDockerArchive writes img to a docker save tarball at path, tagged with
+// ref if it's a tag. The tarball is written to a temporary file beside path
+// first, so there's never a partial tarball at path.
+func writeDockerArchive(path string, ref name.Reference, img v1.Image) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := tarball.Write(ref, img, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// dockerLoad loads img into the local docker daemon, tagged with ref if it's
+// a tag, and returns the ID that docker gave it.
+func dockerLoad(ctx context.Context, ref name.Reference, img v1.Image) (string, error) {
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(tarball.Write(ref, img, pw)) }()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "load")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pr, &stdout, &stderr
+	err := cmd.Run()
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", fmt.Errorf("docker load: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if t, ok := ref.(name.Tag); ok {
+		return dockerImageID(ctx, t.String())
+	}
+	id := loadedImageID(stdout.String())
+	if id == "" {
+		return "", fmt.Errorf("docker load didn't say what it loaded: %s", strings.TrimSpace(stdout.String()))
+	}
+	return id, nil
+}
+
+// loadedImageID returns the ID of the untagged image that docker load says
+// it loaded, or "" if it doesn't say.
+func loadedImageID(out string) string {
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		if id, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "Loaded image ID: "); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// dockerImageID returns the ID of the image ref in the local docker daemon,
+// or "" if there's no such image.
+func dockerImageID(ctx context.Context, ref string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{.Id}}", ref)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if isNoSuchImage(stderr.String()) {
+			return "", nil
+		}
+		return "", fmt.Errorf("docker image inspect: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// dockerRemove removes ref from the local docker daemon: just the tag, if ref
+// is a tag and the image has others, or otherwise the image. It's not an
+// error if there's no such image.
+func dockerRemove(ctx context.Context, ref string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "image", "rm", ref)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && !isNoSuchImage(stderr.String()) {
+		return fmt.Errorf("docker image rm: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// isNoSuchImage reports whether the docker CLI's stderr says that the image
+// it was asked about doesn't exist.
+func isNoSuchImage(stderr string) bool {
+	return strings.Contains(strings.ToLower(stderr), "no such image")
+}
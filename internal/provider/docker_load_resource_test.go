@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestDockerLoadArchive(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	// An index of an image for each architecture.
+	idx := v1.ImageIndex(empty.Index)
+	imgs := map[string]v1.Image{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf.OS, cf.Architecture = "linux", arch
+		if img, err = mutate.ConfigFile(img, cf); err != nil {
+			t.Fatal(err)
+		}
+		imgs[arch] = img
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	r := &DockerLoadResource{}
+	path := filepath.Join(t.TempDir(), "image.tar")
+	data := &DockerLoadResourceModel{
+		DigestRef:   types.StringValue(ref.String()),
+		Tag:         types.StringValue("myapp:test"),
+		Platform:    types.StringValue("linux/arm64"),
+		ArchivePath: types.StringValue(path),
+	}
+	if err := r.doLoad(context.Background(), data); err != nil {
+		t.Fatalf("doLoad: %v", err)
+	}
+
+	want := imgs["arm64"]
+	wantDigest, err := want.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConfig, err := want.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data.Id.ValueString(); got != repo.Digest(wantDigest.String()).String() {
+		t.Errorf("id = %s, want the arm64 image %s", got, wantDigest)
+	}
+	if got := data.ImageId.ValueString(); got != wantConfig.String() {
+		t.Errorf("image_id = %s, want %s", got, wantConfig)
+	}
+
+	tag, err := name.NewTag("myapp:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := tarball.ImageFromPath(path, &tag)
+	if err != nil {
+		t.Fatalf("failed to read tarball: %v", err)
+	}
+	if got, err := img.ConfigName(); err != nil || got != wantConfig {
+		t.Errorf("tarball config = %s, %v; want %s", got, err, wantConfig)
+	}
+
+	// Nothing's left behind but the tarball.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want just the tarball", len(entries))
+	}
+}
+
+func TestLoadedImageID(t *testing.T) {
+	for out, want := range map[string]string{
+		"Loaded image ID: sha256:abc\n":           "sha256:abc",
+		"Loaded image: myapp:test\n":              "",
+		"foo\nLoaded image ID: sha256:def\nbar\n": "sha256:def",
+		"": "",
+	} {
+		if got := loadedImageID(out); got != want {
+			t.Errorf("loadedImageID(%q) = %q, want %q", out, got, want)
+		}
+	}
+}
+
+func TestAccDockerLoadResource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	cfg, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "image.tar")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_docker_load" "test" {
+			  digest_ref   = %q
+			  archive_path = %q
+			}`, ref, path),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_docker_load.test", "id", ref.String()),
+				resource.TestCheckResourceAttr("oci_docker_load.test", "image_id", cfg.String()),
+			),
+		}, {
+			// If the tarball is removed, it's written again.
+			PreConfig: func() {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Config: fmt.Sprintf(`resource "oci_docker_load" "test" {
+			  digest_ref   = %q
+			  archive_path = %q
+			}`, ref, path),
+			Check: func(*terraform.State) error {
+				_, err := os.Stat(path)
+				return err
+			},
+		}, {
+			Config: fmt.Sprintf(`resource "oci_docker_load" "test" {
+			  digest_ref   = %q
+			  archive_path = %q
+			  platform     = "linux/arm/v7/extra"
+			}`, ref, path),
+			ExpectError: regexp.MustCompile(`Invalid platform`),
+		}},
+	})
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &EqualFunction{}
+
+func NewEqualFunction() function.Function {
+	return &EqualFunction{}
+}
+
+// EqualFunction defines the function implementation.
+type EqualFunction struct{}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *EqualFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "equal"
+}
+
+// Definition should return the definition for the function.
+func (s *EqualFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Reports whether two OCI references refer to the same image.",
+		Description: "Returns true if both references canonicalize to the same registry, repository and digest, so e.g. `ubuntu@sha256:...` equals `index.docker.io/library/ubuntu:latest@sha256:...`, since a digest overrides a tag. " +
+			"References without a digest are equal if they canonicalize to the same tag. The registry isn't contacted, so a tag never equals a digest. Invalid references are an error.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "a",
+				Description: "The first OCI reference string to compare.",
+			},
+			function.StringParameter{
+				Name:        "b",
+				Description: "The second OCI reference string to compare.",
+			},
+		},
+		Return: function.BoolReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *EqualFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var a, b string
+	if ferr := req.Arguments.Get(ctx, &a, &b); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	ca, err := canonicalRef(a)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+	cb, err := canonicalRef(b)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, ca == cb))
+}
+
+// canonicalRef returns the fully-qualified form of ref, by digest if it has
+// one, and otherwise by tag.
+func canonicalRef(ref string) (string, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if d, ok := r.(name.Digest); ok {
+		return d.Context().Name() + "@" + d.DigestStr(), nil
+	}
+	return r.Name(), nil
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEqualFunction(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `
+output "same" { value = provider::oci::equal("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234", "cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "tag_and_digest" { value = provider::oci::equal("cgr.dev/foo/sample:latest@sha256:1234567890123456789012345678901234567890123456789012345678901234", "cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "docker_hub" { value = provider::oci::equal("ubuntu@sha256:1234567890123456789012345678901234567890123456789012345678901234", "index.docker.io/library/ubuntu@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "implicit_tag" { value = provider::oci::equal("ubuntu", "docker.io/library/ubuntu:latest") }
+output "other_digest" { value = provider::oci::equal("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234", "cgr.dev/foo/sample@sha256:4321567890123456789012345678901234567890123456789012345678901234") }
+output "other_repo" { value = provider::oci::equal("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234", "cgr.dev/foo/other@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "tag_and_digest_only" { value = provider::oci::equal("cgr.dev/foo/sample:latest", "cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("same", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("tag_and_digest", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("docker_hub", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("implicit_tag", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("other_digest", knownvalue.Bool(false)),
+				statecheck.ExpectKnownOutputValue("other_repo", knownvalue.Bool(false)),
+				statecheck.ExpectKnownOutputValue("tag_and_digest_only", knownvalue.Bool(false)),
+			},
+		}},
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config:      `output "equal" { value = provider::oci::equal("cgr.dev/foo/sample", "") }`,
+			ExpectError: regexp.MustCompile("Failed to parse OCI reference"),
+		}},
+	})
+}
+
+func TestCanonicalRef(t *testing.T) {
+	for in, want := range map[string]string{
+		"ubuntu":                "index.docker.io/library/ubuntu:latest",
+		"cgr.dev/foo/sample:v1": "cgr.dev/foo/sample:v1",
+		"cgr.dev/foo/sample:v1@sha256:1234567890123456789012345678901234567890123456789012345678901234": "cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234",
+	} {
+		got, err := canonicalRef(in)
+		if err != nil {
+			t.Errorf("canonicalRef(%q): %v", in, err)
+		} else if got != want {
+			t.Errorf("canonicalRef(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/opencontainers/go-digest"
+)
+
+// estargzCompression is estargz's gzip compression, but with the footer
+// written by hand. estargz builds the footer with compress/gzip and expects
+// a stored (uncompressed) empty block, which newer versions of compress/gzip
+// encode in fewer bytes, making estargz panic.
+type estargzCompression struct {
+	*estargz.GzipCompressor
+	*estargz.GzipDecompressor
+	level int
+}
+
+func newEstargzCompression(level int) estargzCompression {
+	return estargzCompression{
+		GzipCompressor:   estargz.NewGzipCompressorWithLevel(level),
+		GzipDecompressor: &estargz.GzipDecompressor{},
+		level:            level,
+	}
+}
+
+// WriteTOCAndFooter writes the TOC as estargz.GzipCompressor does, followed
+// by the footer.
+func (c estargzCompression) WriteTOCAndFooter(w io.Writer, off int64, toc *estargz.JTOC, diffHash hash.Hash) (digest.Digest, error) {
+	tocJSON, err := json.MarshalIndent(toc, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	gz, err := gzip.NewWriterLevel(w, c.level)
+	if err != nil {
+		return "", err
+	}
+	gw := io.Writer(gz)
+	if diffHash != nil {
+		gw = io.MultiWriter(gz, diffHash)
+	}
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     estargz.TOCTarName,
+		Size:     int64(len(tocJSON)),
+	}); err != nil {
+		return "", err
+	}
+	if _, err := tw.Write(tocJSON); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if _, err := w.Write(estargzFooter(off)); err != nil {
+		return "", err
+	}
+	return digest.FromBytes(tocJSON), nil
+}
+
+// estargzFooter returns the 51-byte eStargz footer: an empty gzip member
+// whose extra field records the offset of the TOC.
+func estargzFooter(tocOff int64) []byte {
+	subfield := fmt.Sprintf("%016xSTARGZ", tocOff)
+	b := []byte{
+		0x1f, 0x8b, // magic
+		8,          // deflate
+		1 << 2,     // FEXTRA
+		0, 0, 0, 0, // mtime
+		0,   // extra flags
+		255, // unknown OS
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(4+len(subfield)))
+	b = append(b, 'S', 'G')
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(subfield)))
+	b = append(b, subfield...)
+	// A final, empty stored block.
+	b = append(b, 1, 0, 0, 0xff, 0xff)
+	// The CRC-32 and size of the empty contents.
+	return append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+}
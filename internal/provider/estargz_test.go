@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+)
+
+func TestEstargzFooter(t *testing.T) {
+	b := estargzFooter(0x1234)
+	if len(b) != estargz.FooterSize {
+		t.Fatalf("got footer of %d bytes, want %d", len(b), estargz.FooterSize)
+	}
+	_, off, _, err := (&estargz.GzipDecompressor{}).ParseFooter(b)
+	if err != nil {
+		t.Fatalf("ParseFooter: %v", err)
+	}
+	if off != 0x1234 {
+		t.Errorf("got TOC offset %#x, want 0x1234", off)
+	}
+
+	// Layers built with it can be read back by estargz.
+	var tb bytes.Buffer
+	tw := tar.NewWriter(&tb)
+	if err := tw.WriteHeader(&tar.Header{Name: "hello.txt", Size: 11, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	layer, _, err := compressLayer(tb.Bytes(), appendOptions{compressionLevel: gzip.DefaultCompression, estargz: true})
+	if err != nil {
+		t.Fatalf("compressLayer: %v", err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	blob, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := estargz.Open(io.NewSectionReader(bytes.NewReader(blob), 0, int64(len(blob))))
+	if err != nil {
+		t.Fatalf("estargz.Open: %v", err)
+	}
+	if e, ok := r.Lookup("hello.txt"); !ok || e.Size != 11 {
+		t.Errorf("got entry %+v, want hello.txt of 11 bytes", e)
+	}
+}
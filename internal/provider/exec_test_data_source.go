@@ -1,23 +1,29 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"os/exec"
-	"sync"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
@@ -26,6 +32,7 @@ import (
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ datasource.DataSource = &ExecTestDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &ExecTestDataSource{}
 
 func NewExecTestDataSource() datasource.DataSource {
 	return &ExecTestDataSource{}
@@ -38,11 +45,34 @@ type ExecTestDataSource struct {
 
 // ExecTestDataSourceModel describes the data source data model.
 type ExecTestDataSourceModel struct {
-	Digest         types.String `tfsdk:"digest"`
-	Script         types.String `tfsdk:"script"`
-	TimeoutSeconds types.Int64  `tfsdk:"timeout_seconds"`
-	WorkingDir     types.String `tfsdk:"working_dir"`
-	Env            []EnvVar     `tfsdk:"env"`
+	Digest         types.String      `tfsdk:"digest"`
+	Resolve        types.Bool        `tfsdk:"resolve"`
+	Name           types.String      `tfsdk:"name"`
+	Insecure       types.Bool        `tfsdk:"insecure"`
+	Script         types.String      `tfsdk:"script"`
+	Steps          []ExecTestStep    `tfsdk:"steps"`
+	TimeoutSeconds types.Int64       `tfsdk:"timeout_seconds"`
+	WorkingDir     types.String      `tfsdk:"working_dir"`
+	CreateWorkDir  types.Bool        `tfsdk:"create_working_dir"`
+	Driver         types.String      `tfsdk:"driver"`
+	Prepull        types.String      `tfsdk:"prepull"`
+	Network        types.String      `tfsdk:"network"`
+	Kubernetes     *KubernetesConfig `tfsdk:"kubernetes"`
+	FreePorts      types.Int64       `tfsdk:"free_ports"`
+	FreePortProto  types.String      `tfsdk:"free_port_protocol"`
+	Env            []EnvVar          `tfsdk:"env"`
+	SensitiveEnv   []EnvVar          `tfsdk:"sensitive_env"`
+	EnvFile        types.String      `tfsdk:"env_file"`
+
+	ArtifactsDestination types.String `tfsdk:"artifacts_destination"`
+	ArtifactsDir         types.String `tfsdk:"artifacts_dir"`
+
+	Skip       types.Bool   `tfsdk:"skip"`
+	Skipped    types.Bool   `tfsdk:"skipped"`
+	SkipReason types.String `tfsdk:"skip_reason"`
+
+	ExpectedExitCodes []int64    `tfsdk:"expected_exit_codes"`
+	ExpectFailure     types.Bool `tfsdk:"expect_failure"`
 
 	ExitCode  types.Int64  `tfsdk:"exit_code"`
 	Output    types.String `tfsdk:"output"`
@@ -50,6 +80,25 @@ type ExecTestDataSourceModel struct {
 	TestedRef types.String `tfsdk:"tested_ref"`
 }
 
+// script returns the test's script, or its steps' scripts, for its ID.
+func (m *ExecTestDataSourceModel) script() string {
+	if len(m.Steps) == 0 {
+		return m.Script.ValueString()
+	}
+	var b strings.Builder
+	for _, s := range m.Steps {
+		fmt.Fprintf(&b, "%s\n%d\n%s\n", s.Name.ValueString(), s.ExpectedExitCode.ValueInt64(), s.Script.ValueString())
+	}
+	return b.String()
+}
+
+// ExecTestStep is one of the scripts of a test with steps.
+type ExecTestStep struct {
+	Name             types.String `tfsdk:"name"`
+	Script           types.String `tfsdk:"script"`
+	ExpectedExitCode types.Int64  `tfsdk:"expected_exit_code"`
+}
+
 type EnvVar struct {
 	Name  string `tfsdk:"name"`
 	Value string `tfsdk:"value"`
@@ -65,14 +114,46 @@ func (d *ExecTestDataSource) Schema(ctx context.Context, req datasource.SchemaRe
 
 		Attributes: map[string]schema.Attribute{
 			"digest": schema.StringAttribute{
-				MarkdownDescription: "Image digest to test",
+				MarkdownDescription: "Image digest to test, or any image ref if `resolve` is set",
 				Optional:            false,
 				Required:            true,
-				Validators:          []validator.String{validators.DigestValidator{}},
+				Validators:          []validator.String{validators.DigestValidator{ResolveAttribute: "resolve"}},
+			},
+			"resolve": schema.BoolAttribute{
+				MarkdownDescription: "If true, `digest` may be a tag, which is resolved to a digest when the data source is read. The digest that was tested is `tested_ref`.",
+				Optional:            true,
+			},
+			"name": schema.StringAttribute{
+				MarkdownDescription: "Name of the test, used in the provider's `exec_test_report` (defaults to the test ID)",
+				Optional:            true,
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
 			},
 			"script": schema.StringAttribute{
-				MarkdownDescription: "Script to run against the image",
-				Required:            true,
+				MarkdownDescription: "Script to run against the image. Exactly one of `script` and `steps` must be set.",
+				Optional:            true,
+			},
+			"steps": schema.ListNestedAttribute{
+				MarkdownDescription: "Scripts to run against the image in order, instead of a single `script`. The test stops at the first step that doesn't exit with its expected exit code, and the error reports how long each step took, which step failed, and its output. Steps share the test's environment, artifacts directory and timeout.",
+				Optional:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							MarkdownDescription: "Name of the step, used in errors",
+							Required:            true,
+						},
+						"script": schema.StringAttribute{
+							MarkdownDescription: "Script to run",
+							Required:            true,
+						},
+						"expected_exit_code": schema.Int64Attribute{
+							MarkdownDescription: "Exit code that the step must exit with (default is 0)",
+							Optional:            true,
+						},
+					},
+				},
 			},
 			"timeout_seconds": schema.Int64Attribute{
 				MarkdownDescription: "Timeout for the test in seconds (default is 5 minutes)",
@@ -80,9 +161,55 @@ func (d *ExecTestDataSource) Schema(ctx context.Context, req datasource.SchemaRe
 				Validators:          []validator.Int64{positiveIntValidator{}},
 			},
 			"working_dir": schema.StringAttribute{
-				MarkdownDescription: "Working directory for the test",
+				MarkdownDescription: "Working directory for the test, which must exist",
 				Optional:            true,
 			},
+			"create_working_dir": schema.BoolAttribute{
+				MarkdownDescription: "If true, the test runs in a new empty temporary directory, which is removed after the test completes. It cannot be combined with `working_dir`.",
+				Optional:            true,
+			},
+			"driver": schema.StringAttribute{
+				MarkdownDescription: "How to run the script: `host` (default) runs it on the host, `docker` runs it inside the image under test with `sh` as the entrypoint, and `k8s` runs it with `sh` in a Pod using the image under test, so with either the image must contain `sh`. With `docker` and `k8s`, only the test environment variables are passed into the container. With `docker`, `working_dir` is mounted at `/work` and the artifacts directory at `/artifacts`; `k8s` supports neither. `FREE_PORT` ports are free on the host, so with `docker` they're only usable on the `host` `network`. A `docker` container or `k8s` Pod is removed if the test times out or is cancelled.",
+				Optional:            true,
+				Validators:          []validator.String{oneOfValidator{values: execDrivers}},
+			},
+			"prepull": schema.StringAttribute{
+				MarkdownDescription: "How to make the image available before the script runs: `none` (default) leaves it to the script, and `docker` pulls it into the local docker daemon with the provider's registry credentials, so that scripts using `docker run` don't need credentials of their own. Tests of the same image share one pull.",
+				Optional:            true,
+				Validators:          []validator.String{oneOfValidator{values: prepullModes}},
+			},
+			"network": schema.StringAttribute{
+				MarkdownDescription: "Network to run the container in when `driver` is `docker`, passed to `docker run --network` (default is `host`). On the host network, the script can listen on `FREE_PORT` ports and reach registries on the host's `localhost`, as with the `host` driver; on any other network, it can do neither.",
+				Optional:            true,
+			},
+			"kubernetes": schema.SingleNestedAttribute{
+				MarkdownDescription: "Where to run the test Pod when `driver` is `k8s`. Defaults to the current kubeconfig context and namespace.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"kubeconfig": schema.StringAttribute{
+						MarkdownDescription: "Path to the kubeconfig file",
+						Optional:            true,
+					},
+					"context": schema.StringAttribute{
+						MarkdownDescription: "Kubeconfig context to use",
+						Optional:            true,
+					},
+					"namespace": schema.StringAttribute{
+						MarkdownDescription: "Namespace to run the test Pod in",
+						Optional:            true,
+					},
+				},
+			},
+			"free_ports": schema.Int64Attribute{
+				MarkdownDescription: "Number of free ports on the host to allocate (default is 1). Ports are exported as `FREE_PORT_0` through `FREE_PORT_<n-1>`, and the first is also exported as `FREE_PORT`. It cannot be combined with the `k8s` driver, whose Pods don't run on the host, and for which no ports are allocated.",
+				Optional:            true,
+				Validators:          []validator.Int64{positiveIntValidator{}},
+			},
+			"free_port_protocol": schema.StringAttribute{
+				MarkdownDescription: "Protocol to allocate free ports for: `tcp` (default) or `udp`. It cannot be combined with the `k8s` driver.",
+				Optional:            true,
+				Validators:          []validator.String{oneOfValidator{values: []string{"tcp", "udp"}}},
+			},
 			"env": schema.ListAttribute{
 				ElementType: basetypes.ObjectType{
 					AttrTypes: map[string]attr.Type{
@@ -93,6 +220,38 @@ func (d *ExecTestDataSource) Schema(ctx context.Context, req datasource.SchemaRe
 				MarkdownDescription: "Environment variables for the test",
 				Optional:            true,
 			},
+			"sensitive_env": schema.ListAttribute{
+				ElementType: basetypes.ObjectType{
+					AttrTypes: map[string]attr.Type{
+						"name":  basetypes.StringType{},
+						"value": basetypes.StringType{},
+					},
+				},
+				MarkdownDescription: "Environment variables for the test whose values are secret. Values are redacted from the test output reported in errors.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"env_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a file of `NAME=value` lines to add to the test environment. Blank lines and lines starting with `#` are ignored. Variables in `env` take precedence.",
+				Optional:            true,
+			},
+			"artifacts_destination": schema.StringAttribute{
+				MarkdownDescription: "If set, the contents of the artifacts directory are copied to this directory when the test fails. It cannot be combined with the `k8s` driver.",
+				Optional:            true,
+			},
+			"skip": schema.BoolAttribute{
+				MarkdownDescription: "If true, skip this test",
+				Optional:            true,
+			},
+			"expected_exit_codes": schema.ListAttribute{
+				MarkdownDescription: "Exit codes that are considered a successful test (default is only 0)",
+				ElementType:         basetypes.Int64Type{},
+				Optional:            true,
+			},
+			"expect_failure": schema.BoolAttribute{
+				MarkdownDescription: "If true, the test passes only if the script exits with a non-zero exit code",
+				Optional:            true,
+			},
 
 			// TODO: platform?
 
@@ -105,12 +264,24 @@ func (d *ExecTestDataSource) Schema(ctx context.Context, req datasource.SchemaRe
 				Computed:            true,
 				DeprecationMessage:  "Not populated",
 			},
+			"artifacts_dir": schema.StringAttribute{
+				MarkdownDescription: "Directory the test can write artifacts to, exported to the script as `TEST_ARTIFACTS`. The directory is left in place after the test completes. Artifacts aren't supported with the `k8s` driver, whose scripts don't get `TEST_ARTIFACTS`.",
+				Computed:            true,
+			},
+			"skipped": schema.BoolAttribute{
+				MarkdownDescription: "Whether the test was skipped",
+				Computed:            true,
+			},
+			"skip_reason": schema.StringAttribute{
+				MarkdownDescription: "Why the test was skipped, if it was",
+				Computed:            true,
+			},
 			"id": schema.StringAttribute{
 				MarkdownDescription: "Fully qualified image digest of the image.",
 				Computed:            true,
 			},
 			"tested_ref": schema.StringAttribute{
-				MarkdownDescription: "Tested image ref by digest.",
+				MarkdownDescription: "Tested image ref by digest, which `digest` resolved to if `resolve` is set.",
 				Computed:            true,
 			},
 		},
@@ -131,6 +302,61 @@ func (d *ExecTestDataSource) Configure(ctx context.Context, req datasource.Confi
 	d.popts = *popts
 }
 
+func (d *ExecTestDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var expectFailure types.Bool
+	var expectedExitCodes types.List
+	var driver, prepull, script, workingDir, network, freePortProto, artifactsDestination types.String
+	var freePorts types.Int64
+	var createWorkingDir types.Bool
+	var steps types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("expect_failure"), &expectFailure)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("expected_exit_codes"), &expectedExitCodes)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("driver"), &driver)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("prepull"), &prepull)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("script"), &script)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("steps"), &steps)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("working_dir"), &workingDir)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("create_working_dir"), &createWorkingDir)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("network"), &network)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("free_ports"), &freePorts)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("free_port_protocol"), &freePortProto)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("artifacts_destination"), &artifactsDestination)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if expectFailure.ValueBool() && !expectedExitCodes.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("expect_failure"), "Conflicting attributes", "expect_failure cannot be combined with expected_exit_codes")
+	}
+	if !script.IsUnknown() && !steps.IsUnknown() && script.IsNull() == steps.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("script"), "Invalid attribute combination", "Exactly one of script and steps must be set")
+	}
+	if !steps.IsNull() && (expectFailure.ValueBool() || !expectedExitCodes.IsNull()) {
+		resp.Diagnostics.AddAttributeError(path.Root("steps"), "Conflicting attributes", "steps cannot be combined with expect_failure or expected_exit_codes; set each step's expected_exit_code instead")
+	}
+	if prepull.ValueString() == prepullDocker && driver.ValueString() == driverK8s {
+		resp.Diagnostics.AddAttributeError(path.Root("prepull"), "Conflicting attributes", "prepull = \"docker\" cannot be combined with driver = \"k8s\", whose Pods don't use the local docker daemon")
+	}
+	if createWorkingDir.ValueBool() && !workingDir.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("create_working_dir"), "Conflicting attributes", "create_working_dir cannot be combined with working_dir")
+	}
+	if !network.IsNull() && !driver.IsUnknown() && driver.ValueString() != driverDocker {
+		resp.Diagnostics.AddAttributeError(path.Root("network"), "Conflicting attributes", "network can only be set with driver = \"docker\"")
+	}
+	if driver.ValueString() == driverK8s {
+		// Pods can't use ports that are free on the host, or write to its
+		// artifacts directory.
+		for _, a := range []struct {
+			name string
+			v    attr.Value
+		}{{"free_ports", freePorts}, {"free_port_protocol", freePortProto}, {"artifacts_destination", artifactsDestination}} {
+			if !a.v.IsNull() {
+				resp.Diagnostics.AddAttributeError(path.Root(a.name), "Conflicting attributes", fmt.Sprintf("%s cannot be combined with driver = \"k8s\"", a.name))
+			}
+		}
+	}
+}
+
 func (d *ExecTestDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var data ExecTestDataSourceModel
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
@@ -138,23 +364,92 @@ func (d *ExecTestDataSource) Read(ctx context.Context, req datasource.ReadReques
 		return
 	}
 
-	if d.popts.skipExecTests {
-		resp.Diagnostics.AddWarning("Skipping exec tests", "Skipping exec tests as per provider configuration")
+	data.Id = types.StringValue(md5str(data.script()) + data.Digest.ValueString())
+	data.TestedRef = data.Digest
+	data.Output = types.StringValue("") // always empty.
+	data.Skipped = types.BoolValue(false)
+
+	result := execTestResult{Name: data.Name.ValueString()}
+	if result.Name == "" {
+		result.Name = data.Id.ValueString()
+	}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		if errs := resp.Diagnostics.Errors(); len(errs) > 0 {
+			result.Failure = errs[0].Summary()
+		}
+		if err := d.popts.execReport.record(result); err != nil {
+			resp.Diagnostics.AddWarning("Unable to write exec test report", fmt.Sprintf("Unable to write exec test report, got error: %s", err))
+		}
+	}()
+
+	var reason string
+	switch {
+	case d.popts.skipExecTests:
+		reason = "Skipping exec tests as per provider configuration"
+		resp.Diagnostics.AddWarning("Skipping exec tests", reason)
+	case data.Skip.ValueBool():
+		reason = "Skipping exec test as per data source configuration"
+	}
+	if reason != "" {
+		data.Skipped = types.BoolValue(true)
+		data.SkipReason = types.StringValue(reason)
+		result.SkipReason = reason
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 		return
 	}
 
-	ref, err := name.NewDigest(data.Digest.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", data.Digest.ValueString(), err))
+	ref, diags := d.popts.testDigest(ctx, data.Digest.ValueString(), data.Resolve.ValueBool(), data.Insecure.ValueBool())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
+	image := ref.String()
+	data.Id = types.StringValue(md5str(data.script()) + image)
+	data.TestedRef = types.StringValue(image)
 
 	// Check we can get the image before running the test.
-	if _, err := remote.Get(ref, d.popts.withContext(ctx)...); err != nil {
-		resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", data.Digest.ValueString(), err))
+	desc, err := remote.Get(ref, d.popts.withContext(ctx)...)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", image, err))
 		return
 	}
 
+	// For an index, this resolves the image for the default platform.
+	img, err := desc.Image()
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", image, err))
+		return
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to fetch image config", fmt.Sprintf("Unable to fetch image config for ref %s, got error: %s", image, err))
+		return
+	}
+
+	// Wait for a slot if the provider limits the number of parallel tests.
+	// This happens before the timeout starts, so waiting doesn't count
+	// against the test's own timeout.
+	if d.popts.execSem != nil {
+		select {
+		case d.popts.execSem <- struct{}{}:
+			defer func() { <-d.popts.execSem }()
+		case <-ctx.Done():
+			resp.Diagnostics.AddError("Test cancelled", fmt.Sprintf("Test for ref %s was cancelled while waiting to run: %s", image, ctx.Err()))
+			return
+		}
+	}
+
+	// Like waiting for a slot, pulling the image doesn't count against the
+	// test's timeout.
+	if data.Prepull.ValueString() == prepullDocker {
+		if err := d.popts.prepullDocker(ctx, ref); err != nil {
+			resp.Diagnostics.AddError("Unable to pull image", fmt.Sprintf("Unable to pull image for ref %s into docker, got error: %s", image, err))
+			return
+		}
+	}
+
 	timeout := data.TimeoutSeconds.ValueInt64()
 	if timeout == 0 {
 		if d.popts.defaultExecTimeoutSeconds != 0 {
@@ -167,48 +462,164 @@ func (d *ExecTestDataSource) Read(ctx context.Context, req datasource.ReadReques
 	defer cancel()
 
 	// Prepopulate some environment variables:
-	// - any environment variables defined on the host
+	// - any environment variables defined on the host (host driver only)
 	// - IMAGE_NAME: the fully qualified image name
 	// - IMAGE_REPOSITORY: the repository part of the image name
 	// - IMAGE_REGISTRY: the registry part of the image name
-	// - FREE_PORT: a free port on the host
-	// - any environment variables defined in the data source
-	repo := ref.Context().RepositoryStr()
-	registry := ref.Context().RegistryStr()
-	env := append(os.Environ(),
-		"IMAGE_NAME="+data.Digest.ValueString(),
-		"IMAGE_REPOSITORY="+repo,
-		"IMAGE_REGISTRY="+registry,
-	)
-	fp, err := freePort()
+	// - IMAGE_ENTRYPOINT, IMAGE_CMD, IMAGE_USER, IMAGE_EXPOSED_PORTS: from the image config
+	// - FREE_PORT: a free port on the host (not with the k8s driver)
+	// - FREE_PORT_0..N: free_ports free ports on the host (not with the k8s
+	//   driver)
+	// - TEST_ARTIFACTS: a directory to write test artifacts to (not with the
+	//   k8s driver)
+	// - any environment variables defined in the provider's exec_test_env
+	// - any environment variables defined in env_file
+	// - any environment variables defined in the data source, including
+	//   sensitive ones
+	nports := int64(1)
+	if !data.FreePorts.IsNull() {
+		nports = data.FreePorts.ValueInt64()
+	}
+	if data.Driver.ValueString() == driverK8s {
+		nports = 0
+	}
+	env, leases, err := execTestEnv(image, ref, cf, nports, data.FreePortProto.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError("Unable to find free port", fmt.Sprintf("Unable to find free port for ref %s, got error: %s", data.Digest.ValueString(), err))
+		resp.Diagnostics.AddError("Unable to find free port", fmt.Sprintf("Unable to find free port for ref %s, got error: %s", image, err))
 		return
 	}
-	defer discardPort(fp)
-	env = append(env, fmt.Sprintf("FREE_PORT=%d", fp))
+	for _, lease := range leases {
+		defer lease.discard()
+	}
+	env = append(env, d.popts.execTestEnv...)
+	if data.EnvFile.ValueString() != "" {
+		fileEnv, err := readEnvFile(data.EnvFile.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to read env file", fmt.Sprintf("Unable to read env file %s, got error: %s", data.EnvFile.ValueString(), err))
+			return
+		}
+		env = append(env, fileEnv...)
+	}
 	for _, e := range data.Env {
 		env = append(env, fmt.Sprintf("%s=%s", e.Name, e.Value))
 	}
+	secrets := make([]string, 0, len(data.SensitiveEnv))
+	for _, e := range data.SensitiveEnv {
+		env = append(env, fmt.Sprintf("%s=%s", e.Name, e.Value))
+		secrets = append(secrets, e.Value)
+	}
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", data.Script.ValueString())
-	cmd.Env = env
-	cmd.Dir = data.WorkingDir.ValueString()
-
-	fullout, err := cmd.CombinedOutput()
-	data.Output = types.StringValue("") // always empty.
+	artifacts, err := os.MkdirTemp("", "oci-exec-test-")
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to create artifacts directory", fmt.Sprintf("Unable to create artifacts directory for ref %s, got error: %s", image, err))
+		return
+	}
+	data.ArtifactsDir = types.StringValue(artifacts)
+	if dst := data.ArtifactsDestination.ValueString(); dst != "" {
+		defer func() {
+			if !resp.Diagnostics.HasError() {
+				return
+			}
+			if err := os.CopyFS(dst, os.DirFS(artifacts)); err != nil {
+				resp.Diagnostics.AddWarning("Unable to copy test artifacts", fmt.Sprintf("Unable to copy test artifacts from %s to %s, got error: %s", artifacts, dst, err))
+			}
+		}()
+	}
 
-	data.TestedRef = data.Digest
-	data.Id = types.StringValue(md5str(data.Script.ValueString()) + data.Digest.ValueString())
-	data.ExitCode = types.Int64Value(int64(cmd.ProcessState.ExitCode()))
+	workingDir := data.WorkingDir.ValueString()
+	if data.CreateWorkDir.ValueBool() {
+		workingDir, err = os.MkdirTemp("", "oci-exec-test-work-")
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to create working directory", fmt.Sprintf("Unable to create working directory for ref %s, got error: %s", image, err))
+			return
+		}
+		defer os.RemoveAll(workingDir)
+	}
 
-	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-		resp.Diagnostics.AddError("Test timed out", fmt.Sprintf("Test for ref %s timed out after %d seconds:\n%s", data.Digest.ValueString(), timeout, string(fullout)))
-		return
-	} else if err != nil {
-		resp.Diagnostics.AddError("Test failed", fmt.Sprintf("Test failed for ref %s, got error: %s\n%s", data.Digest.ValueString(), err, string(fullout)))
+	spec := execSpec{
+		Driver:       data.Driver.ValueString(),
+		Network:      data.Network.ValueString(),
+		Image:        image,
+		WorkingDir:   workingDir,
+		ArtifactsDir: artifacts,
+		Env:          env,
+		Kubernetes:   data.Kubernetes,
+	}
+	// Whether the command can be prepared doesn't depend on the script, so
+	// check it once, before running anything.
+	if _, err := spec.command(ctx); err != nil {
+		resp.Diagnostics.AddError("Unable to prepare test", fmt.Sprintf("Unable to prepare test for ref %s, got error: %s", image, err))
 		return
 	}
+	// run runs script, returning its redacted output, and the error from
+	// running it, which is an *exec.ExitError if it ran but failed.
+	run := func(script, step string) (string, *os.ProcessState, error) {
+		spec.Script = script
+		cmd, err := spec.command(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// Capture the output for error messages, and stream it to the debug
+		// log as the test runs.
+		var out bytes.Buffer
+		lw := &logWriter{ctx: ctx, secrets: secrets, ref: image, step: step}
+		w := io.MultiWriter(&out, lw)
+		cmd.Stdout = w
+		cmd.Stderr = w
+
+		// Keep the free ports bound until the very last moment, so nothing
+		// else can grab them while we're setting up the test.
+		for _, lease := range leases {
+			lease.release()
+		}
+		if err = cmd.Start(); err == nil {
+			err = cmd.Wait()
+		}
+		lw.flush()
+		return redact(out.String(), secrets), cmd.ProcessState, err
+	}
+
+	if len(data.Steps) > 0 {
+		d.runSteps(ctx, &data, &result, image, timeout, run, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		fullout, state, err := run(data.Script.ValueString(), "")
+		result.Output = fullout
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			resp.Diagnostics.AddError("Test timed out", fmt.Sprintf("Test for ref %s timed out after %d seconds:\n%s", image, timeout, fullout))
+			return
+		}
+
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			// The script didn't run at all, so there's no exit code to check.
+			resp.Diagnostics.AddError("Test failed", fmt.Sprintf("Test failed for ref %s, got error: %s\n%s", image, err, fullout))
+			return
+		}
+		code := state.ExitCode()
+		result.ExitCode = &code
+		data.ExitCode = types.Int64Value(int64(code))
+
+		switch {
+		case data.ExpectFailure.ValueBool():
+			if code == 0 {
+				resp.Diagnostics.AddError("Test failed", fmt.Sprintf("Test for ref %s was expected to fail, but exited successfully\n%s", image, fullout))
+				return
+			}
+		case len(data.ExpectedExitCodes) > 0:
+			if !slices.Contains(data.ExpectedExitCodes, int64(code)) {
+				resp.Diagnostics.AddError("Test failed", fmt.Sprintf("Test failed for ref %s, got exit code %d, expected one of %v\n%s", image, code, data.ExpectedExitCodes, fullout))
+				return
+			}
+		case err != nil:
+			resp.Diagnostics.AddError("Test failed", fmt.Sprintf("Test failed for ref %s, got error: %s\n%s", image, err, fullout))
+			return
+		}
+	}
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
@@ -218,12 +629,198 @@ func (d *ExecTestDataSource) Read(ctx context.Context, req datasource.ReadReques
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
+// runSteps runs the test's steps in order with run, stopping at the first
+// step that doesn't exit with its expected exit code. Errors report how long
+// each step took and which step failed, with that step's output.
+func (d *ExecTestDataSource) runSteps(ctx context.Context, data *ExecTestDataSourceModel, result *execTestResult, image string, timeout int64, run func(script, step string) (string, *os.ProcessState, error), diags *diag.Diagnostics) {
+	var summary, outputs strings.Builder
+	defer func() { result.Output = outputs.String() }()
+	for i, step := range data.Steps {
+		name := step.Name.ValueString()
+		start := time.Now()
+		out, state, err := run(step.Script.ValueString(), name)
+		took := time.Since(start).Round(time.Millisecond)
+		fmt.Fprintf(&outputs, "=== step %s\n%s", name, out)
+
+		// skipped lists the steps that didn't run because this one failed.
+		skipped := func() string {
+			var b strings.Builder
+			for _, s := range data.Steps[i+1:] {
+				fmt.Fprintf(&b, "SKIP %s\n", s.Name.ValueString())
+			}
+			return b.String()
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fmt.Fprintf(&summary, "FAIL %s (%s): timed out\n", name, took)
+			diags.AddError("Test timed out", fmt.Sprintf("Test for ref %s timed out after %d seconds in step %q (%d of %d):\n%s%s\n%s", image, timeout, name, i+1, len(data.Steps), summary.String(), skipped(), out))
+			return
+		}
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			fmt.Fprintf(&summary, "FAIL %s (%s): %s\n", name, took, err)
+			diags.AddError("Test failed", fmt.Sprintf("Test failed for ref %s in step %q (%d of %d), got error: %s\n%s%s\n%s", image, name, i+1, len(data.Steps), err, summary.String(), skipped(), out))
+			return
+		}
+		code := state.ExitCode()
+		result.ExitCode = &code
+		data.ExitCode = types.Int64Value(int64(code))
+
+		if want := step.ExpectedExitCode.ValueInt64(); int64(code) != want {
+			fmt.Fprintf(&summary, "FAIL %s (%s): exit code %d\n", name, took, code)
+			diags.AddError("Test failed", fmt.Sprintf("Test failed for ref %s in step %q (%d of %d), got exit code %d, expected %d\n%s%s\n%s", image, name, i+1, len(data.Steps), code, want, summary.String(), skipped(), out))
+			return
+		}
+		fmt.Fprintf(&summary, "ok   %s (%s)\n", name, took)
+		tflog.Debug(ctx, "exec test step passed", map[string]interface{}{"ref": image, "step": name, "duration_ms": took.Milliseconds()})
+	}
+}
+
+// execTestEnv returns the IMAGE_* and FREE_PORT* environment variables for a
+// test of the image ref, called image by the user, with config cf.
+//
+// The returned leases hold the free ports; the caller must release them right
+// before starting the test and discard them once it's done.
+func execTestEnv(image string, ref name.Digest, cf *v1.ConfigFile, nports int64, proto string) ([]string, []*portLease, error) {
+	env := []string{
+		"IMAGE_NAME=" + image,
+		"IMAGE_REPOSITORY=" + ref.Context().RepositoryStr(),
+		"IMAGE_REGISTRY=" + ref.Context().RegistryStr(),
+	}
+	env = append(env, imageConfigEnv(cf)...)
+
+	if proto == "" {
+		proto = "tcp"
+	}
+	var leases []*portLease
+	for i := int64(0); i < nports; i++ {
+		lease, err := freePort(proto)
+		if err != nil {
+			for _, l := range leases {
+				l.discard()
+			}
+			return nil, nil, err
+		}
+		leases = append(leases, lease)
+		if i == 0 {
+			env = append(env, fmt.Sprintf("FREE_PORT=%d", lease.port))
+		}
+		env = append(env, fmt.Sprintf("FREE_PORT_%d=%d", i, lease.port))
+	}
+	return env, leases, nil
+}
+
+// imageConfigEnv returns IMAGE_* environment variables describing the image
+// config. List values are joined with spaces.
+func imageConfigEnv(cf *v1.ConfigFile) []string {
+	ports := make([]string, 0, len(cf.Config.ExposedPorts))
+	for p := range cf.Config.ExposedPorts {
+		ports = append(ports, p)
+	}
+	sort.Strings(ports)
+
+	return []string{
+		"IMAGE_ENTRYPOINT=" + strings.Join(cf.Config.Entrypoint, " "),
+		"IMAGE_CMD=" + strings.Join(cf.Config.Cmd, " "),
+		"IMAGE_USER=" + cf.Config.User,
+		"IMAGE_EXPOSED_PORTS=" + strings.Join(ports, " "),
+	}
+}
+
+// readEnvFile reads NAME=value lines from the file at path.
+func readEnvFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, _, ok := strings.Cut(line, "="); !ok || k == "" {
+			return nil, fmt.Errorf("line %d: expected NAME=value", i+1)
+		}
+		env = append(env, line)
+	}
+	return env, nil
+}
+
+// logWriter writes each line of output to the debug log, with secrets
+// redacted.
+type logWriter struct {
+	ctx     context.Context
+	secrets []string
+	ref     string
+	// step is the name of the step being run, if the test has steps.
+	step string
+	buf  []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs any remaining partial line.
+func (w *logWriter) flush() {
+	if len(w.buf) > 0 {
+		w.log(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) log(line string) {
+	fields := map[string]interface{}{"ref": w.ref}
+	if w.step != "" {
+		fields["step"] = w.step
+	}
+	tflog.Debug(w.ctx, redact(line, w.secrets), fields)
+}
+
+// redact replaces all occurrences of the secrets in s.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	return s
+}
+
 func md5str(s string) string {
 	h := md5.New()
 	h.Write([]byte(s))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+type oneOfValidator struct {
+	values []string
+}
+
+func (v oneOfValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+func (v oneOfValidator) Description(context.Context) string {
+	return fmt.Sprintf("value must be one of %v", v.values)
+}
+func (v oneOfValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if val := req.ConfigValue.ValueString(); !slices.Contains(v.values, val) {
+		resp.Diagnostics.AddAttributeError(req.Path, fmt.Sprintf("value %q must be one of %v", val, v.values), "")
+	}
+}
+
 type positiveIntValidator struct{}
 
 func (positiveIntValidator) MarkdownDescription(context.Context) string { return "positive integer" }
@@ -234,38 +831,56 @@ func (positiveIntValidator) ValidateInt64(ctx context.Context, req validator.Int
 	}
 }
 
-var mu sync.Mutex
-var freePorts = map[int]bool{}
+type minIntValidator struct {
+	min int64
+}
 
-func freePort() (int, error) {
-	mu.Lock()
-	defer mu.Unlock()
+func (v minIntValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+func (v minIntValidator) Description(context.Context) string {
+	return fmt.Sprintf("value must be at least %d", v.min)
+}
+func (v minIntValidator) ValidateInt64(ctx context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if i := req.ConfigValue.ValueInt64(); i < v.min {
+		resp.Diagnostics.AddAttributeError(req.Path, fmt.Sprintf("value %d must be at least %d", i, v.min), "")
+	}
+}
 
-	for {
-		addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
-		if err != nil {
-			return 0, err
-		}
+// minItemsValidator requires a list to have at least min elements. Unlike
+// the other validators, it counts an unset list as empty, so it can make
+// a block required.
+type minItemsValidator struct {
+	min int
+}
 
-		l, err := net.ListenTCP("tcp", addr)
-		if err != nil {
-			return 0, err
-		}
-		defer l.Close()
-		ta, ok := l.Addr().(*net.TCPAddr)
-		if !ok {
-			return 0, fmt.Errorf("failed to get port")
-		}
-		if freePorts[ta.Port] {
-			tflog.Debug(context.Background(), "port already in use, trying again", map[string]interface{}{"port": ta.Port})
-			continue
-		}
-		return ta.Port, nil
+func (v minItemsValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+func (v minItemsValidator) Description(context.Context) string {
+	return fmt.Sprintf("list must have at least %d elements", v.min)
+}
+func (v minItemsValidator) ValidateList(ctx context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	if req.ConfigValue.IsUnknown() {
+		return
+	}
+	if n := len(req.ConfigValue.Elements()); n < v.min {
+		resp.Diagnostics.AddAttributeError(req.Path, fmt.Sprintf("%d elements given, at least %d required", n, v.min), "")
 	}
 }
 
-func discardPort(port int) {
-	mu.Lock()
-	defer mu.Unlock()
-	delete(freePorts, port)
+type intRangeValidator struct {
+	min, max int64
+}
+
+func (v intRangeValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+func (v intRangeValidator) Description(context.Context) string {
+	return fmt.Sprintf("value must be between %d and %d", v.min, v.max)
+}
+func (v intRangeValidator) ValidateInt64(ctx context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if i := req.ConfigValue.ValueInt64(); i < v.min || i > v.max {
+		resp.Diagnostics.AddAttributeError(req.Path, fmt.Sprintf("value %d must be between %d and %d", i, v.min, v.max), "")
+	}
 }
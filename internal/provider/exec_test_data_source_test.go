@@ -1,15 +1,26 @@
 package provider
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
 )
 
 func TestAccExecTestDataSource(t *testing.T) {
@@ -22,6 +33,11 @@ func TestAccExecTestDataSource(t *testing.T) {
 		t.Fatalf("failed to get image digest: %v", err)
 	}
 
+	envFile := filepath.Join(t.TempDir(), "test.env")
+	if err := os.WriteFile(envFile, []byte("# comment\nFROM_FILE=hello\n\nOVERRIDDEN=by-file\n"), 0644); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
@@ -66,6 +82,141 @@ func TestAccExecTestDataSource(t *testing.T) {
 }`, d.String()),
 			ExpectError: regexp.MustCompile(`Test failed for ref\ncgr.dev/chainguard/wolfi-base@sha256:[0-9a-f]{64},\ngot error: exit status 12\nfailed`),
 			// We don't get the exit code or output because the datasource failed.
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "expected-exit-code" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  expected_exit_codes = [0, 2]
+  script = "exit 2"
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.expected-exit-code", "exit_code", "2"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "unexpected-exit-code" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  expected_exit_codes = [0, 2]
+  script = "exit 3"
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`got exit code 3, expected one of \[0 2\]`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "steps" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  steps = [
+    { name = "setup", script = "echo setting up" },
+    { name = "check", script = "echo checking && exit 4", expected_exit_code = 4 },
+  ]
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.steps", "exit_code", "4"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "failing-step" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  steps = [
+    { name = "setup", script = "true" },
+    { name = "check", script = "echo broken && exit 1" },
+    { name = "teardown", script = "true" },
+  ]
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`in step "check" \(2 of 3\), got exit code 1, expected 0`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "script-and-steps" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  script = "true"
+  steps  = [{ name = "setup", script = "true" }]
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`Exactly one of script and steps must be set`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "expect-failure" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  expect_failure = true
+  script = "exit 1"
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.expect-failure", "exit_code", "1"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "expect-failure-succeeded" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  expect_failure = true
+  script = "exit 0"
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`was expected to fail, but exited successfully`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "docker-driver" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  driver = "docker"
+
+  env {
+	name  = "FOO"
+	value = "bar"
+  }
+
+  script = "grep -q ID=wolfi /etc/os-release && test \"$${FOO}\" = bar && test -n \"$${IMAGE_NAME}\""
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.docker-driver", "exit_code", "0"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "bad-driver" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  driver = "podman"
+
+  script = "true"
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`must be one of`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "prepull-k8s" {
+  digest  = "cgr.dev/chainguard/wolfi-base@%s"
+  driver  = "k8s"
+  prepull = "docker"
+
+  script = "true"
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`cannot be combined with driver = "k8s"`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "sensitive-env" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  sensitive_env {
+	name  = "TOKEN"
+	value = "hunter2"
+  }
+
+  script = "echo token is $${TOKEN} && exit 1"
+}`, d.String()),
+			ExpectError: regexp.MustCompile(`token is \[REDACTED\]`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "image-config" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  script = "test \"$${IMAGE_ENTRYPOINT}\" = \"\" && test \"$${IMAGE_CMD}\" = \"/bin/sh -l\""
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.image-config", "exit_code", "0"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "env-file" {
+  digest   = "cgr.dev/chainguard/wolfi-base@%s"
+  env_file = %q
+
+  env {
+	name  = "OVERRIDDEN"
+	value = "by-env"
+  }
+
+  script = "test \"$${FROM_FILE}\" = hello && test \"$${OVERRIDDEN}\" = by-env"
+}`, d.String(), envFile),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.env-file", "exit_code", "0"),
+			),
 		}, {
 			Config: fmt.Sprintf(`data "oci_exec_test" "timeout" {
 	  digest = "cgr.dev/chainguard/wolfi-base@%s"
@@ -87,6 +238,48 @@ func TestAccExecTestDataSource(t *testing.T) {
 				resource.TestCheckResourceAttr("data.oci_exec_test.working_dir", "exit_code", "0"),
 				resource.TestCheckResourceAttr("data.oci_exec_test.working_dir", "output", ""),
 			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "working_dir" {
+		  digest = "cgr.dev/chainguard/wolfi-base@%s"
+		  working_dir = "${path.module}/does-not-exist"
+
+		  script = "true"
+		}`, d.String()),
+			ExpectError: regexp.MustCompile(`does not exist`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "create_working_dir" {
+		  digest = "cgr.dev/chainguard/wolfi-base@%s"
+		  create_working_dir = true
+
+		  script = "test -z \"$(ls -A)\" && touch scratch"
+		}`, d.String()),
+			Check: resource.TestCheckResourceAttr("data.oci_exec_test.create_working_dir", "exit_code", "0"),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "create_working_dir" {
+		  digest = "cgr.dev/chainguard/wolfi-base@%s"
+		  working_dir = "."
+		  create_working_dir = true
+
+		  script = "true"
+		}`, d.String()),
+			ExpectError: regexp.MustCompile(`create_working_dir cannot be combined with working_dir`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "network" {
+		  digest  = "cgr.dev/chainguard/wolfi-base@%s"
+		  network = "bridge"
+
+		  script = "true"
+		}`, d.String()),
+			ExpectError: regexp.MustCompile(`network can only be set with driver = "docker"`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "k8s_free_ports" {
+		  digest     = "cgr.dev/chainguard/wolfi-base@%s"
+		  driver     = "k8s"
+		  free_ports = 2
+
+		  script = "true"
+		}`, d.String()),
+			ExpectError: regexp.MustCompile(`free_ports cannot be combined with driver = "k8s"`),
 		}},
 	})
 
@@ -144,6 +337,203 @@ func TestAccExecTestDataSource_FreePort(t *testing.T) {
 	})
 }
 
+func TestAccExecTestDataSource_MultipleFreePorts(t *testing.T) {
+	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
+	if err != nil {
+		t.Fatalf("failed to fetch image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_exec_test" "tcp" {
+  digest     = "cgr.dev/chainguard/wolfi-base@%s"
+  free_ports = 3
+  script     = "test $${FREE_PORT} = $${FREE_PORT_0} && test -n \"$${FREE_PORT_2}\" && test $${FREE_PORT_0} != $${FREE_PORT_1} && test $${FREE_PORT_1} != $${FREE_PORT_2}"
+}
+
+data "oci_exec_test" "udp" {
+  digest             = "cgr.dev/chainguard/wolfi-base@%s"
+  free_ports         = 2
+  free_port_protocol = "udp"
+  script             = "test -n \"$${FREE_PORT_1}\" && test $${FREE_PORT_0} != $${FREE_PORT_1}"
+}`, d.String(), d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.tcp", "exit_code", "0"),
+				resource.TestCheckResourceAttr("data.oci_exec_test.udp", "exit_code", "0"),
+			),
+		}},
+	})
+}
+
+func TestAccExecTestDataSource_MaxParallel(t *testing.T) {
+	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
+	if err != nil {
+		t.Fatalf("failed to fetch image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	// Each test fails if another test is running at the same time.
+	lock := filepath.Join(t.TempDir(), "lock")
+	cfg := ""
+	for i := 0; i < 5; i++ {
+		cfg += fmt.Sprintf(`data "oci_exec_test" "parallel-%d" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  script = "mkdir %s && sleep 1 && rmdir %s"
+}
+`, i, d.String(), lock, lock)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"oci": providerserver.NewProtocol6WithError(&OCIProvider{
+				maxParallelExecTests: 1,
+			}),
+		},
+		Steps: []resource.TestStep{{
+			Config: cfg,
+		}},
+	})
+}
+
+func TestAccExecTestDataSource_Artifacts(t *testing.T) {
+	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
+	if err != nil {
+		t.Fatalf("failed to fetch image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "artifacts")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_exec_test" "artifacts" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  script = "echo hello > $${TEST_ARTIFACTS}/out.log"
+}`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttrWith("data.oci_exec_test.artifacts", "artifacts_dir", func(dir string) error {
+					b, err := os.ReadFile(filepath.Join(dir, "out.log"))
+					if err != nil {
+						return err
+					}
+					if got, want := string(b), "hello\n"; got != want {
+						return fmt.Errorf("got %q, want %q", got, want)
+					}
+					return nil
+				}),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_exec_test" "artifacts-on-failure" {
+  digest                = "cgr.dev/chainguard/wolfi-base@%s"
+  artifacts_destination = %q
+  script                = "echo failed > $${TEST_ARTIFACTS}/out.log && exit 1"
+}`, d.String(), dst),
+			ExpectError: regexp.MustCompile(`Test failed`),
+		}, {
+			// The previous step copied the artifacts to the destination.
+			Config: fmt.Sprintf(`data "oci_exec_test" "check-destination" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  script = "grep failed %s/out.log"
+}`, d.String(), dst),
+		}},
+	})
+}
+
+func TestAccExecTestDataSource_Report(t *testing.T) {
+	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
+	if err != nil {
+		t.Fatalf("failed to fetch image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	report := filepath.Join(t.TempDir(), "report.tap")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`provider "oci" {
+  exec_test_report = {
+    path   = %q
+    format = "tap"
+  }
+}
+
+data "oci_exec_test" "report" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  name   = "hello"
+  script = "echo hello"
+}`, report, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				func(*terraform.State) error {
+					b, err := os.ReadFile(report)
+					if err != nil {
+						return err
+					}
+					if !strings.Contains(string(b), "ok 1 - hello\n") {
+						return fmt.Errorf("report does not contain the test result:\n%s", b)
+					}
+					return nil
+				},
+			),
+		}},
+	})
+}
+
+func TestAccExecTestDataSource_ProviderEnv(t *testing.T) {
+	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
+	if err != nil {
+		t.Fatalf("failed to fetch image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`provider "oci" {
+  exec_test_env = {
+    FROM_PROVIDER = "hello"
+    OVERRIDDEN    = "by-provider"
+  }
+}
+
+data "oci_exec_test" "env" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+
+  env {
+    name  = "OVERRIDDEN"
+    value = "by-test"
+  }
+
+  script = "test \"$${FROM_PROVIDER}\" = hello && test \"$${OVERRIDDEN}\" = by-test"
+}`, d.String()),
+			Check: resource.TestCheckResourceAttr("data.oci_exec_test.env", "exit_code", "0"),
+		}},
+	})
+}
+
 func TestAccExecTestDataSource_SkipExecTests(t *testing.T) {
 	img, err := remote.Image(name.MustParseReference("cgr.dev/chainguard/wolfi-base:latest"))
 	if err != nil {
@@ -165,6 +555,121 @@ func TestAccExecTestDataSource_SkipExecTests(t *testing.T) {
   digest = "cgr.dev/chainguard/wolfi-base@%s"
   script = "exit 1"
 }`, d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.skipped", "skipped", "true"),
+				resource.TestCheckResourceAttr("data.oci_exec_test.skipped", "skip_reason", "Skipping exec tests as per provider configuration"),
+			),
 		}},
 	})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_exec_test" "skipped" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  skip   = true
+  script = "exit 1"
+}
+
+data "oci_exec_test" "not-skipped" {
+  digest = "cgr.dev/chainguard/wolfi-base@%s"
+  skip   = false
+  script = "exit 0"
+}`, d.String(), d.String()),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_exec_test.skipped", "skipped", "true"),
+				resource.TestCheckResourceAttr("data.oci_exec_test.skipped", "skip_reason", "Skipping exec test as per data source configuration"),
+				resource.TestCheckNoResourceAttr("data.oci_exec_test.skipped", "exit_code"),
+				resource.TestCheckResourceAttr("data.oci_exec_test.not-skipped", "skipped", "false"),
+				resource.TestCheckResourceAttr("data.oci_exec_test.not-skipped", "exit_code", "0"),
+			),
+		}},
+	})
+}
+
+func TestRunSteps(t *testing.T) {
+	run := func(script, step string) (string, *os.ProcessState, error) {
+		cmd := exec.Command("sh", "-c", script)
+		out, err := cmd.CombinedOutput()
+		return string(out), cmd.ProcessState, err
+	}
+	step := func(name, script string, code int64) ExecTestStep {
+		s := ExecTestStep{Name: types.StringValue(name), Script: types.StringValue(script), ExpectedExitCode: types.Int64Null()}
+		if code != 0 {
+			s.ExpectedExitCode = types.Int64Value(code)
+		}
+		return s
+	}
+	d := &ExecTestDataSource{}
+
+	data := &ExecTestDataSourceModel{Steps: []ExecTestStep{
+		step("setup", "echo setting up", 0),
+		step("check", "echo checking; exit 3", 3),
+	}}
+	var result execTestResult
+	var diags diag.Diagnostics
+	d.runSteps(context.Background(), data, &result, "example.com/image", 60, run, &diags)
+	if diags.HasError() {
+		t.Fatalf("runSteps: %v", diags)
+	}
+	if data.ExitCode.ValueInt64() != 3 {
+		t.Errorf("got exit code %d, want 3", data.ExitCode.ValueInt64())
+	}
+	if want := "=== step setup\nsetting up\n=== step check\nchecking\n"; result.Output != want {
+		t.Errorf("got output %q, want %q", result.Output, want)
+	}
+
+	data = &ExecTestDataSourceModel{Steps: []ExecTestStep{
+		step("setup", "echo setting up", 0),
+		step("check", "echo broken; exit 1", 0),
+		step("teardown", "echo tearing down", 0),
+	}}
+	diags = nil
+	d.runSteps(context.Background(), data, &result, "example.com/image", 60, run, &diags)
+	if !diags.HasError() {
+		t.Fatal("runSteps: want error")
+	}
+	got := diags.Errors()[0].Detail()
+	for _, want := range []string{
+		`Test failed for ref example.com/image in step "check" (2 of 3), got exit code 1, expected 0`,
+		"ok   setup (",
+		"FAIL check (",
+		"SKIP teardown",
+		"broken",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error %q doesn't contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "setting up") || strings.Contains(result.Output, "tearing down") {
+		t.Errorf("got output of other steps: %q, %q", got, result.Output)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+
+	w := &logWriter{ctx: ctx, secrets: []string{"hunter2"}, ref: "example.com/image@sha256:abc"}
+	for _, s := range []string{"hello\nwor", "ld\n", "password is hunter2\n", "no newline"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	w.flush()
+
+	entries, err := tflogtest.MultilineJSONDecode(&buf)
+	if err != nil {
+		t.Fatalf("decoding log entries: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		msg, _ := e["@message"].(string)
+		got = append(got, msg)
+	}
+	want := []string{"hello", "world", "password is [REDACTED]", "no newline"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
 }
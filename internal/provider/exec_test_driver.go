@@ -0,0 +1,209 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	driverHost   = "host"
+	driverDocker = "docker"
+	driverK8s    = "k8s"
+)
+
+var execDrivers = []string{driverHost, driverDocker, driverK8s}
+
+// execSpec describes how to run an exec test script.
+type execSpec struct {
+	Driver       string
+	Image        string
+	Script       string
+	WorkingDir   string
+	ArtifactsDir string
+
+	// Network is the docker driver's network, or host if it's empty.
+	Network string
+
+	// Env holds the test-specific environment variables (IMAGE_NAME,
+	// FREE_PORT, user-provided env, etc.).
+	Env []string
+
+	// Kubernetes configures the k8s driver.
+	Kubernetes *KubernetesConfig
+}
+
+// KubernetesConfig describes where the k8s driver runs test Pods.
+type KubernetesConfig struct {
+	Kubeconfig types.String `tfsdk:"kubeconfig"`
+	Context    types.String `tfsdk:"context"`
+	Namespace  types.String `tfsdk:"namespace"`
+}
+
+// containerArtifactsDir is where the artifacts directory is mounted for
+// containerized drivers.
+const containerArtifactsDir = "/artifacts"
+
+// command returns the command that runs the script with the spec's driver.
+//
+// The returned command always inherits the host environment so that tools
+// like the docker CLI keep working; for containerized drivers only the spec's
+// Env is forwarded into the container.
+func (s execSpec) command(ctx context.Context) (*exec.Cmd, error) {
+	switch s.Driver {
+	case "", driverHost:
+		if err := checkWorkingDir(s.WorkingDir); err != nil {
+			return nil, err
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", s.Script)
+		cmd.Env = append(os.Environ(), s.Env...)
+		if s.ArtifactsDir != "" {
+			cmd.Env = append(cmd.Env, "TEST_ARTIFACTS="+s.ArtifactsDir)
+		}
+		cmd.Dir = s.WorkingDir
+		return cmd, nil
+
+	case driverDocker:
+		// FREE_PORT ports are free on the host, so by default share its
+		// network, where the script can listen on them and reach the
+		// host's localhost.
+		network := s.Network
+		if network == "" {
+			network = "host"
+		}
+		// The container is named so it can be removed if the test is
+		// cancelled, and --init forwards signals to sh.
+		name := runName()
+		args := []string{"run", "--rm", "--init", "--name", name, "--network", network, "--entrypoint", "sh"}
+		for _, e := range s.Env {
+			// Pass only the name so the value is read from the docker CLI's
+			// environment and never shows up in the process args.
+			k, _, _ := strings.Cut(e, "=")
+			args = append(args, "-e", k)
+		}
+		if err := checkWorkingDir(s.WorkingDir); err != nil {
+			return nil, err
+		}
+		if s.WorkingDir != "" {
+			abs, err := filepath.Abs(s.WorkingDir)
+			if err != nil {
+				return nil, fmt.Errorf("resolving working directory %q: %w", s.WorkingDir, err)
+			}
+			args = append(args, "-v", abs+":/work", "-w", "/work")
+		}
+		if s.ArtifactsDir != "" {
+			args = append(args,
+				"-v", s.ArtifactsDir+":"+containerArtifactsDir,
+				"-e", "TEST_ARTIFACTS="+containerArtifactsDir)
+		}
+		args = append(args, s.Image, "-c", s.Script)
+
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Env = append(os.Environ(), s.Env...)
+		cancelWith(ctx, cmd, "docker", "rm", "-f", name)
+		return cmd, nil
+
+	case driverK8s:
+		if s.WorkingDir != "" {
+			return nil, fmt.Errorf("working_dir is not supported with the %s driver", driverK8s)
+		}
+		// The flags that pick the cluster and namespace are shared by the
+		// run and the delete that cleans up after a cancelled test.
+		var flags []string
+		if k := s.Kubernetes; k != nil {
+			if v := k.Kubeconfig.ValueString(); v != "" {
+				flags = append(flags, "--kubeconfig", v)
+			}
+			if v := k.Context.ValueString(); v != "" {
+				flags = append(flags, "--context", v)
+			}
+			if v := k.Namespace.ValueString(); v != "" {
+				flags = append(flags, "--namespace", v)
+			}
+		}
+		pod := runName()
+		args := append(slices.Clone(flags), "run", pod,
+			"--image", s.Image,
+			"--restart", "Never",
+			"--rm", "--attach", "--stdin", "--quiet",
+			"--command", "--", "sh", "-s")
+
+		// The environment and script are sent over stdin so that secret
+		// values never show up in the process args or the Pod spec.
+		var script strings.Builder
+		for _, e := range s.Env {
+			k, v, _ := strings.Cut(e, "=")
+			fmt.Fprintf(&script, "export %s=%s\n", k, shellQuote(v))
+		}
+		script.WriteString(s.Script)
+		script.WriteString("\n")
+
+		cmd := exec.CommandContext(ctx, "kubectl", args...)
+		cmd.Env = os.Environ()
+		cmd.Stdin = strings.NewReader(script.String())
+		del := append([]string{"kubectl"}, flags...)
+		cancelWith(ctx, cmd, append(del, "delete", "pod", pod, "--wait=false")...)
+		return cmd, nil
+	}
+	return nil, fmt.Errorf("unsupported driver %q, must be one of %v", s.Driver, execDrivers)
+}
+
+// runName returns a name for a test's container or Pod that won't collide
+// with other tests, even on other machines.
+func runName() string {
+	return fmt.Sprintf("oci-exec-test-%d-%08x", time.Now().UnixNano(), rand.Uint32())
+}
+
+// cleanupTimeout bounds the command that cancelWith runs.
+const cleanupTimeout = 30 * time.Second
+
+// cancelWith makes cmd, when ctx is done, run the cleanup command before it's
+// killed. Killing the docker or kubectl CLI alone leaves the container or Pod
+// it started running.
+func cancelWith(ctx context.Context, cmd *exec.Cmd, cleanup ...string) {
+	cmd.Cancel = func() error {
+		cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+		c := exec.CommandContext(cctx, cleanup[0], cleanup[1:]...)
+		c.Env = cmd.Env
+		if out, err := c.CombinedOutput(); err != nil {
+			tflog.Warn(ctx, "unable to clean up cancelled test", map[string]interface{}{"command": strings.Join(cleanup, " "), "error": err.Error(), "output": string(out)})
+		}
+		return cmd.Process.Kill()
+	}
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// checkWorkingDir returns an error if dir is set but isn't a directory, which
+// would otherwise only fail when the script is run, with an opaque error from
+// starting the shell or docker.
+func checkWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	fi, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("working directory %q does not exist", dir)
+	} else if err != nil {
+		return fmt.Errorf("checking working directory %q: %w", dir, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("working directory %q is not a directory", dir)
+	}
+	return nil
+}
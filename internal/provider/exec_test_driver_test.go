@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// withoutName returns args with the value of its --name flag, which is
+// random, replaced by NAME.
+func withoutName(args []string) []string {
+	args = slices.Clone(args)
+	if i := slices.Index(args, "--name"); i >= 0 && i+1 < len(args) {
+		args[i+1] = "NAME"
+	}
+	return args
+}
+
+// fakeCLI puts a fake command called name first on the PATH, which logs its
+// args to the file it returns, one call per line, and runs script.
+func fakeCLI(t *testing.T, name, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(fmt.Sprintf("#!/bin/sh\necho \"$*\" >> %s\n%s\n", calls, script)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+func TestExecSpecCommand(t *testing.T) {
+	ctx := context.Background()
+	image := "example.com/image@sha256:1234567890123456789012345678901234567890123456789012345678901234"
+
+	t.Run("docker", func(t *testing.T) {
+		cmd, err := execSpec{
+			Driver:       driverDocker,
+			Image:        image,
+			Script:       "echo hello",
+			ArtifactsDir: "/tmp/artifacts",
+			Env:          []string{"FOO=secret"},
+		}.command(ctx)
+		if err != nil {
+			t.Fatalf("command: %v", err)
+		}
+		want := []string{"docker", "run", "--rm", "--init", "--name", "NAME", "--network", "host", "--entrypoint", "sh", "-e", "FOO", "-v", "/tmp/artifacts:/artifacts", "-e", "TEST_ARTIFACTS=/artifacts", image, "-c", "echo hello"}
+		if !slices.Equal(withoutName(cmd.Args), want) {
+			t.Errorf("args: got %q, want %q", cmd.Args, want)
+		}
+		if !slices.Contains(cmd.Env, "FOO=secret") {
+			t.Errorf("env does not contain FOO")
+		}
+	})
+
+	t.Run("docker network", func(t *testing.T) {
+		cmd, err := execSpec{Driver: driverDocker, Network: "bridge", Image: image, Script: "echo hello"}.command(ctx)
+		if err != nil {
+			t.Fatalf("command: %v", err)
+		}
+		want := []string{"docker", "run", "--rm", "--init", "--name", "NAME", "--network", "bridge", "--entrypoint", "sh", image, "-c", "echo hello"}
+		if !slices.Equal(withoutName(cmd.Args), want) {
+			t.Errorf("args: got %q, want %q", cmd.Args, want)
+		}
+	})
+
+	t.Run("k8s", func(t *testing.T) {
+		cmd, err := execSpec{
+			Driver: driverK8s,
+			Image:  image,
+			Script: "echo hello",
+			Env:    []string{"FOO=it's a secret"},
+			Kubernetes: &KubernetesConfig{
+				Context:   types.StringValue("kind-kind"),
+				Namespace: types.StringValue("tests"),
+			},
+		}.command(ctx)
+		if err != nil {
+			t.Fatalf("command: %v", err)
+		}
+		if !slices.Equal(cmd.Args[:5], []string{"kubectl", "--context", "kind-kind", "--namespace", "tests"}) {
+			t.Errorf("args: got %q", cmd.Args)
+		}
+		if !slices.Contains(cmd.Args, image) {
+			t.Errorf("args do not contain the image: %q", cmd.Args)
+		}
+		if pod := cmd.Args[slices.Index(cmd.Args, "run")+1]; !regexp.MustCompile(`^oci-exec-test-\d+-[0-9a-f]{8}$`).MatchString(pod) {
+			t.Errorf("pod name %q doesn't have a random suffix", pod)
+		}
+		if strings.Contains(strings.Join(cmd.Args, " "), "secret") {
+			t.Errorf("args contain a secret: %q", cmd.Args)
+		}
+		b, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			t.Fatalf("reading stdin: %v", err)
+		}
+
+		// The script sent to the Pod evaluates correctly.
+		out, err := exec.Command("sh", "-c", string(b)+`echo "$FOO"`).Output()
+		if err != nil {
+			t.Fatalf("running script: %v", err)
+		}
+		if got, want := string(out), "hello\nit's a secret\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("k8s working_dir", func(t *testing.T) {
+		if _, err := (execSpec{Driver: driverK8s, WorkingDir: "."}).command(ctx); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("missing working_dir", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, driver := range []string{driverHost, driverDocker} {
+			for dir, want := range map[string]string{
+				filepath.Join(t.TempDir(), "typo"): "does not exist",
+				file:                               "is not a directory",
+			} {
+				if _, err := (execSpec{Driver: driver, Image: image, WorkingDir: dir}).command(ctx); err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("%s with working_dir %s: got error %v, want %q", driver, dir, err, want)
+				}
+			}
+		}
+	})
+}
+
+func TestExecSpecCommandCancel(t *testing.T) {
+	image := "example.com/image@sha256:1234567890123456789012345678901234567890123456789012345678901234"
+
+	t.Run("docker", func(t *testing.T) {
+		// The fake docker run never exits on its own, like a container
+		// that's still running.
+		calls := fakeCLI(t, "docker", `[ "$1" = run ] && exec sleep 60`)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		cmd, err := execSpec{Driver: driverDocker, Image: image, Script: "sleep 60"}.command(ctx)
+		if err != nil {
+			t.Fatalf("command: %v", err)
+		}
+		if err := cmd.Run(); err == nil {
+			t.Fatal("timed out run succeeded")
+		}
+
+		name := cmd.Args[slices.Index(cmd.Args, "--name")+1]
+		b, err := os.ReadFile(calls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if want := "rm -f " + name; !slices.Contains(lines, want) {
+			t.Errorf("docker calls: got %q, want %q, leaving no container behind", lines, want)
+		}
+	})
+	t.Run("k8s", func(t *testing.T) {
+		calls := fakeCLI(t, "kubectl", `[ "$3" = run ] && exec sleep 60`)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		cmd, err := execSpec{
+			Driver:     driverK8s,
+			Image:      image,
+			Script:     "sleep 60",
+			Kubernetes: &KubernetesConfig{Namespace: types.StringValue("tests")},
+		}.command(ctx)
+		if err != nil {
+			t.Fatalf("command: %v", err)
+		}
+		if err := cmd.Run(); err == nil {
+			t.Fatal("timed out run succeeded")
+		}
+
+		pod := cmd.Args[slices.Index(cmd.Args, "run")+1]
+		b, err := os.ReadFile(calls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if want := "--namespace tests delete pod " + pod + " --wait=false"; !slices.Contains(lines, want) {
+			t.Errorf("kubectl calls: got %q, want %q, leaving no Pod behind", lines, want)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	prepullNone   = "none"
+	prepullDocker = "docker"
+)
+
+var prepullModes = []string{prepullNone, prepullDocker}
+
+// prepuller makes sure each image is only pulled once at a time, however many
+// tests of it run in parallel.
+type prepuller struct {
+	mu    sync.Mutex
+	pulls map[string]*prepull
+}
+
+type prepull struct {
+	done chan struct{}
+	err  error
+}
+
+func newPrepuller() *prepuller {
+	return &prepuller{pulls: map[string]*prepull{}}
+}
+
+// do calls pull unless it has already succeeded for key, or is in progress,
+// in which case it waits for that call instead. Failed pulls are retried by
+// the next caller.
+func (p *prepuller) do(ctx context.Context, key string, pull func() error) error {
+	p.mu.Lock()
+	if pp, ok := p.pulls[key]; ok {
+		p.mu.Unlock()
+		select {
+		case <-pp.done:
+			if pp.err != nil {
+				return p.do(ctx, key, pull)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	pp := &prepull{done: make(chan struct{})}
+	p.pulls[key] = pp
+	p.mu.Unlock()
+
+	pp.err = pull()
+	if pp.err != nil {
+		p.mu.Lock()
+		delete(p.pulls, key)
+		p.mu.Unlock()
+	}
+	close(pp.done)
+	return pp.err
+}
+
+// prepullDocker pulls ref into the local docker daemon, with credentials from
+// the provider's keychain rather than the docker CLI's.
+func (p *ProviderOpts) prepullDocker(ctx context.Context, ref name.Digest) error {
+	pull := func() error { return dockerPull(ctx, p.keychain, ref) }
+	if p.prepulls == nil {
+		return pull()
+	}
+	return p.prepulls.do(ctx, ref.String(), pull)
+}
+
+// dockerPull runs docker pull for ref, with a temporary docker config that
+// holds only the credentials that kc has for ref's registry. If kc is nil,
+// the image is pulled anonymously.
+func dockerPull(ctx context.Context, kc authn.Keychain, ref name.Digest) error {
+	cfg, err := dockerConfig(ctx, kc, ref.Context())
+	if err != nil {
+		return fmt.Errorf("resolving credentials: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "oci-exec-test-docker-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), cfg, 0600); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", "pull", "--quiet", ref.String())
+	cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("docker pull %s: %w\n%s", ref, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// dockerConfig returns a docker CLI config.json with the credentials that kc
+// has for repo's registry.
+func dockerConfig(ctx context.Context, kc authn.Keychain, repo name.Repository) ([]byte, error) {
+	ac := &authn.AuthConfig{}
+	if kc != nil {
+		a, err := authn.Resolve(ctx, kc, repo)
+		if err != nil {
+			return nil, err
+		}
+		if ac, err = authn.Authorization(ctx, a); err != nil {
+			return nil, err
+		}
+	}
+	return marshalDockerConfig(repo.RegistryStr(), ac)
+}
+
+// marshalDockerConfig returns a docker CLI config.json with the credentials
+// ac for registry, or none if ac is empty.
+func marshalDockerConfig(registry string, ac *authn.AuthConfig) ([]byte, error) {
+	type authEntry struct {
+		Auth          string `json:"auth,omitempty"`
+		IdentityToken string `json:"identitytoken,omitempty"`
+		RegistryToken string `json:"registrytoken,omitempty"`
+	}
+	auths := map[string]authEntry{}
+	e := authEntry{Auth: ac.Auth, IdentityToken: ac.IdentityToken, RegistryToken: ac.RegistryToken}
+	if ac.Username != "" || ac.Password != "" {
+		e.Auth = base64.StdEncoding.EncodeToString([]byte(ac.Username + ":" + ac.Password))
+	}
+	if e != (authEntry{}) {
+		// The docker CLI knows Docker Hub by its v1 index URL.
+		if registry == name.DefaultRegistry {
+			registry = authn.DefaultAuthKey
+		}
+		auths[registry] = e
+	}
+	return json.Marshal(map[string]any{"auths": auths})
+}
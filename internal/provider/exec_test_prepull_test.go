@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestPrepuller(t *testing.T) {
+	p := newPrepuller()
+	var pulls atomic.Int32
+	release := make(chan struct{})
+	pull := func() error {
+		pulls.Add(1)
+		<-release
+		return nil
+	}
+
+	// Concurrent pulls of the same image share one pull.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.do(context.Background(), "img", pull); err != nil {
+				t.Errorf("do: %v", err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if n := pulls.Load(); n != 1 {
+		t.Errorf("got %d pulls, want 1", n)
+	}
+	// Once it's pulled, it's not pulled again.
+	if err := p.do(context.Background(), "img", pull); err != nil || pulls.Load() != 1 {
+		t.Errorf("do again: %v, %d pulls", err, pulls.Load())
+	}
+
+	// Failed pulls are retried.
+	fails := 0
+	fail := func() error {
+		fails++
+		return errors.New("boom")
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.do(context.Background(), "other", fail); err == nil {
+			t.Error("do: want error")
+		}
+	}
+	if fails != 2 {
+		t.Errorf("got %d failed pulls, want 2", fails)
+	}
+}
+
+// staticKeychain returns the same credentials for every registry.
+type staticKeychain authn.AuthConfig
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return authn.FromConfig(authn.AuthConfig(k)), nil
+}
+
+func TestDockerConfig(t *testing.T) {
+	for _, c := range []struct {
+		desc string
+		kc   authn.Keychain
+		repo string
+		want string
+	}{{
+		desc: "anonymous",
+		repo: "registry.example.com/repo",
+		want: `{"auths":{}}`,
+	}, {
+		desc: "no credentials",
+		kc:   authn.NewMultiKeychain(),
+		repo: "registry.example.com/repo",
+		want: `{"auths":{}}`,
+	}, {
+		desc: "basic",
+		kc:   staticKeychain{Username: "user", Password: "pass"},
+		repo: "registry.example.com/repo",
+		want: `{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`,
+	}, {
+		desc: "token on Docker Hub",
+		kc:   staticKeychain{RegistryToken: "token"},
+		repo: "library/alpine",
+		want: `{"auths":{"https://index.docker.io/v1/":{"registrytoken":"token"}}}`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			repo, err := name.NewRepository(c.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := dockerConfig(context.Background(), c.kc, repo)
+			if err != nil {
+				t.Fatalf("dockerConfig: %v", err)
+			}
+			if string(got) != c.want {
+				t.Errorf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
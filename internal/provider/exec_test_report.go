@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	reportFormatJUnit = "junit"
+	reportFormatTAP   = "tap"
+)
+
+var reportFormats = []string{reportFormatJUnit, reportFormatTAP}
+
+// execTestResult is the outcome of a single exec test.
+type execTestResult struct {
+	Name       string
+	Duration   time.Duration
+	ExitCode   *int
+	Output     string
+	Failure    string // empty if the test passed
+	SkipReason string // empty if the test ran
+}
+
+// execTestReport collects exec test results across a Terraform operation and
+// writes them to a file. A nil report discards results.
+type execTestReport struct {
+	path   string
+	format string
+
+	mu      sync.Mutex
+	results []execTestResult
+}
+
+func newExecTestReport(path, format string) *execTestReport {
+	if format == "" {
+		format = reportFormatJUnit
+	}
+	return &execTestReport{path: path, format: format}
+}
+
+// record adds the result to the report and rewrites the report file, so the
+// file is complete no matter which test finishes last.
+func (r *execTestReport) record(res execTestResult) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, res)
+
+	var b []byte
+	switch r.format {
+	case reportFormatJUnit:
+		var err error
+		if b, err = junitReport(r.results); err != nil {
+			return err
+		}
+	case reportFormatTAP:
+		b = []byte(tapReport(r.results))
+	default:
+		return fmt.Errorf("unsupported report format %q, must be one of %v", r.format, reportFormats)
+	}
+
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(r.path, b, 0644)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitReport(results []execTestResult) ([]byte, error) {
+	suite := junitTestSuite{Name: "oci_exec_test", Tests: len(results)}
+	var total time.Duration
+	for _, res := range results {
+		total += res.Duration
+		tc := junitTestCase{
+			Name:      res.Name,
+			ClassName: "oci_exec_test",
+			Time:      seconds(res.Duration),
+			SystemOut: res.Output,
+		}
+		switch {
+		case res.SkipReason != "":
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: res.SkipReason}
+		case res.Failure != "":
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: res.Failure}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = seconds(total)
+
+	b, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+func tapReport(results []execTestResult) string {
+	var sb strings.Builder
+	sb.WriteString("TAP version 13\n")
+	fmt.Fprintf(&sb, "1..%d\n", len(results))
+	for i, res := range results {
+		switch {
+		case res.SkipReason != "":
+			fmt.Fprintf(&sb, "ok %d - %s # SKIP %s\n", i+1, res.Name, res.SkipReason)
+			continue
+		case res.Failure != "":
+			fmt.Fprintf(&sb, "not ok %d - %s\n", i+1, res.Name)
+		default:
+			fmt.Fprintf(&sb, "ok %d - %s\n", i+1, res.Name)
+		}
+
+		sb.WriteString("  ---\n")
+		if res.Failure != "" {
+			fmt.Fprintf(&sb, "  message: %q\n", res.Failure)
+		}
+		if res.ExitCode != nil {
+			fmt.Fprintf(&sb, "  exit_code: %d\n", *res.ExitCode)
+		}
+		fmt.Fprintf(&sb, "  duration_ms: %d\n", res.Duration.Milliseconds())
+		if res.Output != "" {
+			sb.WriteString("  output: |\n")
+			for _, line := range strings.Split(strings.TrimRight(res.Output, "\n"), "\n") {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
+		sb.WriteString("  ...\n")
+	}
+	return sb.String()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
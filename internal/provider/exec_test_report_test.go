@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecTestReport(t *testing.T) {
+	zero, one := 0, 1
+	results := []execTestResult{{
+		Name:     "passes",
+		Duration: 1500 * time.Millisecond,
+		ExitCode: &zero,
+		Output:   "hello\n",
+	}, {
+		Name:     "fails",
+		Duration: 2 * time.Second,
+		ExitCode: &one,
+		Output:   "oh <no>\nbad\n",
+		Failure:  "Test failed",
+	}, {
+		Name:       "skipped",
+		SkipReason: "Skipping exec tests as per provider configuration",
+	}}
+
+	for _, c := range []struct {
+		format string
+		want   string
+	}{{
+		format: reportFormatJUnit,
+		want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="oci_exec_test" tests="3" failures="1" skipped="1" time="3.500">
+    <testcase name="passes" classname="oci_exec_test" time="1.500">
+      <system-out>hello&#xA;</system-out>
+    </testcase>
+    <testcase name="fails" classname="oci_exec_test" time="2.000">
+      <failure message="Test failed"></failure>
+      <system-out>oh &lt;no&gt;&#xA;bad&#xA;</system-out>
+    </testcase>
+    <testcase name="skipped" classname="oci_exec_test" time="0.000">
+      <skipped message="Skipping exec tests as per provider configuration"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+	}, {
+		format: reportFormatTAP,
+		want: `TAP version 13
+1..3
+ok 1 - passes
+  ---
+  exit_code: 0
+  duration_ms: 1500
+  output: |
+    hello
+  ...
+not ok 2 - fails
+  ---
+  message: "Test failed"
+  exit_code: 1
+  duration_ms: 2000
+  output: |
+    oh <no>
+    bad
+  ...
+ok 3 - skipped # SKIP Skipping exec tests as per provider configuration
+`,
+	}} {
+		t.Run(c.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "reports", "report")
+			r := newExecTestReport(path, c.format)
+			for _, res := range results {
+				if err := r.record(res); err != nil {
+					t.Fatalf("record: %v", err)
+				}
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading report: %v", err)
+			}
+			if got := string(b); got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+
+	// A nil report discards results.
+	var r *execTestReport
+	if err := r.record(results[0]); err != nil {
+		t.Errorf("record on nil report: %v", err)
+	}
+}
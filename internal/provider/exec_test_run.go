@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ExecTest describes an exec test run outside of Terraform, e.g. by the check
+// CLI. It gets the same environment as the oci_exec_test data source.
+type ExecTest struct {
+	// Image is the image to test, by digest.
+	Image  string
+	Config *v1.ConfigFile
+
+	Script     string
+	Driver     string
+	WorkingDir string
+	// ArtifactsDir, if set, is exported to the script as TEST_ARTIFACTS.
+	ArtifactsDir string
+
+	FreePorts     int64
+	FreePortProto string
+	// EnvFile and then Env are appended to the environment after the
+	// IMAGE_* and FREE_PORT* variables, so they can override them.
+	EnvFile string
+	Env     []string
+
+	Stdout, Stderr io.Writer
+}
+
+// RunExecTest runs the test and returns the script's exit code. An error is
+// only returned if the script couldn't be run at all.
+func RunExecTest(ctx context.Context, t ExecTest) (int, error) {
+	ref, err := name.NewDigest(t.Image)
+	if err != nil {
+		return 0, fmt.Errorf("parsing image %q: %w", t.Image, err)
+	}
+	env, leases, err := execTestEnv(t.Image, ref, t.Config, t.FreePorts, t.FreePortProto)
+	if err != nil {
+		return 0, fmt.Errorf("finding free port: %w", err)
+	}
+	for _, lease := range leases {
+		defer lease.discard()
+	}
+	if t.EnvFile != "" {
+		fileEnv, err := readEnvFile(t.EnvFile)
+		if err != nil {
+			return 0, fmt.Errorf("reading env file %s: %w", t.EnvFile, err)
+		}
+		env = append(env, fileEnv...)
+	}
+
+	spec := execSpec{
+		Driver:       t.Driver,
+		Image:        t.Image,
+		Script:       t.Script,
+		WorkingDir:   t.WorkingDir,
+		ArtifactsDir: t.ArtifactsDir,
+		Env:          append(env, t.Env...),
+	}
+	cmd, err := spec.command(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cmd.Stdout = t.Stdout
+	cmd.Stderr = t.Stderr
+
+	for _, lease := range leases {
+		lease.release()
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
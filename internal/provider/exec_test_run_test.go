@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestRunExecTest(t *testing.T) {
+	image := "example.com/repo@sha256:1234567890123456789012345678901234567890123456789012345678901234"
+	cf := &v1.ConfigFile{Config: v1.Config{User: "nonroot"}}
+
+	var out bytes.Buffer
+	code, err := RunExecTest(context.Background(), ExecTest{
+		Image:     image,
+		Config:    cf,
+		Script:    `echo "$IMAGE_NAME $IMAGE_REGISTRY $IMAGE_REPOSITORY $IMAGE_USER $FOO"; [ -n "$FREE_PORT" ] && [ "$FREE_PORT" = "$FREE_PORT_0" ] && [ -n "$FREE_PORT_1" ]`,
+		FreePorts: 2,
+		Env:       []string{"FOO=bar"},
+		Stdout:    &out,
+	})
+	if err != nil {
+		t.Fatalf("RunExecTest: %v", err)
+	}
+	if code != 0 {
+		t.Errorf("got exit code %d, want 0", code)
+	}
+	if got, want := strings.TrimSpace(out.String()), image+" example.com repo nonroot bar"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+
+	code, err = RunExecTest(context.Background(), ExecTest{Image: image, Config: cf, Script: "exit 3"})
+	if err != nil {
+		t.Fatalf("RunExecTest: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("got exit code %d, want 3", code)
+	}
+
+	if _, err := RunExecTest(context.Background(), ExecTest{Image: "example.com/repo:tag", Config: cf}); err == nil {
+		t.Error("expected an error for a tag reference")
+	}
+}
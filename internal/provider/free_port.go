@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var mu sync.Mutex
+
+// freePorts tracks ports leased to running tests, keyed by "network/port".
+var freePorts = map[string]bool{}
+
+// portLease is a free port reserved for a single test.
+//
+// The port stays bound by the lease's listener until release is called, which
+// should happen right before the test process starts, so that nothing else can
+// be handed the same port in the meantime. The port stays leased
+// provider-wide until discard is called, so parallel tests in this provider
+// never receive the same port even once the listener has been closed.
+type portLease struct {
+	network string
+	port    int
+
+	once   sync.Once
+	closer io.Closer
+}
+
+// release closes the listener holding the port, so the test can bind it.
+func (l *portLease) release() {
+	l.once.Do(func() { l.closer.Close() })
+}
+
+// discard releases the port and returns it to the pool.
+func (l *portLease) discard() {
+	l.release()
+
+	mu.Lock()
+	defer mu.Unlock()
+	delete(freePorts, leaseKey(l.network, l.port))
+}
+
+// freePort leases a port on localhost that is currently free for the given
+// network ("tcp" or "udp").
+func freePort(network string) (*portLease, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Listeners for ports that are already leased are held open until we find
+	// a free one, so the OS doesn't hand us the same port again.
+	var skipped []io.Closer
+	defer func() {
+		for _, c := range skipped {
+			c.Close()
+		}
+	}()
+
+	for {
+		port, closer, err := listenFreePort(network)
+		if err != nil {
+			return nil, err
+		}
+		key := leaseKey(network, port)
+		if freePorts[key] {
+			tflog.Debug(context.Background(), "port already in use, trying again", map[string]interface{}{"port": port, "network": network})
+			skipped = append(skipped, closer)
+			continue
+		}
+		freePorts[key] = true
+		return &portLease{network: network, port: port, closer: closer}, nil
+	}
+}
+
+func leaseKey(network string, port int) string {
+	return fmt.Sprintf("%s/%d", network, port)
+}
+
+func listenFreePort(network string) (int, io.Closer, error) {
+	switch network {
+	case "tcp":
+		addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
+		if err != nil {
+			return 0, nil, err
+		}
+		l, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			return 0, nil, err
+		}
+		ta, ok := l.Addr().(*net.TCPAddr)
+		if !ok {
+			l.Close()
+			return 0, nil, fmt.Errorf("failed to get port")
+		}
+		return ta.Port, l, nil
+
+	case "udp":
+		addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+		if err != nil {
+			return 0, nil, err
+		}
+		l, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return 0, nil, err
+		}
+		ua, ok := l.LocalAddr().(*net.UDPAddr)
+		if !ok {
+			l.Close()
+			return 0, nil, fmt.Errorf("failed to get port")
+		}
+		return ua.Port, l, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported network %q", network)
+}
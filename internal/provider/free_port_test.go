@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestFreePort(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		t.Run(network, func(t *testing.T) {
+			seen := map[int]bool{}
+			var leases []*portLease
+			for i := 0; i < 20; i++ {
+				lease, err := freePort(network)
+				if err != nil {
+					t.Fatalf("freePort: %v", err)
+				}
+				if seen[lease.port] {
+					t.Errorf("port %d leased twice", lease.port)
+				}
+				seen[lease.port] = true
+				leases = append(leases, lease)
+			}
+
+			// The port is held until it's released.
+			lease := leases[0]
+			addr := net.JoinHostPort("localhost", strconv.Itoa(lease.port))
+			if network == "tcp" {
+				if l, err := net.Listen(network, addr); err == nil {
+					l.Close()
+					t.Errorf("listening on leased port %d succeeded before release", lease.port)
+				}
+			} else {
+				if l, err := net.ListenPacket(network, addr); err == nil {
+					l.Close()
+					t.Errorf("listening on leased port %d succeeded before release", lease.port)
+				}
+			}
+
+			lease.release()
+			if network == "tcp" {
+				l, err := net.Listen(network, addr)
+				if err != nil {
+					t.Fatalf("listening on released port %d: %v", lease.port, err)
+				}
+				l.Close()
+			} else {
+				l, err := net.ListenPacket(network, addr)
+				if err != nil {
+					t.Fatalf("listening on released port %d: %v", lease.port, err)
+				}
+				l.Close()
+			}
+
+			for _, l := range leases {
+				l.discard()
+			}
+			if len(freePorts) != 0 {
+				t.Errorf("leases were not discarded: %v", freePorts)
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package provider
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/function"
@@ -16,12 +17,16 @@ import (
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ function.Function = &GetFunction{}
 
-func NewGetFunction() function.Function {
-	return &GetFunction{}
+// NewGetFunction returns the get function, which fetches images with the
+// options returned by popts.
+func NewGetFunction(popts func() *ProviderOpts) function.Function {
+	return &GetFunction{popts: popts}
 }
 
 // GetFunction defines the function implementation.
-type GetFunction struct{}
+type GetFunction struct {
+	popts func() *ProviderOpts
+}
 
 // Metadata should return the name of the function, such as parse_xyz.
 func (s *GetFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
@@ -31,21 +36,27 @@ func (s *GetFunction) Metadata(_ context.Context, _ function.MetadataRequest, re
 // Definition should return the definition for the function.
 func (s *GetFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
 	resp.Definition = function.Definition{
-		Summary: "Parses a pinned OCI string into its constituent parts.",
+		Summary:     "Parses a pinned OCI string into its constituent parts.",
+		Description: "Fetches the image or index with the provider's registry configuration (credentials, TLS, proxies, etc.) once the provider has been configured, and with the default Docker credentials otherwise. The result includes the manifest's `artifact_type` and `subject`, and `referrers`, the number of referrers to it (e.g. signatures, attestations and SBOMs) by artifact type.",
 		Parameters: []function.Parameter{
 			function.StringParameter{
 				Name:        "input",
 				Description: "The OCI reference string to get.",
 			},
 		},
+		VariadicParameter: function.StringParameter{
+			Name:        "platform",
+			Description: "If the reference is an index, get the image for this platform (e.g. `linux/arm64`) instead of the index.",
+		},
 		Return: function.ObjectReturn{
 			AttributeTypes: map[string]attr.Type{
-				"full_ref": basetypes.StringType{},
-				"digest":   basetypes.StringType{},
-				"tag":      basetypes.StringType{},
-				"manifest": basetypes.ObjectType{AttrTypes: manifestAttribute.AttributeTypes},
-				"images":   basetypes.MapType{ElemType: imageType},
-				"config":   basetypes.ObjectType{AttrTypes: configAttribute.AttributeTypes},
+				"full_ref":  basetypes.StringType{},
+				"digest":    basetypes.StringType{},
+				"tag":       basetypes.StringType{},
+				"manifest":  basetypes.ObjectType{AttrTypes: manifestAttribute.AttributeTypes},
+				"images":    basetypes.MapType{ElemType: imageType},
+				"config":    basetypes.ObjectType{AttrTypes: configAttribute.AttributeTypes},
+				"referrers": basetypes.MapType{ElemType: basetypes.NumberType{}},
 			},
 		},
 	}
@@ -57,13 +68,30 @@ func (s *GetFunction) Definition(_ context.Context, _ function.DefinitionRequest
 // the [RunResponse].
 func (s *GetFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
 	var input string
-	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+	var platforms []string
+	if ferr := req.Arguments.Get(ctx, &input, &platforms); ferr != nil {
 		resp.Error = ferr
 		return
 	}
+	var platform *v1.Platform
+	switch len(platforms) {
+	case 0:
+	case 1:
+		p, err := v1.ParsePlatform(platforms[0])
+		if err != nil {
+			resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Failed to parse platform: %v", err))
+			return
+		}
+		platform = p
+	default:
+		resp.Error = function.NewArgumentFuncError(1, "At most one platform may be given")
+		return
+	}
+
+	popts := s.popts()
 
 	// Parse the input string into its constituent parts.
-	ref, err := name.ParseReference(input)
+	ref, err := name.ParseReference(input, popts.nameOptions(input, false)...)
 	if err != nil {
 		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
 		return
@@ -76,21 +104,50 @@ func (s *GetFunction) Run(ctx context.Context, req function.RunRequest, resp *fu
 		Manifest *Manifest        `tfsdk:"manifest"`
 		Images   map[string]Image `tfsdk:"images"`
 		Config   *Config          `tfsdk:"config"`
+		// Referrers counts the referrers by artifact type.
+		Referrers map[string]int64 `tfsdk:"referrers"`
 	}{}
 
 	if t, ok := ref.(name.Tag); ok {
 		result.Tag = t.TagStr()
 	}
 
-	desc, err := remote.Get(ref,
-		remote.WithAuthFromKeychain(authn.DefaultKeychain),
-		remote.WithUserAgent("terraform-provider-oci"),
-		remote.WithContext(ctx))
+	desc, err := remote.Get(ref, popts.withContext(ctx)...)
 	if err != nil {
 		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to get image: %v", err))
 		return
 	}
 
+	if platform != nil && desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse index: %v", err))
+			return
+		}
+		imf, err := idx.IndexManifest()
+		if err != nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse index manifest: %v", err))
+			return
+		}
+		var found *v1.Descriptor
+		for _, m := range imf.Manifests {
+			if m.Platform != nil && m.Platform.Satisfies(*platform) {
+				m := m
+				found = &m
+				break
+			}
+		}
+		if found == nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("No image for platform %s in index %s", platform, ref))
+			return
+		}
+		desc, err = remote.Get(ref.Context().Digest(found.Digest.String()), popts.withContext(ctx)...)
+		if err != nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to get image: %v", err))
+			return
+		}
+	}
+
 	result.Digest = desc.Digest.String()
 	result.FullRef = ref.Context().Digest(desc.Digest.String()).String()
 
@@ -101,6 +158,25 @@ func (s *GetFunction) Run(ctx context.Context, req function.RunRequest, resp *fu
 	}
 	result.Manifest = mf
 
+	// This falls back to the referrers tag schema if the registry doesn't
+	// support the referrers API.
+	referrers, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()), popts.withContext(ctx)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to list referrers: %v", err))
+		return
+	}
+	rmf, err := referrers.IndexManifest()
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse referrers: %v", err))
+		return
+	}
+	result.Referrers = map[string]int64{}
+	for _, r := range rmf.Manifests {
+		// Registries should set artifactType on referrers, from the config's
+		// media type if need be, but not all do.
+		result.Referrers[cmp.Or(r.ArtifactType, string(r.MediaType))]++
+	}
+
 	if desc.MediaType.IsIndex() {
 		idx, err := desc.ImageIndex()
 		if err != nil {
@@ -133,6 +209,10 @@ func (s *GetFunction) Run(ctx context.Context, req function.RunRequest, resp *fu
 			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse config: %v", err))
 			return
 		}
+		if platform != nil && (cf.Platform() == nil || !cf.Platform().Satisfies(*platform)) {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Image %s is not for platform %s", ref, platform))
+			return
+		}
 		cfg := &Config{}
 		cfg.FromConfigFile(cf)
 		result.Config = cfg
@@ -153,6 +233,7 @@ var manifestAttribute = schema.ObjectAttribute{
 	AttributeTypes: map[string]attr.Type{
 		"schema_version": basetypes.NumberType{},
 		"media_type":     basetypes.StringType{},
+		"artifact_type":  basetypes.StringType{},
 		"config":         descriptorType,
 		"layers": basetypes.ListType{
 			ElemType: descriptorType,
@@ -3,6 +3,7 @@ package provider
 import (
 	"fmt"
 	"math/big"
+	"regexp"
 	"testing"
 	"time"
 
@@ -58,6 +59,30 @@ func TestGetFunction(t *testing.T) {
 		t.Fatalf("failed to get image digest: %v", err)
 	}
 
+	// Attach an SBOM to the image.
+	sbom, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	sbom = mutate.MediaType(sbom, ggcrtypes.OCIManifestSchema1)
+	sbom = mutate.ConfigMediaType(sbom, "application/spdx+json")
+	mt, err := img.MediaType()
+	if err != nil {
+		t.Fatalf("failed to get image media type: %v", err)
+	}
+	sz, err := img.Size()
+	if err != nil {
+		t.Fatalf("failed to get image size: %v", err)
+	}
+	sbom = mutate.Subject(sbom, v1.Descriptor{MediaType: mt, Size: sz, Digest: d}).(v1.Image) //nolint:forcetypeassert
+	sbomDigest, err := sbom.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+	if err := remote.Write(repo.Digest(sbomDigest.String()), sbom); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
 	isDescriptor := func(mt ggcrtypes.MediaType) knownvalue.Check {
 		return knownvalue.ObjectExact(map[string]knownvalue.Check{
 			"digest":     knownvalue.StringRegexp(digestRE),
@@ -80,6 +105,7 @@ func TestGetFunction(t *testing.T) {
 					"manifest": knownvalue.ObjectExact(map[string]knownvalue.Check{
 						"schema_version": knownvalue.NumberExact(big.NewFloat(2)),
 						"media_type":     knownvalue.StringExact(string(ggcrtypes.OCIManifestSchema1)),
+						"artifact_type":  knownvalue.StringExact(""),
 						"config":         isDescriptor(ggcrtypes.DockerConfigJSON),
 						"layers": knownvalue.ListExact([]knownvalue.Check{
 							isDescriptor(ggcrtypes.DockerLayer),
@@ -98,7 +124,20 @@ func TestGetFunction(t *testing.T) {
 						"working_dir": knownvalue.StringExact("/tmp"),
 						"created_at":  knownvalue.StringExact(now.Format(time.RFC3339)),
 					}),
-					"images": knownvalue.Null(),
+					"images":    knownvalue.Null(),
+					"referrers": knownvalue.MapExact(map[string]knownvalue.Check{"application/spdx+json": knownvalue.Int64Exact(1)}),
+				})),
+			},
+		}, {
+			Config: fmt.Sprintf(`output "gotten" { value = provider::oci::get(%q) }`, repo.Digest(sbomDigest.String())),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("gotten", knownvalue.ObjectPartial(map[string]knownvalue.Check{
+					"manifest": knownvalue.ObjectPartial(map[string]knownvalue.Check{
+						"subject": knownvalue.ObjectPartial(map[string]knownvalue.Check{
+							"digest": knownvalue.StringExact(d.String()),
+						}),
+					}),
+					"referrers": knownvalue.MapExact(map[string]knownvalue.Check{}),
 				})),
 			},
 		}},
@@ -106,6 +145,7 @@ func TestGetFunction(t *testing.T) {
 
 	// Push an index to the local registry.
 	var idx v1.ImageIndex = empty.Index
+	imageDigests := map[string]v1.Hash{}
 	for _, plat := range []v1.Platform{
 		{OS: "linux", Architecture: "amd64"},
 		{OS: "windows", Architecture: "arm64", Variant: "v3", OSVersion: "1-rc365"},
@@ -116,6 +156,14 @@ func TestGetFunction(t *testing.T) {
 			t.Fatalf("failed to create image: %v", err)
 		}
 		img = mutate.MediaType(img, ggcrtypes.OCIManifestSchema1)
+		img, err = mutate.ConfigFile(img, &v1.ConfigFile{OS: plat.OS, Architecture: plat.Architecture, Variant: plat.Variant, OSVersion: plat.OSVersion})
+		if err != nil {
+			t.Fatalf("failed to mutate image: %v", err)
+		}
+		imageDigests[plat.String()], err = img.Digest()
+		if err != nil {
+			t.Fatalf("failed to get image digest: %v", err)
+		}
 		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
 			Add:        img,
 			Descriptor: v1.Descriptor{Platform: &plat},
@@ -152,6 +200,7 @@ func TestGetFunction(t *testing.T) {
 					"manifest": knownvalue.ObjectExact(map[string]knownvalue.Check{
 						"schema_version": knownvalue.NumberExact(big.NewFloat(2)),
 						"media_type":     knownvalue.StringExact(string(ggcrtypes.OCIImageIndex)),
+						"artifact_type":  knownvalue.StringExact(""),
 						"manifests": knownvalue.ListExact([]knownvalue.Check{
 							knownvalue.ObjectExact(map[string]knownvalue.Check{
 								"digest": knownvalue.StringRegexp(digestRE),
@@ -181,7 +230,8 @@ func TestGetFunction(t *testing.T) {
 						"subject":     knownvalue.Null(),
 						"config":      knownvalue.Null(),
 					}),
-					"config": knownvalue.Null(),
+					"config":    knownvalue.Null(),
+					"referrers": knownvalue.MapExact(map[string]knownvalue.Check{}),
 					"images": knownvalue.MapExact(map[string]knownvalue.Check{
 						"linux/amd64": knownvalue.ObjectExact(map[string]knownvalue.Check{
 							"digest":    knownvalue.StringRegexp(digestRE),
@@ -196,4 +246,27 @@ func TestGetFunction(t *testing.T) {
 			},
 		}},
 	})
+
+	// With a platform, the matching image is returned instead of the index.
+	amd64 := imageDigests["linux/amd64"]
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`output "gotten" { value = provider::oci::get(%q, "linux/amd64") }`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("gotten", knownvalue.ObjectPartial(map[string]knownvalue.Check{
+					"full_ref": knownvalue.StringExact(fmt.Sprintf("%s@%s", ref.Context().Name(), amd64.String())),
+					"digest":   knownvalue.StringExact(amd64.String()),
+					"tag":      knownvalue.StringExact("index"),
+					"images":   knownvalue.Null(),
+					"config":   knownvalue.NotNull(),
+				})),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "gotten" { value = provider::oci::get(%q, "linux/s390x") }`, ref),
+			ExpectError: regexp.MustCompile("No image for platform linux/s390x"),
+		}},
+	})
 }
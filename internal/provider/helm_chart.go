@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Media types of Helm charts stored as OCI artifacts.
+const (
+	helmConfigMediaType     = "application/vnd.cncf.helm.config.v1+json"
+	helmChartMediaType      = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	helmProvenanceMediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+)
+
+// helmChart is the metadata of a packaged chart, from its Chart.yaml.
+type helmChart struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"appVersion"`
+	Description string `json:"description"`
+	// config is the chart's metadata as JSON, for the config of its OCI
+	// manifest.
+	config []byte
+}
+
+// parseHelmChart returns the metadata of the packaged (.tgz) chart b, from the
+// Chart.yaml in its top-level directory.
+func parseHelmChart(b []byte) (helmChart, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return helmChart{}, fmt.Errorf("reading chart: %w", err)
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return helmChart{}, errors.New("chart has no Chart.yaml")
+		} else if err != nil {
+			return helmChart{}, fmt.Errorf("reading chart: %w", err)
+		}
+		// Subcharts have their own Chart.yaml, deeper in the archive.
+		if n := path.Clean(hdr.Name); path.Base(n) != "Chart.yaml" || strings.Count(n, "/") != 1 {
+			continue
+		}
+		y, err := io.ReadAll(tr)
+		if err != nil {
+			return helmChart{}, fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		var metadata map[string]any
+		if err := yaml.Unmarshal(y, &metadata); err != nil {
+			return helmChart{}, fmt.Errorf("parsing %s: %w", hdr.Name, err)
+		}
+		config, err := json.Marshal(metadata)
+		if err != nil {
+			return helmChart{}, fmt.Errorf("converting %s to JSON: %w", hdr.Name, err)
+		}
+		var c helmChart
+		if err := json.Unmarshal(config, &c); err != nil {
+			return helmChart{}, fmt.Errorf("parsing %s: %w", hdr.Name, err)
+		}
+		if c.Name == "" || c.Version == "" {
+			return helmChart{}, fmt.Errorf("%s must set name and version", hdr.Name)
+		}
+		if _, ok := parseSemver(c.Version); !ok {
+			return helmChart{}, fmt.Errorf("%s has version %q, which isn't a semantic version", hdr.Name, c.Version)
+		}
+		c.config = config
+		return c, nil
+	}
+}
+
+// helmTag returns the tag of a chart version. Tags can't contain "+", so Helm
+// replaces it with "_".
+func helmTag(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
+
+// semver is a parsed semantic version. Build metadata is ignored.
+type semver struct {
+	release [3]uint64
+	pre     []string
+}
+
+// parseSemver parses a semantic version, with an optional leading "v".
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || (hasPre && pre == "") {
+		return semver{}, false
+	}
+	var v semver
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return semver{}, false
+		}
+		v.release[i] = n
+	}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// compare orders semantic versions by precedence.
+func (v semver) compare(o semver) int {
+	for i := range v.release {
+		if c := cmp.Compare(v.release[i], o.release[i]); c != 0 {
+			return c
+		}
+	}
+	// A prerelease comes before its release.
+	if len(v.pre) == 0 || len(o.pre) == 0 {
+		return cmp.Compare(len(o.pre), len(v.pre))
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		a, b := v.pre[i], o.pre[i]
+		an, aerr := strconv.ParseUint(a, 10, 64)
+		bn, berr := strconv.ParseUint(b, 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if c := cmp.Compare(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			// Numeric identifiers come before alphanumeric ones.
+			return -1
+		case berr == nil:
+			return 1
+		case a != b:
+			return strings.Compare(a, b)
+		}
+	}
+	return cmp.Compare(len(v.pre), len(o.pre))
+}
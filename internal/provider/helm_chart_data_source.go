@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &HelmChartDataSource{}
+
+func NewHelmChartDataSource() datasource.DataSource {
+	return &HelmChartDataSource{}
+}
+
+// HelmChartDataSource defines the data source implementation.
+type HelmChartDataSource struct {
+	popts ProviderOpts
+}
+
+// HelmChartDataSourceModel describes the data source data model.
+type HelmChartDataSourceModel struct {
+	Repo     types.String `tfsdk:"repo"`
+	Version  types.String `tfsdk:"version"`
+	Insecure types.Bool   `tfsdk:"insecure"`
+
+	Id          types.String `tfsdk:"id"`
+	ImageRef    types.String `tfsdk:"image_ref"`
+	Versions    []string     `tfsdk:"versions"`
+	Name        types.String `tfsdk:"name"`
+	AppVersion  types.String `tfsdk:"app_version"`
+	Description types.String `tfsdk:"description"`
+}
+
+func (d *HelmChartDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_helm_chart"
+}
+
+func (d *HelmChartDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Resolves a version of a Helm chart stored as an OCI artifact to its digest.",
+
+		Attributes: map[string]schema.Attribute{
+			"repo": schema.StringAttribute{
+				MarkdownDescription: "Repository of the chart, e.g. `example.com/charts/mychart`.",
+				Required:            true,
+				Validators:          []validator.String{validators.RepoValidator{}},
+			},
+			"version": schema.StringAttribute{
+				MarkdownDescription: "Version of the chart to resolve. Defaults to the latest version that isn't a prerelease.",
+				Optional:            true,
+				Computed:            true,
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified digest of the chart.",
+				Computed:            true,
+			},
+			"image_ref": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified digest of the chart (e.g. {repo}@sha256:deadbeef).",
+				Computed:            true,
+			},
+			"versions": schema.ListAttribute{
+				MarkdownDescription: "Versions of the chart in the repository, from oldest to newest.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+			"name": schema.StringAttribute{
+				MarkdownDescription: "Name of the chart.",
+				Computed:            true,
+			},
+			"app_version": schema.StringAttribute{
+				MarkdownDescription: "Version of the app that the chart deploys.",
+				Computed:            true,
+			},
+			"description": schema.StringAttribute{
+				MarkdownDescription: "Description of the chart.",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *HelmChartDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *HelmChartDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data HelmChartDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	repo, err := name.NewRepository(data.Repo.ValueString(), d.popts.nameOptions(data.Repo.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid repo", fmt.Sprintf("Unable to parse repo %s, got error: %s", data.Repo.ValueString(), err))
+		return
+	}
+	var tags []string
+	if err := retryTransient(ctx, func(ctx context.Context) (err error) {
+		tags, err = remote.List(repo, d.popts.withContext(ctx)...)
+		return err
+	}); err != nil {
+		resp.Diagnostics.AddError("Unable to list chart versions", fmt.Sprintf("Unable to list tags of %s, got error: %s", repo, err))
+		return
+	}
+	versions := helmVersions(tags)
+
+	version := data.Version.ValueString()
+	if version == "" {
+		if version, err = latestHelmVersion(versions); err != nil {
+			resp.Diagnostics.AddError("Unable to resolve chart version", fmt.Sprintf("Unable to resolve the latest version of %s: %s", repo, err))
+			return
+		}
+	}
+
+	desc, err := remote.Get(repo.Tag(helmTag(version)), d.popts.withContext(ctx)...)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to fetch chart", fmt.Sprintf("Unable to fetch version %s of %s, got error: %s", version, repo, err))
+		return
+	}
+	c, err := readHelmChart(desc)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to read chart", fmt.Sprintf("Unable to read version %s of %s, got error: %s", version, repo, err))
+		return
+	}
+
+	ref := repo.Digest(desc.Digest.String()).String()
+	data.Id = types.StringValue(ref)
+	data.ImageRef = types.StringValue(ref)
+	data.Version = types.StringValue(version)
+	data.Versions = versions
+	data.Name = types.StringValue(c.Name)
+	data.AppVersion = types.StringValue(c.AppVersion)
+	data.Description = types.StringValue(c.Description)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// helmVersions returns the chart versions of tags, which aren't all
+// necessarily versions, from oldest to newest.
+func helmVersions(tags []string) []string {
+	versions := []string{}
+	for _, t := range tags {
+		v := strings.ReplaceAll(t, "_", "+")
+		if _, ok := parseSemver(v); ok {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortStableFunc(versions, func(a, b string) int {
+		va, _ := parseSemver(a)
+		vb, _ := parseSemver(b)
+		return va.compare(vb)
+	})
+	return versions
+}
+
+// latestHelmVersion returns the newest of versions, sorted from oldest to
+// newest, that isn't a prerelease.
+func latestHelmVersion(versions []string) (string, error) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v, _ := parseSemver(versions[i]); len(v.pre) == 0 {
+			return versions[i], nil
+		}
+	}
+	return "", errors.New("no released versions found")
+}
+
+// readHelmChart returns the metadata of the chart desc, from its config.
+func readHelmChart(desc *remote.Descriptor) (helmChart, error) {
+	img, err := desc.Image()
+	if err != nil {
+		return helmChart{}, err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return helmChart{}, err
+	}
+	if m.Config.MediaType != helmConfigMediaType {
+		return helmChart{}, fmt.Errorf("config has media type %s, so it isn't a Helm chart", m.Config.MediaType)
+	}
+	b, err := img.RawConfigFile()
+	if err != nil {
+		return helmChart{}, err
+	}
+	var c helmChart
+	if err := json.Unmarshal(b, &c); err != nil {
+		return helmChart{}, fmt.Errorf("parsing config: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.Resource                = &HelmChartResource{}
+	_ resource.ResourceWithImportState = &HelmChartResource{}
+)
+
+func NewHelmChartResource() resource.Resource {
+	return &HelmChartResource{}
+}
+
+// HelmChartResource defines the resource implementation.
+type HelmChartResource struct {
+	popts ProviderOpts
+}
+
+// HelmChartResourceModel describes the resource data model.
+type HelmChartResourceModel struct {
+	Id       types.String `tfsdk:"id"`
+	ImageRef types.String `tfsdk:"image_ref"`
+	Name     types.String `tfsdk:"name"`
+	Version  types.String `tfsdk:"version"`
+
+	Repo           types.String `tfsdk:"repo"`
+	ChartPath      types.String `tfsdk:"chart_path"`
+	ProvenancePath types.String `tfsdk:"provenance_path"`
+	Insecure       types.Bool   `tfsdk:"insecure"`
+}
+
+func (r *HelmChartResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_helm_chart"
+}
+
+func (r *HelmChartResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Push a packaged Helm chart as an OCI artifact, like `helm push`. The chart is pushed to `<repo>/<name>:<version>`, taking its name and version from its `Chart.yaml`, with a `+` in the version replaced by `_`.",
+		Attributes: map[string]schema.Attribute{
+			"repo": schema.StringAttribute{
+				MarkdownDescription: "Repository to push the chart under, e.g. `example.com/charts`.",
+				Required:            true,
+				Validators:          []validator.String{validators.RepoValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"chart_path": schema.StringAttribute{
+				MarkdownDescription: "Path to the packaged chart (`.tgz`), as built by `helm package`.",
+				Required:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"provenance_path": schema.StringAttribute{
+				MarkdownDescription: "Path to the chart's provenance file (`.prov`), as built by `helm package --sign`, to push with the chart.",
+				Optional:            true,
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"name": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Name of the chart.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"version": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Version of the chart.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"image_ref": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}/{name}@sha256:deadbeef).",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified digest (e.g. {repo}/{name}@sha256:deadbeef).",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *HelmChartResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	r.popts = *popts
+}
+
+func (r *HelmChartResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *HelmChartResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.doPush(ctx, data); err != nil {
+		resp.Diagnostics.AddError("Unable to push chart", fmt.Sprintf("Unable to push chart %s, got error: %s", data.ChartPath.ValueString(), err))
+		return
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *HelmChartResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *HelmChartResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Check that the chart is still there. If it's gone, it's pushed again.
+	ref := data.Id.ValueString()
+	d, err := name.NewDigest(ref, r.popts.nameOptions(ref, data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", ref, err))
+		return
+	}
+	err = retryTransient(ctx, func(ctx context.Context) error {
+		_, err := remote.Head(d, r.popts.withContext(ctx)...)
+		return err
+	})
+	if isNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("Unable to read chart", fmt.Sprintf("Unable to read chart %s, got error: %s", d, err))
+		return
+	}
+	data.ImageRef = data.Id
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *HelmChartResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Everything but insecure requires replacement, so there's nothing to
+	// push.
+	var data *HelmChartResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *HelmChartResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// TODO: optionally delete the chart when the resource is deleted.
+	resp.Diagnostics.Append(req.State.Get(ctx, &HelmChartResourceModel{})...)
+}
+
+func (r *HelmChartResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (r *HelmChartResource) doPush(ctx context.Context, data *HelmChartResourceModel) error {
+	b, err := os.ReadFile(data.ChartPath.ValueString())
+	if err != nil {
+		return err
+	}
+	var prov []byte
+	if !data.ProvenancePath.IsNull() {
+		if prov, err = os.ReadFile(data.ProvenancePath.ValueString()); err != nil {
+			return err
+		}
+	}
+	d, c, err := r.popts.pushHelmChart(ctx, data.Repo.ValueString(), data.Insecure.ValueBool(), b, prov)
+	if err != nil {
+		return err
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+	data.Name = types.StringValue(c.Name)
+	data.Version = types.StringValue(c.Version)
+	return nil
+}
+
+// pushHelmChart pushes the packaged chart b, with its provenance prov if it
+// isn't empty, to the repository for the chart in repo, tagged with its
+// version, as Helm does. It returns the chart's digest and metadata.
+func (p *ProviderOpts) pushHelmChart(ctx context.Context, repo string, insecure bool, b, prov []byte) (name.Digest, helmChart, error) {
+	c, err := parseHelmChart(b)
+	if err != nil {
+		return name.Digest{}, c, err
+	}
+	r, err := name.NewRepository(repo+"/"+c.Name, p.nameOptions(repo, insecure)...)
+	if err != nil {
+		return name.Digest{}, c, fmt.Errorf("parsing repo for chart %q: %w", c.Name, err)
+	}
+
+	files := []artifactFile{{Contents: types.StringValue(string(b)), MediaType: types.StringValue(helmChartMediaType)}}
+	if len(prov) > 0 {
+		files = append(files, artifactFile{Contents: types.StringValue(string(prov)), MediaType: types.StringValue(helmProvenanceMediaType)})
+	}
+	annotations := map[string]string{
+		"org.opencontainers.image.title":   c.Name,
+		"org.opencontainers.image.version": c.Version,
+	}
+	if c.Description != "" {
+		annotations["org.opencontainers.image.description"] = c.Description
+	}
+	// Helm charts have no artifactType; they're known by their config's
+	// media type.
+	d, err := p.pushArtifact(ctx, r, helmTag(c.Version), "", helmConfigMediaType, c.config, files, annotations)
+	return d, c, err
+}
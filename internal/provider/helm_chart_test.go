@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+// helmPackage returns a packaged chart with the given files, as helm package
+// would build it.
+func helmPackage(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseHelmChart(t *testing.T) {
+	c, err := parseHelmChart(helmPackage(t, map[string]string{
+		"mychart/Chart.yaml":             "apiVersion: v2\nname: mychart\nversion: 1.2.3+build.1\nappVersion: \"4.5\"\ndescription: My chart\n",
+		"mychart/values.yaml":            "replicas: 1\n",
+		"mychart/charts/sub/Chart.yaml":  "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+		"mychart/templates/service.yaml": "kind: Service\n",
+	}))
+	if err != nil {
+		t.Fatalf("parseHelmChart: %v", err)
+	}
+	if c.Name != "mychart" || c.Version != "1.2.3+build.1" || c.AppVersion != "4.5" || c.Description != "My chart" {
+		t.Errorf("got %+v", c)
+	}
+	var config map[string]any
+	if err := json.Unmarshal(c.config, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["apiVersion"] != "v2" {
+		t.Errorf("got config %s", c.config)
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		files map[string]string
+		want  string
+	}{
+		{"no Chart.yaml", map[string]string{"mychart/values.yaml": ""}, "no Chart.yaml"},
+		{"only a subchart", map[string]string{"mychart/charts/sub/Chart.yaml": "name: sub\nversion: 0.1.0\n"}, "no Chart.yaml"},
+		{"no version", map[string]string{"mychart/Chart.yaml": "name: mychart\n"}, "must set name and version"},
+		{"bad version", map[string]string{"mychart/Chart.yaml": "name: mychart\nversion: latest\n"}, "isn't a semantic version"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := parseHelmChart(helmPackage(t, tc.files)); err == nil || !regexp.MustCompile(tc.want).MatchString(err.Error()) {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+	if _, err := parseHelmChart([]byte("not a chart")); err == nil {
+		t.Error("parseHelmChart of garbage: want error")
+	}
+}
+
+func TestHelmVersions(t *testing.T) {
+	got := helmVersions([]string{"1.10.0", "latest", "1.2.0", "v1.2.0-rc.1", "1.2.0-rc.10", "1.2.0-rc.2", "1.2.0-alpha", "2.0.0-beta", "1.2.1_build.5", "sha256-abc.sig"})
+	want := []string{"1.2.0-alpha", "v1.2.0-rc.1", "1.2.0-rc.2", "1.2.0-rc.10", "1.2.0", "1.2.1+build.5", "1.10.0", "2.0.0-beta"}
+	if !slices.Equal(got, want) {
+		t.Errorf("helmVersions = %v, want %v", got, want)
+	}
+	if latest, err := latestHelmVersion(got); err != nil || latest != "1.10.0" {
+		t.Errorf("latestHelmVersion = %q, %v; want 1.10.0", latest, err)
+	}
+	if _, err := latestHelmVersion([]string{"1.0.0-rc.1"}); err == nil {
+		t.Error("latestHelmVersion of prereleases: want error")
+	}
+}
+
+func TestPushHelmChart(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "charts")
+	defer cleanup()
+
+	p := &ProviderOpts{}
+	chart := helmPackage(t, map[string]string{"mychart/Chart.yaml": "name: mychart\nversion: 1.0.0+abc\n"})
+	d, c, err := p.pushHelmChart(context.Background(), repo.String(), false, chart, []byte("-----BEGIN PGP SIGNED MESSAGE-----"))
+	if err != nil {
+		t.Fatalf("pushHelmChart: %v", err)
+	}
+	if c.Name != "mychart" || d.Context().String() != repo.String()+"/mychart" {
+		t.Errorf("pushed %+v to %s", c, d)
+	}
+
+	desc, err := remote.Get(d.Context().Tag("1.0.0_abc"))
+	if err != nil {
+		t.Fatalf("failed to get tag: %v", err)
+	}
+	if desc.Digest.String() != d.DigestStr() {
+		t.Errorf("tag is %s, want %s", desc.Digest, d.DigestStr())
+	}
+	var m artifactManifest
+	if err := json.Unmarshal(desc.Manifest, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ArtifactType != "" || m.Config.MediaType != helmConfigMediaType {
+		t.Errorf("got manifest %s", desc.Manifest)
+	}
+	if len(m.Layers) != 2 || m.Layers[0].MediaType != helmChartMediaType || m.Layers[1].MediaType != helmProvenanceMediaType {
+		t.Errorf("got layers %+v", m.Layers)
+	}
+	if m.Annotations["org.opencontainers.image.version"] != "1.0.0+abc" {
+		t.Errorf("got annotations %v", m.Annotations)
+	}
+
+	got, err := readHelmChart(desc)
+	if err != nil {
+		t.Fatalf("readHelmChart: %v", err)
+	}
+	if got.Name != "mychart" || got.Version != "1.0.0+abc" {
+		t.Errorf("readHelmChart = %+v", got)
+	}
+}
+
+func TestAccHelmChart(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "charts")
+	defer cleanup()
+
+	dir := t.TempDir()
+	for _, v := range []string{"0.1.0", "0.2.0", "0.3.0-rc.1"} {
+		chart := helmPackage(t, map[string]string{"mychart/Chart.yaml": fmt.Sprintf("name: mychart\nversion: %s\nappVersion: \"1.0\"\n", v)})
+		if err := os.WriteFile(filepath.Join(dir, "mychart-"+v+".tgz"), chart, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notChart := filepath.Join(dir, "not-a-chart.tgz")
+	if err := os.WriteFile(notChart, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_helm_chart" "test" {
+			  repo       = %q
+			  chart_path = %q
+			}`, repo, notChart),
+			ExpectError: regexp.MustCompile(`Unable to push chart`),
+		}, {
+			Config: fmt.Sprintf(`
+			resource "oci_helm_chart" "v1" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.1.0.tgz"
+			}
+			resource "oci_helm_chart" "v2" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.2.0.tgz"
+			}
+			resource "oci_helm_chart" "rc" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.3.0-rc.1.tgz"
+			}`, repo, dir),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("oci_helm_chart.v2", "name", "mychart"),
+				resource.TestCheckResourceAttr("oci_helm_chart.v2", "version", "0.2.0"),
+				resource.TestMatchResourceAttr("oci_helm_chart.v2", "image_ref", regexp.MustCompile(regexp.QuoteMeta(repo.String())+`/mychart@sha256:`)),
+			),
+		}, {
+			ResourceName:            "oci_helm_chart.v2",
+			ImportState:             true,
+			ImportStateVerify:       true,
+			ImportStateVerifyIgnore: []string{"repo", "chart_path", "name", "version"},
+		}, {
+			Config: fmt.Sprintf(`
+			resource "oci_helm_chart" "v1" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.1.0.tgz"
+			}
+			resource "oci_helm_chart" "v2" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.2.0.tgz"
+			}
+			resource "oci_helm_chart" "rc" {
+			  repo       = %[1]q
+			  chart_path = "%[2]s/mychart-0.3.0-rc.1.tgz"
+			}
+			data "oci_helm_chart" "latest" {
+			  repo       = "%[1]s/mychart"
+			  depends_on = [oci_helm_chart.v1, oci_helm_chart.v2, oci_helm_chart.rc]
+			}
+			data "oci_helm_chart" "v1" {
+			  repo       = "%[1]s/mychart"
+			  version    = "0.1.0"
+			  depends_on = [oci_helm_chart.v1]
+			}`, repo, dir),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_helm_chart.latest", "version", "0.2.0"),
+				resource.TestCheckResourceAttr("data.oci_helm_chart.latest", "app_version", "1.0"),
+				resource.TestCheckResourceAttr("data.oci_helm_chart.latest", "versions.#", "3"),
+				resource.TestCheckResourceAttr("data.oci_helm_chart.latest", "versions.2", "0.3.0-rc.1"),
+				resource.TestCheckResourceAttrPair("data.oci_helm_chart.latest", "image_ref", "oci_helm_chart.v2", "image_ref"),
+				resource.TestCheckResourceAttrPair("data.oci_helm_chart.v1", "image_ref", "oci_helm_chart.v1", "image_ref"),
+			),
+		}},
+	})
+}
@@ -0,0 +1,369 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// imagePolicy is a sigstore policy-controller ClusterImagePolicy or
+// ImagePolicy. Only the parts that can be checked without a cluster, KMS or
+// the public sigstore infrastructure are supported; see unsupported.
+type imagePolicy struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Images []struct {
+			Glob string `yaml:"glob"`
+		} `yaml:"images"`
+		Authorities []policyAuthority `yaml:"authorities"`
+		Mode        string            `yaml:"mode"`
+		Policy      any               `yaml:"policy"`
+	} `yaml:"spec"`
+}
+
+type policyAuthority struct {
+	Name string `yaml:"name"`
+	Key  *struct {
+		Data          string `yaml:"data"`
+		HashAlgorithm string `yaml:"hashAlgorithm"`
+		KMS           string `yaml:"kms"`
+		SecretRef     any    `yaml:"secretRef"`
+	} `yaml:"key"`
+	Keyless *struct {
+		URL    string `yaml:"url"`
+		CACert *struct {
+			Data string `yaml:"data"`
+		} `yaml:"ca-cert"`
+		Identities   []cosignIdentity `yaml:"identities"`
+		TrustRootRef string           `yaml:"trustRootRef"`
+	} `yaml:"keyless"`
+	Static *struct {
+		Action  string `yaml:"action"`
+		Message string `yaml:"message"`
+	} `yaml:"static"`
+	Source       any `yaml:"source"`
+	Attestations []struct {
+		Name          string `yaml:"name"`
+		PredicateType string `yaml:"predicateType"`
+		Policy        any    `yaml:"policy"`
+	} `yaml:"attestations"`
+}
+
+// predicateTypes are the short names that policy-controller accepts for
+// attestation predicate types.
+var predicateTypes = map[string]string{
+	"custom":           "https://cosign.sigstore.dev/attestation/v1",
+	"slsaprovenance":   "https://slsa.dev/provenance/v0.2",
+	"slsaprovenance02": "https://slsa.dev/provenance/v0.2",
+	"slsaprovenance1":  "https://slsa.dev/provenance/v1",
+	"spdx":             "https://spdx.dev/Document",
+	"spdxjson":         "https://spdx.dev/Document",
+	"cyclonedx":        "https://cyclonedx.org/bom",
+	"link":             "https://in-toto.io/Link/v1",
+	"vuln":             "https://cosign.sigstore.dev/attestation/vuln/v1",
+}
+
+// parseImagePolicies parses the policies in the YAML documents of b.
+func parseImagePolicies(b []byte) ([]imagePolicy, error) {
+	var policies []imagePolicy
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var p imagePolicy
+		if err := dec.Decode(&p); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing policy: %w", err)
+		}
+		if p.Kind != "ClusterImagePolicy" && p.Kind != "ImagePolicy" {
+			return nil, fmt.Errorf("policy %q has kind %q, want ClusterImagePolicy or ImagePolicy", p.Metadata.Name, p.Kind)
+		}
+		if err := p.unsupported(); err != nil {
+			return nil, fmt.Errorf("policy %q: %w", p.Metadata.Name, err)
+		}
+		policies = append(policies, p)
+	}
+	if len(policies) == 0 {
+		return nil, errors.New("no policies found")
+	}
+	return policies, nil
+}
+
+// unsupported returns an error describing the parts of p that can't be
+// evaluated here.
+func (p imagePolicy) unsupported() error {
+	var errs []error
+	if p.Spec.Policy != nil {
+		errs = append(errs, errors.New("spec.policy is not supported"))
+	}
+	if len(p.Spec.Authorities) == 0 {
+		errs = append(errs, errors.New("no authorities"))
+	}
+	for i, a := range p.Spec.Authorities {
+		an := a.name(i)
+		n := 0
+		if a.Key != nil {
+			n++
+			if a.Key.KMS != "" || a.Key.SecretRef != nil {
+				errs = append(errs, fmt.Errorf("authority %q: only key.data is supported", an))
+			}
+			if _, err := hashAlgorithm(a.Key.HashAlgorithm); err != nil {
+				errs = append(errs, fmt.Errorf("authority %q: %w", an, err))
+			}
+		}
+		if a.Keyless != nil {
+			n++
+			if a.Keyless.CACert == nil || a.Keyless.CACert.Data == "" {
+				errs = append(errs, fmt.Errorf("authority %q: keyless requires ca-cert.data", an))
+			}
+			if a.Keyless.TrustRootRef != "" {
+				errs = append(errs, fmt.Errorf("authority %q: keyless.trustRootRef is not supported", an))
+			}
+		}
+		if a.Static != nil {
+			n++
+			if a.Static.Action != "pass" && a.Static.Action != "fail" {
+				errs = append(errs, fmt.Errorf("authority %q: static.action must be pass or fail", an))
+			}
+		}
+		if n != 1 {
+			errs = append(errs, fmt.Errorf("authority %q: exactly one of key, keyless or static is required", an))
+		}
+		if a.Source != nil {
+			errs = append(errs, fmt.Errorf("authority %q: source is not supported", an))
+		}
+		for _, att := range a.Attestations {
+			if att.Policy != nil {
+				errs = append(errs, fmt.Errorf("authority %q: attestation %q: policy is not supported", an, att.Name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a policyAuthority) name(i int) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return fmt.Sprintf("authority-%d", i)
+}
+
+func hashAlgorithm(s string) (crypto.Hash, error) {
+	switch s {
+	case "", "sha256":
+		return crypto.SHA256, nil
+	case "sha384":
+		return crypto.SHA384, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hashAlgorithm %q", s)
+	}
+}
+
+// matches reports whether p applies to d, by one of its images' globs.
+func (p imagePolicy) matches(d name.Digest) (bool, error) {
+	for _, img := range p.Spec.Images {
+		ok, err := globMatch(img.Glob, d)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// globMatch reports whether glob matches the repository of d. "*" matches
+// within a path component, and "**" across them. Like image references, globs
+// without a registry are on Docker Hub.
+func globMatch(glob string, d name.Digest) (bool, error) {
+	if first, _, ok := strings.Cut(glob, "/"); !strings.ContainsAny(first, ".:*") && first != "localhost" {
+		if !ok {
+			glob = "library/" + glob
+		}
+		glob = name.DefaultRegistry + "/" + glob
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			re.WriteString("[^/]*")
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	r, err := regexp.Compile(re.String())
+	if err != nil {
+		return false, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	return r.MatchString(d.Context().Name()), nil
+}
+
+// policyResult is the outcome of evaluating policies against an image.
+type policyResult struct {
+	// matchedPolicies are the names of the policies that apply to the image.
+	matchedPolicies []string
+	// matchedAuthorities are the authorities, as "policy/authority", that
+	// the image satisfied.
+	matchedAuthorities []string
+	// failures describe the policies that the image fails, in enforce mode,
+	// and warnings those that it fails in warn mode.
+	failures, warnings []string
+}
+
+// passed reports whether the image satisfies every policy that's enforced.
+func (r policyResult) passed() bool { return len(r.failures) == 0 }
+
+// evaluatePolicies evaluates policies against d. An image satisfies a policy
+// if it satisfies any of its authorities, and passes if it satisfies every
+// enforced policy that applies to it. Keyless signatures must be logged in
+// tlog.
+func (p *ProviderOpts) evaluatePolicies(ctx context.Context, d name.Digest, policies []imagePolicy, tlog cosignTlog) (policyResult, error) {
+	var res policyResult
+	entries := map[string][]cosignEntry{}
+	getEntries := func(suffix string) ([]cosignEntry, error) {
+		if es, ok := entries[suffix]; ok {
+			return es, nil
+		}
+		es, err := p.cosignEntries(ctx, d, suffix)
+		if err != nil {
+			return nil, err
+		}
+		entries[suffix] = es
+		return es, nil
+	}
+
+	for _, pol := range policies {
+		ok, err := pol.matches(d)
+		if err != nil {
+			return res, fmt.Errorf("policy %q: %w", pol.Metadata.Name, err)
+		} else if !ok {
+			continue
+		}
+		res.matchedPolicies = append(res.matchedPolicies, pol.Metadata.Name)
+
+		var errs []string
+		satisfied := false
+		for i, a := range pol.Spec.Authorities {
+			err := a.check(d, getEntries, tlog)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("authority %q: %s", a.name(i), err))
+				continue
+			}
+			satisfied = true
+			res.matchedAuthorities = append(res.matchedAuthorities, pol.Metadata.Name+"/"+a.name(i))
+		}
+		if satisfied {
+			continue
+		}
+		msg := fmt.Sprintf("policy %q: no authority is satisfied:\n%s", pol.Metadata.Name, strings.Join(errs, "\n"))
+		if pol.Spec.Mode == "warn" {
+			res.warnings = append(res.warnings, msg)
+		} else {
+			res.failures = append(res.failures, msg)
+		}
+	}
+	return res, nil
+}
+
+// check returns an error unless d satisfies a: it's signed by a's signer, or,
+// if a lists attestations, it has each of them from a's signer.
+func (a policyAuthority) check(d name.Digest, getEntries func(suffix string) ([]cosignEntry, error), tlog cosignTlog) error {
+	if a.Static != nil {
+		if a.Static.Action == "pass" {
+			return nil
+		}
+		if a.Static.Message != "" {
+			return fmt.Errorf("static fail: %s", a.Static.Message)
+		}
+		return errors.New("static fail")
+	}
+	v, err := a.verifier(tlog)
+	if err != nil {
+		return err
+	}
+
+	if len(a.Attestations) == 0 {
+		sigs, err := getEntries("sig")
+		if err != nil {
+			return err
+		}
+		return verifyAnySignature(v, sigs, d)
+	}
+
+	atts, err := getEntries("att")
+	if err != nil {
+		return err
+	}
+	var verified []string
+	var errs []error
+	for _, e := range atts {
+		pt, err := verifyAttestationEntry(v, e, d)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		verified = append(verified, pt)
+	}
+	var missing []error
+	for _, att := range a.Attestations {
+		want := att.PredicateType
+		if full, ok := predicateTypes[want]; ok {
+			want = full
+		}
+		found := false
+		for _, pt := range verified {
+			found = found || pt == want
+		}
+		if !found {
+			missing = append(missing, fmt.Errorf("no valid attestation %q of type %s found", att.Name, want))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Join(append(missing, errs...)...)
+	}
+	return nil
+}
+
+// verifier returns the verifier of a's key or keyless signer, whose
+// signatures must be logged in tlog.
+func (a policyAuthority) verifier(tlog cosignTlog) (cosignVerifier, error) {
+	if a.Key != nil {
+		key, err := parsePublicKey([]byte(a.Key.Data))
+		if err != nil {
+			return cosignVerifier{}, fmt.Errorf("parsing key: %w", err)
+		}
+		h, err := hashAlgorithm(a.Key.HashAlgorithm)
+		if err != nil {
+			return cosignVerifier{}, err
+		}
+		return cosignVerifier{key: key, hash: h}, nil
+	}
+	certs, err := parseCertificates([]byte(a.Keyless.CACert.Data))
+	if err != nil {
+		return cosignVerifier{}, fmt.Errorf("parsing ca-cert: %w", err)
+	}
+	if len(certs) == 0 {
+		return cosignVerifier{}, errors.New("no certificates in ca-cert")
+	}
+	roots := x509.NewCertPool()
+	for _, c := range certs {
+		roots.AddCert(c)
+	}
+	return cosignVerifier{roots: roots, identities: a.Keyless.Identities, tlog: tlog}, nil
+}
@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// indent indents each line of s by n spaces, to embed it in YAML.
+func indent(s string, n int) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n"+strings.Repeat(" ", n))
+}
+
+func keyPolicy(name, glob, pub string) string {
+	return fmt.Sprintf(`apiVersion: policy.sigstore.dev/v1beta1
+kind: ClusterImagePolicy
+metadata:
+  name: %s
+spec:
+  images:
+  - glob: %q
+  authorities:
+  - name: key
+    key:
+      data: |
+        %s
+`, name, glob, indent(pub, 8))
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, c := range []struct {
+		glob, ref string
+		want      bool
+	}{
+		{"example.com/foo/*", "example.com/foo/bar", true},
+		{"example.com/foo/*", "example.com/foo/bar/baz", false},
+		{"example.com/foo/**", "example.com/foo/bar/baz", true},
+		{"example.com/*", "example.org/foo", false},
+		{"**", "example.org/foo", true},
+		{"ubuntu", "index.docker.io/library/ubuntu", true},
+		{"ubuntu", "ubuntu", true},
+		{"chainguard/*", "chainguard/static", true},
+		{"localhost:5000/app", "localhost:5000/app", true},
+	} {
+		d, err := name.NewDigest(c.ref + "@sha256:" + strings.Repeat("0", 64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := globMatch(c.glob, d)
+		if err != nil {
+			t.Errorf("globMatch(%q, %q): %v", c.glob, c.ref, err)
+		}
+		if got != c.want {
+			t.Errorf("globMatch(%q, %q) = %t, want %t", c.glob, c.ref, got, c.want)
+		}
+	}
+}
+
+func TestParseImagePolicies(t *testing.T) {
+	_, pub := newKeySigner(t)
+	for _, c := range []struct {
+		desc, policy, wantErr string
+	}{{
+		desc:   "key",
+		policy: keyPolicy("a", "**", pub),
+	}, {
+		desc:   "multiple documents",
+		policy: keyPolicy("a", "**", pub) + "---\n" + keyPolicy("b", "**", pub),
+	}, {
+		desc:    "wrong kind",
+		policy:  "kind: Deployment\nmetadata: {name: a}\n",
+		wantErr: `has kind "Deployment"`,
+	}, {
+		desc:    "empty",
+		policy:  "",
+		wantErr: "no policies found",
+	}, {
+		desc:    "kms",
+		policy:  "kind: ClusterImagePolicy\nmetadata: {name: a}\nspec:\n  authorities:\n  - key: {kms: 'awskms:///foo'}\n",
+		wantErr: `authority "authority-0": only key.data is supported`,
+	}, {
+		desc:    "keyless without ca-cert",
+		policy:  "kind: ClusterImagePolicy\nmetadata: {name: a}\nspec:\n  authorities:\n  - keyless: {url: 'https://fulcio.sigstore.dev'}\n",
+		wantErr: "keyless requires ca-cert.data",
+	}, {
+		desc:    "cue policy",
+		policy:  "kind: ClusterImagePolicy\nmetadata: {name: a}\nspec:\n  authorities:\n  - static: {action: pass}\n  policy: {type: cue, data: 'foo'}\n",
+		wantErr: "spec.policy is not supported",
+	}, {
+		desc:    "no authority type",
+		policy:  "kind: ClusterImagePolicy\nmetadata: {name: a}\nspec:\n  authorities:\n  - name: nothing\n",
+		wantErr: `authority "nothing": exactly one of key, keyless or static is required`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := parseImagePolicies([]byte(c.policy))
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("got error %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+	glob := repo.Name()
+
+	keySigner, pub := newKeySigner(t)
+	_, otherPub := newKeySigner(t)
+	fulcio := newTestFulcio(t)
+	keyless := fulcio.signer(t, "me@example.com", "https://accounts.example.com")
+	tlog := cosignTlog{key: &fulcio.rekor.key.PublicKey}
+
+	signed := pushRandomImage(t, repo)
+	keySigner.signImage(t, signed)
+	keyless.signImage(t, signed)
+	keySigner.attestImage(t, signed, "https://slsa.dev/provenance/v1", map[string]any{"builder": "test"})
+	unsigned := pushRandomImage(t, repo)
+
+	keylessPolicy := func(subject string) string {
+		return fmt.Sprintf(`kind: ClusterImagePolicy
+metadata:
+  name: keyless
+spec:
+  images:
+  - glob: %q
+  authorities:
+  - keyless:
+      ca-cert:
+        data: |
+          %s
+      identities:
+      - issuer: https://accounts.example.com
+        subject: %s
+`, glob, indent(fulcio.pem, 10), subject)
+	}
+	attestationPolicy := func(predicateType string) string {
+		return keyPolicy("attested", glob, pub) + fmt.Sprintf(`    attestations:
+    - name: provenance
+      predicateType: %s
+`, predicateType)
+	}
+
+	for _, c := range []struct {
+		desc, policy             string
+		d                        name.Digest
+		tlog                     *cosignTlog
+		wantPassed               bool
+		wantPolicies             []string
+		wantAuthorities          []string
+		wantFailure, wantWarning string
+	}{{
+		desc:            "key",
+		policy:          keyPolicy("signed", glob, pub),
+		d:               signed,
+		wantPassed:      true,
+		wantPolicies:    []string{"signed"},
+		wantAuthorities: []string{"signed/key"},
+	}, {
+		desc:         "wrong key",
+		policy:       keyPolicy("signed", glob, otherPub),
+		d:            signed,
+		wantPolicies: []string{"signed"},
+		wantFailure:  "no valid signatures found",
+	}, {
+		desc:         "unsigned",
+		policy:       keyPolicy("signed", glob, pub),
+		d:            unsigned,
+		wantPolicies: []string{"signed"},
+		wantFailure:  "no signatures found",
+	}, {
+		desc:       "no matching policy",
+		policy:     keyPolicy("elsewhere", "example.com/**", pub),
+		d:          unsigned,
+		wantPassed: true,
+	}, {
+		desc:            "any authority",
+		policy:          keyPolicy("either", glob, otherPub) + "  - name: pass\n    static: {action: pass}\n",
+		d:               unsigned,
+		wantPassed:      true,
+		wantPolicies:    []string{"either"},
+		wantAuthorities: []string{"either/pass"},
+	}, {
+		desc:         "every policy",
+		policy:       keyPolicy("signed", glob, pub) + "---\n" + keyPolicy("other", glob, otherPub),
+		d:            signed,
+		wantPolicies: []string{"signed", "other"},
+		// The image satisfies one policy, but not the other.
+		wantAuthorities: []string{"signed/key"},
+		wantFailure:     `policy "other"`,
+	}, {
+		desc:         "warn mode",
+		policy:       keyPolicy("warned", glob, otherPub) + "  mode: warn\n",
+		d:            signed,
+		wantPassed:   true,
+		wantPolicies: []string{"warned"},
+		wantWarning:  `policy "warned"`,
+	}, {
+		desc:         "static fail",
+		policy:       fmt.Sprintf("kind: ClusterImagePolicy\nmetadata: {name: nope}\nspec:\n  images: [{glob: %q}]\n  authorities:\n  - static: {action: fail, message: not allowed}\n", glob),
+		d:            signed,
+		wantPolicies: []string{"nope"},
+		wantFailure:  "static fail: not allowed",
+	}, {
+		desc:            "keyless",
+		policy:          keylessPolicy("me@example.com"),
+		d:               signed,
+		wantPassed:      true,
+		wantPolicies:    []string{"keyless"},
+		wantAuthorities: []string{"keyless/authority-0"},
+	}, {
+		desc:         "keyless untrusted log",
+		policy:       keylessPolicy("me@example.com"),
+		d:            signed,
+		tlog:         &cosignTlog{},
+		wantPolicies: []string{"keyless"},
+		wantFailure:  "not the trusted log",
+	}, {
+		desc:            "keyless ignoring log",
+		policy:          keylessPolicy("me@example.com"),
+		d:               signed,
+		tlog:            &cosignTlog{insecureIgnore: true},
+		wantPassed:      true,
+		wantPolicies:    []string{"keyless"},
+		wantAuthorities: []string{"keyless/authority-0"},
+	}, {
+		desc:         "keyless wrong identity",
+		policy:       keylessPolicy("you@example.com"),
+		d:            signed,
+		wantPolicies: []string{"keyless"},
+		wantFailure:  "doesn't match any identity",
+	}, {
+		desc:            "attestation",
+		policy:          attestationPolicy("slsaprovenance1"),
+		d:               signed,
+		wantPassed:      true,
+		wantPolicies:    []string{"attested"},
+		wantAuthorities: []string{"attested/key"},
+	}, {
+		desc:         "missing attestation",
+		policy:       attestationPolicy("spdxjson"),
+		d:            signed,
+		wantPolicies: []string{"attested"},
+		wantFailure:  `no valid attestation "provenance" of type https://spdx.dev/Document found`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			policies, err := parseImagePolicies([]byte(c.policy))
+			if err != nil {
+				t.Fatalf("parseImagePolicies: %v\n%s", err, c.policy)
+			}
+			if c.tlog == nil {
+				c.tlog = &tlog
+			}
+			p := &ProviderOpts{}
+			res, err := p.evaluatePolicies(context.Background(), c.d, policies, *c.tlog)
+			if err != nil {
+				t.Fatalf("evaluatePolicies: %v", err)
+			}
+			if got := res.passed(); got != c.wantPassed {
+				t.Errorf("passed = %t, want %t; failures: %v", got, c.wantPassed, res.failures)
+			}
+			if !slices.Equal(res.matchedPolicies, c.wantPolicies) {
+				t.Errorf("matched policies = %v, want %v", res.matchedPolicies, c.wantPolicies)
+			}
+			if !slices.Equal(res.matchedAuthorities, c.wantAuthorities) {
+				t.Errorf("matched authorities = %v, want %v", res.matchedAuthorities, c.wantAuthorities)
+			}
+			if got := strings.Join(res.failures, "\n"); !strings.Contains(got, c.wantFailure) || (c.wantFailure == "" && got != "") {
+				t.Errorf("failures = %q, want %q", got, c.wantFailure)
+			}
+			if got := strings.Join(res.warnings, "\n"); !strings.Contains(got, c.wantWarning) || (c.wantWarning == "" && got != "") {
+				t.Errorf("warnings = %q, want %q", got, c.wantWarning)
+			}
+		})
+	}
+}
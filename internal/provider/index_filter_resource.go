@@ -0,0 +1,234 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+var _ resource.Resource = &IndexFilterResource{}
+
+// Annotations that buildx sets on the attestation manifests in an index, to
+// say which image they're about.
+const (
+	dockerReferenceTypeAnnotation   = "vnd.docker.reference.type"
+	dockerReferenceDigestAnnotation = "vnd.docker.reference.digest"
+)
+
+func NewIndexFilterResource() resource.Resource {
+	return &IndexFilterResource{}
+}
+
+// IndexFilterResource defines the resource implementation.
+type IndexFilterResource struct {
+	popts ProviderOpts
+}
+
+// IndexFilterResourceModel describes the resource data model.
+type IndexFilterResourceModel struct {
+	Id       types.String `tfsdk:"id"`
+	ImageRef types.String `tfsdk:"image_ref"`
+
+	BaseIndex types.String `tfsdk:"base_index"`
+	Platforms []string     `tfsdk:"platforms"`
+	Insecure  types.Bool   `tfsdk:"insecure"`
+}
+
+func (r *IndexFilterResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_index_filter"
+}
+
+func (r *IndexFilterResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Publish a copy of a multi-platform index with only some of its platforms, e.g. for a registry that only supports some architectures. The index's and its manifests' annotations are kept, and so are the attestation manifests of the images that are kept.",
+		Attributes: map[string]schema.Attribute{
+			"base_index": schema.StringAttribute{
+				MarkdownDescription: "Index ref by digest to filter.",
+				Required:            true,
+				Validators:          []validator.String{validators.DigestValidator{}},
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"platforms": schema.ListAttribute{
+				MarkdownDescription: "Platforms to keep, e.g. `linux/amd64`. A platform without a variant, like `linux/arm64`, keeps every variant of it. Each platform must match at least one image in the index.",
+				Required:            true,
+				ElementType:         basetypes.StringType{},
+				Validators:          []validator.List{validators.PlatformValidator{}},
+				PlanModifiers:       []planmodifier.List{listplanmodifier.RequiresReplace()},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"image_ref": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified index ref by digest (e.g. {repo}@sha256:deadbeef), in the repository of `base_index`.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The resulting fully-qualified index ref by digest (e.g. {repo}@sha256:deadbeef), in the repository of `base_index`.",
+				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *IndexFilterResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	r.popts = *popts
+}
+
+func (r *IndexFilterResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *IndexFilterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := r.doFilter(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to filter index", fmt.Sprintf("Unable to filter index %s, got error: %s", data.BaseIndex.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *IndexFilterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *IndexFilterResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Filtering is deterministic, so this pushes the same index again if it
+	// was deleted, like oci_append does.
+	d, err := r.doFilter(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to filter index", fmt.Sprintf("Unable to filter index %s, got error: %s", data.BaseIndex.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *IndexFilterResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *IndexFilterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	d, err := r.doFilter(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to filter index", fmt.Sprintf("Unable to filter index %s, got error: %s", data.BaseIndex.ValueString(), err))
+		return
+	}
+	data.Id = types.StringValue(d.String())
+	data.ImageRef = types.StringValue(d.String())
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *IndexFilterResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	resp.Diagnostics.Append(req.State.Get(ctx, &IndexFilterResourceModel{})...)
+}
+
+func (r *IndexFilterResource) doFilter(ctx context.Context, data *IndexFilterResourceModel) (name.Digest, error) {
+	ref, err := name.NewDigest(data.BaseIndex.ValueString(), r.popts.nameOptions(data.BaseIndex.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("invalid base index: %w", err)
+	}
+	platforms := make([]v1.Platform, len(data.Platforms))
+	for i, s := range data.Platforms {
+		p, err := v1.ParsePlatform(s)
+		if err != nil {
+			return name.Digest{}, fmt.Errorf("invalid platform %q: %w", s, err)
+		}
+		platforms[i] = *p
+	}
+	return r.popts.filterIndex(ctx, ref, platforms)
+}
+
+// filterIndex pushes a copy of the index ref with only the images for
+// platforms, and the attestation manifests that refer to them, to the same
+// repository, and returns its ref by digest.
+func (p *ProviderOpts) filterIndex(ctx context.Context, ref name.Digest, platforms []v1.Platform) (name.Digest, error) {
+	var idx v1.ImageIndex
+	if err := retryTransient(ctx, func(ctx context.Context) (err error) {
+		idx, err = remote.Index(ref, p.withContext(ctx)...)
+		return err
+	}); err != nil {
+		return name.Digest{}, err
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+
+	keep := map[v1.Hash]bool{}
+	for _, want := range platforms {
+		found := false
+		for _, desc := range m.Manifests {
+			if desc.Platform != nil && desc.Platform.Satisfies(want) {
+				keep[desc.Digest], found = true, true
+			}
+		}
+		if !found {
+			return name.Digest{}, fmt.Errorf("no image for platform %s in the index", want)
+		}
+	}
+	for _, desc := range m.Manifests {
+		if desc.Annotations[dockerReferenceTypeAnnotation] == "attestation-manifest" {
+			if h, err := v1.NewHash(desc.Annotations[dockerReferenceDigestAnnotation]); err == nil && keep[h] {
+				keep[desc.Digest] = true
+			}
+		}
+	}
+
+	// Removing the others keeps the index's media type and annotations, and
+	// the descriptors of what's kept as they are.
+	filtered := mutate.RemoveManifests(idx, func(desc v1.Descriptor) bool {
+		return !keep[desc.Digest]
+	})
+	dig, err := filtered.Digest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	d := ref.Context().Digest(dig.String())
+	if err := retryTransient(ctx, func(ctx context.Context) error {
+		return remote.WriteIndex(d, filtered, p.withContext(ctx)...)
+	}); err != nil {
+		return name.Digest{}, fmt.Errorf("pushing %s: %w", d, err)
+	}
+	return d, nil
+}
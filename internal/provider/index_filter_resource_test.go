@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+// multiPlatformIndex returns an annotated index of images for linux/amd64,
+// linux/arm64/v8 and linux/arm/v7, each followed by its attestation manifest.
+func multiPlatformIndex(t *testing.T) v1.ImageIndex {
+	t.Helper()
+	var idx v1.ImageIndex = mutate.Annotations(empty.Index, map[string]string{"org.opencontainers.image.source": "https://example.com/app"}).(v1.ImageIndex)
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	} {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		att, err := random.Image(128, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform:    &p,
+				Annotations: map[string]string{"org.opencontainers.image.revision": p.Architecture},
+			},
+		}, mutate.IndexAddendum{
+			Add: att,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+				Annotations: map[string]string{
+					dockerReferenceTypeAnnotation:   "attestation-manifest",
+					dockerReferenceDigestAnnotation: d.String(),
+				},
+			},
+		})
+	}
+	return idx
+}
+
+func TestFilterIndex(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	idx := multiPlatformIndex(t)
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+	base, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &ProviderOpts{}
+	got, err := p.filterIndex(context.Background(), ref, []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	})
+	if err != nil {
+		t.Fatalf("filterIndex: %v", err)
+	}
+	if got.Context() != ref.Context() {
+		t.Errorf("pushed to %s, want %s", got.Context(), ref.Context())
+	}
+
+	filtered, err := remote.Index(got)
+	if err != nil {
+		t.Fatalf("failed to get filtered index: %v", err)
+	}
+	m, err := filtered.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Annotations["org.opencontainers.image.source"], "https://example.com/app"; got != want {
+		t.Errorf("index annotation = %q, want %q", got, want)
+	}
+	// The amd64 and arm64 images, and their attestations, in their order.
+	want := []v1.Descriptor{base.Manifests[0], base.Manifests[1], base.Manifests[2], base.Manifests[3]}
+	if len(m.Manifests) != len(want) {
+		t.Fatalf("got %d manifests, want %d", len(m.Manifests), len(want))
+	}
+	for i, desc := range m.Manifests {
+		if desc.Digest != want[i].Digest {
+			t.Errorf("manifest %d = %s, want %s", i, desc.Digest, want[i].Digest)
+		}
+		if fmt.Sprint(desc.Annotations) != fmt.Sprint(want[i].Annotations) {
+			t.Errorf("manifest %d annotations = %v, want %v", i, desc.Annotations, want[i].Annotations)
+		}
+	}
+
+	if _, err := p.filterIndex(context.Background(), ref, []v1.Platform{{OS: "linux", Architecture: "s390x"}}); err == nil {
+		t.Error("filterIndex for a missing platform succeeded")
+	}
+}
+
+func TestAccIndexFilterResource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	idx := multiPlatformIndex(t)
+	d, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_index_filter" "test" {
+  base_index = %q
+  platforms  = ["linux/amd64"]
+}`, ref),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestMatchResourceAttr("oci_index_filter.test", "image_ref", regexp.MustCompile(`^`+regexp.QuoteMeta(repo.String())+`@sha256:`)),
+				resource.TestCheckResourceAttrPair("oci_index_filter.test", "id", "oci_index_filter.test", "image_ref"),
+			),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_index_filter" "test" {
+  base_index = %q
+  platforms  = ["windows/amd64"]
+}`, ref),
+			ExpectError: regexp.MustCompile(`no image for platform windows/amd64`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_index_filter" "test" {
+  base_index = %q
+  platforms  = ["linux"]
+}`, ref),
+			ExpectError: regexp.MustCompile(`Invalid platform`),
+		}},
+	})
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &IsPinnedFunction{}
+
+func NewIsPinnedFunction() function.Function {
+	return &IsPinnedFunction{}
+}
+
+// IsPinnedFunction defines the function implementation.
+type IsPinnedFunction struct{}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *IsPinnedFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "is_pinned"
+}
+
+// Definition should return the definition for the function.
+func (s *IsPinnedFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Reports whether an OCI reference is pinned by digest.",
+		Description: "Returns true if the reference includes a digest, and false if it only has a tag. Invalid references are an error.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string to check.",
+			},
+		},
+		Return: function.BoolReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *IsPinnedFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	ref, err := name.ParseReference(input)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+	_, pinned := ref.(name.Digest)
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, pinned))
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestIsPinnedFunction(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `
+output "digest" { value = provider::oci::is_pinned("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "tag_and_digest" { value = provider::oci::is_pinned("cgr.dev/foo/sample:latest@sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "tag" { value = provider::oci::is_pinned("cgr.dev/foo/sample:latest") }
+output "implicit_tag" { value = provider::oci::is_pinned("sample") }
+`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("tag_and_digest", knownvalue.Bool(true)),
+				statecheck.ExpectKnownOutputValue("tag", knownvalue.Bool(false)),
+				statecheck.ExpectKnownOutputValue("implicit_tag", knownvalue.Bool(false)),
+			},
+		}},
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config:      `output "pinned" { value = provider::oci::is_pinned("") }`,
+			ExpectError: regexp.MustCompile("Failed to parse OCI reference"),
+		}},
+	})
+}
@@ -0,0 +1,475 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// layoutRegistry serves the registry API from OCI image layouts on disk, so
+// that the provider can run without network access.
+//
+// Each repository is stored as its own layout at <root>/<registry>/<repo>,
+// and tags are recorded as org.opencontainers.image.ref.name annotations in
+// the layout's index.json.
+type layoutRegistry struct {
+	root string
+
+	// mu guards updates to index.json files.
+	mu sync.Mutex
+}
+
+func newLayoutRegistry(root string) *layoutRegistry {
+	return &layoutRegistry{root: root}
+}
+
+// refNameAnnotation records a manifest's tag in a layout's index.json.
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+var (
+	manifestPathRE = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	uploadPathRE   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([^/]*)$`)
+	blobPathRE     = regexp.MustCompile(`^/v2/(.+)/blobs/([a-z0-9]+:[a-f0-9]+)$`)
+	tagsPathRE     = regexp.MustCompile(`^/v2/(.+)/tags/list$`)
+)
+
+type registryError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *registryError) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": e.code, "message": e.message}},
+	})
+}
+
+func (r *layoutRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var err *registryError
+	switch p := req.URL.Path; {
+	case p == "/v2/" || p == "/v2":
+		w.WriteHeader(http.StatusOK)
+	case manifestPathRE.MatchString(p):
+		m := manifestPathRE.FindStringSubmatch(p)
+		err = r.manifest(w, req, m[1], m[2])
+	case uploadPathRE.MatchString(p):
+		m := uploadPathRE.FindStringSubmatch(p)
+		err = r.upload(w, req, m[1], m[2])
+	case blobPathRE.MatchString(p):
+		m := blobPathRE.FindStringSubmatch(p)
+		err = r.blob(w, req, m[1], m[2])
+	case tagsPathRE.MatchString(p):
+		err = r.tags(w, req, tagsPathRE.FindStringSubmatch(p)[1])
+	default:
+		err = &registryError{http.StatusNotFound, "NAME_UNKNOWN", "unsupported path " + p}
+	}
+	if err != nil {
+		err.write(w)
+	}
+}
+
+// layout returns the layout for repo on the request's registry, creating it
+// if create is true.
+func (r *layoutRegistry) layout(req *http.Request, repo string, create bool) (layout.Path, *registryError) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	ref, err := name.NewRepository(host + "/" + repo)
+	if err != nil {
+		return "", &registryError{http.StatusBadRequest, "NAME_INVALID", err.Error()}
+	}
+	dir := filepath.Join(r.root, ref.RegistryStr(), filepath.FromSlash(ref.RepositoryStr()))
+	if p, err := layout.FromPath(dir); err == nil {
+		return p, nil
+	} else if !create {
+		return "", &registryError{http.StatusNotFound, "NAME_UNKNOWN", fmt.Sprintf("repository %s not found", ref)}
+	}
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return "", &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	return p, nil
+}
+
+func (r *layoutRegistry) indexManifest(p layout.Path) (*v1.IndexManifest, error) {
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	return idx.IndexManifest()
+}
+
+func (r *layoutRegistry) manifest(w http.ResponseWriter, req *http.Request, repo, ref string) *registryError {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		p, rerr := r.layout(req, repo, false)
+		if rerr != nil {
+			return &registryError{http.StatusNotFound, "MANIFEST_UNKNOWN", rerr.message}
+		}
+		im, err := r.indexManifest(p)
+		if err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		var mt types.MediaType
+		h, err := v1.NewHash(ref)
+		if err != nil {
+			// ref is a tag.
+			found := false
+			for _, d := range im.Manifests {
+				if d.Annotations[refNameAnnotation] == ref {
+					h, mt, found = d.Digest, d.MediaType, true
+				}
+			}
+			if !found {
+				return &registryError{http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("tag %s not found", ref)}
+			}
+		}
+		b, err := p.Bytes(h)
+		if errors.Is(err, os.ErrNotExist) {
+			return &registryError{http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("manifest %s not found", h)}
+		} else if err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		if mt == "" {
+			var m struct {
+				MediaType types.MediaType `json:"mediaType"`
+			}
+			_ = json.Unmarshal(b, &m)
+			mt = m.MediaType
+			if mt == "" {
+				mt = types.OCIManifestSchema1
+			}
+		}
+		w.Header().Set("Content-Type", string(mt))
+		w.Header().Set("Docker-Content-Digest", h.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+		return nil
+
+	case http.MethodPut:
+		p, rerr := r.layout(req, repo, true)
+		if rerr != nil {
+			return rerr
+		}
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return &registryError{http.StatusBadRequest, "MANIFEST_INVALID", err.Error()}
+		}
+		sum := sha256.Sum256(b)
+		h := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+		if want, err := v1.NewHash(ref); err == nil && want != h {
+			return &registryError{http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("got digest %s, want %s", h, want)}
+		}
+		if err := p.WriteBlob(h, io.NopCloser(bytes.NewReader(b))); err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		desc := v1.Descriptor{
+			MediaType: types.MediaType(req.Header.Get("Content-Type")),
+			Size:      int64(len(b)),
+			Digest:    h,
+		}
+		if _, err := v1.NewHash(ref); err != nil {
+			desc.Annotations = map[string]string{refNameAnnotation: ref}
+		}
+		if err := r.addDescriptor(p, desc); err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		w.Header().Set("Docker-Content-Digest", h.String())
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+	return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method + " is not supported"}
+}
+
+// addDescriptor records desc in p's index.json, moving its tag if it has one.
+// Untagged manifests are recorded once, so that the layout stays complete.
+func (r *layoutRegistry) addDescriptor(p layout.Path, desc v1.Descriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	im, err := r.indexManifest(p)
+	if err != nil {
+		return err
+	}
+	tag := desc.Annotations[refNameAnnotation]
+	manifests := im.Manifests[:0]
+	for _, d := range im.Manifests {
+		dtag := d.Annotations[refNameAnnotation]
+		if tag != "" && dtag == tag {
+			continue
+		}
+		if tag == "" && dtag == "" && d.Digest == desc.Digest {
+			return nil
+		}
+		manifests = append(manifests, d)
+	}
+	im.Manifests = append(manifests, desc)
+	b, err := json.MarshalIndent(im, "", "   ")
+	if err != nil {
+		return err
+	}
+	return p.WriteFile("index.json", b, os.ModePerm)
+}
+
+func (r *layoutRegistry) blob(w http.ResponseWriter, req *http.Request, repo, digest string) *registryError {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method + " is not supported"}
+	}
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return &registryError{http.StatusBadRequest, "DIGEST_INVALID", err.Error()}
+	}
+	p, rerr := r.layout(req, repo, false)
+	if rerr != nil {
+		return &registryError{http.StatusNotFound, "BLOB_UNKNOWN", rerr.message}
+	}
+	rc, err := p.Blob(h)
+	if errors.Is(err, os.ErrNotExist) {
+		return &registryError{http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("blob %s not found", h)}
+	} else if err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	defer rc.Close()
+	f, _ := rc.(*os.File)
+	if f == nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", "unexpected blob reader"}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", h.String())
+	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = io.Copy(w, f)
+	}
+	return nil
+}
+
+func (r *layoutRegistry) upload(w http.ResponseWriter, req *http.Request, repo, id string) *registryError {
+	p, rerr := r.layout(req, repo, true)
+	if rerr != nil {
+		return rerr
+	}
+	uploads := filepath.Join(r.root, ".uploads")
+	location := func(id string) string { return "/v2/" + repo + "/blobs/uploads/" + id }
+
+	switch {
+	case req.Method == http.MethodPost && id == "":
+		// Cross-repository mounts copy the blob, if it exists.
+		if mount, from := req.URL.Query().Get("mount"), req.URL.Query().Get("from"); mount != "" && from != "" {
+			if h, err := v1.NewHash(mount); err == nil {
+				if src, rerr := r.layout(req, from, false); rerr == nil {
+					if rc, err := src.Blob(h); err == nil {
+						if err := p.WriteBlob(h, rc); err != nil {
+							return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+						}
+						w.Header().Set("Docker-Content-Digest", h.String())
+						w.WriteHeader(http.StatusCreated)
+						return nil
+					}
+				}
+			}
+		}
+		if err := os.MkdirAll(uploads, 0o755); err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		id := hex.EncodeToString(b[:])
+		f, err := os.Create(filepath.Join(uploads, id))
+		if err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		if _, err := io.Copy(f, req.Body); err != nil {
+			f.Close()
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		f.Close()
+		if d := req.URL.Query().Get("digest"); d != "" {
+			return r.commit(w, p, filepath.Join(uploads, id), d)
+		}
+		w.Header().Set("Location", location(id))
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+
+	case (req.Method == http.MethodPatch || req.Method == http.MethodPut) && id != "":
+		if _, err := hex.DecodeString(id); err != nil {
+			return &registryError{http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "unknown upload " + id}
+		}
+		path := filepath.Join(uploads, id)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return &registryError{http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "unknown upload " + id}
+		}
+		_, err = io.Copy(f, req.Body)
+		f.Close()
+		if err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		if req.Method == http.MethodPut {
+			return r.commit(w, p, path, req.URL.Query().Get("digest"))
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+		}
+		w.Header().Set("Location", location(id))
+		w.Header().Set("Range", fmt.Sprintf("0-%d", max(fi.Size()-1, 0)))
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method + " is not supported"}
+}
+
+// commit verifies that the upload at path has the given digest and moves it
+// into p's blobs.
+func (r *layoutRegistry) commit(w http.ResponseWriter, p layout.Path, path, digest string) *registryError {
+	defer os.Remove(path)
+	want, err := v1.NewHash(digest)
+	if err != nil {
+		return &registryError{http.StatusBadRequest, "DIGEST_INVALID", err.Error()}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	got, _, err := v1.SHA256(f)
+	f.Close()
+	if err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	if got != want {
+		return &registryError{http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("got digest %s, want %s", got, want)}
+	}
+	if err := os.MkdirAll(filepath.Join(string(p), "blobs", want.Algorithm), os.ModePerm); err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	if err := os.Rename(path, filepath.Join(string(p), "blobs", want.Algorithm, want.Hex)); err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	w.Header().Set("Docker-Content-Digest", want.String())
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (r *layoutRegistry) tags(w http.ResponseWriter, req *http.Request, repo string) *registryError {
+	if req.Method != http.MethodGet {
+		return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", req.Method + " is not supported"}
+	}
+	p, rerr := r.layout(req, repo, false)
+	if rerr != nil {
+		return rerr
+	}
+	im, err := r.indexManifest(p)
+	if err != nil {
+		return &registryError{http.StatusInternalServerError, "UNKNOWN", err.Error()}
+	}
+	tags := []string{}
+	for _, d := range im.Manifests {
+		if t := d.Annotations[refNameAnnotation]; t != "" {
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{repo, tags})
+	return nil
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// in-process http.Handler, streaming response bodies.
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{header: http.Header{}, pw: pw, ready: make(chan struct{})}
+	go func() {
+		t.h.ServeHTTP(w, req)
+		w.WriteHeader(http.StatusOK)
+		pw.Close()
+	}()
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		pr.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+	contentLength := int64(-1)
+	if cl, err := strconv.ParseInt(w.header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = cl
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          pr,
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+// pipeResponseWriter is an http.ResponseWriter that writes the body to a pipe,
+// signalling ready once the headers are written.
+type pipeResponseWriter struct {
+	header http.Header
+	status int
+	pw     *io.PipeWriter
+	once   sync.Once
+	ready  chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header { return w.header }
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(b)
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestLayoutRegistry(t *testing.T) {
+	dir := t.TempDir()
+	ropt := remote.WithTransport(handlerTransport{h: newLayoutRegistry(dir)})
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := name.MustParseReference("registry.example.com/foo/bar:latest")
+	if err := remote.Write(tag, img, ropt); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// The image can be read back by tag and by digest.
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Get(tag, ropt)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if desc.Digest != want {
+		t.Errorf("got digest %s, want %s", desc.Digest, want)
+	}
+	got, err := remote.Image(tag.Context().Digest(want.String()), ropt)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if _, err := got.ConfigFile(); err != nil {
+		t.Errorf("ConfigFile: %v", err)
+	}
+
+	// Indexes can be written too, and tags move to the latest manifest.
+	idx, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(tag, idx, ropt); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+	if err := remote.Tag(tag.Context().Tag("other"), desc, ropt); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	desc, err = remote.Get(tag, ropt)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !desc.MediaType.IsIndex() {
+		t.Errorf("got media type %s, want an index", desc.MediaType)
+	}
+	tags, err := remote.List(tag.Context(), ropt)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "latest" || tags[1] != "other" {
+		t.Errorf("got tags %v, want [latest other]", tags)
+	}
+
+	// Everything is stored in a layout per repository.
+	p, err := layout.FromPath(filepath.Join(dir, "registry.example.com", "foo", "bar"))
+	if err != nil {
+		t.Fatalf("FromPath: %v", err)
+	}
+	if _, err := p.Image(want); err != nil {
+		t.Errorf("layout Image: %v", err)
+	}
+
+	if _, err := remote.Get(tag.Context().Tag("missing"), ropt); err == nil {
+		t.Error("expected an error for a missing tag")
+	}
+}
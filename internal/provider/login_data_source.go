@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &LoginDataSource{}
+
+func NewLoginDataSource() datasource.DataSource {
+	return &LoginDataSource{}
+}
+
+// LoginDataSource defines the data source implementation.
+type LoginDataSource struct {
+	popts ProviderOpts
+}
+
+// LoginDataSourceModel describes the data source data model.
+type LoginDataSourceModel struct {
+	Registry types.String `tfsdk:"registry"`
+
+	Id               types.String `tfsdk:"id"`
+	Anonymous        types.Bool   `tfsdk:"anonymous"`
+	Username         types.String `tfsdk:"username"`
+	Password         types.String `tfsdk:"password"`
+	IdentityToken    types.String `tfsdk:"identity_token"`
+	RegistryToken    types.String `tfsdk:"registry_token"`
+	DockerConfigJSON types.String `tfsdk:"docker_config_json"`
+}
+
+func (d *LoginDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_login"
+}
+
+func (d *LoginDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Resolves the credentials that the provider uses for a registry, from the same keychains (`credential_helper`, ECR, ACR, Google and the docker config), so that other providers, e.g. for a Kubernetes image pull secret, can use the same credentials. Credentials are read again on every plan, so short-lived tokens are refreshed, but they are stored in the state.",
+
+		Attributes: map[string]schema.Attribute{
+			"registry": schema.StringAttribute{
+				MarkdownDescription: "Registry to get credentials for (e.g. `cgr.dev` or `registry.local:5000`)",
+				Required:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "The registry's hostname.",
+				Computed:            true,
+			},
+			"anonymous": schema.BoolAttribute{
+				MarkdownDescription: "Whether there are no credentials for the registry, because none of the keychains have any or the provider is `anonymous`.",
+				Computed:            true,
+			},
+			"username": schema.StringAttribute{
+				MarkdownDescription: "Username for basic auth, if the credentials are a username and password.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"password": schema.StringAttribute{
+				MarkdownDescription: "Password for basic auth, if the credentials are a username and password.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"identity_token": schema.StringAttribute{
+				MarkdownDescription: "Refresh token to exchange for registry tokens, if the credentials are one.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"registry_token": schema.StringAttribute{
+				MarkdownDescription: "Bearer token to send to the registry, if the credentials are one.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"docker_config_json": schema.StringAttribute{
+				MarkdownDescription: "A docker CLI `config.json` with the credentials, e.g. for a `kubernetes.io/dockerconfigjson` secret.",
+				Computed:            true,
+				Sensitive:           true,
+			},
+		},
+	}
+}
+
+func (d *LoginDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *LoginDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data LoginDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	reg, err := name.NewRegistry(data.Registry.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid registry", fmt.Sprintf("Unable to parse registry %s, got error: %s", data.Registry.ValueString(), err))
+		return
+	}
+	ac, err := login(ctx, d.popts.keychain, reg)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to resolve credentials", fmt.Sprintf("Unable to resolve credentials for registry %s, got error: %s", reg, err))
+		return
+	}
+	cfg, err := marshalDockerConfig(reg.RegistryStr(), &ac)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to marshal docker config", fmt.Sprintf("Unable to marshal docker config for registry %s, got error: %s", reg, err))
+		return
+	}
+
+	data.Id = types.StringValue(reg.RegistryStr())
+	data.Anonymous = types.BoolValue(ac == authn.AuthConfig{})
+	data.Username = types.StringValue(ac.Username)
+	data.Password = types.StringValue(ac.Password)
+	data.IdentityToken = types.StringValue(ac.IdentityToken)
+	data.RegistryToken = types.StringValue(ac.RegistryToken)
+	data.DockerConfigJSON = types.StringValue(string(cfg))
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// login returns the credentials that kc has for reg, with the username and
+// password decoded from an encoded auth. If kc is nil, there are none.
+func login(ctx context.Context, kc authn.Keychain, reg name.Registry) (authn.AuthConfig, error) {
+	if kc == nil {
+		return authn.AuthConfig{}, nil
+	}
+	a, err := authn.Resolve(ctx, kc, reg)
+	if err != nil {
+		return authn.AuthConfig{}, err
+	}
+	ac, err := authn.Authorization(ctx, a)
+	if err != nil {
+		return authn.AuthConfig{}, err
+	}
+	if ac.Auth != "" && ac.Username == "" && ac.Password == "" {
+		b, err := base64.StdEncoding.DecodeString(ac.Auth)
+		if err != nil {
+			return authn.AuthConfig{}, fmt.Errorf("decoding auth: %w", err)
+		}
+		user, pass, ok := strings.Cut(string(b), ":")
+		if !ok {
+			return authn.AuthConfig{}, fmt.Errorf("auth is not of the form username:password")
+		}
+		ac.Username, ac.Password = user, pass
+	}
+	ac.Auth = ""
+	return *ac, nil
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestLogin(t *testing.T) {
+	for _, c := range []struct {
+		desc string
+		kc   authn.Keychain
+		want authn.AuthConfig
+	}{{
+		desc: "anonymous",
+	}, {
+		desc: "no credentials",
+		kc:   authn.NewMultiKeychain(),
+	}, {
+		desc: "basic",
+		kc:   staticKeychain{Username: "user", Password: "pass"},
+		want: authn.AuthConfig{Username: "user", Password: "pass"},
+	}, {
+		desc: "encoded auth",
+		kc:   staticKeychain{Auth: "dXNlcjpwYTpzcw=="}, // user:pa:ss
+		want: authn.AuthConfig{Username: "user", Password: "pa:ss"},
+	}, {
+		desc: "identity token",
+		kc:   staticKeychain{Username: "<token>", IdentityToken: "refresh"},
+		want: authn.AuthConfig{Username: "<token>", IdentityToken: "refresh"},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := login(context.Background(), c.kc, name.MustParseReference("registry.example.com/repo").Context().Registry)
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+
+	if _, err := login(context.Background(), staticKeychain{Auth: "not base64!"}, name.Registry{}); err == nil {
+		t.Error("login with an invalid auth succeeded")
+	}
+}
+
+func TestAccLoginDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: `provider "oci" {
+  anonymous = true
+}
+
+data "oci_login" "test" {
+  registry = "registry.example.com"
+}`,
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_login.test", "id", "registry.example.com"),
+				resource.TestCheckResourceAttr("data.oci_login.test", "anonymous", "true"),
+				resource.TestCheckResourceAttr("data.oci_login.test", "username", ""),
+				resource.TestCheckResourceAttr("data.oci_login.test", "docker_config_json", `{"auths":{}}`),
+			),
+		}, {
+			Config: `data "oci_login" "test" {
+  registry = "not a registry"
+}`,
+			ExpectError: regexp.MustCompile(`Invalid registry`),
+		}},
+	})
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &ManifestFunction{}
+
+// NewManifestFunction returns the manifest function, which fetches manifests
+// with the options returned by popts.
+func NewManifestFunction(popts func() *ProviderOpts) function.Function {
+	return &ManifestFunction{popts: popts}
+}
+
+// ManifestFunction defines the function implementation.
+type ManifestFunction struct {
+	popts func() *ProviderOpts
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *ManifestFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "manifest"
+}
+
+// Definition should return the definition for the function.
+func (s *ManifestFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Fetches the raw manifest of an OCI reference.",
+		Description: "Returns the image manifest or index exactly as served by the registry, as a JSON string for use with `jsondecode`.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string to fetch.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *ManifestFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	popts := s.popts()
+	ref, err := name.ParseReference(input, popts.nameOptions(input, false)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	desc, err := remote.Get(ref, popts.withContext(ctx)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to get manifest: %v", err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, string(desc.Manifest)))
+}
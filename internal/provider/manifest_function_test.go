@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestManifestFunction(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	ref := repo.Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	mf, err := img.RawManifest()
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`
+output "manifest" { value = provider::oci::manifest(%q) }
+output "schema_version" { value = jsondecode(provider::oci::manifest(%q)).schemaVersion }
+`, ref, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("manifest", knownvalue.StringExact(string(mf))),
+				statecheck.ExpectKnownOutputValue("schema_version", knownvalue.Int64Exact(2)),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "manifest" { value = provider::oci::manifest(%q) }`, repo.Tag("missing")),
+			ExpectError: regexp.MustCompile("Failed to get manifest"),
+		}},
+	})
+}
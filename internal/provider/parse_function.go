@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
@@ -35,12 +36,17 @@ func (s *ParseFunction) Definition(_ context.Context, _ function.DefinitionReque
 				Description: "The OCI reference string to parse.",
 			},
 		},
+		VariadicParameter: function.BoolParameter{
+			Name:        "allow_tag",
+			Description: "If true, accept references with only a tag, returning a null `digest` and `pseudo_tag`.",
+		},
 		Return: function.ObjectReturn{
 			AttributeTypes: map[string]attr.Type{
 				"registry":      basetypes.StringType{},
 				"repo":          basetypes.StringType{},
 				"registry_repo": basetypes.StringType{},
 				"digest":        basetypes.StringType{},
+				"tag":           basetypes.StringType{},
 				"pseudo_tag":    basetypes.StringType{},
 				"ref":           basetypes.StringType{},
 			},
@@ -54,10 +60,15 @@ func (s *ParseFunction) Definition(_ context.Context, _ function.DefinitionReque
 // the [RunResponse].
 func (s *ParseFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
 	var input string
-	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+	var allowTag []bool
+	if ferr := req.Arguments.Get(ctx, &input, &allowTag); ferr != nil {
 		resp.Error = ferr
 		return
 	}
+	if len(allowTag) > 1 {
+		resp.Error = function.NewArgumentFuncError(1, "allow_tag may only be given once")
+		return
+	}
 
 	// Parse the input string into its constituent parts.
 	ref, err := name.ParseReference(input)
@@ -66,26 +77,49 @@ func (s *ParseFunction) Run(ctx context.Context, req function.RunRequest, resp *
 		return
 	}
 
-	if _, ok := ref.(name.Tag); ok {
-		resp.Error = function.NewFuncError(fmt.Sprintf("Reference %s contains only a tag, but a digest is required", input))
-		return
-	}
-
 	result := struct {
-		Registry     string `tfsdk:"registry"`
-		Repo         string `tfsdk:"repo"`
-		RegistryRepo string `tfsdk:"registry_repo"`
-		Digest       string `tfsdk:"digest"`
-		PseudoTag    string `tfsdk:"pseudo_tag"`
-		Ref          string `tfsdk:"ref"`
+		Registry     string  `tfsdk:"registry"`
+		Repo         string  `tfsdk:"repo"`
+		RegistryRepo string  `tfsdk:"registry_repo"`
+		Digest       *string `tfsdk:"digest"`
+		Tag          *string `tfsdk:"tag"`
+		PseudoTag    *string `tfsdk:"pseudo_tag"`
+		Ref          string  `tfsdk:"ref"`
 	}{
 		Registry:     ref.Context().RegistryStr(),
 		Repo:         ref.Context().RepositoryStr(),
 		RegistryRepo: ref.Context().RegistryStr() + "/" + ref.Context().RepositoryStr(),
-		Digest:       ref.Identifier(),
-		PseudoTag:    fmt.Sprintf("unused@%s", ref.Identifier()),
 		Ref:          ref.String(),
 	}
 
+	if t, ok := ref.(name.Tag); ok {
+		if len(allowTag) == 0 || !allowTag[0] {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Reference %s contains only a tag, but a digest is required", input))
+			return
+		}
+		tag := t.TagStr()
+		result.Tag = &tag
+	} else {
+		digest, pseudoTag := ref.Identifier(), fmt.Sprintf("unused@%s", ref.Identifier())
+		result.Digest, result.PseudoTag = &digest, &pseudoTag
+		if tag, ok := refTag(input, ref); ok {
+			result.Tag = &tag
+		}
+	}
+
 	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, &result))
 }
+
+// refTag returns the tag of ref, parsed from input. A digest reference may
+// also have a tag, which go-containerregistry drops, so it's found in input.
+func refTag(input string, ref name.Reference) (string, bool) {
+	if t, ok := ref.(name.Tag); ok {
+		return t.TagStr(), true
+	}
+	if before, _, ok := strings.Cut(input, "@"); ok {
+		if t, err := name.NewTag(before); err == nil && strings.HasSuffix(before, ":"+t.TagStr()) {
+			return t.TagStr(), true
+		}
+	}
+	return "", false
+}
@@ -45,6 +45,7 @@ func TestParseFunction(t *testing.T) {
 					"repo":          knownvalue.StringExact("foo/sample"),
 					"registry_repo": knownvalue.StringExact("cgr.dev/foo/sample"),
 					"digest":        knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234"),
+					"tag":           knownvalue.Null(),
 					"pseudo_tag":    knownvalue.StringExact("unused@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 					"ref":           knownvalue.StringExact("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 				})),
@@ -64,6 +65,7 @@ func TestParseFunction(t *testing.T) {
 					"repo":          knownvalue.StringExact("library/sample"),
 					"registry_repo": knownvalue.StringExact("index.docker.io/library/sample"),
 					"digest":        knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234"),
+					"tag":           knownvalue.Null(),
 					"pseudo_tag":    knownvalue.StringExact("unused@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 					"ref":           knownvalue.StringExact("sample@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 				})),
@@ -83,10 +85,31 @@ func TestParseFunction(t *testing.T) {
 					"repo":          knownvalue.StringExact("library/sample"),
 					"registry_repo": knownvalue.StringExact("index.docker.io/library/sample"),
 					"digest":        knownvalue.StringExact("sha256:1234567890123456789012345678901234567890123456789012345678901234"),
+					"tag":           knownvalue.StringExact("cursed"),
 					"pseudo_tag":    knownvalue.StringExact("unused@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 					"ref":           knownvalue.StringExact("sample:cursed@sha256:1234567890123456789012345678901234567890123456789012345678901234"),
 				})),
 			},
 		}},
 	})
+
+	// A tag-only ref string is accepted with allow_tag, with a null digest
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `output "parsed" { value = provider::oci::parse("cgr.dev/foo/sample:latest", true) }`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("parsed", knownvalue.ObjectExact(map[string]knownvalue.Check{
+					"registry":      knownvalue.StringExact("cgr.dev"),
+					"repo":          knownvalue.StringExact("foo/sample"),
+					"registry_repo": knownvalue.StringExact("cgr.dev/foo/sample"),
+					"digest":        knownvalue.Null(),
+					"tag":           knownvalue.StringExact("latest"),
+					"pseudo_tag":    knownvalue.Null(),
+					"ref":           knownvalue.StringExact("cgr.dev/foo/sample:latest"),
+				})),
+			},
+		}},
+	})
 }
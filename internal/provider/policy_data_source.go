@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &PolicyDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &PolicyDataSource{}
+
+func NewPolicyDataSource() datasource.DataSource {
+	return &PolicyDataSource{}
+}
+
+// PolicyDataSource defines the data source implementation.
+type PolicyDataSource struct {
+	popts ProviderOpts
+}
+
+// PolicyDataSourceModel describes the data source data model.
+type PolicyDataSourceModel struct {
+	Digest   types.String `tfsdk:"digest"`
+	Policy   types.String `tfsdk:"policy"`
+	Insecure types.Bool   `tfsdk:"insecure"`
+
+	RekorPublicKey     types.String `tfsdk:"rekor_public_key"`
+	InsecureIgnoreTlog types.Bool   `tfsdk:"insecure_ignore_tlog"`
+
+	Id                 types.String `tfsdk:"id"`
+	Passed             types.Bool   `tfsdk:"passed"`
+	MatchedPolicies    []string     `tfsdk:"matched_policies"`
+	MatchedAuthorities []string     `tfsdk:"matched_authorities"`
+	Failures           []string     `tfsdk:"failures"`
+}
+
+func (d *PolicyDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_policy"
+}
+
+func (d *PolicyDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Evaluates [sigstore policy-controller](https://docs.sigstore.dev/policy-controller/overview/) `ClusterImagePolicy` and `ImagePolicy` resources against an image, without a cluster. " +
+			"An image satisfies a policy if it satisfies any of the policy's authorities, and passes if it satisfies every policy whose `images` match it, other than those in `warn` mode.\n\n" +
+			"Authorities may use `key.data`, `keyless` with `ca-cert.data` and `identities`, or `static`, and may require `attestations` by predicate type. " +
+			"Keyless signatures must have a transparency log bundle signed by `rekor_public_key`, and their certificates are checked at the time the log recorded. " +
+			"KMS keys, `source`, `trustRootRef` and CUE or Rego `policy` blocks aren't supported.",
+
+		Attributes: map[string]schema.Attribute{
+			"digest": schema.StringAttribute{
+				MarkdownDescription: "Image digest to evaluate the policies against",
+				Required:            true,
+				Validators:          []validator.String{validators.DigestValidator{}},
+			},
+			"policy": schema.StringAttribute{
+				MarkdownDescription: "YAML of one or more `ClusterImagePolicy` or `ImagePolicy` resources, separated by `---`",
+				Required:            true,
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+			"rekor_public_key": schema.StringAttribute{
+				MarkdownDescription: "PEM-encoded public key of the Rekor transparency log that keyless signatures must be logged in. Defaults to the public-good instance at `rekor.sigstore.dev`.",
+				Optional:            true,
+			},
+			"insecure_ignore_tlog": schema.BoolAttribute{
+				MarkdownDescription: "If true, trust the time in keyless signatures' transparency log bundles without verifying that the log signed them. Anyone who can sign with an expired certificate can then forge it.",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified image digest of the image.",
+				Computed:            true,
+			},
+			"passed": schema.BoolAttribute{
+				MarkdownDescription: "Whether the image satisfies every enforced policy that matches it.",
+				Computed:            true,
+			},
+			"matched_policies": schema.ListAttribute{
+				MarkdownDescription: "Names of the policies whose `images` match the image.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+			"matched_authorities": schema.ListAttribute{
+				MarkdownDescription: "Authorities that the image satisfies, as `policy/authority`. Unnamed authorities are named `authority-<index>`.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+			"failures": schema.ListAttribute{
+				MarkdownDescription: "Why the image fails each enforced policy that it fails.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+		},
+	}
+}
+
+func (d *PolicyDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *PolicyDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var policy, key types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("policy"), &policy)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("rekor_public_key"), &key)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !policy.IsNull() && !policy.IsUnknown() {
+		if _, err := parseImagePolicies([]byte(policy.ValueString())); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("policy"), "Invalid policy", err.Error())
+		}
+	}
+	if !key.IsNull() && !key.IsUnknown() {
+		if _, err := newCosignTlog(key.ValueString(), false); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("rekor_public_key"), "Invalid Rekor public key", err.Error())
+		}
+	}
+}
+
+func (d *PolicyDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data PolicyDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ref, err := name.NewDigest(data.Digest.ValueString(), d.popts.nameOptions(data.Digest.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", data.Digest.ValueString(), err))
+		return
+	}
+	policies, err := parseImagePolicies([]byte(data.Policy.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid policy", err.Error())
+		return
+	}
+
+	tlog, err := newCosignTlog(data.RekorPublicKey.ValueString(), data.InsecureIgnoreTlog.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Rekor public key", err.Error())
+		return
+	}
+
+	res, err := d.popts.evaluatePolicies(ctx, ref, policies, tlog)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to evaluate policies", fmt.Sprintf("Unable to evaluate policies for ref %s, got error: %s", ref, err))
+		return
+	}
+	if len(res.warnings) > 0 {
+		resp.Diagnostics.AddWarning("Image fails policies in warn mode", fmt.Sprintf("Image %s fails policies in warn mode:\n%s", ref, strings.Join(res.warnings, "\n")))
+	}
+
+	data.Id = types.StringValue(ref.String())
+	data.Passed = types.BoolValue(res.passed())
+	data.MatchedPolicies = append([]string{}, res.matchedPolicies...)
+	data.MatchedAuthorities = append([]string{}, res.matchedAuthorities...)
+	data.Failures = append([]string{}, res.failures...)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
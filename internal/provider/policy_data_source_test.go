@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccPolicyDataSource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	signer, pub := newKeySigner(t)
+	_, otherPub := newKeySigner(t)
+	ref := pushRandomImage(t, repo)
+	signer.signImage(t, ref)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_policy" "test" {
+  digest = %q
+  policy = %q
+}`, ref, keyPolicy("signed", repo.Name(), pub)),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_policy.test", "id", ref.String()),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "passed", "true"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "matched_policies.#", "1"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "matched_policies.0", "signed"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "matched_authorities.0", "signed/key"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "failures.#", "0"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_policy" "test" {
+  digest = %q
+  policy = %q
+}`, ref, keyPolicy("signed", repo.Name(), otherPub)),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_policy.test", "passed", "false"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "matched_authorities.#", "0"),
+				resource.TestCheckResourceAttr("data.oci_policy.test", "failures.#", "1"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_policy" "test" {
+  digest = %q
+  policy = "kind: Deployment"
+}`, ref),
+			ExpectError: regexp.MustCompile(`Invalid policy`),
+		}},
+	})
+}
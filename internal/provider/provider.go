@@ -2,15 +2,30 @@ package provider
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/keychain"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/cache"
 	"github.com/google/go-containerregistry/pkg/v1/google"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 )
 
 var _ provider.ProviderWithFunctions = &OCIProvider{}
@@ -24,24 +39,163 @@ type OCIProvider struct {
 
 	defaultExecTimeoutSeconds int64
 	skipExecTests             bool
+	maxParallelExecTests      int64
+
+	// popts holds the options from Configure, for provider functions, which
+	// aren't passed provider data. It is nil until the provider is configured.
+	popts atomic.Pointer[ProviderOpts]
+
+	// defaultPopts holds the options for functions called before the
+	// provider is configured. They're made once, so that those calls share
+	// connections and registry tokens.
+	defaultOnce  sync.Once
+	defaultPopts *ProviderOpts
 }
 
 // OCIProviderModel describes the provider data model.
 type OCIProviderModel struct {
-	DefaultExecTimeoutSeconds *int64 `tfsdk:"default_exec_timeout_seconds"`
-	SkipExecTests             *bool  `tfsdk:"skip_exec_tests"`
+	DefaultExecTimeoutSeconds *int64               `tfsdk:"default_exec_timeout_seconds"`
+	SkipExecTests             *bool                `tfsdk:"skip_exec_tests"`
+	MaxParallelExecTests      *int64               `tfsdk:"max_parallel_exec_tests"`
+	ExecTestEnv               map[string]string    `tfsdk:"exec_test_env"`
+	MaxParallelRequests       *int64               `tfsdk:"max_parallel_requests"`
+	Anonymous                 *bool                `tfsdk:"anonymous"`
+	EnableECRAuth             *bool                `tfsdk:"enable_ecr_auth"`
+	EnableACRAuth             *bool                `tfsdk:"enable_acr_auth"`
+	InsecureRegistries        []string             `tfsdk:"insecure_registries"`
+	TLS                       *TLSConfig           `tfsdk:"tls"`
+	RegistryTLS               map[string]TLSConfig `tfsdk:"registry_tls"`
+	LayoutRoot                *string              `tfsdk:"layout_root"`
+	DebugHTTP                 *bool                `tfsdk:"debug_http"`
+	DisableDescriptorCache    *bool                `tfsdk:"disable_descriptor_cache"`
+	UserAgent                 *string              `tfsdk:"user_agent"`
+	HTTPProxy                 *string              `tfsdk:"http_proxy"`
+	HTTPSProxy                *string              `tfsdk:"https_proxy"`
+	NoProxy                   *string              `tfsdk:"no_proxy"`
+	CredentialHelper          *struct {
+		Command    string   `tfsdk:"command"`
+		Args       []string `tfsdk:"args"`
+		Registries []string `tfsdk:"registries"`
+	} `tfsdk:"credential_helper"`
+	BlobCache *struct {
+		Path      string `tfsdk:"path"`
+		MaxSizeMB *int64 `tfsdk:"max_size_mb"`
+	} `tfsdk:"blob_cache"`
+	ExecTestReport *struct {
+		Path   string  `tfsdk:"path"`
+		Format *string `tfsdk:"format"`
+	} `tfsdk:"exec_test_report"`
 }
 
 type ProviderOpts struct {
 	ropts                     []remote.Option
 	defaultExecTimeoutSeconds int64
 	skipExecTests             bool
+
+	// execTestEnv is the environment, as NAME=value, that every exec test
+	// gets, before its own.
+	execTestEnv []string
+
+	// execSem limits the number of concurrently running exec tests.
+	// It is nil if there is no limit.
+	execSem chan struct{}
+
+	// execReport collects exec test results. It is nil if no report was
+	// requested.
+	execReport *execTestReport
+
+	// insecureRegistries are accessed over plain HTTP.
+	insecureRegistries map[string]bool
+
+	// blobCache caches pulled layers and downloaded files on disk. It is
+	// nil if caching is disabled.
+	blobCache *blobCache
+
+	// transport makes requests other than to registries, e.g. downloads,
+	// with the provider's TLS and proxy settings. It is nil until the
+	// provider is configured.
+	transport http.RoundTripper
+
+	// keychain resolves registry credentials, for tools other than ggcr. It
+	// is nil if registries are accessed anonymously.
+	keychain authn.Keychain
+
+	// prepulls dedupes the image pulls of exec tests.
+	prepulls *prepuller
 }
 
 func (p *ProviderOpts) withContext(ctx context.Context) []remote.Option {
 	return append([]remote.Option{remote.WithContext(ctx)}, p.ropts...)
 }
 
+// cacheImage returns img with its layers read through the blob cache, if any.
+func (p *ProviderOpts) cacheImage(img v1.Image) v1.Image {
+	if p.blobCache == nil {
+		return img
+	}
+	return cache.Image(img, p.blobCache)
+}
+
+// cacheIndex returns idx with its images' layers read through the blob cache,
+// if any.
+func (p *ProviderOpts) cacheIndex(idx v1.ImageIndex) v1.ImageIndex {
+	if p.blobCache == nil {
+		return idx
+	}
+	return cache.ImageIndex(idx, p.blobCache)
+}
+
+// nameOptions returns the options for parsing ref, a reference or
+// repository. If insecure is true, or ref's registry is configured as
+// insecure, ref is accessed over plain HTTP.
+func (p *ProviderOpts) nameOptions(ref string, insecure bool) []name.Option {
+	if insecure {
+		return []name.Option{name.Insecure}
+	}
+	if len(p.insecureRegistries) == 0 {
+		return nil
+	}
+	var reg string
+	if r, err := name.ParseReference(ref); err == nil {
+		reg = r.Context().RegistryStr()
+	} else if r, err := name.NewRepository(ref); err == nil {
+		reg = r.RegistryStr()
+	}
+	if p.insecureRegistries[reg] {
+		return []name.Option{name.Insecure}
+	}
+	return nil
+}
+
+// resolveDigest returns ref by digest, resolving its tag if it has one.
+func (p *ProviderOpts) resolveDigest(ctx context.Context, ref string, insecure bool) (name.Digest, diag.Diagnostics) {
+	r, err := name.ParseReference(ref, p.nameOptions(ref, insecure)...)
+	if err != nil {
+		return name.Digest{}, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", ref, err))}
+	}
+	if d, ok := r.(name.Digest); ok {
+		return d, nil
+	}
+	desc, err := remote.Head(r, p.withContext(ctx)...)
+	if err != nil {
+		return name.Digest{}, []diag.Diagnostic{diag.NewErrorDiagnostic("Unable to resolve ref", fmt.Sprintf("Unable to resolve ref %s, got error: %s", ref, err))}
+	}
+	return r.Context().Digest(desc.Digest.String()), nil
+}
+
+// testDigest returns the digest that a test data source tests: ref, which
+// must be by digest unless resolve is set.
+func (p *ProviderOpts) testDigest(ctx context.Context, ref string, resolve, insecure bool) (name.Digest, diag.Diagnostics) {
+	if resolve {
+		return p.resolveDigest(ctx, ref, insecure)
+	}
+	d, err := name.NewDigest(ref, p.nameOptions(ref, insecure)...)
+	if err != nil {
+		return name.Digest{}, []diag.Diagnostic{diag.NewErrorDiagnostic("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", ref, err))}
+	}
+	return d, nil
+}
+
 func (p *OCIProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "oci"
 	resp.Version = p.version
@@ -58,6 +212,158 @@ func (p *OCIProvider) Schema(ctx context.Context, req provider.SchemaRequest, re
 				MarkdownDescription: "If true, skip oci_exec_test tests",
 				Optional:            true,
 			},
+			"max_parallel_exec_tests": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of oci_exec_test scripts to run at the same time (default is unlimited)",
+				Optional:            true,
+				Validators:          []validator.Int64{positiveIntValidator{}},
+			},
+			"exec_test_env": schema.MapAttribute{
+				MarkdownDescription: "Environment variables for every oci_exec_test (e.g. `DOCKER_HOST`, or registry mirrors). A test's `env_file`, `env` and `sensitive_env` override them.",
+				ElementType:         basetypes.StringType{},
+				Optional:            true,
+			},
+			"max_parallel_requests": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of registry requests to make at the same time, across all resources and data sources (default is unlimited). This also limits the concurrency of each individual push or pull.",
+				Optional:            true,
+				Validators:          []validator.Int64{minIntValidator{min: 1}},
+			},
+			"anonymous": schema.BoolAttribute{
+				MarkdownDescription: "If true, access registries anonymously, without looking up credentials from any keychain",
+				Optional:            true,
+			},
+			"enable_ecr_auth": schema.BoolAttribute{
+				MarkdownDescription: "If true, authenticate to Amazon ECR registries using AWS credentials from the environment (`AWS_ACCESS_KEY_ID`/`AWS_SECRET_ACCESS_KEY`, or `AWS_WEB_IDENTITY_TOKEN_FILE`/`AWS_ROLE_ARN`)",
+				Optional:            true,
+			},
+			"enable_acr_auth": schema.BoolAttribute{
+				MarkdownDescription: "If true, authenticate to Azure Container Registry using Azure AD credentials from the environment (`AZURE_TENANT_ID`, `AZURE_CLIENT_ID`, and `AZURE_CLIENT_SECRET` or `AZURE_FEDERATED_TOKEN_FILE`)",
+				Optional:            true,
+			},
+			"insecure_registries": schema.ListAttribute{
+				MarkdownDescription: "Registries to access over plain HTTP (e.g. `registry.local:5000`)",
+				ElementType:         basetypes.StringType{},
+				Optional:            true,
+			},
+			"tls": schema.SingleNestedAttribute{
+				MarkdownDescription: "TLS settings for all registries",
+				Optional:            true,
+				Attributes:          tlsAttributes(),
+			},
+			"registry_tls": schema.MapNestedAttribute{
+				MarkdownDescription: "TLS settings for specific registry hosts (e.g. `registry.corp.example:5000`), replacing `tls` for those hosts",
+				Optional:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: tlsAttributes(),
+				},
+			},
+			"layout_root": schema.StringAttribute{
+				MarkdownDescription: "If set, read and write images in OCI image layouts under this directory instead of in remote registries, for air-gapped use and testing. Each repository is stored as a layout at `<layout_root>/<registry>/<repository>`, with tags recorded as `org.opencontainers.image.ref.name` annotations.",
+				Optional:            true,
+			},
+			"debug_http": schema.BoolAttribute{
+				MarkdownDescription: "If true, log the method, URL, status, timing and request IDs of every registry request at debug level (`TF_LOG=DEBUG`), with credentials redacted",
+				Optional:            true,
+			},
+			"disable_descriptor_cache": schema.BoolAttribute{
+				MarkdownDescription: "If true, don't cache manifests fetched by digest in memory. By default, each manifest is fetched by digest at most once per Terraform operation, since its contents can't change",
+				Optional:            true,
+			},
+			"user_agent": schema.StringAttribute{
+				MarkdownDescription: "Additional product tokens to append to the User-Agent of registry requests (e.g. `ci-job/1234`), after `terraform-provider-oci/<version>`",
+				Optional:            true,
+			},
+			"http_proxy": schema.StringAttribute{
+				MarkdownDescription: "Proxy URL for plain HTTP registry requests, overriding `HTTP_PROXY` from the environment",
+				Optional:            true,
+			},
+			"https_proxy": schema.StringAttribute{
+				MarkdownDescription: "Proxy URL for HTTPS registry requests, overriding `HTTPS_PROXY` from the environment",
+				Optional:            true,
+			},
+			"no_proxy": schema.StringAttribute{
+				MarkdownDescription: "Comma-separated hosts, domains and CIDRs to access without a proxy, overriding `NO_PROXY` from the environment",
+				Optional:            true,
+			},
+			"credential_helper": schema.SingleNestedAttribute{
+				MarkdownDescription: "Get registry credentials by running a command. The registry hostname is passed on stdin and in `OCI_REGISTRY`, and the command must print a JSON object with `username` and `password` (or `auth`, `identitytoken` or `registrytoken`) to stdout, or nothing to fall back to other credentials. Credentials from the helper take precedence over all others. They're cached until the time in an `expires_at` field (RFC 3339) if it prints one, or for 5 minutes, and the command is run again sooner if a registry rejects them.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"command": schema.StringAttribute{
+						MarkdownDescription: "Command to run",
+						Required:            true,
+					},
+					"args": schema.ListAttribute{
+						MarkdownDescription: "Arguments to pass to the command",
+						ElementType:         basetypes.StringType{},
+						Optional:            true,
+					},
+					"registries": schema.ListAttribute{
+						MarkdownDescription: "Only run the command for these registries (default is all registries)",
+						ElementType:         basetypes.StringType{},
+						Optional:            true,
+					},
+				},
+			},
+			"blob_cache": schema.SingleNestedAttribute{
+				MarkdownDescription: "Cache pulled layers and the files that appends download on disk, so that structure tests and appends don't download the same layers and files on every run",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"path": schema.StringAttribute{
+						MarkdownDescription: "Directory to store cached layers in",
+						Required:            true,
+					},
+					"max_size_mb": schema.Int64Attribute{
+						MarkdownDescription: "Maximum size of the cache in megabytes; the least recently used layers are evicted first (default is unlimited)",
+						Optional:            true,
+						Validators:          []validator.Int64{positiveIntValidator{}},
+					},
+				},
+			},
+			"exec_test_report": schema.SingleNestedAttribute{
+				MarkdownDescription: "Write the results of all oci_exec_test tests run by Terraform to a report file",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"path": schema.StringAttribute{
+						MarkdownDescription: "Path of the report file",
+						Required:            true,
+					},
+					"format": schema.StringAttribute{
+						MarkdownDescription: "Format of the report file: `junit` (default) or `tap`",
+						Optional:            true,
+						Validators:          []validator.String{oneOfValidator{values: reportFormats}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func tlsAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"ca_file": schema.StringAttribute{
+			MarkdownDescription: "Path to a PEM bundle of CA certificates to trust, in addition to the system roots",
+			Optional:            true,
+		},
+		"ca_pem": schema.StringAttribute{
+			MarkdownDescription: "PEM bundle of CA certificates to trust, in addition to the system roots",
+			Optional:            true,
+		},
+		"client_cert_file": schema.StringAttribute{
+			MarkdownDescription: "Path to a PEM client certificate",
+			Optional:            true,
+		},
+		"client_key_file": schema.StringAttribute{
+			MarkdownDescription: "Path to a PEM client private key",
+			Optional:            true,
+		},
+		"client_cert_pem": schema.StringAttribute{
+			MarkdownDescription: "PEM client certificate",
+			Optional:            true,
+		},
+		"client_key_pem": schema.StringAttribute{
+			MarkdownDescription: "PEM client private key",
+			Optional:            true,
+			Sensitive:           true,
 		},
 	}
 }
@@ -69,26 +375,92 @@ func (p *OCIProvider) Configure(ctx context.Context, req provider.ConfigureReque
 		return
 	}
 
-	kc := authn.NewMultiKeychain(google.Keychain, authn.DefaultKeychain)
-	ropts := []remote.Option{remote.WithAuthFromKeychain(kc)}
+	var tlsConfig *tls.Config
+	if data.TLS != nil {
+		var err error
+		if tlsConfig, err = data.TLS.tlsConfig(); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("tls"), "Invalid TLS configuration", err.Error())
+			return
+		}
+	}
+	proxy := proxyFunc(data.HTTPProxy, data.HTTPSProxy, data.NoProxy)
+	base := newTransport(tlsConfig, proxy)
 
-	// These errors are impossible in current impl, but we can't return an err, so panic.
-	puller, err := remote.NewPuller(ropts...)
-	if err != nil {
-		resp.Diagnostics.AddError("NewPuller", err.Error())
-		return
+	var ropts []remote.Option
+	var kc authn.Keychain
+	var execKeychain *keychain.Exec
+	if data.LayoutRoot != nil || (data.Anonymous != nil && *data.Anonymous) {
+		// Don't consult any keychains: there are no credentials to look up
+		// for local layouts, and anonymous mode must never pick up ambient
+		// credentials.
+		ropts = append(ropts, remote.WithAuth(authn.Anonymous))
+	} else {
+		var keychains []authn.Keychain
+		if h := data.CredentialHelper; h != nil {
+			execKeychain = keychain.NewExec(h.Command, h.Args, h.Registries)
+			keychains = append(keychains, execKeychain)
+		}
+		keychains = append(keychains, google.Keychain)
+		if data.EnableECRAuth != nil && *data.EnableECRAuth {
+			keychains = append(keychains, keychain.NewECR(base))
+		}
+		if data.EnableACRAuth != nil && *data.EnableACRAuth {
+			keychains = append(keychains, keychain.NewACR(base))
+		}
+		keychains = append(keychains, authn.DefaultKeychain)
+		kc = authn.NewMultiKeychain(keychains...)
+		ropts = append(ropts, remote.WithAuthFromKeychain(kc))
 	}
 
-	pusher, err := remote.NewPusher(ropts...)
+	var t http.RoundTripper = base
+	if len(data.RegistryTLS) > 0 {
+		ht := &hostTransport{def: t, byHost: map[string]http.RoundTripper{}}
+		for host, c := range data.RegistryTLS {
+			cfg, err := c.tlsConfig()
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("registry_tls").AtMapKey(host), "Invalid TLS configuration", err.Error())
+				return
+			}
+			ht.byHost[host] = newTransport(cfg, proxy)
+		}
+		t = ht
+	}
+	if root := data.LayoutRoot; root != nil {
+		t = handlerTransport{h: newLayoutRegistry(*root)}
+	}
+	if data.DebugHTTP != nil && *data.DebugHTTP {
+		t = debugTransport{next: t}
+	}
+	if execKeychain != nil {
+		t = execKeychain.Transport(t)
+	}
+	t = retryAfterTransport{next: t}
+	if n := data.MaxParallelRequests; n != nil {
+		t = newLimitTransport(*n, t)
+		ropts = append(ropts, remote.WithJobs(int(*n)))
+	}
+	if data.DisableDescriptorCache == nil || !*data.DisableDescriptorCache {
+		t = newDescriptorCacheTransport(t)
+	}
+	ropts = append(ropts, remote.WithTransport(t))
+
+	var extraUserAgent string
+	if data.UserAgent != nil {
+		extraUserAgent = *data.UserAgent
+	}
+	ropts = append(ropts, remote.WithUserAgent(userAgent(p.version, extraUserAgent)))
+
+	ropts, err := withReuse(ropts)
 	if err != nil {
-		resp.Diagnostics.AddError("NewPusher", err.Error())
+		resp.Diagnostics.AddError("Client Error", err.Error())
 		return
 	}
 
-	ropts = append(ropts, remote.Reuse(puller), remote.Reuse(pusher))
-
 	opts := &ProviderOpts{
-		ropts: ropts,
+		ropts:     ropts,
+		keychain:  kc,
+		prepulls:  newPrepuller(),
+		transport: base,
 	}
 	if p.defaultExecTimeoutSeconds != 0 {
 		// This is only for testing, so we can inject provider config
@@ -99,8 +471,95 @@ func (p *OCIProvider) Configure(ctx context.Context, req provider.ConfigureReque
 
 	opts.skipExecTests = p.skipExecTests || (data.SkipExecTests != nil && *data.SkipExecTests)
 
+	maxParallel := p.maxParallelExecTests
+	if maxParallel == 0 && data.MaxParallelExecTests != nil {
+		maxParallel = *data.MaxParallelExecTests
+	}
+	if maxParallel > 0 {
+		opts.execSem = make(chan struct{}, maxParallel)
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(data.ExecTestEnv)) {
+		opts.execTestEnv = append(opts.execTestEnv, fmt.Sprintf("%s=%s", k, data.ExecTestEnv[k]))
+	}
+
+	if r := data.ExecTestReport; r != nil {
+		var format string
+		if r.Format != nil {
+			format = *r.Format
+		}
+		opts.execReport = newExecTestReport(r.Path, format)
+	}
+
+	if c := data.BlobCache; c != nil {
+		var maxBytes int64
+		if c.MaxSizeMB != nil {
+			maxBytes = *c.MaxSizeMB << 20
+		}
+		opts.blobCache = newBlobCache(c.Path, maxBytes)
+	}
+
+	for _, r := range data.InsecureRegistries {
+		reg, err := name.NewRegistry(r)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("insecure_registries"), "Invalid registry", fmt.Sprintf("Unable to parse registry %q, got error: %s", r, err))
+			return
+		}
+		if opts.insecureRegistries == nil {
+			opts.insecureRegistries = map[string]bool{}
+		}
+		opts.insecureRegistries[reg.RegistryStr()] = true
+	}
+
 	resp.DataSourceData = opts
 	resp.ResourceData = opts
+	p.popts.Store(opts)
+}
+
+// providerOpts returns the options from Configure, or the default options if
+// the provider hasn't been configured (e.g. when functions are called during
+// validation).
+func (p *OCIProvider) providerOpts() *ProviderOpts {
+	if opts := p.popts.Load(); opts != nil {
+		return opts
+	}
+	p.defaultOnce.Do(func() {
+		// Use the same keychains that Configure uses by default.
+		kc := authn.NewMultiKeychain(google.Keychain, authn.DefaultKeychain)
+		ropts := []remote.Option{
+			remote.WithAuthFromKeychain(kc),
+			remote.WithUserAgent(userAgent(p.version, "")),
+		}
+		if r, err := withReuse(ropts); err == nil {
+			ropts = r
+		}
+		p.defaultPopts = &ProviderOpts{ropts: ropts, keychain: kc, prepulls: newPrepuller()}
+	})
+	return p.defaultPopts
+}
+
+// withReuse returns ropts with a puller and pusher made from them, so that
+// everything using the options shares registry tokens and connections.
+func withReuse(ropts []remote.Option) ([]remote.Option, error) {
+	puller, err := remote.NewPuller(ropts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating puller: %w", err)
+	}
+	pusher, err := remote.NewPusher(ropts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating pusher: %w", err)
+	}
+	return append(ropts, remote.Reuse(puller), remote.Reuse(pusher)), nil
+}
+
+// userAgent returns the User-Agent for registry requests made by the given
+// provider version, followed by extra if it's set.
+func userAgent(version, extra string) string {
+	ua := "terraform-provider-oci/" + version
+	if extra != "" {
+		ua += " " + extra
+	}
+	return ua
 }
 
 func (p *OCIProvider) Resources(ctx context.Context) []func() resource.Resource {
@@ -108,6 +567,11 @@ func (p *OCIProvider) Resources(ctx context.Context) []func() resource.Resource
 		NewAppendResource,
 		NewTagResource,
 		NewTagsResource,
+		NewArtifactResource,
+		NewHelmChartResource,
+		NewDockerLoadResource,
+		NewIndexFilterResource,
+		NewCreatedAtResource,
 	}
 }
 
@@ -115,13 +579,33 @@ func (p *OCIProvider) DataSources(ctx context.Context) []func() datasource.DataS
 	return []func() datasource.DataSource{
 		NewStructureTestDataSource,
 		NewExecTestDataSource,
+		NewScanDataSource,
+		NewPolicyDataSource,
+		NewHelmChartDataSource,
+		NewWaitDataSource,
+		NewReplicationCheckDataSource,
+		NewLoginDataSource,
 	}
 }
 
 func (p *OCIProvider) Functions(ctx context.Context) []func() function.Function {
 	return []func() function.Function{
 		NewParseFunction,
-		NewGetFunction,
+		NewRefFunction,
+		NewWithTagFunction,
+		NewWithDigestFunction,
+		NewIsPinnedFunction,
+		NewEqualFunction,
+		NewRegistryFunction,
+		NewRepoFunction,
+		NewTagFunction,
+		NewDigestOfFunction,
+		func() function.Function { return NewGetFunction(p.providerOpts) },
+		func() function.Function { return NewDigestFunction(p.providerOpts) },
+		func() function.Function { return NewManifestFunction(p.providerOpts) },
+		func() function.Function { return NewConfigJSONFunction(p.providerOpts) },
+		func() function.Function { return NewTagsFunction(p.providerOpts) },
+		func() function.Function { return NewVerifyFunction(p.providerOpts) },
 	}
 }
 
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccProviderTLS(t *testing.T) {
+	reg, ca, cleanup := ocitesting.SetupTLSRegistry(t)
+	defer cleanup()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}
+
+	ref := reg.Repo("test").Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img, remote.WithTransport(tr)); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`
+provider "oci" {
+  tls = {
+    ca_pem = <<EOT
+%sEOT
+  }
+}
+
+output "digest" { value = provider::oci::digest(%q) }
+`, ca, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact(d.String())),
+			},
+		}, {
+			Config: fmt.Sprintf(`
+provider "oci" {
+  registry_tls = {
+    %q = {
+      ca_pem = <<EOT
+%sEOT
+    }
+  }
+}
+
+output "digest" { value = provider::oci::digest(%q) }
+`, reg.RegistryStr(), ca, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact(d.String())),
+			},
+		}, {
+			// The self-signed certificate isn't trusted without the CA.
+			Config:      fmt.Sprintf(`output "digest" { value = provider::oci::digest(%q) }`, ref),
+			ExpectError: regexp.MustCompile("certificate"),
+		}},
+	})
+}
+
+func TestAccProviderBasicAuth(t *testing.T) {
+	reg, cleanup := ocitesting.SetupAuthRegistry(t, "user", "secret")
+	defer cleanup()
+
+	ref := reg.Repo("test").Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img, remote.WithAuth(&authn.Basic{Username: "user", Password: "secret"})); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	helper := func(password string) string {
+		return fmt.Sprintf(`
+provider "oci" {
+  credential_helper = {
+    command = "sh"
+    args    = ["-c", "echo '{\"username\":\"user\",\"password\":\"%s\"}'"]
+  }
+}
+`, password)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: helper("secret") + fmt.Sprintf(`output "digest" { value = provider::oci::digest(%q) }`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact(d.String())),
+			},
+		}, {
+			Config:      helper("wrong") + fmt.Sprintf(`output "digest" { value = provider::oci::digest(%q) }`, ref),
+			ExpectError: regexp.MustCompile("UNAUTHORIZED|401"),
+		}, {
+			Config:      fmt.Sprintf(`output "digest" { value = provider::oci::digest(%q) }`, ref),
+			ExpectError: regexp.MustCompile("UNAUTHORIZED|401"),
+		}},
+	})
+}
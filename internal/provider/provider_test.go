@@ -1,9 +1,23 @@
 package provider
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
 )
 
@@ -20,3 +34,104 @@ func testAccPreCheck(t *testing.T) {
 	// about the appropriate environment variables being set are common to see in a pre-check
 	// function.
 }
+
+func TestNameOptions(t *testing.T) {
+	popts := &ProviderOpts{insecureRegistries: map[string]bool{"registry.internal:5000": true}}
+
+	for _, c := range []struct {
+		ref      string
+		insecure bool
+		want     string
+	}{
+		{"registry.internal:5000/repo:tag", false, "http"},
+		{"registry.internal:5000/repo", false, "http"},
+		{"registry.internal:5000/repo@sha256:1234567890123456789012345678901234567890123456789012345678901234", false, "http"},
+		{"registry.example.com/repo:tag", false, "https"},
+		{"registry.example.com/repo:tag", true, "http"},
+	} {
+		ref, err := name.ParseReference(c.ref, popts.nameOptions(c.ref, c.insecure)...)
+		if err != nil {
+			t.Fatalf("ParseReference(%q): %v", c.ref, err)
+		}
+		if got := ref.Context().Scheme(); got != c.want {
+			t.Errorf("%q (insecure=%t): got scheme %q, want %q", c.ref, c.insecure, got, c.want)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if got, want := userAgent("1.2.3", ""), "terraform-provider-oci/1.2.3"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := userAgent("1.2.3", "ci-job/42"), "terraform-provider-oci/1.2.3 ci-job/42"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDefaultProviderOpts(t *testing.T) {
+	var mu sync.Mutex
+	pings := 0
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			mu.Lock()
+			pings++
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	pings = 0
+	mu.Unlock()
+
+	// Until the provider is configured, functions share the same options,
+	// and so the same puller.
+	p := New("test")().(*OCIProvider) //nolint:forcetypeassert
+	popts := p.providerOpts()
+	for i := 0; i < 3; i++ {
+		if got := p.providerOpts(); got != popts {
+			t.Fatalf("providerOpts() = %p, want %p", got, popts)
+		}
+		if _, err := remote.Get(ref, p.providerOpts().withContext(context.Background())...); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if pings != 1 {
+		t.Errorf("got %d pings, want 1", pings)
+	}
+}
+
+func TestMaxParallelRequestsValidation(t *testing.T) {
+	ctx := context.Background()
+	var resp provider.SchemaResponse
+	New("test")().Schema(ctx, provider.SchemaRequest{}, &resp)
+	attr, ok := resp.Schema.Attributes["max_parallel_requests"].(schema.Int64Attribute)
+	if !ok {
+		t.Fatalf("max_parallel_requests is %T", resp.Schema.Attributes["max_parallel_requests"])
+	}
+	for _, c := range []struct {
+		n       int64
+		wantErr bool
+	}{{n: -1, wantErr: true}, {n: 0, wantErr: true}, {n: 1}, {n: 8}} {
+		var vresp validator.Int64Response
+		for _, v := range attr.Int64Validators() {
+			v.ValidateInt64(ctx, validator.Int64Request{Path: path.Root("max_parallel_requests"), ConfigValue: types.Int64Value(c.n)}, &vresp)
+		}
+		if got := vresp.Diagnostics.HasError(); got != c.wantErr {
+			t.Errorf("max_parallel_requests = %d: got error %t, want %t: %v", c.n, got, c.wantErr, vresp.Diagnostics)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &RefFunction{}
+
+func NewRefFunction() function.Function {
+	return &RefFunction{}
+}
+
+// RefFunction defines the function implementation.
+type RefFunction struct{}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *RefFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "ref"
+}
+
+// Definition should return the definition for the function.
+func (s *RefFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Builds an OCI reference string from its constituent parts.",
+		Description: "Validates the parts and returns the fully-qualified reference, the inverse of `parse`. The tag and digest may be null, but not both.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "registry_repo",
+				Description: "The registry and repository, e.g. `cgr.dev/chainguard/static`.",
+			},
+			function.StringParameter{
+				Name:           "tag",
+				Description:    "The tag, or null.",
+				AllowNullValue: true,
+			},
+			function.StringParameter{
+				Name:           "digest",
+				Description:    "The digest, e.g. `sha256:...`, or null.",
+				AllowNullValue: true,
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *RefFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var registryRepo string
+	var tag, digest types.String
+	if ferr := req.Arguments.Get(ctx, &registryRepo, &tag, &digest); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	repo, err := name.NewRepository(registryRepo)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse repository: %v", err))
+		return
+	}
+	if tag.IsNull() && digest.IsNull() {
+		resp.Error = function.NewFuncError("At least one of tag and digest is required")
+		return
+	}
+
+	ref := repo.Name()
+	if !tag.IsNull() {
+		t, err := name.NewTag(repo.Name() + ":" + tag.ValueString())
+		if err != nil || tag.ValueString() == "" || t.TagStr() != tag.ValueString() {
+			resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Invalid tag %q", tag.ValueString()))
+			return
+		}
+		ref += ":" + t.TagStr()
+	}
+	if !digest.IsNull() {
+		if _, err := v1.NewHash(digest.ValueString()); err != nil {
+			resp.Error = function.NewArgumentFuncError(2, fmt.Sprintf("Invalid digest %q: %v", digest.ValueString(), err))
+			return
+		}
+		if _, err := name.NewDigest(repo.Name() + "@" + digest.ValueString()); err != nil {
+			resp.Error = function.NewArgumentFuncError(2, fmt.Sprintf("Invalid digest %q: %v", digest.ValueString(), err))
+			return
+		}
+		ref += "@" + digest.ValueString()
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, ref))
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestRefFunction(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `
+output "tag" { value = provider::oci::ref("cgr.dev/foo/sample", "latest", null) }
+output "digest" { value = provider::oci::ref("cgr.dev/foo/sample", null, "sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "both" { value = provider::oci::ref("cgr.dev/foo/sample", "latest", "sha256:1234567890123456789012345678901234567890123456789012345678901234") }
+output "short" { value = provider::oci::ref("sample", "latest", null) }
+`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("tag", knownvalue.StringExact("cgr.dev/foo/sample:latest")),
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234")),
+				statecheck.ExpectKnownOutputValue("both", knownvalue.StringExact("cgr.dev/foo/sample:latest@sha256:1234567890123456789012345678901234567890123456789012345678901234")),
+				statecheck.ExpectKnownOutputValue("short", knownvalue.StringExact("index.docker.io/library/sample:latest")),
+			},
+		}},
+	})
+
+	for _, c := range []struct {
+		config string
+		err    string
+	}{
+		{`provider::oci::ref("cgr.dev/foo/sample:latest", "latest", null)`, "Failed to parse repository"},
+		{`provider::oci::ref("cgr.dev/foo/sample", null, null)`, "At least one of tag and digest is required"},
+		{`provider::oci::ref("cgr.dev/foo/sample", "not a tag", null)`, "Invalid tag"},
+		{`provider::oci::ref("cgr.dev/foo/sample", null, "sha256:1234")`, "Invalid digest"},
+	} {
+		resource.Test(t, resource.TestCase{
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+			Steps: []resource.TestStep{{
+				Config:      `output "ref" { value = ` + c.config + ` }`,
+				ExpectError: regexp.MustCompile(c.err),
+			}},
+		})
+	}
+}
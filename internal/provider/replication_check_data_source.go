@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &ReplicationCheckDataSource{}
+
+func NewReplicationCheckDataSource() datasource.DataSource {
+	return &ReplicationCheckDataSource{}
+}
+
+// ReplicationCheckDataSource defines the data source implementation.
+type ReplicationCheckDataSource struct {
+	popts ProviderOpts
+}
+
+// ReplicationCheckDataSourceModel describes the data source data model.
+type ReplicationCheckDataSourceModel struct {
+	Digest   types.String `tfsdk:"digest"`
+	Repos    []string     `tfsdk:"repos"`
+	Insecure types.Bool   `tfsdk:"insecure"`
+
+	Id         types.String `tfsdk:"id"`
+	Present    []string     `tfsdk:"present"`
+	Missing    []string     `tfsdk:"missing"`
+	Replicated types.Bool   `tfsdk:"replicated"`
+}
+
+func (d *ReplicationCheckDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_replication_check"
+}
+
+func (d *ReplicationCheckDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Checks which of a list of repositories, e.g. replicas in several regions, have a digest.",
+
+		Attributes: map[string]schema.Attribute{
+			"digest": schema.StringAttribute{
+				MarkdownDescription: "Digest to look for, e.g. `sha256:deadbeef`. A reference by digest, like an `image_ref`, can be used too, in which case only its digest is looked for.",
+				Required:            true,
+			},
+			"repos": schema.ListAttribute{
+				MarkdownDescription: "Repositories to look for the digest in, e.g. `us.example.com/image`.",
+				Required:            true,
+				ElementType:         basetypes.StringType{},
+				Validators:          []validator.List{validators.RefListValidator{Repo: true}},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registries over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "The digest that was looked for.",
+				Computed:            true,
+			},
+			"present": schema.ListAttribute{
+				MarkdownDescription: "Fully qualified references by digest in the repositories that have the digest, in the order of `repos`.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+			"missing": schema.ListAttribute{
+				MarkdownDescription: "Repositories that don't have the digest, in the order of `repos`.",
+				Computed:            true,
+				ElementType:         basetypes.StringType{},
+			},
+			"replicated": schema.BoolAttribute{
+				MarkdownDescription: "Whether every repository has the digest.",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *ReplicationCheckDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *ReplicationCheckDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data ReplicationCheckDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dig := data.Digest.ValueString()
+	if _, after, ok := strings.Cut(dig, "@"); ok {
+		dig = after
+	}
+	h, err := v1.NewHash(dig)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid digest", fmt.Sprintf("Unable to parse digest %s, got error: %s", data.Digest.ValueString(), err))
+		return
+	}
+	repos := make([]name.Repository, len(data.Repos))
+	for i, r := range data.Repos {
+		if repos[i], err = name.NewRepository(r, d.popts.nameOptions(r, data.Insecure.ValueBool())...); err != nil {
+			resp.Diagnostics.AddError("Invalid repo", fmt.Sprintf("Unable to parse repo %s, got error: %s", r, err))
+			return
+		}
+	}
+
+	found, err := d.popts.checkReplication(ctx, h, repos)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to check replication", fmt.Sprintf("Unable to check replication of %s, got error: %s", h, err))
+		return
+	}
+
+	data.Id = types.StringValue(h.String())
+	data.Present = []string{}
+	data.Missing = []string{}
+	for i, repo := range repos {
+		if found[i] {
+			data.Present = append(data.Present, repo.Digest(h.String()).String())
+		} else {
+			data.Missing = append(data.Missing, data.Repos[i])
+		}
+	}
+	data.Replicated = types.BoolValue(len(data.Missing) == 0)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// checkReplication reports, for each of repos, whether it has the digest h.
+// The repos are checked in parallel. A repo that can't be checked, other than
+// because it doesn't have the digest, is an error, since it can't be said
+// whether it's been replicated to.
+func (p *ProviderOpts) checkReplication(ctx context.Context, h v1.Hash, repos []name.Repository) ([]bool, error) {
+	found := make([]bool, len(repos))
+	errs := make([]error, len(repos))
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := retryTransient(ctx, func(ctx context.Context) error {
+				_, err := remote.Head(repo.Digest(h.String()), p.withContext(ctx)...)
+				return err
+			})
+			switch {
+			case err == nil:
+				found[i] = true
+			case isNotFound(err):
+			default:
+				errs[i] = fmt.Errorf("%s: %w", repo, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
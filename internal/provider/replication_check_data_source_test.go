@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestCheckReplication(t *testing.T) {
+	us, cleanup := ocitesting.SetupRepository(t, "us/image")
+	defer cleanup()
+	eu, cleanup := ocitesting.SetupRepository(t, "eu/image")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(us.Digest(d.String()), img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	// Another repo in the same registry doesn't count.
+	other := us.Registry.Repo("other")
+
+	p := &ProviderOpts{}
+	got, err := p.checkReplication(context.Background(), d, []name.Repository{us, eu, other})
+	if err != nil {
+		t.Fatalf("checkReplication: %v", err)
+	}
+	if want := []bool{true, false, false}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A registry that can't be reached is an error, not missing.
+	setTransientRetry(t, 2, time.Millisecond)
+	down, err := name.NewRepository("localhost:1/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.checkReplication(context.Background(), d, []name.Repository{us, down}); err == nil {
+		t.Error("checkReplication of unreachable registry: want error")
+	}
+}
+
+func TestAccReplicationCheckDataSource(t *testing.T) {
+	us, cleanup := ocitesting.SetupRepository(t, "us/image")
+	defer cleanup()
+	eu, cleanup := ocitesting.SetupRepository(t, "eu/image")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig := us.Digest(d.String())
+	if err := remote.Write(dig, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_replication_check" "test" {
+			  digest = %q
+			  repos  = [%q, %q]
+			}`, dig, us, eu),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "id", d.String()),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "replicated", "false"),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "present.#", "1"),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "present.0", dig.String()),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "missing.#", "1"),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "missing.0", eu.String()),
+			),
+		}, {
+			PreConfig: func() {
+				if err := remote.Write(eu.Digest(d.String()), img); err != nil {
+					t.Fatalf("failed to write image: %v", err)
+				}
+			},
+			Config: fmt.Sprintf(`data "oci_replication_check" "test" {
+			  digest = %q
+			  repos  = [%q, %q]
+			}`, d, us, eu),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "replicated", "true"),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "present.#", "2"),
+				resource.TestCheckResourceAttr("data.oci_replication_check.test", "missing.#", "0"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_replication_check" "test" {
+			  digest = "latest"
+			  repos  = [%q]
+			}`, us),
+			ExpectError: regexp.MustCompile(`Unable to parse digest`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_replication_check" "test" {
+			  digest = %q
+			  repos  = [%q]
+			}`, d, us.String()+":latest"),
+			ExpectError: regexp.MustCompile(`Invalid OCI repository`),
+		}},
+	})
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// transientRetry bounds how registry requests are retried by retryTransient.
+var transientRetry = struct {
+	// attempts is the most times a request is made.
+	attempts int
+	// backoff is the wait before the first retry, which doubles up to
+	// maxBackoff with each retry.
+	backoff, maxBackoff time.Duration
+	// maxRetryAfter caps how long a Retry-After header can make us wait.
+	maxRetryAfter time.Duration
+}{
+	attempts:      5,
+	backoff:       time.Second,
+	maxBackoff:    30 * time.Second,
+	maxRetryAfter: time.Minute,
+}
+
+// retryTransient calls f until it succeeds, it fails with an error that isn't
+// transient, or it has been called transientRetry.attempts times. Between
+// attempts it backs off exponentially, or waits as long as the registry asked
+// in a Retry-After header.
+//
+// ggcr already retries some failed requests, but not 429s, or uploads whose
+// bodies have been consumed, so a single blip can fail a whole push. Pushes
+// and tags are idempotent, so f is retried as a whole. It must make its
+// requests with the context it's passed, for Retry-After to be honored.
+func retryTransient(ctx context.Context, f func(ctx context.Context) error) error {
+	ra := &retryAfter{}
+	ctx = context.WithValue(ctx, retryAfterKey{}, ra)
+	backoff := transientRetry.backoff
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+		if err == nil || attempt >= transientRetry.attempts || !isTransient(err) {
+			return err
+		}
+		wait := backoff
+		if d, ok := ra.take(); ok {
+			wait = min(d, transientRetry.maxRetryAfter)
+		}
+		tflog.Warn(ctx, "retrying registry request", map[string]interface{}{
+			"attempt": attempt,
+			"wait_ms": wait.Milliseconds(),
+			"error":   err.Error(),
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, transientRetry.maxBackoff)
+	}
+}
+
+// isTransient reports whether err is worth retrying: a 429 or 5xx from the
+// registry, or a connection that was reset or dropped.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode == http.StatusTooManyRequests || terr.StatusCode >= 500
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// isNotFound reports whether err is from a registry saying that what was
+// requested doesn't exist.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.ManifestUnknownErrorCode || d.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfterKey is the context key of the retryAfter that retryAfterTransport
+// records into.
+type retryAfterKey struct{}
+
+// retryAfter is the last Retry-After that a registry sent to retryTransient.
+type retryAfter struct {
+	mu sync.Mutex
+	d  time.Duration
+	ok bool
+}
+
+func (r *retryAfter) set(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.d, r.ok = d, true
+}
+
+// take returns the recorded Retry-After, if there is one, and clears it.
+func (r *retryAfter) take() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.d, r.ok
+	r.d, r.ok = 0, false
+	return d, ok
+}
+
+// retryAfterTransport records the Retry-After header of throttled responses to
+// requests made by retryTransient.
+type retryAfterTransport struct {
+	next http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := req.Context().Value(retryAfterKey{}).(*retryAfter); ok {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			ra.set(d)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date, into how long to wait from now.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
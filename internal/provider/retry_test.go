@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		v      string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 01 Jan 2024 00:00:30 GMT", 30 * time.Second, true},
+		{"Sun, 31 Dec 2023 23:59:00 GMT", 0, true},
+	} {
+		got, ok := parseRetryAfter(c.v, now)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t; want %s, %t", c.v, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+// setTransientRetry overrides transientRetry for the duration of the test.
+func setTransientRetry(t *testing.T, attempts int, backoff time.Duration) {
+	t.Helper()
+	old := transientRetry
+	transientRetry.attempts = attempts
+	transientRetry.backoff = backoff
+	transientRetry.maxBackoff = backoff
+	t.Cleanup(func() { transientRetry = old })
+}
+
+func TestRetryWriteTag(t *testing.T) {
+	// The backoff is too long to wait for, so the test only passes if
+	// Retry-After is honored.
+	setTransientRetry(t, 5, time.Hour)
+
+	var (
+		failing           atomic.Bool
+		status, throttled atomic.Int32
+	)
+	status.Store(http.StatusTooManyRequests)
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") && failing.Load() && throttled.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			// A bare status, as CDNs send, which ggcr doesn't retry itself.
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	if err := remote.Write(repo.Digest(dig.String()), img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	failing.Store(true)
+	p := &ProviderOpts{ropts: []remote.Option{remote.WithTransport(retryAfterTransport{next: http.DefaultTransport})}}
+	if _, err := p.tag(context.Background(), repo.Digest(dig.String()).String(), "latest", false, false); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	if got := throttled.Load(); got != 3 {
+		t.Errorf("got %d tag attempts, want 3", got)
+	}
+
+	// Errors that aren't transient aren't retried.
+	throttled.Store(0)
+	status.Store(http.StatusForbidden)
+	if _, err := p.tag(context.Background(), repo.Digest(dig.String()).String(), "latest", false, false); err == nil {
+		t.Error("tag succeeded, want error")
+	}
+	if got := throttled.Load(); got != 1 {
+		t.Errorf("got %d tag attempts, want 1", got)
+	}
+}
+
+func TestRetryWriteAppend(t *testing.T) {
+	setTransientRetry(t, 5, time.Millisecond)
+
+	// ggcr can't retry uploads itself, since their bodies are consumed.
+	reg, stats, cleanup := ocitesting.SetupFaultyRegistry(t, ocitesting.Faults{
+		TooManyRequests: 0.5,
+		Methods:         []string{http.MethodPatch},
+		Seed:            1,
+	})
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	base := reg.Repo("test").Tag("base")
+	if err := retryTransient(context.Background(), func(ctx context.Context) error {
+		return remote.Write(base, img, remote.WithContext(ctx))
+	}); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	p := &ProviderOpts{}
+	if _, _, diags := p.appendLayers(context.Background(), base.String(), false, []appendLayer{{Files: map[string]appendFile{
+		"/a.txt": {Contents: types.StringValue("a")},
+	}}}, appendOptions{compressionLevel: gzip.DefaultCompression}); diags.HasError() {
+		t.Fatalf("appendLayers: %v", diags)
+	}
+	if stats.Injected() == 0 {
+		t.Error("no faults were injected")
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&transport.Error{StatusCode: http.StatusNotFound}, true},
+		{&transport.Error{StatusCode: http.StatusUnauthorized, Errors: []transport.Diagnostic{{Code: transport.NameUnknownErrorCode}}}, true},
+		{fmt.Errorf("wrapped: %w", &transport.Error{StatusCode: http.StatusNotFound}), true},
+		{&transport.Error{StatusCode: http.StatusInternalServerError}, false},
+		{&transport.Error{StatusCode: http.StatusTooManyRequests}, false},
+		{io.ErrUnexpectedEOF, false},
+	} {
+		if got := isNotFound(c.err); got != c.want {
+			t.Errorf("isNotFound(%v) = %t, want %t", c.err, got, c.want)
+		}
+	}
+}
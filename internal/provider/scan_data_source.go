@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+const (
+	scannerGrype = "grype"
+	scannerTrivy = "trivy"
+)
+
+var scanners = []string{scannerGrype, scannerTrivy}
+
+// scanSeverities are the severities that findings are counted by, with the
+// scanners' own severities lowercased. Trivy never reports negligible.
+var scanSeverities = []string{"critical", "high", "medium", "low", "negligible", "unknown"}
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &ScanDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &ScanDataSource{}
+
+func NewScanDataSource() datasource.DataSource {
+	return &ScanDataSource{}
+}
+
+// ScanDataSource defines the data source implementation.
+type ScanDataSource struct {
+	popts ProviderOpts
+}
+
+// ScanDataSourceModel describes the data source data model.
+type ScanDataSourceModel struct {
+	Digest         types.String     `tfsdk:"digest"`
+	Scanner        types.String     `tfsdk:"scanner"`
+	ScannerPath    types.String     `tfsdk:"scanner_path"`
+	Thresholds     map[string]int64 `tfsdk:"thresholds"`
+	TimeoutSeconds types.Int64      `tfsdk:"timeout_seconds"`
+	Insecure       types.Bool       `tfsdk:"insecure"`
+
+	Id         types.String     `tfsdk:"id"`
+	ScannedRef types.String     `tfsdk:"scanned_ref"`
+	Counts     map[string]int64 `tfsdk:"counts"`
+}
+
+func (d *ScanDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_scan"
+}
+
+func (d *ScanDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Scans an image for vulnerabilities with [grype](https://github.com/anchore/grype) or [trivy](https://github.com/aquasecurity/trivy), and fails if there are more findings of any severity than its threshold allows. The scanner pulls the image itself, with its own registry credentials.",
+
+		Attributes: map[string]schema.Attribute{
+			"digest": schema.StringAttribute{
+				MarkdownDescription: "Image digest to scan",
+				Required:            true,
+				Validators:          []validator.String{validators.DigestValidator{}},
+			},
+			"scanner": schema.StringAttribute{
+				MarkdownDescription: "Scanner to run: `grype` (default) or `trivy`.",
+				Optional:            true,
+				Validators:          []validator.String{oneOfValidator{values: scanners}},
+			},
+			"scanner_path": schema.StringAttribute{
+				MarkdownDescription: "Path to the scanner binary (defaults to `scanner`, found on the `PATH`)",
+				Optional:            true,
+			},
+			"thresholds": schema.MapAttribute{
+				MarkdownDescription: fmt.Sprintf("Map of severity to the most findings of that severity that are allowed, e.g. `{ critical = 0, high = 5 }`. Severities are %s. Severities that aren't listed are unlimited.", strings.Join(scanSeverities, ", ")),
+				Optional:            true,
+				ElementType:         basetypes.Int64Type{},
+			},
+			"timeout_seconds": schema.Int64Attribute{
+				MarkdownDescription: "Timeout for the scan in seconds (default is 10 minutes)",
+				Optional:            true,
+				Validators:          []validator.Int64{positiveIntValidator{}},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified image digest of the image.",
+				Computed:            true,
+			},
+			"scanned_ref": schema.StringAttribute{
+				MarkdownDescription: "Scanned image ref by digest.",
+				Computed:            true,
+			},
+			"counts": schema.MapAttribute{
+				MarkdownDescription: "Map of severity to the number of findings of that severity.",
+				Computed:            true,
+				ElementType:         basetypes.Int64Type{},
+			},
+		},
+	}
+}
+
+func (d *ScanDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *ScanDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var thresholds types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("thresholds"), &thresholds)...)
+	if resp.Diagnostics.HasError() || thresholds.IsNull() || thresholds.IsUnknown() {
+		return
+	}
+	for sev, v := range thresholds.Elements() {
+		if !slices.Contains(scanSeverities, sev) {
+			resp.Diagnostics.AddAttributeError(path.Root("thresholds").AtMapKey(sev), "Invalid severity", fmt.Sprintf("Severity %q must be one of %v", sev, scanSeverities))
+			continue
+		}
+		if n, ok := v.(types.Int64); ok && !n.IsUnknown() && n.ValueInt64() < 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("thresholds").AtMapKey(sev), "Invalid threshold", fmt.Sprintf("Threshold for %q must not be negative", sev))
+		}
+	}
+}
+
+func (d *ScanDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data ScanDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ref, err := name.NewDigest(data.Digest.ValueString(), d.popts.nameOptions(data.Digest.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", data.Digest.ValueString(), err))
+		return
+	}
+
+	timeout := data.TimeoutSeconds.ValueInt64()
+	if timeout == 0 {
+		timeout = 600
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	insecure := data.Insecure.ValueBool() || ref.Context().Registry.Scheme() == "http"
+	counts, err := runScan(ctx, data.Scanner.ValueString(), data.ScannerPath.ValueString(), ref.String(), insecure)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		resp.Diagnostics.AddError("Scan timed out", fmt.Sprintf("Scan of ref %s timed out after %d seconds", ref, timeout))
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("Unable to scan image", fmt.Sprintf("Unable to scan image for ref %s, got error: %s", ref, err))
+		return
+	}
+
+	var over []string
+	for _, sev := range scanSeverities {
+		if limit, ok := data.Thresholds[sev]; ok && counts[sev] > limit {
+			over = append(over, fmt.Sprintf("%s: %d findings, at most %d allowed", sev, counts[sev], limit))
+		}
+	}
+	if len(over) > 0 {
+		resp.Diagnostics.AddError("Image exceeds vulnerability thresholds", fmt.Sprintf("Image %s exceeds vulnerability thresholds:\n%s", ref, strings.Join(over, "\n")))
+		return
+	}
+
+	data.Id = types.StringValue(ref.String())
+	data.ScannedRef = types.StringValue(ref.String())
+	data.Counts = counts
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// runScan scans ref with scanner, run from path if it's set, and returns the
+// number of findings of each of scanSeverities.
+func runScan(ctx context.Context, scanner, path, ref string, insecure bool) (map[string]int64, error) {
+	if scanner == "" {
+		scanner = scannerGrype
+	}
+	if path == "" {
+		path = scanner
+	}
+	env := os.Environ()
+	var args []string
+	switch scanner {
+	case scannerGrype:
+		args = []string{"registry:" + ref, "--output", "json", "--quiet"}
+		if insecure {
+			env = append(env, "GRYPE_REGISTRY_INSECURE_USE_HTTP=true")
+		}
+	case scannerTrivy:
+		args = []string{"image", "--format", "json", "--quiet", "--image-src", "remote"}
+		if insecure {
+			args = append(args, "--insecure")
+		}
+		args = append(args, ref)
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", scanner)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running %s: %w\n%s", scanner, err, stderr.String())
+	}
+	return parseScanReport(scanner, stdout.Bytes())
+}
+
+// parseScanReport counts the findings of each of scanSeverities in the JSON
+// report of scanner.
+func parseScanReport(scanner string, b []byte) (map[string]int64, error) {
+	var sevs []string
+	switch scanner {
+	case scannerGrype:
+		var report struct {
+			Matches []struct {
+				Vulnerability struct {
+					Severity string `json:"severity"`
+				} `json:"vulnerability"`
+			} `json:"matches"`
+		}
+		if err := json.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("parsing grype report: %w", err)
+		}
+		for _, m := range report.Matches {
+			sevs = append(sevs, m.Vulnerability.Severity)
+		}
+	case scannerTrivy:
+		var report struct {
+			Results []struct {
+				Vulnerabilities []struct {
+					Severity string `json:"Severity"`
+				} `json:"Vulnerabilities"`
+			} `json:"Results"`
+		}
+		if err := json.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("parsing trivy report: %w", err)
+		}
+		for _, r := range report.Results {
+			for _, v := range r.Vulnerabilities {
+				sevs = append(sevs, v.Severity)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", scanner)
+	}
+
+	counts := make(map[string]int64, len(scanSeverities))
+	for _, sev := range scanSeverities {
+		counts[sev] = 0
+	}
+	for _, sev := range sevs {
+		sev = strings.ToLower(sev)
+		if !slices.Contains(scanSeverities, sev) {
+			sev = "unknown"
+		}
+		counts[sev]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+const (
+	grypeReport = `{"matches": [
+  {"vulnerability": {"id": "CVE-1", "severity": "Critical"}},
+  {"vulnerability": {"id": "CVE-2", "severity": "High"}},
+  {"vulnerability": {"id": "CVE-3", "severity": "High"}},
+  {"vulnerability": {"id": "CVE-4", "severity": "Negligible"}},
+  {"vulnerability": {"id": "CVE-5", "severity": ""}}
+]}`
+	trivyReport = `{"Results": [
+  {"Target": "os", "Vulnerabilities": [
+    {"VulnerabilityID": "CVE-1", "Severity": "CRITICAL"},
+    {"VulnerabilityID": "CVE-2", "Severity": "MEDIUM"}
+  ]},
+  {"Target": "app"},
+  {"Target": "lib", "Vulnerabilities": [
+    {"VulnerabilityID": "CVE-3", "Severity": "LOW"}
+  ]}
+]}`
+)
+
+func TestParseScanReport(t *testing.T) {
+	for _, c := range []struct {
+		scanner, report string
+		want            map[string]int64
+		wantErr         bool
+	}{{
+		scanner: "grype",
+		report:  grypeReport,
+		want:    map[string]int64{"critical": 1, "high": 2, "medium": 0, "low": 0, "negligible": 1, "unknown": 1},
+	}, {
+		scanner: "trivy",
+		report:  trivyReport,
+		want:    map[string]int64{"critical": 1, "high": 0, "medium": 1, "low": 1, "negligible": 0, "unknown": 0},
+	}, {
+		scanner: "grype",
+		report:  `{}`,
+		want:    map[string]int64{"critical": 0, "high": 0, "medium": 0, "low": 0, "negligible": 0, "unknown": 0},
+	}, {
+		scanner: "grype",
+		report:  `not json`,
+		wantErr: true,
+	}, {
+		scanner: "clair",
+		report:  `{}`,
+		wantErr: true,
+	}} {
+		got, err := parseScanReport(c.scanner, []byte(c.report))
+		if (err != nil) != c.wantErr {
+			t.Errorf("parseScanReport(%s): got error %v, want error %t", c.scanner, err, c.wantErr)
+			continue
+		}
+		if !maps.Equal(got, c.want) {
+			t.Errorf("parseScanReport(%s) = %v, want %v", c.scanner, got, c.want)
+		}
+	}
+}
+
+// fakeScanner writes a script that checks it's run with want as its arguments,
+// then prints report, and returns its path.
+func fakeScanner(t *testing.T, want, report string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scanner")
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$*" != %q ]; then
+  echo "unexpected args: $*" >&2
+  exit 1
+fi
+cat <<'EOF'
+%s
+EOF
+`, want, report)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunScan(t *testing.T) {
+	ref := "example.com/repo@sha256:" + fmt.Sprintf("%064d", 0)
+
+	got, err := runScan(context.Background(), "", fakeScanner(t, "registry:"+ref+" --output json --quiet", grypeReport), ref, false)
+	if err != nil {
+		t.Fatalf("runScan(grype): %v", err)
+	}
+	if got["high"] != 2 {
+		t.Errorf("runScan(grype) = %v, want 2 high", got)
+	}
+
+	got, err = runScan(context.Background(), "trivy", fakeScanner(t, "image --format json --quiet --image-src remote --insecure "+ref, trivyReport), ref, true)
+	if err != nil {
+		t.Fatalf("runScan(trivy): %v", err)
+	}
+	if got["low"] != 1 {
+		t.Errorf("runScan(trivy) = %v, want 1 low", got)
+	}
+
+	// The scanner's stderr is included in errors.
+	if _, err := runScan(context.Background(), "trivy", fakeScanner(t, "something else", trivyReport), ref, false); err == nil || !regexp.MustCompile("unexpected args").MatchString(err.Error()) {
+		t.Errorf("runScan with bad args: got error %v, want unexpected args", err)
+	}
+}
+
+func TestAccScanDataSource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	// The local registry is served over plain HTTP.
+	scanner := fakeScanner(t, "image --format json --quiet --image-src remote --insecure "+ref.String(), trivyReport)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_scan" "test" {
+  digest       = %q
+  scanner      = "trivy"
+  scanner_path = %q
+  thresholds   = { critical = 1, high = 0 }
+}`, ref, scanner),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_scan.test", "scanned_ref", ref.String()),
+				resource.TestCheckResourceAttr("data.oci_scan.test", "counts.critical", "1"),
+				resource.TestCheckResourceAttr("data.oci_scan.test", "counts.medium", "1"),
+				resource.TestCheckResourceAttr("data.oci_scan.test", "counts.negligible", "0"),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_scan" "test" {
+  digest       = %q
+  scanner      = "trivy"
+  scanner_path = %q
+  thresholds   = { critical = 0, medium = 0 }
+}`, ref, scanner),
+			ExpectError: regexp.MustCompile(`critical: 1 findings, at most 0 allowed\nmedium: 1 findings, at most 0 allowed`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_scan" "test" {
+  digest     = %q
+  thresholds = { severe = 0 }
+}`, ref),
+			ExpectError: regexp.MustCompile(`Invalid severity`),
+		}},
+	})
+}
@@ -2,14 +2,17 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
 	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
-	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
@@ -33,19 +36,65 @@ type StructureTestDataSource struct {
 // StructureTestDataSourceModel describes the data source data model.
 type StructureTestDataSourceModel struct {
 	Digest     types.String `tfsdk:"digest"`
+	Resolve    types.Bool   `tfsdk:"resolve"`
+	Insecure   types.Bool   `tfsdk:"insecure"`
 	Conditions []struct {
 		Env []struct {
 			Key   types.String `tfsdk:"key"`
 			Value types.String `tfsdk:"value"`
 		} `tfsdk:"env"`
 		Files []struct {
-			Path  types.String `tfsdk:"path"`
-			Regex types.String `tfsdk:"regex"`
+			Path        types.String `tfsdk:"path"`
+			Regex       types.String `tfsdk:"regex"`
+			MaxSize     types.Int64  `tfsdk:"max_size"`
+			AllowBinary types.Bool   `tfsdk:"allow_binary"`
+			Mode        types.String `tfsdk:"mode"`
+			Optional    types.Bool   `tfsdk:"optional"`
 		} `tfsdk:"files"`
+		Dirs []struct {
+			Path       types.String `tfsdk:"path"`
+			Mode       types.String `tfsdk:"mode"`
+			Recursive  types.Bool   `tfsdk:"recursive"`
+			ExistsOnly types.Bool   `tfsdk:"exists_only"`
+		} `tfsdk:"dirs"`
+		Permissions []struct {
+			Path     types.String `tfsdk:"path"`
+			Override []string     `tfsdk:"override"`
+		} `tfsdk:"permissions"`
+		Healthcheck []struct {
+			Present            types.Bool   `tfsdk:"present"`
+			TestRegex          types.String `tfsdk:"test_regex"`
+			MinIntervalSeconds types.Int64  `tfsdk:"min_interval_seconds"`
+			MaxIntervalSeconds types.Int64  `tfsdk:"max_interval_seconds"`
+		} `tfsdk:"healthcheck"`
+		Ports []struct {
+			Port    types.String `tfsdk:"port"`
+			Present types.Bool   `tfsdk:"present"`
+		} `tfsdk:"ports"`
+		Volumes []struct {
+			Path    types.String `tfsdk:"path"`
+			Present types.Bool   `tfsdk:"present"`
+		} `tfsdk:"volumes"`
+		NoShell              types.Bool   `tfsdk:"no_shell"`
+		ForbiddenExecutables []string     `tfsdk:"forbidden_executables"`
+		GoldenFile           types.String `tfsdk:"golden_file"`
+		Rego                 []struct {
+			Policy  types.String `tfsdk:"policy"`
+			Query   types.String `tfsdk:"query"`
+			OPAPath types.String `tfsdk:"opa_path"`
+		} `tfsdk:"rego"`
+		Custom []struct {
+			Name    types.String `tfsdk:"name"`
+			Options types.String `tfsdk:"options"`
+		} `tfsdk:"custom"`
 	} `tfsdk:"conditions"`
 
-	Id        types.String `tfsdk:"id"`
-	TestedRef types.String `tfsdk:"tested_ref"`
+	Id           types.String `tfsdk:"id"`
+	TestedRef    types.String `tfsdk:"tested_ref"`
+	Platform     types.String `tfsdk:"platform"`
+	ConfigDigest types.String `tfsdk:"config_digest"`
+	TotalSize    types.Int64  `tfsdk:"total_size"`
+	LayerCount   types.Int64  `tfsdk:"layer_count"`
 }
 
 func (d *StructureTestDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -54,40 +103,23 @@ func (d *StructureTestDataSource) Metadata(ctx context.Context, req datasource.M
 
 func (d *StructureTestDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Structure test data source",
+		MarkdownDescription: "Structure test data source. Each `conditions` block lists conditions that the image must meet; misconfigured conditions fail at plan time. `conditions` is a block, so the `conditions = [{ ... }]` attribute syntax of earlier versions is no longer accepted; write `conditions { ... }` instead.",
 
 		Attributes: map[string]schema.Attribute{
 			"digest": schema.StringAttribute{
-				MarkdownDescription: "Image digest to test",
+				MarkdownDescription: "Image digest to test, or any image ref if `resolve` is set",
 				Optional:            false,
 				Required:            true,
-				Validators:          []validator.String{validators.DigestValidator{}},
+				Validators:          []validator.String{validators.DigestValidator{ResolveAttribute: "resolve"}},
 			},
-			"conditions": schema.ListAttribute{
-				MarkdownDescription: "List of conditions to test",
-				Required:            true,
-				ElementType: basetypes.ObjectType{
-					AttrTypes: map[string]attr.Type{
-						"env": basetypes.ListType{
-							ElemType: basetypes.ObjectType{
-								AttrTypes: map[string]attr.Type{
-									"key":   basetypes.StringType{},
-									"value": basetypes.StringType{},
-								},
-							},
-						},
-						"files": basetypes.ListType{
-							ElemType: basetypes.ObjectType{
-								AttrTypes: map[string]attr.Type{
-									"path":  basetypes.StringType{},
-									"regex": basetypes.StringType{},
-								},
-							},
-						},
-					},
-				},
+			"resolve": schema.BoolAttribute{
+				MarkdownDescription: "If true, `digest` may be a tag, which is resolved to a digest when the data source is read. The digest that was tested is `tested_ref`.",
+				Optional:            true,
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
 			},
-
 			// TODO: platform?
 
 			"id": schema.StringAttribute{
@@ -95,10 +127,228 @@ func (d *StructureTestDataSource) Schema(ctx context.Context, req datasource.Sch
 				Computed:            true,
 			},
 			"tested_ref": schema.StringAttribute{
-				MarkdownDescription: "Tested image ref by digest.",
+				MarkdownDescription: "Tested image ref by digest, which `digest` resolved to if `resolve` is set.",
+				Computed:            true,
+			},
+			"platform": schema.StringAttribute{
+				MarkdownDescription: "Platform of the tested image (e.g. `linux/amd64`). For an index, this is the platform of the first image, which is the one tested.",
+				Computed:            true,
+			},
+			"config_digest": schema.StringAttribute{
+				MarkdownDescription: "Digest of the tested image's config.",
+				Computed:            true,
+			},
+			"total_size": schema.Int64Attribute{
+				MarkdownDescription: "Total size in bytes of the tested image's config and compressed layers.",
+				Computed:            true,
+			},
+			"layer_count": schema.Int64Attribute{
+				MarkdownDescription: "Number of layers in the tested image.",
 				Computed:            true,
 			},
 		},
+		Blocks: map[string]schema.Block{
+			"conditions": schema.ListNestedBlock{
+				MarkdownDescription: "Conditions to test. At least one `conditions` block is required.",
+				Validators:          []validator.List{minItemsValidator{min: 1}},
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"no_shell": schema.BoolAttribute{
+							MarkdownDescription: fmt.Sprintf("If true, the image must have no shell (%s), looked for as `forbidden_executables` are.", strings.Join(structure.Shells, ", ")),
+							Optional:            true,
+						},
+						"forbidden_executables": schema.ListAttribute{
+							MarkdownDescription: fmt.Sprintf("Executables the image must not have, by name or absolute path. Names are looked for in %s and the directories on the image's `PATH`.", strings.Join(structure.ExecutableDirs, ", ")),
+							Optional:            true,
+							ElementType:         basetypes.StringType{},
+						},
+						"golden_file": schema.StringAttribute{
+							MarkdownDescription: "Path to a golden manifest that must list exactly the image's files, one per line as its octal mode, what it is, and its path, e.g. `0644 11 /etc/passwd`; what it is is `dir`, a size or `sha256:<hex>` for a regular file, `link:<target>`, `hardlink:<target>` or `other`. Files added, removed or changed since are reported in full, so a golden manifest can be started empty.",
+							Optional:            true,
+						},
+					},
+					Blocks: map[string]schema.Block{
+						"env": schema.ListNestedBlock{
+							MarkdownDescription: "Environment variables the image must set.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"key": schema.StringAttribute{
+										MarkdownDescription: "Name of the variable.",
+										Required:            true,
+									},
+									"value": schema.StringAttribute{
+										MarkdownDescription: "Value the variable must have.",
+										Required:            true,
+									},
+								},
+							},
+						},
+						"files": schema.ListNestedBlock{
+							MarkdownDescription: "Files the image must have.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"path": schema.StringAttribute{
+										MarkdownDescription: "Absolute path of the file.",
+										Required:            true,
+									},
+									"regex": schema.StringAttribute{
+										MarkdownDescription: "Regular expression the file's contents must match.",
+										Optional:            true,
+										Validators:          []validator.String{validators.RegexValidator{}},
+									},
+									"max_size": schema.Int64Attribute{
+										MarkdownDescription: "If set, the largest file, in bytes, that `regex` is matched against. Larger files fail the check, as do binary files unless `allow_binary` is set. If it isn't set, `regex` is matched against any file.",
+										Optional:            true,
+									},
+									"allow_binary": schema.BoolAttribute{
+										MarkdownDescription: "If true, `regex` is matched against binary files even though `max_size` is set.",
+										Optional:            true,
+									},
+									"mode": schema.StringAttribute{
+										MarkdownDescription: "Octal mode the file must have, including setuid, setgid and sticky bits, e.g. `0755` or `4755`.",
+										Optional:            true,
+										Validators:          []validator.String{validators.ModeValidator{}},
+									},
+									"optional": schema.BoolAttribute{
+										MarkdownDescription: "If true, the file may be absent, but is checked if present.",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"dirs": schema.ListNestedBlock{
+							MarkdownDescription: "Directories the image must have.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"path": schema.StringAttribute{
+										MarkdownDescription: "Absolute path of the directory.",
+										Required:            true,
+									},
+									"mode": schema.StringAttribute{
+										MarkdownDescription: "Octal mode the directory must have, e.g. `0755`. If it isn't set, only the directory's existence is checked.",
+										Optional:            true,
+										Validators:          []validator.String{validators.ModeValidator{}},
+									},
+									"recursive": schema.BoolAttribute{
+										MarkdownDescription: "If true, every directory beneath this one must have `mode` too.",
+										Optional:            true,
+									},
+									"exists_only": schema.BoolAttribute{
+										MarkdownDescription: "If true, only the directory's existence is checked, ignoring `mode` and `recursive`.",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"permissions": schema.ListNestedBlock{
+							MarkdownDescription: "Paths beneath which nothing may be writable by group or others.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"path": schema.StringAttribute{
+										MarkdownDescription: "Absolute path to check, and everything beneath it.",
+										Required:            true,
+									},
+									"override": schema.ListAttribute{
+										MarkdownDescription: "Paths that may be writable by group or others.",
+										Optional:            true,
+										ElementType:         basetypes.StringType{},
+									},
+								},
+							},
+						},
+						"healthcheck": schema.ListNestedBlock{
+							MarkdownDescription: "Requires the image to have a HEALTHCHECK, or not to if `present` is false.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"present": schema.BoolAttribute{
+										MarkdownDescription: "If false, the image must not have a HEALTHCHECK (default true).",
+										Optional:            true,
+									},
+									"test_regex": schema.StringAttribute{
+										MarkdownDescription: "Regular expression the test's words joined by spaces must match (e.g. `CMD-SHELL curl -f http://localhost/`).",
+										Optional:            true,
+										Validators:          []validator.String{validators.RegexValidator{}},
+									},
+									"min_interval_seconds": schema.Int64Attribute{
+										MarkdownDescription: fmt.Sprintf("Shortest interval the healthcheck may have. An image that doesn't set an interval has the default of %s.", structure.DefaultHealthcheckInterval),
+										Optional:            true,
+									},
+									"max_interval_seconds": schema.Int64Attribute{
+										MarkdownDescription: "Longest interval the healthcheck may have.",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"ports": schema.ListNestedBlock{
+							MarkdownDescription: "Ports the image must expose.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"port": schema.StringAttribute{
+										MarkdownDescription: "Port, e.g. `8080/tcp`, or `8080` for TCP.",
+										Required:            true,
+									},
+									"present": schema.BoolAttribute{
+										MarkdownDescription: "If false, the image must not expose the port (default true).",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"volumes": schema.ListNestedBlock{
+							MarkdownDescription: "Volumes the image must declare.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"path": schema.StringAttribute{
+										MarkdownDescription: "Path of the volume.",
+										Required:            true,
+									},
+									"present": schema.BoolAttribute{
+										MarkdownDescription: "If false, the image must not declare the volume (default true).",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"rego": schema.ListNestedBlock{
+							MarkdownDescription: "Rego policies evaluated with the [opa](https://www.openpolicyagent.org/) CLI, as conftest would. Their `input` has the image's `config`, `manifest`, `env` as a map, and `files`, mapping each path to its `type` (`file`, `dir`, `symlink`, `hardlink` or `other`), octal `mode`, `size`, link `target`, `uid` and `gid`.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"policy": schema.StringAttribute{
+										MarkdownDescription: "Rego source of the policy.",
+										Required:            true,
+									},
+									"query": schema.StringAttribute{
+										MarkdownDescription: fmt.Sprintf("Rule to evaluate, which must return no messages (default `%s`).", structure.DefaultPolicyQuery),
+										Optional:            true,
+									},
+									"opa_path": schema.StringAttribute{
+										MarkdownDescription: "Path to the opa binary (default `opa`, found on the `PATH`).",
+										Optional:            true,
+									},
+								},
+							},
+						},
+						"custom": schema.ListNestedBlock{
+							MarkdownDescription: "Conditions registered with `structure.RegisterCondition` by a program that embeds the provider.",
+							NestedObject: schema.NestedBlockObject{
+								Attributes: map[string]schema.Attribute{
+									"name": schema.StringAttribute{
+										MarkdownDescription: "Name the condition is registered by.",
+										Required:            true,
+									},
+									"options": schema.StringAttribute{
+										MarkdownDescription: "Options of the condition, as JSON (e.g. `jsonencode({...})`).",
+										Optional:            true,
+										Validators:          []validator.String{validators.JSONValidator{}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 }
 
@@ -123,15 +373,15 @@ func (d *StructureTestDataSource) Read(ctx context.Context, req datasource.ReadR
 		return
 	}
 
-	ref, err := name.NewDigest(data.Digest.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", data.Digest.ValueString(), err))
+	ref, diags := d.popts.testDigest(ctx, data.Digest.ValueString(), data.Resolve.ValueBool(), data.Insecure.ValueBool())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	desc, err := remote.Get(ref, d.popts.withContext(ctx)...)
 	if err != nil {
-		resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", data.Digest.ValueString(), err))
+		resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", ref, err))
 		return
 	}
 
@@ -143,12 +393,92 @@ func (d *StructureTestDataSource) Read(ctx context.Context, req datasource.ReadR
 			}})
 		}
 		for _, f := range c.Files {
+			mode, err := parseMode(f.Mode)
+			if err != nil {
+				resp.Diagnostics.AddError("Invalid mode", fmt.Sprintf("Invalid mode of file %s, got error: %s", f.Path.ValueString(), err))
+				return
+			}
 			conds = append(conds, structure.FilesCondition{Want: map[string]structure.File{
 				f.Path.ValueString(): {
-					Regex: f.Regex.ValueString(),
+					Regex:       f.Regex.ValueString(),
+					MaxSize:     f.MaxSize.ValueInt64(),
+					AllowBinary: f.AllowBinary.ValueBool(),
+					Mode:        mode,
+					Optional:    f.Optional.ValueBool(),
 				},
 			}})
 		}
+		for _, dir := range c.Dirs {
+			mode, err := parseMode(dir.Mode)
+			if err != nil {
+				resp.Diagnostics.AddError("Invalid mode", fmt.Sprintf("Invalid mode of directory %s, got error: %s", dir.Path.ValueString(), err))
+				return
+			}
+			conds = append(conds, structure.DirsCondition{Want: map[string]structure.Dir{
+				dir.Path.ValueString(): {
+					Mode:       mode,
+					Recursive:  dir.Recursive.ValueBool(),
+					ExistsOnly: dir.ExistsOnly.ValueBool(),
+				},
+			}})
+		}
+		for _, p := range c.Permissions {
+			conds = append(conds, structure.PermissionsCondition{Want: map[string]structure.Permission{
+				p.Path.ValueString(): {Override: p.Override},
+			}})
+		}
+		for _, h := range c.Healthcheck {
+			conds = append(conds, structure.HealthcheckCondition{
+				Absent:      !h.Present.IsNull() && !h.Present.ValueBool(),
+				TestRegex:   h.TestRegex.ValueString(),
+				MinInterval: time.Duration(h.MinIntervalSeconds.ValueInt64()) * time.Second,
+				MaxInterval: time.Duration(h.MaxIntervalSeconds.ValueInt64()) * time.Second,
+			})
+		}
+		for _, p := range c.Ports {
+			conds = append(conds, structure.ExposedPortsCondition{Want: map[string]bool{
+				p.Port.ValueString(): p.Present.IsNull() || p.Present.ValueBool(),
+			}})
+		}
+		for _, v := range c.Volumes {
+			conds = append(conds, structure.VolumesCondition{Want: map[string]bool{
+				v.Path.ValueString(): v.Present.IsNull() || v.Present.ValueBool(),
+			}})
+		}
+		if c.NoShell.ValueBool() {
+			conds = append(conds, structure.ForbiddenExecutablesCondition{Names: structure.Shells})
+		}
+		if len(c.ForbiddenExecutables) > 0 {
+			conds = append(conds, structure.ForbiddenExecutablesCondition{Names: c.ForbiddenExecutables})
+		}
+		if f := c.GoldenFile.ValueString(); f != "" {
+			g, err := readGolden(f)
+			if err != nil {
+				resp.Diagnostics.AddError("Invalid golden file", fmt.Sprintf("Unable to read golden file %s, got error: %s", f, err))
+				return
+			}
+			conds = append(conds, g)
+		}
+		for _, r := range c.Rego {
+			conds = append(conds, structure.PolicyCondition{
+				Policy:  r.Policy.ValueString(),
+				Query:   r.Query.ValueString(),
+				OPAPath: r.OPAPath.ValueString(),
+				Context: ctx,
+			})
+		}
+		for _, cc := range c.Custom {
+			var opts json.RawMessage
+			if !cc.Options.IsNull() {
+				opts = json.RawMessage(cc.Options.ValueString())
+			}
+			cond, err := structure.NewCondition(cc.Name.ValueString(), opts)
+			if err != nil {
+				resp.Diagnostics.AddError("Invalid custom condition", fmt.Sprintf("Unable to create custom condition %s, got error: %s", cc.Name.ValueString(), err))
+				return
+			}
+			conds = append(conds, cond)
+		}
 	}
 
 	var img v1.Image
@@ -156,43 +486,65 @@ func (d *StructureTestDataSource) Read(ctx context.Context, req datasource.ReadR
 	case desc.MediaType.IsImage():
 		img, err = desc.Image()
 		if err != nil {
-			resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", data.Digest.ValueString(), err))
+			resp.Diagnostics.AddError("Unable to fetch image", fmt.Sprintf("Unable to fetch image for ref %s, got error: %s", ref, err))
 			return
 		}
 	case desc.MediaType.IsIndex():
 		index, err := desc.ImageIndex()
 		if err != nil {
-			resp.Diagnostics.AddError("Unable to read image index", fmt.Sprintf("Unable to read image index for ref %s, got error: %s", data.Digest.ValueString(), err))
+			resp.Diagnostics.AddError("Unable to read image index", fmt.Sprintf("Unable to read image index for ref %s, got error: %s", ref, err))
 			return
 		}
 
 		indexManifest, err := index.IndexManifest()
 		if err != nil {
-			resp.Diagnostics.AddError("Unable to read image index manifest", fmt.Sprintf("Unable to read image index manifest for ref %s, got error: %s", data.Digest.ValueString(), err))
+			resp.Diagnostics.AddError("Unable to read image index manifest", fmt.Sprintf("Unable to read image index manifest for ref %s, got error: %s", ref, err))
 			return
 		}
 
 		if len(indexManifest.Manifests) == 0 {
-			resp.Diagnostics.AddError("Unable to read image from index manifest", fmt.Sprintf("Unable to read image from index manifest for ref %s: index is empty", data.Digest.ValueString()))
+			resp.Diagnostics.AddError("Unable to read image from index manifest", fmt.Sprintf("Unable to read image from index manifest for ref %s: index is empty", ref))
 		}
 
 		firstDescriptor := indexManifest.Manifests[0]
 		img, err = index.Image(firstDescriptor.Digest)
 		if err != nil {
-			resp.Diagnostics.AddError("Unable to load image", fmt.Sprintf("Unable to load image for ref %s, got error: %s", data.Digest.ValueString(), err))
+			resp.Diagnostics.AddError("Unable to load image", fmt.Sprintf("Unable to load image for ref %s, got error: %s", ref, err))
 			return
 		}
 	}
 
-	if err := conds.Check(img); err != nil {
+	m, err := img.Manifest()
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to read image manifest", fmt.Sprintf("Unable to read image manifest for ref %s, got error: %s", ref, err))
+		return
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to read image config", fmt.Sprintf("Unable to read image config for ref %s, got error: %s", ref, err))
+		return
+	}
+
+	if err := conds.Check(d.popts.cacheImage(img)); err != nil {
 		data.TestedRef = basetypes.NewStringValue("")
 		data.Id = basetypes.NewStringValue("")
 		resp.Diagnostics.AddError("Image does not match rules", fmt.Sprintf("Image does not match rules:\n%s", err))
 		return
 	}
 
-	data.TestedRef = data.Digest
-	data.Id = data.Digest
+	data.TestedRef = types.StringValue(ref.String())
+	data.Id = types.StringValue(ref.String())
+	data.Platform = types.StringValue("")
+	if p := cf.Platform(); p != nil {
+		data.Platform = types.StringValue(p.String())
+	}
+	data.ConfigDigest = types.StringValue(m.Config.Digest.String())
+	size := m.Config.Size
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+	data.TotalSize = types.Int64Value(size)
+	data.LayerCount = types.Int64Value(int64(len(m.Layers)))
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
@@ -201,3 +553,25 @@ func (d *StructureTestDataSource) Read(ctx context.Context, req datasource.ReadR
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
+
+// parseMode parses an optional octal mode, returning nil if it isn't set.
+func parseMode(s types.String) (*fs.FileMode, error) {
+	if s.IsNull() || s.IsUnknown() {
+		return nil, nil
+	}
+	mode, err := structure.ParseMode(s.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	return &mode, nil
+}
+
+// readGolden parses the golden manifest at path.
+func readGolden(path string) (structure.GoldenCondition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return structure.GoldenCondition{}, err
+	}
+	defer f.Close()
+	return structure.ParseGolden(f)
+}
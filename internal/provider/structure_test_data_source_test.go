@@ -3,20 +3,111 @@ package provider
 import (
 	"archive/tar"
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
 	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
+// conditionsBlock returns the schema of oci_structure_test's conditions.
+func conditionsBlock(t *testing.T) schema.ListNestedBlock {
+	t.Helper()
+	var resp datasource.SchemaResponse
+	NewStructureTestDataSource().Schema(context.Background(), datasource.SchemaRequest{}, &resp)
+	block, ok := resp.Schema.Blocks["conditions"].(schema.ListNestedBlock)
+	if !ok {
+		t.Fatalf("conditions is %T", resp.Schema.Blocks["conditions"])
+	}
+	return block
+}
+
+// checkOptional fails t unless each of names is an optional attribute of obj,
+// so that configs written before it was added still validate.
+func checkOptional(t *testing.T, obj schema.NestedBlockObject, names ...string) {
+	t.Helper()
+	for _, n := range names {
+		a, ok := obj.Attributes[n]
+		if !ok {
+			t.Errorf("no attribute %s", n)
+		} else if a.IsRequired() || !a.IsOptional() {
+			t.Errorf("attribute %s isn't optional", n)
+		}
+	}
+}
+
+// nestedBlock returns the object of the list block name in obj, failing t
+// unless the block may be left out.
+func nestedBlock(t *testing.T, obj schema.NestedBlockObject, name string) schema.NestedBlockObject {
+	t.Helper()
+	b, ok := obj.Blocks[name].(schema.ListNestedBlock)
+	if !ok {
+		t.Fatalf("%s is %T", name, obj.Blocks[name])
+	}
+	if len(b.Validators) != 0 {
+		t.Errorf("block %s has validators, so may be required", name)
+	}
+	return b.NestedObject
+}
+
+// TestStructureTestConditionsOptional checks that conditions added since
+// conditions were first released are optional.
+func TestStructureTestConditionsOptional(t *testing.T) {
+	conds := conditionsBlock(t).NestedObject
+	checkOptional(t, nestedBlock(t, conds, "files"), "regex", "max_size", "allow_binary")
+	checkOptional(t, nestedBlock(t, conds, "healthcheck"), "present", "test_regex", "min_interval_seconds", "max_interval_seconds")
+	checkOptional(t, nestedBlock(t, conds, "ports"), "present")
+	checkOptional(t, nestedBlock(t, conds, "volumes"), "present")
+	checkOptional(t, conds, "no_shell", "forbidden_executables", "golden_file")
+}
+
+func TestStructureTestConditionsRequired(t *testing.T) {
+	ctx := context.Background()
+	block := conditionsBlock(t)
+	typ, ok := block.NestedObject.Type().(types.ObjectType)
+	if !ok {
+		t.Fatalf("conditions elements are %T", block.NestedObject.Type())
+	}
+	for _, c := range []struct {
+		desc    string
+		value   types.List
+		wantErr bool
+	}{
+		{desc: "null", value: types.ListNull(typ), wantErr: true},
+		{desc: "empty", value: types.ListValueMust(typ, nil), wantErr: true},
+		{desc: "unknown", value: types.ListUnknown(typ)},
+		{desc: "one block", value: types.ListValueMust(typ, []attr.Value{types.ObjectNull(typ.AttrTypes)})},
+	} {
+		var vresp validator.ListResponse
+		for _, v := range block.ListValidators() {
+			v.ValidateList(ctx, validator.ListRequest{Path: path.Root("conditions"), ConfigValue: c.value}, &vresp)
+		}
+		if got := vresp.Diagnostics.HasError(); got != c.wantErr {
+			t.Errorf("%s conditions: got error %t, want %t: %v", c.desc, got, c.wantErr, vresp.Diagnostics)
+		}
+	}
+}
+
 func TestAccStructureTestDataSource(t *testing.T) {
 	repo, cleanup := ocitesting.SetupRepository(t, "test")
 	defer cleanup()
@@ -55,6 +146,20 @@ func TestAccStructureTestDataSource(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to mutate image: %v", err)
 	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("failed to get config file: %v", err)
+	}
+	cf = cf.DeepCopy()
+	cf.OS, cf.Architecture = "linux", "arm64"
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatalf("failed to set platform: %v", err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatalf("failed to get manifest: %v", err)
+	}
 	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
 	d, err := idx.Digest()
 	if err != nil {
@@ -101,6 +206,10 @@ func TestAccStructureTestDataSource(t *testing.T) {
 			Check: resource.ComposeTestCheckFunc(
 				resource.TestCheckResourceAttr("data.oci_structure_test.test", "digest", ref.String()),
 				resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "platform", "linux/arm64"),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "config_digest", m.Config.Digest.String()),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "total_size", fmt.Sprint(m.Config.Size+m.Layers[0].Size)),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "layer_count", "1"),
 			),
 		}},
 	})
@@ -126,4 +235,581 @@ func TestAccStructureTestDataSource(t *testing.T) {
 		}},
 	})
 
+	// A tag is tested by the digest it resolves to, if resolve is set.
+	tag := repo.Tag("latest")
+	if err := remote.Tag(tag, idx); err != nil {
+		t.Fatalf("failed to tag index: %v", err)
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    env {
+      key = "FOO"
+      value = "bar"
+    }
+  }
+}`, tag),
+			ExpectError: regexp.MustCompile(`Invalid ref`),
+		}, {
+			// A conditions block is required.
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest  = %q
+  resolve = true
+}`, tag),
+			ExpectError: regexp.MustCompile(`at least 1 required`),
+		}, {
+			// The attribute syntax of conditions before they were blocks.
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest  = %q
+  resolve = true
+
+  conditions = [{
+    env = [{
+      key = "FOO"
+      value = "bar"
+    }]
+  }]
+}`, tag),
+			ExpectError: regexp.MustCompile(`Unsupported argument`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest  = %q
+  resolve = true
+
+  conditions {
+    env {
+      key = "FOO"
+      value = "bar"
+    }
+  }
+}`, tag),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "digest", tag.String()),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "tested_ref", ref.String()),
+				resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+			),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_Healthcheck(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := mutate.Config(empty.Image, v1.Config{Healthcheck: &v1.HealthConfig{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: 10 * time.Second,
+	}})
+	if err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    healthcheck {
+      test_regex           = "^CMD-SHELL curl "
+      min_interval_seconds = 5
+      max_interval_seconds = 60
+    }
+  }
+}`, ref),
+			Check: resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    healthcheck {
+      present = false
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`healthcheck "CMD-SHELL curl -f http://localhost/" is set, want none`),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_PortsAndVolumes(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := mutate.Config(empty.Image, v1.Config{
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+		Volumes:      map[string]struct{}{"/data": {}},
+	})
+	if err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    ports {
+      port = "8080"
+    }
+    ports {
+      port    = "22/tcp"
+      present = false
+    }
+    volumes {
+      path = "/data"
+    }
+  }
+}`, ref),
+			Check: resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    ports {
+      port    = "8080/tcp"
+      present = false
+    }
+    volumes {
+      path = "/var/lib/db"
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`port "8080/tcp" is exposed\n.*volume "/var/lib/db" is not declared`),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_NoShell(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "bin/sh", Typeflag: tar.TypeSymlink, Linkname: "busybox", Mode: 0777},
+		{Name: "opt/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "opt/bin/curl", Typeflag: tar.TypeReg, Mode: 0755},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create layer: %v", err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, l)
+	if err != nil {
+		t.Fatalf("failed to append layer: %v", err)
+	}
+	if img, err = mutate.Config(img, v1.Config{Env: []string{"PATH=/opt/bin"}}); err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    forbidden_executables = ["wget", "/usr/bin/curl"]
+  }
+}`, ref),
+			Check: resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    no_shell              = true
+    forbidden_executables = ["curl"]
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`forbidden executable "/bin/sh" found\n.*forbidden executable "/opt/bin/curl" found`),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_Golden(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct{ name, contents string }{{"etc/passwd", "root:x:0:0"}, {"etc/hosts", "localhost"}} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create layer: %v", err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, l)
+	if err != nil {
+		t.Fatalf("failed to append layer: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.txt")
+	if err := os.WriteFile(golden, []byte("0644 10 /etc/passwd\n0644 9 /etc/hosts\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drifted := filepath.Join(dir, "drifted.txt")
+	if err := os.WriteFile(drifted, []byte("0644 10 /etc/passwd\n0644 5 /etc/shadow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    golden_file = %q
+  }
+}`, ref, golden),
+			Check: resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    golden_file = %q
+  }
+}`, ref, drifted),
+			ExpectError: regexp.MustCompile(`added: 0644 9 /etc/hosts\n.*removed: 0644 5 /etc/shadow`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    golden_file = %q
+  }
+}`, ref, filepath.Join(dir, "missing.txt")),
+			ExpectError: regexp.MustCompile(`Unable to read golden file`),
+		}},
+	})
+}
+
+// layersCondition is a custom condition, registered as a program embedding
+// the provider would, requiring an image to have at most max layers.
+type layersCondition struct {
+	Max int `json:"max"`
+}
+
+func (l layersCondition) Check(i v1.Image) error {
+	ls, err := i.Layers()
+	if err != nil {
+		return err
+	}
+	if len(ls) > l.Max {
+		return fmt.Errorf("image has %d layers, want at most %d", len(ls), l.Max)
+	}
+	return nil
+}
+
+func init() {
+	structure.RegisterCondition("test_max_layers", func(options json.RawMessage) (structure.Condition, error) {
+		var l layersCondition
+		if err := json.Unmarshal(options, &l); err != nil {
+			return nil, err
+		}
+		return l, nil
+	})
+}
+
+func TestAccStructureTestDataSource_Custom(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	config := func(name, options string) string {
+		return fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    custom {
+      name    = %q
+      options = %s
+    }
+  }
+}`, ref, name, options)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: config("test_max_layers", `jsonencode({ max = 2 })`),
+			Check:  resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config:      config("test_max_layers", `jsonencode({ max = 1 })`),
+			ExpectError: regexp.MustCompile(`image has 2 layers, want at most 1`),
+		}, {
+			Config:      config("test_max_layers", `"{"`),
+			ExpectError: regexp.MustCompile(`Invalid json`),
+		}, {
+			Config:      config("not_registered", `"{}"`),
+			ExpectError: regexp.MustCompile(`unknown condition "not_registered"`),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_Rego(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	// Stand in for opa, denying the image if the query is data.main.deny.
+	opa := filepath.Join(t.TempDir(), "opa")
+	if err := os.WriteFile(opa, []byte(`#!/bin/sh
+for last; do :; done
+if [ "$last" = data.main.deny ]; then
+  echo '{"result": [{"expressions": [{"value": ["image is not allowed"]}]}]}'
+else
+  echo '{}'
+fi
+`), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := func(query string) string {
+		return fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    rego {
+      policy   = "package main"
+      query    = %q
+      opa_path = %q
+    }
+  }
+}`, ref, query, opa)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config:      config("data.main.deny"),
+			ExpectError: regexp.MustCompile(`policy denied: image is not allowed`),
+		}, {
+			Config: config("data.main.violation"),
+			Check:  resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}},
+	})
+}
+
+func TestAccStructureTestDataSource_Modes(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "etc", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/ssl", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "tmp", Typeflag: tar.TypeDir, Mode: 01777},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewBuffer(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create layer: %v", err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, l)
+	if err != nil {
+		t.Fatalf("failed to append layer: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	ref := repo.Digest(d.String())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    files {
+      path = "/etc/passwd"
+      mode = "0644"
+    }
+    files {
+      path     = "/etc/shadow"
+      optional = true
+    }
+    dirs {
+      path      = "/etc"
+      mode      = "0755"
+      recursive = true
+    }
+    dirs {
+      path = "/tmp"
+      mode = "1777"
+    }
+    permissions {
+      path     = "/"
+      override = ["/tmp"]
+    }
+  }
+}`, ref),
+			Check: resource.TestCheckResourceAttr("data.oci_structure_test.test", "id", ref.String()),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    files {
+      path = "/etc/passwd"
+      mode = "0600"
+    }
+    dirs {
+      path = "/var"
+    }
+    permissions {
+      path = "/"
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`file "/etc/passwd" has mode 0644, want 0600(.|\n)*directory "/var" not found(.|\n)*"/tmp" is writable by group or others`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    files {
+      path  = "/etc/passwd"
+      regex = "root:("
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`Invalid regex`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    files {
+      path = "/etc/passwd"
+      mode = "0999"
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`invalid mode "0999"`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_structure_test" "test" {
+  digest = %q
+
+  conditions {
+    dirs {
+      path = "/etc"
+      mode = "0o777"
+    }
+  }
+}`, ref),
+			ExpectError: regexp.MustCompile(`invalid mode "0o777"`),
+		}},
+	})
 }
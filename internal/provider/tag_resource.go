@@ -2,11 +2,17 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -14,6 +20,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var _ resource.Resource = &TagResource{}
@@ -35,6 +42,9 @@ type TagResourceModel struct {
 
 	DigestRef types.String `tfsdk:"digest_ref"`
 	Tag       types.String `tfsdk:"tag"`
+	Insecure  types.Bool   `tfsdk:"insecure"`
+
+	AcceptSameDigest types.Bool `tfsdk:"accept_same_digest"`
 }
 
 func (r *TagResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -57,6 +67,14 @@ func (r *TagResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 				Validators:          []validator.String{validators.TagValidator{}},
 				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+			"accept_same_digest": schema.BoolAttribute{
+				MarkdownDescription: "If true, and the registry won't overwrite the tag because its tags are immutable, succeed if the tag already points to the digest.",
+				Optional:            true,
+			},
 
 			"tagged_ref": schema.StringAttribute{
 				Computed:            true,
@@ -95,7 +113,7 @@ func (r *TagResource) Create(ctx context.Context, req resource.CreateRequest, re
 
 	digest, err := r.doTag(ctx, data)
 	if err != nil {
-		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error tagging image: %s", err.Error()))
+		addTagError(&resp.Diagnostics, err, fmt.Sprintf("Error tagging image: %s", err.Error()))
 		return
 	}
 
@@ -116,24 +134,32 @@ func (r *TagResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 	// Don't actually tag, but check whether the digest is already tagged so we get a useful diff.
 	// If the digest is already tagged, we'll set the ID and tagged_ref to the correct output value.
 	// Otherwise, we'll set them to empty strings so that the create will run when applied.
+	// Transient errors are retried, and then fail the read rather than
+	// looking like a missing tag, so a flaky network doesn't cause re-tagging.
 
-	d, err := name.NewDigest(data.DigestRef.ValueString())
+	d, err := name.NewDigest(data.DigestRef.ValueString(), r.popts.nameOptions(data.DigestRef.ValueString(), data.Insecure.ValueBool())...)
 	if err != nil {
 		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error parsing digest ref: %s", err.Error()))
 		return
 	}
 
 	t := d.Context().Tag(data.Tag.ValueString())
-	desc, err := remote.Get(t, r.popts.withContext(ctx)...)
-	if err != nil {
-		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error getting image: %s", err.Error()))
+	var desc *remote.Descriptor
+	err = retryTransient(ctx, func(ctx context.Context) (err error) {
+		desc, err = remote.Get(t, r.popts.withContext(ctx)...)
+		return err
+	})
+	switch {
+	case isNotFound(err):
+		data.Id = types.StringValue("")
+		data.TaggedRef = types.StringValue("")
+	case err != nil:
+		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error getting tag %s: %s", t, err.Error()))
 		return
-	}
-
-	if desc.Digest.String() != d.DigestStr() {
+	case desc.Digest.String() != d.DigestStr():
 		data.Id = types.StringValue("")
 		data.TaggedRef = types.StringValue("")
-	} else {
+	default:
 		id := fmt.Sprintf("%s@%s", t.Name(), desc.Digest.String())
 		data.Id = types.StringValue(id)
 		data.TaggedRef = types.StringValue(id)
@@ -152,7 +178,7 @@ func (r *TagResource) Update(ctx context.Context, req resource.UpdateRequest, re
 
 	digest, err := r.doTag(ctx, data)
 	if err != nil {
-		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error tagging image: %s", err.Error()))
+		addTagError(&resp.Diagnostics, err, fmt.Sprintf("Error tagging image: %s", err.Error()))
 		return
 	}
 
@@ -171,21 +197,106 @@ func (r *TagResource) ImportState(ctx context.Context, req resource.ImportStateR
 }
 
 func (r *TagResource) doTag(ctx context.Context, data *TagResourceModel) (string, error) {
-	d, err := name.NewDigest(data.DigestRef.ValueString())
+	return r.popts.tag(ctx, data.DigestRef.ValueString(), data.Tag.ValueString(), data.Insecure.ValueBool(), data.AcceptSameDigest.ValueBool())
+}
+
+// tag points tag in the repository of digestRef at digestRef, returning the
+// tagged reference by digest. If acceptSame is true, a registry refusing to
+// move an immutable tag that already points to digestRef isn't an error.
+func (p *ProviderOpts) tag(ctx context.Context, digestRef, tag string, insecure, acceptSame bool) (string, error) {
+	d, err := name.NewDigest(digestRef, p.nameOptions(digestRef, insecure)...)
 	if err != nil {
 		return "", fmt.Errorf("digest_ref must be a digest reference: %v", err)
 	}
-	t := d.Context().Tag(data.Tag.ValueString())
+	t := d.Context().Tag(tag)
 	if err != nil {
 		return "", fmt.Errorf("error parsing tag: %v", err)
 	}
-	desc, err := remote.Get(d, r.popts.withContext(ctx)...)
+	desc, err := remote.Get(d, p.withContext(ctx)...)
 	if err != nil {
 		return "", fmt.Errorf("error fetching digest: %v", err)
 	}
-	if err := remote.Tag(t, desc, r.popts.withContext(ctx)...); err != nil {
-		return "", fmt.Errorf("error tagging digest: %v", err)
+	if err := p.putTag(ctx, t, desc, acceptSame); err != nil {
+		return "", fmt.Errorf("error tagging digest: %w", err)
 	}
 	digest := fmt.Sprintf("%s@%s", t.Name(), desc.Digest.String())
 	return digest, nil
 }
+
+// putTag points t at desc. If the registry refuses because t is immutable,
+// it returns an *immutableTagError saying what t points to instead, or nil
+// if t already points to desc and acceptSame is true.
+func (p *ProviderOpts) putTag(ctx context.Context, t name.Tag, desc *remote.Descriptor, acceptSame bool) error {
+	err := retryTransient(ctx, func(ctx context.Context) error {
+		return remote.Tag(t, desc, p.withContext(ctx)...)
+	})
+	if err == nil || !isImmutableTag(err) {
+		return err
+	}
+	ierr := &immutableTagError{tag: t, digest: desc.Digest, err: err}
+	if cur, herr := remote.Head(t, p.withContext(ctx)...); herr == nil {
+		ierr.current = cur.Digest
+		if acceptSame && cur.Digest == desc.Digest {
+			tflog.Info(ctx, "immutable tag already points to digest", map[string]interface{}{"tag": t.String(), "digest": desc.Digest.String()})
+			return nil
+		}
+	}
+	return ierr
+}
+
+// isImmutableTag reports whether err is a registry refusing to overwrite a tag
+// because it's immutable. Registries don't agree on a status or error code
+// for this (ECR says TAG_INVALID, Harbor 412, Artifact Registry 400 or 403),
+// but they all say so in the message.
+func isImmutableTag(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	switch terr.StatusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed:
+	default:
+		return false
+	}
+	msg := strings.ToLower(terr.Error())
+	return strings.Contains(msg, "immutable") || strings.Contains(msg, "cannot be overwritten")
+}
+
+// immutableTagError is returned when a registry won't point an immutable tag
+// at a new digest.
+type immutableTagError struct {
+	tag     name.Tag
+	digest  v1.Hash // what the tag was to point to
+	current v1.Hash // what the tag points to, if known
+	err     error
+}
+
+func (e *immutableTagError) Error() string {
+	msg := fmt.Sprintf("tag %s is immutable and can't be pointed to %s", e.tag, e.digest)
+	if e.current != (v1.Hash{}) {
+		msg += fmt.Sprintf(" (it points to %s)", e.current)
+	}
+	return msg + ": " + e.err.Error()
+}
+
+func (e *immutableTagError) Unwrap() error { return e.err }
+
+// addTagError adds err from tagging to diags, as summary unless the tag was
+// immutable, which gets a diagnostic of its own saying what to do about it.
+func addTagError(diags *diag.Diagnostics, err error, summary string) {
+	var ierr *immutableTagError
+	if !errors.As(err, &ierr) {
+		diags.AddError("Tag Error", summary)
+		return
+	}
+	detail := fmt.Sprintf("The registry won't point tag %s to %s, because its tags are immutable", ierr.tag, ierr.digest)
+	switch ierr.current {
+	case v1.Hash{}:
+		detail += "."
+	case ierr.digest:
+		detail += ", though it already points to that digest. Set accept_same_digest to treat this as success."
+	default:
+		detail += fmt.Sprintf(", and it already points to %s. Use a new tag, or remove the existing one from the registry.", ierr.current)
+	}
+	diags.AddError("Immutable Tag Error", detail+"\n\n"+ierr.err.Error())
+}
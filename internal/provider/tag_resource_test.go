@@ -1,12 +1,20 @@
 package provider
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
@@ -79,3 +87,71 @@ func TestAccTagResource(t *testing.T) {
 		},
 	})
 }
+
+func TestImmutableTag(t *testing.T) {
+	// A registry that won't overwrite tags, and says so as ECR does.
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") && !strings.Contains(r.URL.Path, "sha256:") {
+			rec := httptest.NewRecorder()
+			reg.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, r.URL.Path, nil))
+			if rec.Code == http.StatusOK {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors":[{"code":"TAG_INVALID","message":"The image tag 'latest' already exists in the 'test' repository and cannot be overwritten because the repository is immutable."}]}`)
+				return
+			}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	var digests []string
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dig := repo.Digest(d.String())
+		if err := remote.Write(dig, img); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+		digests = append(digests, dig.String())
+	}
+
+	ctx := context.Background()
+	p := &ProviderOpts{}
+	if _, err := p.tag(ctx, digests[0], "latest", false, false); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+
+	// Moving the tag is refused, and says where it points.
+	_, err = p.tag(ctx, digests[1], "latest", false, true)
+	var ierr *immutableTagError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("tag to another digest: got error %v, want immutableTagError", err)
+	}
+	if ierr.current.String() != strings.SplitN(digests[0], "@", 2)[1] {
+		t.Errorf("got current digest %s, want %s", ierr.current, digests[0])
+	}
+	var diags diag.Diagnostics
+	addTagError(&diags, err, "unused")
+	if len(diags) != 1 || diags[0].Summary() != "Immutable Tag Error" || !strings.Contains(diags[0].Detail(), "already points to "+ierr.current.String()) {
+		t.Errorf("got diagnostics %v", diags)
+	}
+
+	// Tagging the same digest again fails, unless that's accepted.
+	if _, err := p.tag(ctx, digests[0], "latest", false, false); !errors.As(err, &ierr) {
+		t.Errorf("tag to the same digest: got error %v, want immutableTagError", err)
+	}
+	if _, err := p.tag(ctx, digests[0], "latest", false, true); err != nil {
+		t.Errorf("tag to the same digest, accepting it: %v", err)
+	}
+}
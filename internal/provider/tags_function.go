@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &TagsFunction{}
+
+// NewTagsFunction returns the tags function, which lists tags with the options
+// returned by popts.
+func NewTagsFunction(popts func() *ProviderOpts) function.Function {
+	return &TagsFunction{popts: popts}
+}
+
+// TagsFunction defines the function implementation.
+type TagsFunction struct {
+	popts func() *ProviderOpts
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *TagsFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "tags"
+}
+
+// Definition should return the definition for the function.
+func (s *TagsFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Lists the tags in an OCI repository.",
+		Description: "Returns all tags in the repository, in the order the registry lists them.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "repo",
+				Description: "The repository to list, e.g. `cgr.dev/chainguard/wolfi-base`.",
+			},
+		},
+		Return: function.ListReturn{ElementType: basetypes.StringType{}},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *TagsFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	if ferr := req.Arguments.GetArgument(ctx, 0, &input); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	popts := s.popts()
+	repo, err := name.NewRepository(input, popts.nameOptions(input, false)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI repository: %v", err))
+		return
+	}
+
+	tags, err := remote.List(repo, popts.withContext(ctx)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to list tags: %v", err))
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, tags))
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestTagsFunction(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	for _, tag := range []string{"a", "b", "c"} {
+		if err := remote.Write(repo.Tag(tag), img); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`output "tags" { value = provider::oci::tags(%q) }`, repo),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("tags", knownvalue.ListExact([]knownvalue.Check{
+					knownvalue.StringExact("a"),
+					knownvalue.StringExact("b"),
+					knownvalue.StringExact("c"),
+				})),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "tags" { value = provider::oci::tags(%q) }`, repo.Tag("a")),
+			ExpectError: regexp.MustCompile("Failed to parse OCI repository"),
+		}},
+	})
+}
@@ -3,11 +3,13 @@ package provider
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -22,6 +24,7 @@ import (
 
 var _ resource.Resource = &TagsResource{}
 var _ resource.ResourceWithImportState = &TagsResource{}
+var _ resource.ResourceWithUpgradeState = &TagsResource{}
 
 func NewTagsResource() resource.Resource {
 	return &TagsResource{}
@@ -38,6 +41,9 @@ type TagsResourceModel struct {
 
 	Repo string            `tfsdk:"repo"`
 	Tags map[string]string `tfsdk:"tags"` // tag -> digest
+
+	Insecure         types.Bool `tfsdk:"insecure"`
+	AcceptSameDigest types.Bool `tfsdk:"accept_same_digest"`
 }
 
 func (r *TagsResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -47,6 +53,7 @@ func (r *TagsResource) Metadata(ctx context.Context, req resource.MetadataReques
 func (r *TagsResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "Tag many digests with many tags.",
+		Version:             1,
 		Attributes: map[string]schema.Attribute{
 			"repo": schema.StringAttribute{
 				MarkdownDescription: "Repository for the tags.",
@@ -55,18 +62,27 @@ func (r *TagsResource) Schema(ctx context.Context, req resource.SchemaRequest, r
 				PlanModifiers:       []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
 			"tags": schema.MapAttribute{
-				MarkdownDescription: "Map of tag -> digest to apply.",
+				MarkdownDescription: "Map of tag -> digest (e.g. `sha256:deadbeef`) to apply.",
 				Required:            true,
 				ElementType:         basetypes.StringType{},
-				// TODO: validator -- check that digests and tags are well formed.
-				PlanModifiers: []planmodifier.Map{mapplanmodifier.RequiresReplace()},
+				Validators:          []validator.Map{validators.TagValidator{}, validators.DigestListValidator{Bare: true}},
+				PlanModifiers:       []planmodifier.Map{mapplanmodifier.RequiresReplace()},
+			},
+
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+			"accept_same_digest": schema.BoolAttribute{
+				MarkdownDescription: "If true, and the registry won't overwrite a tag because its tags are immutable, succeed if the tag already points to its digest.",
+				Optional:            true,
 			},
 
 			// TODO: any outputs?
 
 			"id": schema.StringAttribute{
 				Computed:            true,
-				MarkdownDescription: "The resulting fully-qualified image ref by digest (e.g. {repo}:tag@sha256:deadbeef).",
+				MarkdownDescription: "SHA-256 of the repository and its tags and digests, sorted by tag.",
 				PlanModifiers:       []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
 			},
 		},
@@ -96,7 +112,7 @@ func (r *TagsResource) Create(ctx context.Context, req resource.CreateRequest, r
 
 	digest, err := r.doTags(ctx, data)
 	if err != nil {
-		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error tagging image: %s", err.Error()))
+		addTagError(&resp.Diagnostics, err, fmt.Sprintf("Error tagging image: %s", err.Error()))
 		return
 	}
 
@@ -117,11 +133,12 @@ func (r *TagsResource) Read(ctx context.Context, req resource.ReadRequest, resp
 	// If the digests are already tagged with all requested tags, we'll set the ID to the correct output value.
 	// Otherwise, we'll set them to empty strings so that the create will run when applied.
 	// TODO: Can we get a better diff about what new updates will be applied?
-	if id, err := r.checkTags(ctx, data); err != nil {
-		data.Id = types.StringValue("")
-	} else {
-		data.Id = types.StringValue(id)
+	id, err := r.checkTags(ctx, data)
+	if err != nil {
+		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error checking tags: %s", err.Error()))
+		return
 	}
+	data.Id = types.StringValue(id)
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -136,7 +153,7 @@ func (r *TagsResource) Update(ctx context.Context, req resource.UpdateRequest, r
 
 	id, err := r.doTags(ctx, data)
 	if err != nil {
-		resp.Diagnostics.AddError("Tag Error", fmt.Sprintf("Error tagging images: %s", err.Error()))
+		addTagError(&resp.Diagnostics, err, fmt.Sprintf("Error tagging images: %s", err.Error()))
 		return
 	}
 
@@ -153,32 +170,36 @@ func (r *TagsResource) ImportState(ctx context.Context, req resource.ImportState
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
+// checkTags returns the ID of data if every tag points to its digest, or an
+// empty ID if any is missing or points elsewhere. Transient errors are
+// retried, and then returned, rather than looking like a missing tag.
 func (r *TagsResource) checkTags(ctx context.Context, data *TagsResourceModel) (string, error) {
-	repo, err := name.NewRepository(data.Repo)
+	repo, err := name.NewRepository(data.Repo, r.popts.nameOptions(data.Repo, data.Insecure.ValueBool())...)
 	if err != nil {
 		return "", fmt.Errorf("error parsing repo ref: %w", err)
 	}
 
 	for tag, digest := range data.Tags {
 		t := repo.Tag(tag)
-		desc, err := remote.Head(t, r.popts.withContext(ctx)...)
-		if err != nil {
+		var desc *v1.Descriptor
+		err := retryTransient(ctx, func(ctx context.Context) (err error) {
+			desc, err = remote.Head(t, r.popts.withContext(ctx)...)
+			return err
+		})
+		if isNotFound(err) {
+			return "", nil
+		} else if err != nil {
 			return "", fmt.Errorf("error getting tag %q: %w", t, err)
 		}
 		if desc.Digest.String() != digest {
-			return "", fmt.Errorf("tag %q does not point to digest %q (got %q)", tag, digest, desc.Digest.String())
+			return "", nil
 		}
 	}
-	// ID is the SHA256 of the JSONified map.
-	b, err := json.Marshal(data.Tags)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling tags: %w", err)
-	}
-	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+	return tagsID(repo, data.Tags), nil
 }
 
 func (r *TagsResource) doTags(ctx context.Context, data *TagsResourceModel) (string, error) {
-	repo, err := name.NewRepository(data.Repo)
+	repo, err := name.NewRepository(data.Repo, r.popts.nameOptions(data.Repo, data.Insecure.ValueBool())...)
 	if err != nil {
 		return "", fmt.Errorf("error parsing repo ref: %w", err)
 	}
@@ -190,15 +211,82 @@ func (r *TagsResource) doTags(ctx context.Context, data *TagsResourceModel) (str
 		if err != nil {
 			return "", fmt.Errorf("error getting digest %q: %w", digest, err)
 		}
-		if err := remote.Tag(t, desc, r.popts.withContext(ctx)...); err != nil {
+		if err := r.popts.putTag(ctx, t, desc, data.AcceptSameDigest.ValueBool()); err != nil {
 			return "", fmt.Errorf("error tagging %q with %q: %w", digest, tag, err)
 		}
 	}
 
-	// ID is the SHA256 of the JSONified map.
-	b, err := json.Marshal(data.Tags)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling tags: %w", err)
+	return tagsID(repo, data.Tags), nil
+}
+
+// tagsID returns the ID of the tags in repo: the SHA-256 of the repository's
+// name, and then each tag and its digest, sorted by tag.
+func tagsID(repo name.Repository, tags map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, repo.Name())
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		fmt.Fprintf(h, "%s %s\n", tag, tags[tag])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// tagsResourceModelV0 is TagsResourceModel at schema version 0.
+type tagsResourceModelV0 struct {
+	Id types.String `tfsdk:"id"`
+
+	Repo string            `tfsdk:"repo"`
+	Tags map[string]string `tfsdk:"tags"`
+}
+
+// tagsSchemaV0 is the schema at version 0. It's written out rather than
+// derived from Schema, so that later changes to Schema can't change how
+// existing state is read.
+var tagsSchemaV0 = schema.Schema{
+	Attributes: map[string]schema.Attribute{
+		"repo": schema.StringAttribute{
+			Required: true,
+		},
+		"tags": schema.MapAttribute{
+			Required:    true,
+			ElementType: basetypes.StringType{},
+		},
+		"id": schema.StringAttribute{
+			Computed: true,
+		},
+	},
+}
+
+// UpgradeState migrates state from version 0, whose ID was the SHA-256 of the
+// JSON-encoded tags, without the repository.
+func (r *TagsResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &tagsSchemaV0,
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var prior tagsResourceModelV0
+				resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				data := TagsResourceModel{
+					Id:               prior.Id,
+					Repo:             prior.Repo,
+					Tags:             prior.Tags,
+					Insecure:         types.BoolNull(),
+					AcceptSameDigest: types.BoolNull(),
+				}
+				// An empty ID means the tags are to be applied again, which
+				// is still true.
+				if data.Id.ValueString() != "" {
+					repo, err := name.NewRepository(data.Repo)
+					if err != nil {
+						resp.Diagnostics.AddError("Upgrade Error", fmt.Sprintf("Error parsing repo ref: %s", err.Error()))
+						return
+					}
+					data.Id = types.StringValue(tagsID(repo, data.Tags))
+				}
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
 	}
-	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
 }
@@ -1,15 +1,24 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
@@ -120,4 +129,138 @@ func TestAccTagsResource(t *testing.T) {
 		dig1.String(): {"1", "foo", "bar", "baz", "hello", "goodbye"},
 		dig2.String(): {"2", "world", "kevin"},
 	})
+
+	// Malformed tags and digests are caught at plan time.
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`resource "oci_tags" "test" {
+				repo = %q
+				tags = { "not:a:tag" = %q }
+			}`, repo, d1),
+			ExpectError: regexp.MustCompile(`Invalid OCI tag name`),
+		}, {
+			Config: fmt.Sprintf(`resource "oci_tags" "test" {
+				repo = %q
+				tags = { latest = %q }
+			}`, repo, dig1),
+			ExpectError: regexp.MustCompile(`Invalid OCI digest`),
+		}},
+	})
+}
+
+func TestCheckTags(t *testing.T) {
+	setTransientRetry(t, 2, time.Millisecond)
+
+	reg, _, cleanup := ocitesting.SetupFaultyRegistry(t, ocitesting.Faults{})
+	defer cleanup()
+	repo := reg.Repo("test")
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	dig, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	if err := remote.Write(repo.Tag("1"), img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	r := &TagsResource{}
+	for _, c := range []struct {
+		desc   string
+		tags   map[string]string
+		wantID bool
+	}{
+		{"tagged", map[string]string{"1": dig.String()}, true},
+		{"missing", map[string]string{"1": dig.String(), "2": dig.String()}, false},
+		{"moved", map[string]string{"1": "sha256:" + strings.Repeat("0", 64)}, false},
+	} {
+		id, err := r.checkTags(context.Background(), &TagsResourceModel{Repo: repo.String(), Tags: c.tags})
+		if err != nil {
+			t.Errorf("%s: checkTags: %v", c.desc, err)
+		}
+		if got := id != ""; got != c.wantID {
+			t.Errorf("%s: got id %q, want id: %t", c.desc, id, c.wantID)
+		}
+	}
+
+	// Server errors aren't mistaken for missing tags.
+	freg, _, fcleanup := ocitesting.SetupFaultyRegistry(t, ocitesting.Faults{
+		ServerErrors: 1,
+		Methods:      []string{http.MethodHead},
+	})
+	defer fcleanup()
+	r.popts.ropts = []remote.Option{remote.WithRetryBackoff(remote.Backoff{Steps: 1})}
+	if _, err := r.checkTags(context.Background(), &TagsResourceModel{Repo: freg.Repo("test").String(), Tags: map[string]string{"1": dig.String()}}); err == nil {
+		t.Error("checkTags succeeded, want error")
+	}
+}
+
+func TestTagsID(t *testing.T) {
+	a, err := name.NewRepository("example.com/aa")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	b, err := name.NewRepository("example.com/bb")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	tags := map[string]string{"1": "sha256:" + strings.Repeat("1", 64), "2": "sha256:" + strings.Repeat("2", 64)}
+
+	// This is the ID that state is upgraded to, so it must not change.
+	if got, want := tagsID(a, tags), "b9116bb7972eef85ce78ea9dea41429f6bb3e2525ddc26595f226a28aec4ef59"; got != want {
+		t.Errorf("got id %s, want %s", got, want)
+	}
+	if tagsID(a, tags) == tagsID(b, tags) {
+		t.Error("got the same id for different repos")
+	}
+}
+
+func TestTagsUpgradeState(t *testing.T) {
+	ctx := context.Background()
+	r := &TagsResource{}
+	upgrader := r.UpgradeState(ctx)[0]
+
+	repo, err := name.NewRepository("example.com/test")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	tags := map[string]string{"latest": "sha256:" + strings.Repeat("1", 64)}
+	for _, c := range []struct {
+		desc, id, want string
+	}{
+		{"tagged", "deadbeef", tagsID(repo, tags)},
+		{"untagged", "", ""},
+	} {
+		prior := tfsdk.State{Schema: tagsSchemaV0, Raw: tftypes.NewValue(tagsSchemaV0.Type().TerraformType(ctx), nil)}
+		if diags := prior.Set(ctx, &tagsResourceModelV0{Id: types.StringValue(c.id), Repo: repo.String(), Tags: tags}); diags.HasError() {
+			t.Fatalf("%s: failed to set prior state: %v", c.desc, diags)
+		}
+
+		var sresp fwresource.SchemaResponse
+		r.Schema(ctx, fwresource.SchemaRequest{}, &sresp)
+		resp := &fwresource.UpgradeStateResponse{State: tfsdk.State{Schema: sresp.Schema, Raw: tftypes.NewValue(sresp.Schema.Type().TerraformType(ctx), nil)}}
+		upgrader.StateUpgrader(ctx, fwresource.UpgradeStateRequest{State: &prior}, resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("%s: UpgradeState: %v", c.desc, resp.Diagnostics)
+		}
+
+		var got TagsResourceModel
+		if diags := resp.State.Get(ctx, &got); diags.HasError() {
+			t.Fatalf("%s: failed to get state: %v", c.desc, diags)
+		}
+		if got.Id.ValueString() != c.want {
+			t.Errorf("%s: got id %q, want %q", c.desc, got.Id.ValueString(), c.want)
+		}
+		if got.Repo != repo.String() || len(got.Tags) != 1 {
+			t.Errorf("%s: got %+v, want the prior repo and tags", c.desc, got)
+		}
+		if !got.Insecure.IsNull() || !got.AcceptSameDigest.IsNull() {
+			t.Errorf("%s: got %+v, want attributes added since version 0 unset", c.desc, got)
+		}
+	}
 }
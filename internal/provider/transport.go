@@ -0,0 +1,321 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TLSConfig describes the TLS settings used to talk to registries.
+type TLSConfig struct {
+	CAFile         types.String `tfsdk:"ca_file"`
+	CAPEM          types.String `tfsdk:"ca_pem"`
+	ClientCertFile types.String `tfsdk:"client_cert_file"`
+	ClientKeyFile  types.String `tfsdk:"client_key_file"`
+	ClientCertPEM  types.String `tfsdk:"client_cert_pem"`
+	ClientKeyPEM   types.String `tfsdk:"client_key_pem"`
+}
+
+// tlsConfig returns the crypto/tls config described by c.
+//
+// CA certificates are added to the system pool, rather than replacing it.
+func (c *TLSConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var cas [][]byte
+	if f := c.CAFile.ValueString(); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		cas = append(cas, b)
+	}
+	if p := c.CAPEM.ValueString(); p != "" {
+		cas = append(cas, []byte(p))
+	}
+	if len(cas) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, ca := range cas {
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in CA bundle")
+			}
+		}
+		cfg.RootCAs = pool
+	}
+
+	certPEM, keyPEM := []byte(c.ClientCertPEM.ValueString()), []byte(c.ClientKeyPEM.ValueString())
+	if f := c.ClientCertFile.ValueString(); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading client certificate: %w", err)
+		}
+		certPEM = b
+	}
+	if f := c.ClientKeyFile.ValueString(); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading client key: %w", err)
+		}
+		keyPEM = b
+	}
+	switch {
+	case len(certPEM) > 0 && len(keyPEM) > 0:
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case len(certPEM) > 0 || len(keyPEM) > 0:
+		return nil, fmt.Errorf("both a client certificate and key are required")
+	}
+
+	return cfg, nil
+}
+
+// proxyFunc returns the proxy func for the given proxy settings, each of which
+// overrides the corresponding environment variable if set. It returns nil if
+// none are set, so that the environment is used as-is.
+func proxyFunc(httpProxy, httpsProxy, noProxy *string) func(*http.Request) (*url.URL, error) {
+	if httpProxy == nil && httpsProxy == nil && noProxy == nil {
+		return nil
+	}
+	cfg := httpproxy.FromEnvironment()
+	if httpProxy != nil {
+		cfg.HTTPProxy = *httpProxy
+	}
+	if httpsProxy != nil {
+		cfg.HTTPSProxy = *httpsProxy
+	}
+	if noProxy != nil {
+		cfg.NoProxy = *noProxy
+	}
+	f := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return f(req.URL)
+	}
+}
+
+// newTransport returns the base transport used for registry requests, with the
+// given TLS config and proxy func (either of which may be nil to use the
+// defaults).
+func newTransport(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	t, ok := remote.DefaultTransport.(*http.Transport)
+	if ok {
+		t = t.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	if proxy != nil {
+		t.Proxy = proxy
+	}
+	return t
+}
+
+// hostTransport routes requests to a per-host transport, falling back to a
+// default transport for other hosts.
+type hostTransport struct {
+	def    http.RoundTripper
+	byHost map[string]http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.byHost[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	if rt, ok := t.byHost[req.URL.Hostname()]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.def.RoundTrip(req)
+}
+
+// limitTransport limits the number of in-flight requests across everything
+// that shares it.
+//
+// A request's slot is released once the response headers arrive rather than
+// when the body is closed: blobs are sometimes streamed from one registry to
+// another, and holding slots for the whole copy could deadlock.
+type limitTransport struct {
+	sem  chan struct{}
+	next http.RoundTripper
+}
+
+// newLimitTransport returns a transport that makes at most n requests at a
+// time. n must be positive, or every request would block.
+func newLimitTransport(n int64, next http.RoundTripper) *limitTransport {
+	return &limitTransport{sem: make(chan struct{}, n), next: next}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	defer func() { <-t.sem }()
+	return t.next.RoundTrip(req)
+}
+
+// descriptorCacheTransport caches successful responses to manifest requests
+// by digest, whose contents can't change, so that everything that shares it
+// fetches each manifest at most once. A HEAD is answered from a cached GET.
+//
+// It caches raw responses rather than remote.Descriptors, which would keep
+// using the context of the request that fetched them.
+type descriptorCacheTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]cachedManifest
+}
+
+// cachedManifest is a cached response. body is nil if only a HEAD has been
+// made.
+type cachedManifest struct {
+	header http.Header
+	body   []byte
+}
+
+func newDescriptorCacheTransport(next http.RoundTripper) *descriptorCacheTransport {
+	return &descriptorCacheTransport{next: next, entries: map[string]cachedManifest{}}
+}
+
+func (t *descriptorCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || !isManifestByDigest(req.URL) {
+		return t.next.RoundTrip(req)
+	}
+	key := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+
+	t.mu.Lock()
+	e, ok := t.entries[key]
+	t.mu.Unlock()
+	if ok && (req.Method == http.MethodHead || e.body != nil) {
+		resp := &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     e.header.Clone(),
+			Body:       http.NoBody,
+			Request:    req,
+		}
+		if req.Method == http.MethodGet {
+			resp.Body = io.NopCloser(bytes.NewReader(e.body))
+			resp.ContentLength = int64(len(e.body))
+		}
+		return resp, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	e = cachedManifest{header: resp.Header.Clone()}
+	if req.Method == http.MethodGet {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		e.body = body
+	}
+	t.mu.Lock()
+	if old, ok := t.entries[key]; !ok || old.body == nil {
+		t.entries[key] = e
+	}
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// isManifestByDigest reports whether u is the URL of a manifest by digest.
+func isManifestByDigest(u *url.URL) bool {
+	i := strings.LastIndex(u.Path, "/manifests/")
+	if i < 0 || !strings.HasPrefix(u.Path, "/v2/") {
+		return false
+	}
+	_, err := v1.NewHash(u.Path[i+len("/manifests/"):])
+	return err == nil
+}
+
+// requestIDHeaders are response headers that registries and CDNs use to
+// identify requests, which are useful when reporting problems to operators.
+var requestIDHeaders = []string{
+	"X-Request-Id",
+	"X-Amz-Request-Id",
+	"X-Amz-Cf-Id",
+	"X-Cloud-Trace-Context",
+	"X-Ms-Request-Id",
+	"Cf-Ray",
+	"Docker-Distribution-Api-Version",
+}
+
+// debugTransport logs request and response metadata at debug level, with
+// credentials redacted.
+type debugTransport struct {
+	next http.RoundTripper
+}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	fields := map[string]interface{}{
+		"method":          req.Method,
+		"url":             req.URL.Redacted(),
+		"request_headers": redactHeaders(req.Header),
+		"duration_ms":     time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		tflog.Debug(req.Context(), "registry request failed", fields)
+		return nil, err
+	}
+	fields["status"] = resp.StatusCode
+	for _, h := range requestIDHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fields[h] = v
+		}
+	}
+	if v := resp.Header.Get("Www-Authenticate"); v != "" {
+		fields["Www-Authenticate"] = v
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		fields["Retry-After"] = v
+	}
+	tflog.Debug(req.Context(), "registry request", fields)
+	return resp, nil
+}
+
+// redactHeaders returns h as a map, with credentials redacted.
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "Proxy-Authorization", "Cookie", "X-Amz-Security-Token":
+			out[k] = "[REDACTED]"
+		default:
+			out[k] = strings.Join(v, ", ")
+		}
+	}
+	return out
+}
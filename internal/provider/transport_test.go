@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	c := &TLSConfig{CAPEM: types.StringValue(string(ca))}
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+
+	// The server's certificate isn't trusted by default.
+	if _, err := (&http.Client{Transport: newTransport(nil, nil)}).Get(srv.URL); err == nil {
+		t.Error("expected an error without the CA")
+	}
+	if _, err := (&http.Client{Transport: newTransport(cfg, nil)}).Get(srv.URL); err != nil {
+		t.Errorf("with the CA: %v", err)
+	}
+
+	// Per-host transports are only used for their host.
+	ht := &hostTransport{
+		def:    newTransport(nil, nil),
+		byHost: map[string]http.RoundTripper{srv.Listener.Addr().String(): newTransport(cfg, nil)},
+	}
+	if _, err := (&http.Client{Transport: ht}).Get(srv.URL); err != nil {
+		t.Errorf("with the per-host CA: %v", err)
+	}
+	ht.byHost = map[string]http.RoundTripper{"registry.example.com": newTransport(cfg, nil)}
+	if _, err := (&http.Client{Transport: ht}).Get(srv.URL); err == nil {
+		t.Error("expected an error with a CA for another host")
+	}
+
+	if _, err := (&TLSConfig{CAPEM: types.StringValue("not a cert")}).tlsConfig(); err == nil {
+		t.Error("expected an error for an invalid CA")
+	}
+	if _, err := (&TLSConfig{ClientCertPEM: types.StringValue("cert")}).tlsConfig(); err == nil {
+		t.Error("expected an error for a client certificate without a key")
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	t.Setenv("NO_PROXY", "")
+
+	if proxyFunc(nil, nil, nil) != nil {
+		t.Error("expected a nil proxy func with no settings")
+	}
+
+	str := func(s string) *string { return &s }
+	for _, c := range []struct {
+		desc                           string
+		httpProxy, httpsProxy, noProxy *string
+		url, want                      string
+	}{
+		{"https from config", nil, str("http://proxy:8080"), nil, "https://registry.example.com/v2/", "http://proxy:8080"},
+		{"http from config", str("http://plain:8080"), nil, nil, "http://registry.example.com/v2/", "http://plain:8080"},
+		{"https from env", str("http://plain:8080"), nil, nil, "https://registry.example.com/v2/", "http://env-proxy:3128"},
+		{"no_proxy", nil, str("http://proxy:8080"), str(".example.com"), "https://registry.example.com/v2/", ""},
+		{"no_proxy other host", nil, str("http://proxy:8080"), str(".example.com"), "https://gcr.io/v2/", "http://proxy:8080"},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.url, nil)
+			u, err := proxyFunc(c.httpProxy, c.httpsProxy, c.noProxy)(req)
+			if err != nil {
+				t.Fatalf("proxy: %v", err)
+			}
+			var got string
+			if u != nil {
+				got = u.String()
+			}
+			if got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLimitTransport(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newLimitTransport(2, newTransport(nil, nil))}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("got %d concurrent requests, want at most 2", peak)
+	}
+}
+
+func TestDescriptorCacheTransport(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(repo.Tag("latest"), img); err != nil {
+		t.Fatal(err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := repo.Digest(h.String())
+	mu.Lock()
+	clear(requests)
+	mu.Unlock()
+
+	tr := remote.WithTransport(newDescriptorCacheTransport(newTransport(nil, nil)))
+	for i := 0; i < 3; i++ {
+		if _, err := remote.Head(d, tr); err != nil {
+			t.Fatalf("Head: %v", err)
+		}
+		desc, err := remote.Get(d, tr)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if desc.Digest != h {
+			t.Errorf("got digest %s, want %s", desc.Digest, h)
+		}
+		if _, err := remote.Get(repo.Tag("latest"), tr); err != nil {
+			t.Fatalf("Get by tag: %v", err)
+		}
+	}
+	// Missing manifests aren't cached.
+	missing := repo.Digest("sha256:" + strings.Repeat("0", 64))
+	for i := 0; i < 2; i++ {
+		if _, err := remote.Head(missing, tr); err == nil {
+			t.Error("Head of a missing manifest: want error")
+		}
+	}
+
+	byDigest := "/v2/test/manifests/" + h.String()
+	for req, want := range map[string]int{
+		"HEAD " + byDigest:                               1,
+		"GET " + byDigest:                                1,
+		"GET /v2/test/manifests/latest":                  3,
+		"HEAD /v2/test/manifests/" + missing.DigestStr(): 2,
+	} {
+		if got := requests[req]; got != want {
+			t.Errorf("got %d requests for %s, want %d", got, req, want)
+		}
+	}
+}
+
+func TestDebugTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v2/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := debugTransport{next: newTransport(nil, nil)}.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+
+	entries, err := tflogtest.MultilineJSONDecode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e["method"] != "GET" || e["url"] != srv.URL+"/v2/" || e["status"] != float64(http.StatusTooManyRequests) || e["X-Request-Id"] != "req-123" {
+		t.Errorf("unexpected log entry: %v", e)
+	}
+	if strings.Contains(fmt.Sprint(e), "secret") {
+		t.Errorf("log entry contains credentials: %v", e)
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -16,6 +17,7 @@ type Image struct {
 type Manifest struct {
 	SchemaVersion int64             `tfsdk:"schema_version"`
 	MediaType     string            `tfsdk:"media_type"`
+	ArtifactType  string            `tfsdk:"artifact_type"`
 	Config        *Descriptor       `tfsdk:"config"`
 	Layers        []Descriptor      `tfsdk:"layers"`
 	Annotations   map[string]string `tfsdk:"annotations"`
@@ -24,6 +26,16 @@ type Manifest struct {
 }
 
 func (m *Manifest) FromDescriptor(desc *remote.Descriptor) error {
+	// ggcr doesn't model artifactType on manifests, so read it from the raw
+	// manifest.
+	var raw struct {
+		ArtifactType string `json:"artifactType"`
+	}
+	if err := json.Unmarshal(desc.Manifest, &raw); err != nil {
+		return err
+	}
+	m.ArtifactType = raw.ArtifactType
+
 	switch {
 	case desc.MediaType.IsImage():
 		img, err := desc.Image()
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &VerifyFunction{}
+
+// NewVerifyFunction returns the verify function, which fetches images with
+// the options returned by popts.
+func NewVerifyFunction(popts func() *ProviderOpts) function.Function {
+	return &VerifyFunction{popts: popts}
+}
+
+// VerifyFunction defines the function implementation.
+type VerifyFunction struct {
+	popts func() *ProviderOpts
+}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *VerifyFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "verify"
+}
+
+// Definition should return the definition for the function.
+func (s *VerifyFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Verifies that an image or index is intact in its registry.",
+		Description: "Pulls the config and every layer of the image, or of every image in the index, and checks that their digests and sizes match the manifest, rather than trusting it, to detect registry corruption before an image is promoted. Returns the fully qualified reference by digest of what was verified. This pulls the whole image, so it's slow for big images.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string to verify.",
+			},
+		},
+		VariadicParameter: function.StringParameter{
+			Name:        "platform",
+			Description: "If the reference is an index, only verify the image for this platform (e.g. `linux/arm64`), and return it instead of the index.",
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *VerifyFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input string
+	var platforms []string
+	if ferr := req.Arguments.Get(ctx, &input, &platforms); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+	var platform *v1.Platform
+	switch len(platforms) {
+	case 0:
+	case 1:
+		p, err := v1.ParsePlatform(platforms[0])
+		if err != nil {
+			resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Failed to parse platform: %v", err))
+			return
+		}
+		platform = p
+	default:
+		resp.Error = function.NewArgumentFuncError(1, "At most one platform may be given")
+		return
+	}
+
+	popts := s.popts()
+	ref, err := name.ParseReference(input, popts.nameOptions(input, false)...)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	d, err := popts.verify(ctx, ref, platform)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to verify %s: %v", ref, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, d.String()))
+}
+
+// verify pulls the image or index ref, or the image for platform in it if
+// platform is set, and checks every blob against the manifests, returning
+// what it verified by digest. Blobs are fetched from the registry, never the
+// provider's blob cache, which would hide corruption.
+func (p *ProviderOpts) verify(ctx context.Context, ref name.Reference, platform *v1.Platform) (name.Digest, error) {
+	opts := p.withContext(ctx)
+	if platform != nil {
+		opts = append(opts, remote.WithPlatform(*platform))
+	}
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	if desc.MediaType.IsIndex() && platform == nil {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		if err := validate.Index(idx); err != nil {
+			return name.Digest{}, err
+		}
+	} else {
+		// With a platform, this resolves an index to the image for it.
+		img, err := desc.Image()
+		if err != nil {
+			return name.Digest{}, err
+		}
+		if err := validate.Image(img); err != nil {
+			return name.Digest{}, err
+		}
+		if desc.Digest, err = img.Digest(); err != nil {
+			return name.Digest{}, err
+		}
+	}
+	return ref.Context().Digest(desc.Digest.String()), nil
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+// corruptWriter flips the first byte written through it.
+type corruptWriter struct {
+	http.ResponseWriter
+	done bool
+}
+
+func (w *corruptWriter) Write(b []byte) (int, error) {
+	if !w.done && len(b) > 0 {
+		w.done = true
+		b = append([]byte{b[0] ^ 0xff}, b[1:]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func TestVerify(t *testing.T) {
+	// A registry that corrupts the layer blobs it serves, while corrupt is set.
+	var corrupt atomic.Bool
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if corrupt.Load() && r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			w = &corruptWriter{ResponseWriter: w}
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://") + "/test")
+	if err != nil {
+		t.Fatalf("failed to parse repo: %v", err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.OS, cf.Architecture = "linux", "arm64"
+	if img, err = mutate.ConfigFile(img, cf); err != nil {
+		t.Fatal(err)
+	}
+	idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}},
+	})
+	tag := repo.Tag("latest")
+	if err := remote.WriteIndex(tag, idx); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+	idxDigest, err := idx.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	p := &ProviderOpts{}
+	if got, err := p.verify(ctx, tag, nil); err != nil || got.DigestStr() != idxDigest.String() {
+		t.Errorf("verify index = %s, %v; want %s", got, err, idxDigest)
+	}
+	if got, err := p.verify(ctx, tag, &v1.Platform{OS: "linux", Architecture: "arm64"}); err != nil || got.DigestStr() != imgDigest.String() {
+		t.Errorf("verify image for platform = %s, %v; want %s", got, err, imgDigest)
+	}
+	if _, err := p.verify(ctx, tag, &v1.Platform{OS: "linux", Architecture: "amd64"}); err == nil {
+		t.Error("verify missing platform: want error")
+	}
+
+	corrupt.Store(true)
+	if _, err := p.verify(ctx, tag, nil); err == nil {
+		t.Error("verify corrupt index: want error")
+	}
+	if _, err := p.verify(ctx, repo.Digest(imgDigest.String()), nil); err == nil {
+		t.Error("verify corrupt image: want error")
+	}
+}
+
+func TestVerifyFunction(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	ref := repo.Tag("latest")
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get image digest: %v", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`output "verified" { value = provider::oci::verify(%q) }`, ref),
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("verified", knownvalue.StringExact(repo.Digest(d.String()).String())),
+			},
+		}, {
+			Config:      fmt.Sprintf(`output "missing" { value = provider::oci::verify(%q) }`, repo.Tag("missing")),
+			ExpectError: regexp.MustCompile("Failed to verify"),
+		}},
+	})
+}
@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/validators"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ datasource.DataSource = &WaitDataSource{}
+
+func NewWaitDataSource() datasource.DataSource {
+	return &WaitDataSource{}
+}
+
+// WaitDataSource defines the data source implementation.
+type WaitDataSource struct {
+	popts ProviderOpts
+}
+
+// WaitDataSourceModel describes the data source data model.
+type WaitDataSourceModel struct {
+	Ref             types.String `tfsdk:"ref"`
+	Digest          types.String `tfsdk:"digest"`
+	TimeoutSeconds  types.Int64  `tfsdk:"timeout_seconds"`
+	IntervalSeconds types.Int64  `tfsdk:"interval_seconds"`
+	Insecure        types.Bool   `tfsdk:"insecure"`
+
+	Id       types.String `tfsdk:"id"`
+	ImageRef types.String `tfsdk:"image_ref"`
+}
+
+func (d *WaitDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_wait"
+}
+
+func (d *WaitDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Waits until a tag or digest is visible in a registry, e.g. after replication or promotion to a registry that's eventually consistent.",
+
+		Attributes: map[string]schema.Attribute{
+			"ref": schema.StringAttribute{
+				MarkdownDescription: "Tag or digest to wait for",
+				Required:            true,
+				Validators:          []validator.String{validators.RefValidator{}},
+			},
+			"digest": schema.StringAttribute{
+				MarkdownDescription: "If `ref` is a tag, wait until it points to this digest (e.g. `sha256:deadbeef`), rather than to anything. Otherwise, this is the digest that `ref` resolved to.",
+				Optional:            true,
+				Computed:            true,
+			},
+			"timeout_seconds": schema.Int64Attribute{
+				MarkdownDescription: "How long to wait in seconds (default is 5 minutes)",
+				Optional:            true,
+				Validators:          []validator.Int64{minIntValidator{min: 1}},
+			},
+			"interval_seconds": schema.Int64Attribute{
+				MarkdownDescription: "How long to wait between checks in seconds (default is 5)",
+				Optional:            true,
+				Validators:          []validator.Int64{minIntValidator{min: 1}},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: "If true, access the registry over plain HTTP, in addition to the provider's `insecure_registries`",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified image digest of the image.",
+				Computed:            true,
+			},
+			"image_ref": schema.StringAttribute{
+				MarkdownDescription: "Fully qualified image digest of the image (e.g. {repo}@sha256:deadbeef).",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *WaitDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	popts, ok := req.ProviderData.(*ProviderOpts)
+	if !ok || popts == nil {
+		resp.Diagnostics.AddError("Client Error", "invalid provider data")
+		return
+	}
+	d.popts = *popts
+}
+
+func (d *WaitDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data WaitDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ref, err := name.ParseReference(data.Ref.ValueString(), d.popts.nameOptions(data.Ref.ValueString(), data.Insecure.ValueBool())...)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ref", fmt.Sprintf("Unable to parse ref %s, got error: %s", data.Ref.ValueString(), err))
+		return
+	}
+	var want v1.Hash
+	if !data.Digest.IsNull() {
+		if want, err = v1.NewHash(data.Digest.ValueString()); err != nil {
+			resp.Diagnostics.AddError("Invalid digest", fmt.Sprintf("Unable to parse digest %s, got error: %s", data.Digest.ValueString(), err))
+			return
+		}
+	}
+
+	timeout := 300 * time.Second
+	if !data.TimeoutSeconds.IsNull() {
+		timeout = time.Duration(data.TimeoutSeconds.ValueInt64()) * time.Second
+	}
+	interval := 5 * time.Second
+	if !data.IntervalSeconds.IsNull() {
+		interval = time.Duration(data.IntervalSeconds.ValueInt64()) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	got, err := d.popts.waitFor(ctx, ref, want, interval)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to wait for ref", fmt.Sprintf("Unable to wait for ref %s, got error: %s", ref, err))
+		return
+	}
+
+	dig := ref.Context().Digest(got.String()).String()
+	data.Digest = types.StringValue(got.String())
+	data.Id = types.StringValue(dig)
+	data.ImageRef = types.StringValue(dig)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// waitFor checks every interval until ref exists, and points to want if it's
+// set, and returns its digest. Registries that say it doesn't exist yet, or
+// fail transiently, are asked again; other errors are returned right away.
+// It gives up when ctx is done.
+func (p *ProviderOpts) waitFor(ctx context.Context, ref name.Reference, want v1.Hash, interval time.Duration) (v1.Hash, error) {
+	for {
+		var reason error
+		desc, err := remote.Head(ref, p.withContext(ctx)...)
+		switch {
+		case err == nil && (want == v1.Hash{} || desc.Digest == want):
+			return desc.Digest, nil
+		case err == nil:
+			reason = fmt.Errorf("points to %s, not %s", desc.Digest, want)
+		case isNotFound(err) || isTransient(err):
+			reason = err
+		case ctx.Err() != nil:
+			// The request was cut short by the timeout.
+			reason = err
+		default:
+			return v1.Hash{}, err
+		}
+		tflog.Debug(ctx, "waiting for ref", map[string]interface{}{"ref": ref.String(), "reason": reason.Error()})
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return v1.Hash{}, fmt.Errorf("timed out, last check: %w", reason)
+			}
+			return v1.Hash{}, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
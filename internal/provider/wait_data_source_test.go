@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	ocitesting "github.com/chainguard-dev/terraform-provider-oci/testing"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestWaitFor(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	p := &ProviderOpts{}
+	ctx := context.Background()
+	tag := repo.Tag("latest")
+
+	first, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstDigest, err := first.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondDigest, err := second.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tag doesn't exist yet, so waiting times out.
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := p.waitFor(tctx, tag, v1.Hash{}, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("waitFor missing tag: got error %v, want timeout", err)
+	}
+
+	// The tag shows up while waiting.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := remote.Write(tag, first); err != nil {
+			t.Errorf("failed to write image: %v", err)
+		}
+	}()
+	got, err := p.waitFor(ctx, tag, v1.Hash{}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("waitFor: %v", err)
+	}
+	if got != firstDigest {
+		t.Errorf("got %s, want %s", got, firstDigest)
+	}
+
+	// The tag moves to the wanted digest while waiting.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := remote.Write(tag, second); err != nil {
+			t.Errorf("failed to write image: %v", err)
+		}
+	}()
+	got, err = p.waitFor(ctx, tag, secondDigest, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("waitFor digest: %v", err)
+	}
+	if got != secondDigest {
+		t.Errorf("got %s, want %s", got, secondDigest)
+	}
+
+	// Waiting for a digest by digest only checks that it exists.
+	if got, err := p.waitFor(ctx, repo.Digest(firstDigest.String()), v1.Hash{}, 10*time.Millisecond); err != nil || got != firstDigest {
+		t.Errorf("waitFor by digest = %s, %v; want %s", got, err, firstDigest)
+	}
+}
+
+func TestAccWaitDataSource(t *testing.T) {
+	repo, cleanup := ocitesting.SetupRepository(t, "test")
+	defer cleanup()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := repo.Tag("latest")
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig := repo.Digest(d.String()).String()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{{
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref = %q
+			}`, tag),
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("data.oci_wait.test", "digest", d.String()),
+				resource.TestCheckResourceAttr("data.oci_wait.test", "image_ref", dig),
+			),
+		}, {
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref    = %q
+			  digest = %q
+			}`, tag, d),
+			Check: resource.TestCheckResourceAttr("data.oci_wait.test", "id", dig),
+		}, {
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref              = %q
+			  digest           = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+			  timeout_seconds  = 2
+			  interval_seconds = 1
+			}`, tag),
+			ExpectError: regexp.MustCompile(`timed out`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref    = %q
+			  digest = "not-a-digest"
+			}`, tag),
+			ExpectError: regexp.MustCompile(`Unable to parse digest`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref              = %q
+			  interval_seconds = 0
+			}`, tag),
+			ExpectError: regexp.MustCompile(`must be at least 1`),
+		}, {
+			Config: fmt.Sprintf(`data "oci_wait" "test" {
+			  ref             = %q
+			  timeout_seconds = 0
+			}`, tag),
+			ExpectError: regexp.MustCompile(`must be at least 1`),
+		}},
+	})
+}
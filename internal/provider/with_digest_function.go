@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &WithDigestFunction{}
+
+func NewWithDigestFunction() function.Function {
+	return &WithDigestFunction{}
+}
+
+// WithDigestFunction defines the function implementation.
+type WithDigestFunction struct{}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *WithDigestFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "with_digest"
+}
+
+// Definition should return the definition for the function.
+func (s *WithDigestFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Replaces the digest of an OCI reference.",
+		Description: "Returns the fully-qualified reference to the same repository with the given digest. Any tag or digest in the input reference is dropped.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string.",
+			},
+			function.StringParameter{
+				Name:        "digest",
+				Description: "The new digest, e.g. `sha256:...`.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *WithDigestFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input, digest string
+	if ferr := req.Arguments.Get(ctx, &input, &digest); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	ref, err := name.ParseReference(input)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	out, err := name.NewDigest(ref.Context().Name() + "@" + digest)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Invalid digest %q: %v", digest, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, out.String()))
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &WithTagFunction{}
+
+func NewWithTagFunction() function.Function {
+	return &WithTagFunction{}
+}
+
+// WithTagFunction defines the function implementation.
+type WithTagFunction struct{}
+
+// Metadata should return the name of the function, such as parse_xyz.
+func (s *WithTagFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "with_tag"
+}
+
+// Definition should return the definition for the function.
+func (s *WithTagFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Replaces the tag of an OCI reference.",
+		Description: "Returns the fully-qualified reference to the same repository with the given tag. Any tag or digest in the input reference is dropped.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "input",
+				Description: "The OCI reference string.",
+			},
+			function.StringParameter{
+				Name:        "tag",
+				Description: "The new tag.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run should return the result of the function logic. It is called when
+// Terraform reaches a function call in the configuration. Argument data
+// values should be read from the [RunRequest] and the result value set in
+// the [RunResponse].
+func (s *WithTagFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var input, tag string
+	if ferr := req.Arguments.Get(ctx, &input, &tag); ferr != nil {
+		resp.Error = ferr
+		return
+	}
+
+	ref, err := name.ParseReference(input)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse OCI reference: %v", err))
+		return
+	}
+
+	out, err := name.NewTag(ref.Context().Name() + ":" + tag)
+	if err == nil && out.TagStr() != tag {
+		err = fmt.Errorf("tag must not be empty")
+	}
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("Invalid tag %q: %v", tag, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, out.String()))
+}
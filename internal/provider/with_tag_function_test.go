@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestWithTagFunction(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config: `
+output "tag" { value = provider::oci::with_tag("cgr.dev/foo/sample:latest", "v1") }
+output "digest" { value = provider::oci::with_tag("cgr.dev/foo/sample@sha256:1234567890123456789012345678901234567890123456789012345678901234", "v1") }
+output "short" { value = provider::oci::with_tag("sample", "v1") }
+`,
+			ConfigStateChecks: []statecheck.StateCheck{
+				statecheck.ExpectKnownOutputValue("tag", knownvalue.StringExact("cgr.dev/foo/sample:v1")),
+				statecheck.ExpectKnownOutputValue("digest", knownvalue.StringExact("cgr.dev/foo/sample:v1")),
+				statecheck.ExpectKnownOutputValue("short", knownvalue.StringExact("index.docker.io/library/sample:v1")),
+			},
+		}},
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks:   []tfversion.TerraformVersionCheck{tfversion.SkipBelow(tfversion.Version1_8_0)},
+		Steps: []resource.TestStep{{
+			Config:      `output "tag" { value = provider::oci::with_tag("cgr.dev/foo/sample", "not a tag") }`,
+			ExpectError: regexp.MustCompile("Invalid tag"),
+		}},
+	})
+}
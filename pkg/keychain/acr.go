@@ -0,0 +1,142 @@
+package keychain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// acrRegistryRE matches Azure Container Registry hostnames.
+var acrRegistryRE = regexp.MustCompile(`^[a-z0-9]+\.azurecr\.(?:io|cn|us)$`)
+
+// acrUsername is the username ACR expects with refresh tokens.
+const acrUsername = "00000000-0000-0000-0000-000000000000"
+
+// ACR is a keychain that authenticates to Azure Container Registry using an
+// Azure AD service principal or workload identity from the environment.
+//
+// AZURE_TENANT_ID and AZURE_CLIENT_ID must be set, along with either
+// AZURE_CLIENT_SECRET or AZURE_FEDERATED_TOKEN_FILE (as with AKS workload
+// identity or OIDC federation). The Azure AD token is exchanged for an ACR
+// refresh token.
+//
+// Registries that aren't ACR registries, or that can't be authenticated to
+// because no credentials are available, resolve to authn.Anonymous so that
+// other keychains can be consulted.
+type ACR struct {
+	client *http.Client
+	// loginEndpoint and registryScheme override the Azure AD login endpoint
+	// and the scheme used to reach the registry, for testing.
+	loginEndpoint  string
+	registryScheme string
+
+	mu    sync.Mutex
+	cache map[string]cachedAuth
+}
+
+var _ authn.ContextKeychain = &ACR{}
+
+// NewACR returns a keychain for Azure Container Registry, that calls Azure AD
+// and the registries with t, or http.DefaultTransport if t is nil.
+func NewACR(t http.RoundTripper) *ACR {
+	return &ACR{client: newClient(t)}
+}
+
+func (a *ACR) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return a.ResolveContext(context.Background(), target)
+}
+
+func (a *ACR) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if !acrRegistryRE.MatchString(registry) {
+		return authn.Anonymous, nil
+	}
+
+	tenant, client := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID")
+	if tenant == "" || client == "" {
+		return authn.Anonymous, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.cache[registry]; ok && time.Now().Before(c.expires) {
+		return authn.FromConfig(c.auth), nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", client)
+	form.Set("scope", "https://management.azure.com/.default")
+	switch {
+	case os.Getenv("AZURE_CLIENT_SECRET") != "":
+		form.Set("client_secret", os.Getenv("AZURE_CLIENT_SECRET"))
+	case os.Getenv("AZURE_FEDERATED_TOKEN_FILE") != "":
+		token, err := os.ReadFile(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("reading federated token: %w", err)
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(token)))
+	default:
+		return authn.Anonymous, nil
+	}
+
+	login := a.loginEndpoint
+	if login == "" {
+		login = "https://login.microsoftonline.com"
+	}
+	var aad struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := postForm(ctx, a.client, fmt.Sprintf("%s/%s/oauth2/v2.0/token", login, tenant), form, &aad); err != nil {
+		return nil, fmt.Errorf("getting Azure AD token: %w", err)
+	}
+
+	scheme := a.registryScheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	exchange := url.Values{}
+	exchange.Set("grant_type", "access_token")
+	exchange.Set("service", registry)
+	exchange.Set("tenant", tenant)
+	exchange.Set("access_token", aad.AccessToken)
+	var acr struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := postForm(ctx, a.client, fmt.Sprintf("%s://%s/oauth2/exchange", scheme, registry), exchange, &acr); err != nil {
+		return nil, fmt.Errorf("exchanging Azure AD token for %s: %w", registry, err)
+	}
+
+	auth := authn.AuthConfig{Username: acrUsername, Password: acr.RefreshToken}
+	if a.cache == nil {
+		a.cache = map[string]cachedAuth{}
+	}
+	// ACR refresh tokens are valid for 3 hours; refresh well before that.
+	a.cache[registry] = cachedAuth{auth: auth, expires: time.Now().Add(2 * time.Hour)}
+	return authn.FromConfig(auth), nil
+}
+
+// postForm posts form to u with client, and decodes the JSON response into
+// out.
+func postForm(ctx context.Context, client *http.Client, u string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := do(client, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,305 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// ecrRegistryRE matches Amazon ECR registry hostnames, capturing the account ID,
+// whether it's a FIPS endpoint, the region, and the domain suffix.
+var ecrRegistryRE = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.(amazonaws\.com(?:\.cn)?)$`)
+
+// ECR is a keychain that authenticates to Amazon ECR registries using AWS
+// credentials from the environment.
+//
+// Static credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN. Otherwise, if AWS_WEB_IDENTITY_TOKEN_FILE and
+// AWS_ROLE_ARN are set (as on EKS or with OIDC federation), the role is
+// assumed with the web identity token.
+//
+// Registries that aren't ECR registries, or that can't be authenticated to
+// because no credentials are available, resolve to authn.Anonymous so that
+// other keychains can be consulted.
+type ECR struct {
+	client *http.Client
+	// endpoint overrides the ECR and STS API endpoints, for testing.
+	endpoint func(service, region, domain string) string
+
+	mu    sync.Mutex
+	cache map[string]cachedAuth
+}
+
+type cachedAuth struct {
+	auth    authn.AuthConfig
+	expires time.Time
+}
+
+var _ authn.ContextKeychain = &ECR{}
+
+// NewECR returns a keychain for Amazon ECR registries, that calls the AWS
+// APIs with t, or http.DefaultTransport if t is nil.
+func NewECR(t http.RoundTripper) *ECR {
+	return &ECR{client: newClient(t)}
+}
+
+func (e *ECR) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return e.ResolveContext(context.Background(), target)
+}
+
+func (e *ECR) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	m := ecrRegistryRE.FindStringSubmatch(target.RegistryStr())
+	if m == nil {
+		return authn.Anonymous, nil
+	}
+	account, fips, region, domain := m[1], m[2] != "", m[3], m[4]
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.cache[target.RegistryStr()]; ok && time.Now().Before(c.expires) {
+		return authn.FromConfig(c.auth), nil
+	}
+
+	creds, err := e.credentials(ctx, region, domain)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return authn.Anonymous, nil
+	}
+
+	auth, expires, err := e.authorizationToken(ctx, creds, account, fips, region, domain)
+	if err != nil {
+		return nil, fmt.Errorf("getting ECR authorization token for %s: %w", target.RegistryStr(), err)
+	}
+	if e.cache == nil {
+		e.cache = map[string]cachedAuth{}
+	}
+	// Refresh a little early so a token doesn't expire mid-operation.
+	e.cache[target.RegistryStr()] = cachedAuth{auth: auth, expires: expires.Add(-5 * time.Minute)}
+	return authn.FromConfig(auth), nil
+}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// credentials returns AWS credentials from the environment, or nil if there
+// are none.
+func (e *ECR) credentials(ctx context.Context, region, domain string) (*awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: secret,
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || role == "" {
+		return nil, nil
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading web identity token: %w", err)
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "terraform-provider-oci"
+	}
+
+	q := url.Values{}
+	q.Set("Action", "AssumeRoleWithWebIdentity")
+	q.Set("Version", "2011-06-15")
+	q.Set("RoleArn", role)
+	q.Set("RoleSessionName", session)
+	q.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url("sts", region, domain), strings.NewReader(q.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := do(e.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("assuming role %s: %w", role, err)
+	}
+	var out struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("parsing AssumeRoleWithWebIdentity response: %w", err)
+	}
+	return &awsCredentials{
+		AccessKeyID:     out.Credentials.AccessKeyID,
+		SecretAccessKey: out.Credentials.SecretAccessKey,
+		SessionToken:    out.Credentials.SessionToken,
+	}, nil
+}
+
+// authorizationToken calls ECR's GetAuthorizationToken API, at its FIPS
+// endpoint if fips is set, as for a FIPS registry.
+func (e *ECR) authorizationToken(ctx context.Context, creds *awsCredentials, account string, fips bool, region, domain string) (authn.AuthConfig, time.Time, error) {
+	payload, err := json.Marshal(map[string][]string{"registryIds": {account}})
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	service := "ecr"
+	if fips {
+		service = "ecr-fips"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url(service, region, domain), bytes.NewReader(payload))
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	signV4(req, payload, creds, region, "ecr", time.Now())
+
+	body, err := do(e.client, req)
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	var out struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return authn.AuthConfig{}, time.Time{}, fmt.Errorf("parsing GetAuthorizationToken response: %w", err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return authn.AuthConfig{}, time.Time{}, fmt.Errorf("no authorization data returned")
+	}
+	data := out.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(data.AuthorizationToken)
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, fmt.Errorf("decoding authorization token: %w", err)
+	}
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return authn.AuthConfig{}, time.Time{}, fmt.Errorf("malformed authorization token")
+	}
+	return authn.AuthConfig{Username: user, Password: pass}, time.Unix(int64(data.ExpiresAt), 0), nil
+}
+
+// url returns the endpoint of service ("ecr", "ecr-fips" or "sts") in region.
+func (e *ECR) url(service, region, domain string) string {
+	if e.endpoint != nil {
+		return e.endpoint(service, region, domain)
+	}
+	if service == "ecr" {
+		return fmt.Sprintf("https://api.ecr.%s.%s/", region, domain)
+	}
+	return fmt.Sprintf("https://%s.%s.%s/", service, region, domain)
+}
+
+// signV4 signs req with AWS Signature Version 4.
+func signV4(req *http.Request, payload []byte, creds *awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// requestTimeout bounds each request to a cloud provider's API, so that an
+// unresponsive endpoint can't hang every registry request.
+const requestTimeout = 30 * time.Second
+
+// newClient returns a client that sends requests with t, or
+// http.DefaultTransport if t is nil, and times them out.
+func newClient(t http.RoundTripper) *http.Client {
+	return &http.Client{Transport: t, Timeout: requestTimeout}
+}
+
+// do sends req with client and returns the response body, or an error if the
+// response isn't a 200.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,197 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Exec is a keychain that gets credentials by running a command.
+//
+// The command is run with the registry hostname on stdin and in the
+// OCI_REGISTRY environment variable, and must print a JSON object with the
+// same fields as a Docker config auth entry (`username` and `password`, or
+// `auth`, `identitytoken` or `registrytoken`) to stdout. If it prints
+// nothing, the registry resolves to authn.Anonymous so that other keychains
+// can be consulted.
+//
+// Credentials are cached per registry until the time in the object's
+// `expires_at` field (RFC 3339), or for five minutes if it has none, and are
+// forgotten early if a registry rejects them; see Transport.
+type Exec struct {
+	command    string
+	args       []string
+	registries map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]execEntry
+}
+
+// execTTL is how long an Exec caches credentials that don't say when they
+// expire.
+const execTTL = 5 * time.Minute
+
+// execEntry is an Exec's cached credentials for a registry.
+type execEntry struct {
+	auth    authn.AuthConfig
+	expires time.Time
+}
+
+var _ authn.ContextKeychain = &Exec{}
+
+// NewExec returns a keychain that runs command with args to get credentials.
+// If registries is non-empty, the command is only run for those registries.
+func NewExec(command string, args []string, registries []string) *Exec {
+	e := &Exec{command: command, args: args}
+	if len(registries) > 0 {
+		e.registries = map[string]bool{}
+		for _, r := range registries {
+			e.registries[r] = true
+		}
+	}
+	return e
+}
+
+func (e *Exec) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return e.ResolveContext(context.Background(), target)
+}
+
+func (e *Exec) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if e.registries != nil && !e.registries[registry] {
+		return authn.Anonymous, nil
+	}
+	auth, err := e.get(ctx, registry)
+	if err != nil {
+		return nil, err
+	}
+	if auth == (authn.AuthConfig{}) {
+		return authn.Anonymous, nil
+	}
+	return execAuth{e: e, registry: registry}, nil
+}
+
+// get returns the credentials for registry, running the command if they
+// aren't cached or have expired.
+func (e *Exec) get(ctx context.Context, registry string) (authn.AuthConfig, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.cache[registry]; ok && time.Now().Before(c.expires) {
+		return c.auth, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.Env = append(os.Environ(), "OCI_REGISTRY="+registry)
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return authn.AuthConfig{}, fmt.Errorf("running credential helper %q for %s: %w: %s", e.command, registry, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var auth authn.AuthConfig
+	expires := time.Now().Add(execTTL)
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, &auth); err != nil {
+			return authn.AuthConfig{}, fmt.Errorf("parsing credential helper %q output for %s: %w", e.command, registry, err)
+		}
+		// AuthConfig has its own UnmarshalJSON, so the expiry can't be
+		// decoded along with it.
+		var exp struct {
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal(out, &exp); err != nil {
+			return authn.AuthConfig{}, fmt.Errorf("parsing credential helper %q expires_at for %s: %w", e.command, registry, err)
+		}
+		if exp.ExpiresAt != nil {
+			expires = *exp.ExpiresAt
+		}
+	}
+	if e.cache == nil {
+		e.cache = map[string]execEntry{}
+	}
+	e.cache[registry] = execEntry{auth: auth, expires: expires}
+	return auth, nil
+}
+
+// execAuth authenticates to a registry with an Exec's current credentials
+// for it, so that when ggcr asks for them again to refresh its token after a
+// 401, it gets new ones if the old ones were rejected.
+type execAuth struct {
+	e        *Exec
+	registry string
+}
+
+func (a execAuth) Authorization() (*authn.AuthConfig, error) {
+	return a.AuthorizationContext(context.Background())
+}
+
+func (a execAuth) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
+	auth, err := a.e.get(ctx, a.registry)
+	if err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// Transport returns a transport that sends requests with inner, and makes e
+// forget the credentials a request was sent with if it gets a 401: those
+// cached for the request's host, and any matching its Authorization header,
+// which may have been sent to a token service on another host. They're
+// fetched from the command again the next time they're needed.
+func (e *Exec) Transport(inner http.RoundTripper) http.RoundTripper {
+	return execTransport{e: e, inner: inner}
+}
+
+type execTransport struct {
+	e     *Exec
+	inner http.RoundTripper
+}
+
+func (t execTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	// A 401 to a request without credentials is just a challenge.
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if h := req.Header.Get("Authorization"); h != "" {
+			t.e.reject(req.URL.Host, h)
+		}
+	}
+	return resp, err
+}
+
+// reject forgets the credentials cached for host, and any that are sent as
+// the Authorization header header.
+func (e *Exec) reject(host, header string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for registry, c := range e.cache {
+		if registry == host || authHeader(c.auth) == header {
+			delete(e.cache, registry)
+		}
+	}
+}
+
+// authHeader returns the Authorization header that ggcr sends auth as, or ""
+// if it's exchanged for a token first.
+func authHeader(auth authn.AuthConfig) string {
+	switch {
+	case auth.RegistryToken != "":
+		return "Bearer " + auth.RegistryToken
+	case auth.Auth != "":
+		return "Basic " + auth.Auth
+	case auth.Username != "" || auth.Password != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password))
+	}
+	return ""
+}
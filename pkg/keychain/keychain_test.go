@@ -0,0 +1,348 @@
+package keychain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestSignV4(t *testing.T) {
+	// Example from https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, nil, &awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestECR(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "session" {
+			http.Error(w, "missing session token", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"authorizationData": []map[string]interface{}{{
+				"authorizationToken": base64.StdEncoding.EncodeToString([]byte("AWS:password")),
+				"expiresAt":          float64(time.Now().Add(12 * time.Hour).Unix()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	kc := NewECR(nil)
+	kc.endpoint = func(service, region, domain string) string {
+		if service != "ecr" || region != "us-west-2" || domain != "amazonaws.com" {
+			t.Errorf("unexpected endpoint for %s %s %s", service, region, domain)
+		}
+		return srv.URL
+	}
+
+	for i := 0; i < 2; i++ {
+		auth, err := kc.Resolve(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/repo:tag").Context())
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("Authorization: %v", err)
+		}
+		if cfg.Username != "AWS" || cfg.Password != "password" {
+			t.Errorf("got %+v", cfg)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the token to be cached, got %d calls", calls)
+	}
+
+	auth, err := kc.Resolve(name.MustParseReference("gcr.io/repo:tag").Context())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if auth != authn.Anonymous {
+		t.Errorf("expected anonymous auth for non-ECR registry, got %v", auth)
+	}
+}
+
+func TestECREndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"authorizationData": []map[string]interface{}{{
+				"authorizationToken": base64.StdEncoding.EncodeToString([]byte("AWS:password")),
+				"expiresAt":          float64(time.Now().Add(12 * time.Hour).Unix()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	for _, c := range []struct {
+		registry string
+		want     string
+	}{
+		{"123456789012.dkr.ecr.us-west-2.amazonaws.com", "https://api.ecr.us-west-2.amazonaws.com/"},
+		{"123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com", "https://ecr-fips.us-gov-west-1.amazonaws.com/"},
+		{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", "https://api.ecr.cn-north-1.amazonaws.com.cn/"},
+	} {
+		kc := NewECR(nil)
+		var got string
+		kc.endpoint = func(service, region, domain string) string {
+			got = (&ECR{}).url(service, region, domain)
+			return srv.URL
+		}
+		repo, err := name.NewRepository(c.registry + "/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := kc.Resolve(repo); err != nil {
+			t.Fatalf("%s: Resolve: %v", c.registry, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got endpoint %q, want %q", c.registry, got, c.want)
+		}
+	}
+}
+
+func TestACR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/token":
+			if r.Form.Get("client_id") != "client" || r.Form.Get("client_secret") != "secret" {
+				http.Error(w, "bad client", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "aad-token"})
+		case "/oauth2/exchange":
+			if r.Form.Get("access_token") != "aad-token" || r.Form.Get("service") != "example.azurecr.io" {
+				http.Error(w, "bad exchange", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"refresh_token": "refresh"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+
+	// Route requests for the registry to the test server.
+	host := strings.TrimPrefix(srv.URL, "http://")
+	kc := NewACR(rewriteTransport{host: host, next: http.DefaultTransport})
+	kc.loginEndpoint = srv.URL
+	kc.registryScheme = "http"
+
+	auth, err := kc.Resolve(name.MustParseReference("example.azurecr.io/repo:tag").Context())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization: %v", err)
+	}
+	if cfg.Username != acrUsername || cfg.Password != "refresh" {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+// rewriteTransport sends all requests to host.
+type rewriteTransport struct {
+	host string
+	next http.RoundTripper
+}
+
+func (t rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Host = t.host
+	return t.next.RoundTrip(r)
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := `read reg; echo "$reg" >> "$1"; [ "$OCI_REGISTRY" = "$reg" ] || exit 1; [ "$reg" = anon.example.com ] || echo '{"username":"user","password":"pass-'"$reg"'"}'`
+
+	kc := NewExec("sh", []string{"-c", script, "sh", calls}, []string{"registry.example.com", "anon.example.com"})
+	for i := 0; i < 2; i++ {
+		auth, err := kc.Resolve(name.MustParseReference("registry.example.com/repo:tag").Context())
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatalf("Authorization: %v", err)
+		}
+		if cfg.Username != "user" || cfg.Password != "pass-registry.example.com" {
+			t.Errorf("got %+v", cfg)
+		}
+	}
+
+	// Empty output and registries out of scope resolve to anonymous.
+	for _, ref := range []name.Reference{
+		name.MustParseReference("anon.example.com/repo:tag"),
+		name.MustParseReference("gcr.io/repo:tag"),
+	} {
+		auth, err := kc.Resolve(ref.Context())
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		if auth != authn.Anonymous {
+			t.Errorf("%s: expected anonymous auth, got %v", ref, auth)
+		}
+	}
+
+	b, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "registry.example.com\nanon.example.com\n"; got != want {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+
+	if _, err := NewExec("false", nil, nil).Resolve(name.MustParseReference("gcr.io/repo:tag").Context()); err == nil {
+		t.Error("expected an error when the helper fails")
+	}
+}
+
+// countingHelper returns args for NewExec that print credentials whose
+// password counts the times the helper has run, with extra JSON fields.
+func countingHelper(t *testing.T, extra string) []string {
+	t.Helper()
+	calls := filepath.Join(t.TempDir(), "calls")
+	script := `echo >> "$1"; echo '{"username":"user","password":"pass-'$(wc -l < "$1" | tr -d ' ')'"` + extra + `}'`
+	return []string{"-c", script, "sh", calls}
+}
+
+func password(t *testing.T, auth authn.Authenticator) string {
+	t.Helper()
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization: %v", err)
+	}
+	return cfg.Password
+}
+
+func TestExecExpiry(t *testing.T) {
+	repo := name.MustParseReference("registry.example.com/repo:tag").Context()
+	for _, c := range []struct {
+		desc  string
+		extra string
+		want  string
+	}{
+		{"default", ``, "pass-1"},
+		{"expires later", `,"expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"`, "pass-1"},
+		// Expired credentials are fetched again every time.
+		{"expired", `,"expires_at":"` + time.Now().Add(-time.Minute).Format(time.RFC3339) + `"`, "pass-3"},
+	} {
+		kc := NewExec("sh", countingHelper(t, c.extra), nil)
+		if _, err := kc.Resolve(repo); err != nil {
+			t.Fatalf("%s: Resolve: %v", c.desc, err)
+		}
+		auth, err := kc.Resolve(repo)
+		if err != nil {
+			t.Fatalf("%s: Resolve: %v", c.desc, err)
+		}
+		if got := password(t, auth); got != c.want {
+			t.Errorf("%s: got password %q, want %q", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestExecTransport(t *testing.T) {
+	unauthorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	registry := httptest.NewServer(unauthorized)
+	defer registry.Close()
+	tokens := httptest.NewServer(unauthorized)
+	defer tokens.Close()
+
+	kc := NewExec("sh", countingHelper(t, ``), nil)
+	client := &http.Client{Transport: kc.Transport(http.DefaultTransport)}
+	repo, err := name.NewRepository(strings.TrimPrefix(registry.URL, "http://") + "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := kc.Resolve(repo)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	get := func(url, header string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	basic := func(password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("user:"+password))
+	}
+
+	// A challenge to a request without credentials doesn't reject any.
+	get(registry.URL+"/v2/", "")
+	if got := password(t, auth); got != "pass-1" {
+		t.Errorf("after a challenge: got password %q, want pass-1", got)
+	}
+
+	// The registry rejecting a request, say with a token made from the
+	// credentials, gets new ones.
+	get(registry.URL+"/v2/", "Bearer token")
+	if got := password(t, auth); got != "pass-2" {
+		t.Errorf("after the registry rejected them: got password %q, want pass-2", got)
+	}
+
+	// Another host rejecting credentials gets new ones only if they're
+	// the ones it was sent.
+	get(tokens.URL+"/token", basic("pass-1"))
+	if got := password(t, auth); got != "pass-2" {
+		t.Errorf("after old credentials were rejected: got password %q, want pass-2", got)
+	}
+	get(tokens.URL+"/token", basic("pass-2"))
+	if got := password(t, auth); got != "pass-3" {
+		t.Errorf("after the token service rejected them: got password %q, want pass-3", got)
+	}
+}
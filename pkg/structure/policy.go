@@ -0,0 +1,196 @@
+package structure
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DefaultPolicyQuery is the rule a PolicyCondition evaluates by default,
+// conftest's deny rules in its default namespace.
+const DefaultPolicyQuery = "data.main.deny"
+
+// PolicyCondition evaluates a Rego policy against a PolicyInput describing
+// the image, with the opa CLI, as conftest would. Each message the query
+// returns, e.g. of a deny rule, is a failure.
+type PolicyCondition struct {
+	// Policy is the Rego source of the policy.
+	Policy string
+	// Query is the rule to evaluate, which must return a set of messages
+	// (default DefaultPolicyQuery). A query that's undefined, because the
+	// policy doesn't have the rule, passes.
+	Query string
+	// OPAPath is the path to the opa binary (default "opa", found on the
+	// PATH).
+	OPAPath string
+	// Context, if set, bounds the opa process: it's killed when Context
+	// is done. Check doesn't take a context of its own.
+	Context context.Context
+}
+
+// PolicyInput is the document a PolicyCondition's policy is evaluated
+// against, as `input`.
+type PolicyInput struct {
+	Config   *v1.ConfigFile        `json:"config"`
+	Manifest *v1.Manifest          `json:"manifest"`
+	Env      map[string]string     `json:"env"`
+	Files    map[string]PolicyFile `json:"files"`
+}
+
+// PolicyFile is an entry of a PolicyInput's file index, which is keyed by
+// absolute path.
+type PolicyFile struct {
+	// Type is "file", "dir", "symlink", "hardlink" or "other".
+	Type string `json:"type"`
+	// Mode is the octal mode, e.g. "0644".
+	Mode string `json:"mode"`
+	// Size is the size of a regular file.
+	Size int64 `json:"size,omitempty"`
+	// Target is the target of a link.
+	Target string `json:"target,omitempty"`
+	UID    int    `json:"uid"`
+	GID    int    `json:"gid"`
+}
+
+func (p PolicyCondition) Check(i v1.Image) error { return check(i, p) }
+
+func (p PolicyCondition) scan(i v1.Image) (scan, error) {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return &policyScan{cond: p, input: PolicyInput{
+		Config:   cf,
+		Manifest: m,
+		Env:      splitEnvs(cf.Config.Env),
+		Files:    map[string]PolicyFile{},
+	}}, nil
+}
+
+type policyScan struct {
+	cond  PolicyCondition
+	input PolicyInput
+}
+
+func (s *policyScan) visit(hdr *tar.Header, _ func() ([]byte, error)) (bool, error) {
+	f := PolicyFile{Mode: FormatMode(headerMode(hdr)), UID: hdr.Uid, GID: hdr.Gid}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		f.Type = "dir"
+	case tar.TypeReg:
+		f.Type, f.Size = "file", hdr.Size
+	case tar.TypeSymlink:
+		f.Type, f.Target = "symlink", hdr.Linkname
+	case tar.TypeLink:
+		f.Type, f.Target = "hardlink", path.Clean("/"+hdr.Linkname)
+	default:
+		f.Type = "other"
+	}
+	s.input.Files[hdr.Name] = f
+	return false, nil
+}
+
+func (s *policyScan) result() error {
+	msgs, err := s.cond.eval(s.input)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = fmt.Errorf("policy denied: %s", msg)
+	}
+	return errors.Join(errs...)
+}
+
+// eval evaluates the policy against input with `opa eval`, and returns the
+// messages the query returned.
+func (p PolicyCondition) eval(input PolicyInput) ([]string, error) {
+	query, opa := p.Query, p.OPAPath
+	if query == "" {
+		query = DefaultPolicyQuery
+	}
+	if opa == "" {
+		opa = "opa"
+	}
+
+	dir, err := os.MkdirTemp("", "structure-policy-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	policy := filepath.Join(dir, "policy.rego")
+	if err := os.WriteFile(policy, []byte(p.Policy), 0o600); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opa, "eval", "--format", "json", "--stdin-input", "--data", policy, query)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running opa: %w\n%s%s", err, stdout.String(), stderr.String())
+	}
+	return parsePolicyResult(stdout.Bytes())
+}
+
+// parsePolicyResult returns the messages in the output of `opa eval --format
+// json`. Messages may be strings, or objects with a msg, as conftest allows.
+func parsePolicyResult(b []byte) ([]string, error) {
+	var out struct {
+		Result []struct {
+			Expressions []struct {
+				Value json.RawMessage `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("parsing opa output: %w", err)
+	}
+	var msgs []string
+	for _, r := range out.Result {
+		for _, e := range r.Expressions {
+			var values []json.RawMessage
+			if err := json.Unmarshal(e.Value, &values); err != nil {
+				return nil, fmt.Errorf("query returned %s, want a set of messages", e.Value)
+			}
+			for _, v := range values {
+				var msg string
+				if err := json.Unmarshal(v, &msg); err == nil {
+					msgs = append(msgs, msg)
+					continue
+				}
+				var obj struct {
+					Msg string `json:"msg"`
+				}
+				if err := json.Unmarshal(v, &obj); err != nil || obj.Msg == "" {
+					msgs = append(msgs, string(v))
+					continue
+				}
+				msgs = append(msgs, obj.Msg)
+			}
+		}
+	}
+	return msgs, nil
+}
@@ -0,0 +1,146 @@
+package structure
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// fakeOPA writes a stand-in for the opa binary, which saves its arguments
+// and input in dir and prints out.
+func fakeOPA(t *testing.T, dir, out string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "out.json"), []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, "opa")
+	script := `#!/bin/sh
+echo "$@" > "` + dir + `/args"
+cat > "` + dir + `/input.json"
+cat "` + dir + `/out.json"
+`
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPolicyCondition(t *testing.T) {
+	img, err := mutate.Config(testImage(t), v1.Config{Env: []string{"FOO=bar"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	opa := fakeOPA(t, dir, `{"result": [{"expressions": [{"value": ["no root", {"msg": "no su"}]}]}]}`)
+	err = PolicyCondition{Policy: "package main", OPAPath: opa}.Check(img)
+	if err == nil {
+		t.Fatal("Check succeeded, want the denials")
+	}
+	for _, want := range []string{"policy denied: no root", "policy denied: no su"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(args)), " "+DefaultPolicyQuery) {
+		t.Errorf("opa args = %q, want the default query", args)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input PolicyInput
+	if err := json.Unmarshal(b, &input); err != nil {
+		t.Fatalf("input isn't a PolicyInput: %v", err)
+	}
+	if got := input.Env["FOO"]; got != "bar" {
+		t.Errorf("env FOO = %q, want bar", got)
+	}
+	if input.Manifest == nil || len(input.Manifest.Layers) != 1 {
+		t.Errorf("manifest = %+v, want one layer", input.Manifest)
+	}
+	for p, want := range map[string]PolicyFile{
+		"/etc":        {Type: "dir", Mode: "0755"},
+		"/etc/passwd": {Type: "file", Mode: "0644", Size: 10},
+		"/usr/bin/su": {Type: "file", Mode: "4755"},
+		"/usr/bin/sh": {Type: "symlink", Mode: "0777", Target: "busybox"},
+	} {
+		if got := input.Files[p]; got != want {
+			t.Errorf("file %s = %+v, want %+v", p, got, want)
+		}
+	}
+
+	// An undefined query passes.
+	opa = fakeOPA(t, t.TempDir(), `{}`)
+	if err := (PolicyCondition{Policy: "package main", Query: "data.main.violation", OPAPath: opa}).Check(img); err != nil {
+		t.Errorf("Check with an undefined query: %v", err)
+	}
+
+	// A query that doesn't return messages fails.
+	opa = fakeOPA(t, t.TempDir(), `{"result": [{"expressions": [{"value": true}]}]}`)
+	if err := (PolicyCondition{Policy: "package main", OPAPath: opa}).Check(img); err == nil {
+		t.Error("Check with a query returning true succeeded")
+	}
+
+	// A cancelled context stops opa.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opa = fakeOPA(t, t.TempDir(), `{}`)
+	if err := (PolicyCondition{Policy: "package main", OPAPath: opa, Context: ctx}).Check(img); err == nil {
+		t.Error("Check with a cancelled context succeeded")
+	}
+}
+
+// TestPolicyConditionOPA evaluates a real policy, if opa is installed.
+func TestPolicyConditionOPA(t *testing.T) {
+	if _, err := exec.LookPath("opa"); err != nil {
+		t.Skip("opa not found")
+	}
+	img := testImage(t)
+	policy := `package main
+
+import rego.v1
+
+deny contains msg if {
+	some path, f in input.files
+	f.type == "file"
+	startswith(f.mode, "4")
+	msg := sprintf("%s is setuid", [path])
+}
+`
+	msgs, err := evalPolicy(t, PolicyCondition{Policy: policy}, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/usr/bin/su is setuid"}; !slices.Equal(msgs, want) {
+		t.Errorf("got %v, want %v", msgs, want)
+	}
+}
+
+// evalPolicy returns the messages of p for i, rather than the error Check
+// makes of them.
+func evalPolicy(t *testing.T, p PolicyCondition, i v1.Image) ([]string, error) {
+	t.Helper()
+	s, err := p.scan(i)
+	if err != nil {
+		return nil, err
+	}
+	if err := walk(i, s.visit); err != nil {
+		return nil, err
+	}
+	return p.eval(s.(*policyScan).input)
+}
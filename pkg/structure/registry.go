@@ -0,0 +1,57 @@
+package structure
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ConditionFactory makes a condition from its options, which are JSON, or
+// nil if none were given.
+type ConditionFactory func(options json.RawMessage) (Condition, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ConditionFactory{}
+)
+
+// RegisterCondition makes a condition available by name, for NewCondition
+// to make, so that programs embedding the provider can add conditions of
+// their own. It's meant to be called from an init function, and panics if
+// name is already registered or factory is nil, like sql.Register.
+func RegisterCondition(name string, factory ConditionFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("structure: RegisterCondition factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic(fmt.Sprintf("structure: RegisterCondition called twice for %q", name))
+	}
+	factories[name] = factory
+}
+
+// NewCondition makes the condition registered as name, with its options.
+func NewCondition(name string, options json.RawMessage) (Condition, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown condition %q", name)
+	}
+	c, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("condition %q: %w", name, err)
+	}
+	return c, nil
+}
+
+// RegisteredConditions returns the names of the registered conditions,
+// sorted.
+func RegisteredConditions() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	return slices.Sorted(maps.Keys(factories))
+}
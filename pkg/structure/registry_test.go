@@ -0,0 +1,69 @@
+package structure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// labelCondition is a condition like one a downstream program would
+// register.
+type labelCondition struct {
+	Key, Value string
+}
+
+func (l labelCondition) Check(i v1.Image) error {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if got := cf.Config.Labels[l.Key]; got != l.Value {
+		return fmt.Errorf("label %q does not match %q (got %q)", l.Key, l.Value, got)
+	}
+	return nil
+}
+
+func TestRegisterCondition(t *testing.T) {
+	RegisterCondition("test_label", func(options json.RawMessage) (Condition, error) {
+		var l labelCondition
+		if err := json.Unmarshal(options, &l); err != nil {
+			return nil, err
+		}
+		if l.Key == "" {
+			return nil, errors.New("key is required")
+		}
+		return l, nil
+	})
+
+	if got := RegisteredConditions(); !slices.Contains(got, "test_label") {
+		t.Errorf("RegisteredConditions() = %v, want test_label", got)
+	}
+
+	c, err := NewCondition("test_label", json.RawMessage(`{"key": "FOO", "value": "bar"}`))
+	if err != nil {
+		t.Fatalf("NewCondition: %v", err)
+	}
+	if want := (labelCondition{Key: "FOO", Value: "bar"}); c != want {
+		t.Errorf("NewCondition = %#v, want %#v", c, want)
+	}
+
+	if _, err := NewCondition("test_label", json.RawMessage(`{}`)); err == nil {
+		t.Error("NewCondition with invalid options succeeded")
+	}
+	if _, err := NewCondition("not_registered", nil); err == nil {
+		t.Error("NewCondition of an unregistered condition succeeded")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("registering a condition twice didn't panic")
+			}
+		}()
+		RegisterCondition("test_label", func(json.RawMessage) (Condition, error) { return nil, nil })
+	}()
+}
@@ -2,12 +2,23 @@ package structure
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
@@ -19,11 +30,48 @@ type Condition interface {
 
 type Conditions []Condition
 
+// Check checks every condition against i, evaluating them concurrently with
+// up to GOMAXPROCS workers.
 func (c Conditions) Check(i v1.Image) error {
-	var errs []error
+	return c.CheckParallel(i, runtime.GOMAXPROCS(0))
+}
+
+// CheckParallel checks every condition against i, evaluating them
+// concurrently with up to n workers. Errors are returned in the order of the
+// conditions, however they are evaluated.
+//
+// If more than one condition reads the filesystem, the image's filesystem is
+// flattened into a temporary file once, and shared by those conditions,
+// rather than each one fetching and extracting every layer.
+func (c Conditions) CheckParallel(i v1.Image, n int) error {
+	walkers := 0
 	for _, cond := range c {
-		errs = append(errs, cond.Check(i))
+		if _, ok := cond.(scanner); ok {
+			walkers++
+		}
+	}
+	if walkers > 1 {
+		e, err := extract(i)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(e.path)
+		i = e
 	}
+
+	errs := make([]error, len(c))
+	sem := make(chan struct{}, max(n, 1))
+	var wg sync.WaitGroup
+	for idx, cond := range c {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[idx] = cond.Check(i)
+		}()
+	}
+	wg.Wait()
 	return errors.Join(errs...)
 }
 
@@ -55,82 +103,718 @@ func splitEnvs(in []string) map[string]string {
 	return out
 }
 
+// HealthcheckCondition checks the image config's HEALTHCHECK.
+type HealthcheckCondition struct {
+	// Absent requires the image to have no healthcheck, or a disabled one.
+	// Otherwise, it must have one, and the fields below are checked.
+	Absent bool
+	// TestRegex, if set, must match the healthcheck's test, as its words
+	// joined by spaces, e.g. "CMD-SHELL curl -f http://localhost/".
+	TestRegex string
+	// MinInterval and MaxInterval, if set, bound the healthcheck's interval.
+	// An image that doesn't set one has the default, DefaultHealthcheckInterval.
+	MinInterval, MaxInterval time.Duration
+}
+
+// DefaultHealthcheckInterval is the interval of a healthcheck that doesn't
+// set one, as Docker runs it.
+const DefaultHealthcheckInterval = 30 * time.Second
+
+func (h HealthcheckCondition) Check(i v1.Image) error {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	hc := cf.Config.Healthcheck
+	if hc != nil && (len(hc.Test) == 0 || hc.Test[0] == "NONE") {
+		// NONE disables the healthcheck. An empty test inherits the base
+		// image's, which would already be in this config if it had one.
+		hc = nil
+	}
+	if h.Absent {
+		if hc != nil {
+			return fmt.Errorf("healthcheck %q is set, want none", strings.Join(hc.Test, " "))
+		}
+		return nil
+	}
+	if hc == nil {
+		return errors.New("healthcheck is not set")
+	}
+
+	var errs []error
+	test := strings.Join(hc.Test, " ")
+	if h.TestRegex != "" {
+		re, err := regexp.Compile(h.TestRegex)
+		if err != nil {
+			return fmt.Errorf("invalid healthcheck test regexp %q: %w", h.TestRegex, err)
+		}
+		if !re.MatchString(test) {
+			errs = append(errs, fmt.Errorf("healthcheck test %q does not match regexp %q", test, h.TestRegex))
+		}
+	}
+	interval := hc.Interval
+	if interval == 0 {
+		interval = DefaultHealthcheckInterval
+	}
+	if h.MinInterval > 0 && interval < h.MinInterval {
+		errs = append(errs, fmt.Errorf("healthcheck interval %s is less than %s", interval, h.MinInterval))
+	}
+	if h.MaxInterval > 0 && interval > h.MaxInterval {
+		errs = append(errs, fmt.Errorf("healthcheck interval %s is more than %s", interval, h.MaxInterval))
+	}
+	return errors.Join(errs...)
+}
+
+// ExposedPortsCondition checks the ports that the image config exposes.
+type ExposedPortsCondition struct {
+	// Want maps each port, e.g. "8080/tcp", to whether it must be exposed,
+	// or must not be. A port without a protocol is TCP, as in EXPOSE.
+	Want map[string]bool
+}
+
+func (p ExposedPortsCondition) Check(i v1.Image) error {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, port := range slices.Sorted(maps.Keys(p.Want)) {
+		name := port
+		if !strings.Contains(name, "/") {
+			name += "/tcp"
+		}
+		_, ok := cf.Config.ExposedPorts[name]
+		switch want := p.Want[port]; {
+		case want && !ok:
+			errs = append(errs, fmt.Errorf("port %q is not exposed", name))
+		case !want && ok:
+			errs = append(errs, fmt.Errorf("port %q is exposed", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VolumesCondition checks the volumes that the image config declares.
+type VolumesCondition struct {
+	// Want maps each volume's path to whether it must be declared, or must
+	// not be.
+	Want map[string]bool
+}
+
+func (v VolumesCondition) Check(i v1.Image) error {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, vol := range slices.Sorted(maps.Keys(v.Want)) {
+		_, ok := cf.Config.Volumes[vol]
+		switch want := v.Want[vol]; {
+		case want && !ok:
+			errs = append(errs, fmt.Errorf("volume %q is not declared", vol))
+		case !want && ok:
+			errs = append(errs, fmt.Errorf("volume %q is declared", vol))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 type FilesCondition struct {
 	Want map[string]File
 }
 
 type File struct {
 	Regex string
-	// TODO: support filemode
-	ran bool
+	// MaxSize, if positive, is the largest file, in bytes, that Regex is
+	// matched against: larger files fail the check, as do binary files
+	// unless AllowBinary is set. Otherwise, Regex is matched against any
+	// file.
+	MaxSize int64
+	// AllowBinary matches Regex against binary files when MaxSize is set.
+	AllowBinary bool
+	// Mode, if set, is the expected permission bits of the file, including
+	// setuid, setgid and sticky bits.
+	Mode *fs.FileMode
+	// Optional files may be absent, but are checked if present.
+	Optional bool
 }
 
-func (f FilesCondition) Check(i v1.Image) error {
-	ls, err := i.Layers()
+func (f FilesCondition) Check(i v1.Image) error { return check(i, f) }
+
+func (f FilesCondition) scan(v1.Image) (scan, error) {
+	return &filesScan{want: f.Want, found: make(map[string]bool, len(f.Want))}, nil
+}
+
+type filesScan struct {
+	want  map[string]File
+	found map[string]bool
+	errs  []error
+}
+
+func (s *filesScan) visit(hdr *tar.Header, content func() ([]byte, error)) (bool, error) {
+	want, ok := s.want[hdr.Name]
+	if !ok {
+		// We don't care about this file at all, on to the next.
+		return false, nil
+	}
+	if want.Regex != "" {
+		if err := matchFile(hdr, content, want); err != nil {
+			s.errs = append(s.errs, err)
+		}
+	}
+	if want.Mode != nil {
+		if got := headerMode(hdr); got != *want.Mode {
+			s.errs = append(s.errs, fmt.Errorf("file %q has mode %s, want %s", hdr.Name, FormatMode(got), FormatMode(*want.Mode)))
+		}
+	}
+	// At least mark that we found this file we cared about.
+	s.found[hdr.Name] = true
+
+	// If all the checks have run, we can stop early.
+	// This might not be strictly correct, since tar files can have multiple
+	// files with the same name, and the last one wins; in practice, this is
+	// unlikely to be a problem, and the optimization is worth it.
+	return len(s.found) == len(s.want), nil
+}
+
+// matchFile checks that the contents of the file hdr match want.Regex,
+// within the limits that want sets, if any.
+func matchFile(hdr *tar.Header, content func() ([]byte, error), want File) error {
+	limited := want.MaxSize > 0
+	if limited && hdr.Size > want.MaxSize {
+		return fmt.Errorf("file %q is %d bytes, larger than the %d byte limit for matching regexp %q", hdr.Name, hdr.Size, want.MaxSize, want.Regex)
+	}
+	// We care about the contents, so read them and regexp.
+	b, err := content()
 	if err != nil {
 		return err
 	}
-	var rc io.ReadCloser
-	// If there's only one layer, we don't need to extract it.
-	if len(ls) == 1 {
-		rc, err = ls[0].Uncompressed()
+	binary := isBinary(b)
+	if binary && limited && !want.AllowBinary {
+		return fmt.Errorf("file %q is binary, so isn't matched against regexp %q", hdr.Name, want.Regex)
+	}
+	if regexp.MustCompile(want.Regex).Match(b) {
+		return nil
+	}
+	if binary {
+		return fmt.Errorf("file %q does not match regexp %q", hdr.Name, want.Regex)
+	}
+	return fmt.Errorf("file %q does not match regexp %q, got:\n%s", hdr.Name, want.Regex, b)
+}
+
+// isBinary reports whether b looks like the contents of a binary file: whether
+// its first 8000 bytes include a NUL, as git decides.
+func isBinary(b []byte) bool {
+	return bytes.IndexByte(b[:min(len(b), 8000)], 0) >= 0
+}
+
+func (s *filesScan) result() error {
+	for path, want := range s.want {
+		if !s.found[path] && !want.Optional {
+			s.errs = append(s.errs, fmt.Errorf("file %q not found", path))
+		}
+	}
+	return errors.Join(s.errs...)
+}
+
+type DirsCondition struct {
+	Want map[string]Dir
+}
+
+type Dir struct {
+	// Mode, if set, is the expected permission bits of the directory. If it
+	// isn't, only the directory's existence is checked.
+	Mode *fs.FileMode
+	// Recursive also checks the mode of every directory beneath this one.
+	Recursive bool
+	// ExistsOnly checks that the directory exists, ignoring Mode and
+	// Recursive.
+	ExistsOnly bool
+}
+
+func (d DirsCondition) Check(i v1.Image) error { return check(i, d) }
+
+func (d DirsCondition) scan(v1.Image) (scan, error) {
+	return &dirsScan{want: d.Want, found: make(map[string]bool, len(d.Want))}, nil
+}
+
+type dirsScan struct {
+	want  map[string]Dir
+	found map[string]bool
+	errs  []error
+}
+
+func (s *dirsScan) visit(hdr *tar.Header, _ func() ([]byte, error)) (bool, error) {
+	for path, want := range s.want {
+		switch {
+		case hdr.Name == path:
+			s.found[path] = true
+			if hdr.Typeflag != tar.TypeDir {
+				s.errs = append(s.errs, fmt.Errorf("%q is not a directory", path))
+				continue
+			}
+		case want.Recursive && !want.ExistsOnly && hdr.Typeflag == tar.TypeDir && under(hdr.Name, path):
+		default:
+			continue
+		}
+		if want.Mode != nil && !want.ExistsOnly {
+			if got := headerMode(hdr); got != *want.Mode {
+				s.errs = append(s.errs, fmt.Errorf("directory %q has mode %s, want %s", hdr.Name, FormatMode(got), FormatMode(*want.Mode)))
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *dirsScan) result() error {
+	for path := range s.want {
+		if !s.found[path] {
+			s.errs = append(s.errs, fmt.Errorf("directory %q not found", path))
+		}
+	}
+	return errors.Join(s.errs...)
+}
+
+// PermissionsCondition checks that nothing beneath each path is writable by
+// group or others, other than the paths listed in Override.
+type PermissionsCondition struct {
+	Want map[string]Permission
+}
+
+type Permission struct {
+	Override []string
+}
+
+func (p PermissionsCondition) Check(i v1.Image) error { return check(i, p) }
+
+func (p PermissionsCondition) scan(v1.Image) (scan, error) {
+	return &permissionsScan{want: p.Want}, nil
+}
+
+type permissionsScan struct {
+	want map[string]Permission
+	errs []error
+}
+
+func (s *permissionsScan) visit(hdr *tar.Header, _ func() ([]byte, error)) (bool, error) {
+	if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+		return false, nil
+	}
+	for path, want := range s.want {
+		if hdr.Name != path && !under(hdr.Name, path) {
+			continue
+		}
+		if slices.Contains(want.Override, hdr.Name) {
+			continue
+		}
+		if mode := headerMode(hdr); mode&0o022 != 0 {
+			s.errs = append(s.errs, fmt.Errorf("%q is writable by group or others (mode %s)", hdr.Name, FormatMode(mode)))
+		}
+	}
+	return false, nil
+}
+
+func (s *permissionsScan) result() error { return errors.Join(s.errs...) }
+
+// Shells are the executables that a distroless image mustn't have, so that
+// nothing can be run in it but its own programs.
+var Shells = []string{"sh", "bash", "ash", "dash", "zsh", "busybox"}
+
+// ExecutableDirs are the directories that forbidden executables are looked
+// for in, as well as those on the image's PATH.
+var ExecutableDirs = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/local/bin", "/usr/local/sbin"}
+
+// ForbiddenExecutablesCondition checks that none of Names are in
+// ExecutableDirs, or the directories on the image's PATH. Use Shells to
+// check that an image is distroless.
+type ForbiddenExecutablesCondition struct {
+	// Names are executable names, like "sh", or absolute paths, like
+	// "/opt/tools/bin/sh", which are only looked for there.
+	Names []string
+}
+
+func (f ForbiddenExecutablesCondition) Check(i v1.Image) error { return check(i, f) }
+
+func (f ForbiddenExecutablesCondition) scan(i v1.Image) (scan, error) {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for _, d := range ExecutableDirs {
+		dirs[d] = true
+	}
+	if p, ok := splitEnvs(cf.Config.Env)["PATH"]; ok {
+		for _, d := range strings.Split(p, ":") {
+			if path.IsAbs(d) {
+				dirs[path.Clean(d)] = true
+			}
+		}
+	}
+	s := &forbiddenScan{dirs: dirs, names: map[string]bool{}, paths: map[string]bool{}}
+	for _, n := range f.Names {
+		if path.IsAbs(n) {
+			s.paths[path.Clean(n)] = true
+		} else {
+			s.names[n] = true
+		}
+	}
+	return s, nil
+}
+
+type forbiddenScan struct {
+	dirs, names, paths map[string]bool
+	errs               []error
+}
+
+func (s *forbiddenScan) visit(hdr *tar.Header, _ func() ([]byte, error)) (bool, error) {
+	if hdr.Typeflag == tar.TypeDir {
+		return false, nil
+	}
+	if s.paths[hdr.Name] || (s.names[path.Base(hdr.Name)] && s.dirs[path.Dir(hdr.Name)]) {
+		s.errs = append(s.errs, fmt.Errorf("forbidden executable %q found", hdr.Name))
+	}
+	return false, nil
+}
+
+func (s *forbiddenScan) result() error { return errors.Join(s.errs...) }
+
+// GoldenCondition checks that the image's files are exactly those listed in a
+// golden manifest, as parsed by ParseGolden, reporting files that were added,
+// removed or changed.
+type GoldenCondition struct {
+	Want map[string]GoldenEntry
+}
+
+// GoldenEntry is a line of a golden manifest: the mode of a path, and what
+// it is, which is one of:
+//
+//   - "dir" for a directory
+//   - a size in bytes, or "sha256:<hex>", for a regular file
+//   - "link:<target>" for a symlink
+//   - "hardlink:<target>" for a hard link
+//   - "other" for anything else, like a device
+type GoldenEntry struct {
+	Mode fs.FileMode
+	What string
+}
+
+func (e GoldenEntry) String() string { return FormatMode(e.Mode) + " " + e.What }
+
+// ParseGolden parses a golden manifest, whose lines are a mode, what the
+// path is, and the path, e.g. "0644 11 /etc/passwd". Blank lines and lines
+// starting with # are ignored.
+func ParseGolden(r io.Reader) (GoldenCondition, error) {
+	g := GoldenCondition{Want: map[string]GoldenEntry{}}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || !path.IsAbs(fields[2]) {
+			return GoldenCondition{}, fmt.Errorf("line %d: want a mode, what the path is, and an absolute path, got %q", n, line)
+		}
+		mode, err := ParseMode(fields[0])
+		if err != nil {
+			return GoldenCondition{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		p := path.Clean(fields[2])
+		if _, ok := g.Want[p]; ok {
+			return GoldenCondition{}, fmt.Errorf("line %d: %q is listed more than once", n, p)
+		}
+		g.Want[p] = GoldenEntry{Mode: mode, What: fields[1]}
+	}
+	return g, sc.Err()
+}
+
+// Listing returns a golden manifest of the files in i, with the sizes of
+// regular files, or their hashes if hashes is set.
+func Listing(i v1.Image, hashes bool) ([]byte, error) {
+	var buf bytes.Buffer
+	err := walk(i, func(hdr *tar.Header, content func() ([]byte, error)) (bool, error) {
+		e, err := goldenEntry(hdr, content, hashes)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(&buf, "%s %s\n", e, hdr.Name)
+		return false, nil
+	})
+	return buf.Bytes(), err
+}
+
+// goldenEntry returns the golden manifest entry of hdr, with the hash of its
+// contents rather than its size if hash is set.
+func goldenEntry(hdr *tar.Header, content func() ([]byte, error), hash bool) (GoldenEntry, error) {
+	e := GoldenEntry{Mode: headerMode(hdr)}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		e.What = "dir"
+	case tar.TypeReg:
+		if !hash {
+			e.What = strconv.FormatInt(hdr.Size, 10)
+			break
+		}
+		b, err := content()
 		if err != nil {
+			return GoldenEntry{}, err
+		}
+		e.What = fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	case tar.TypeSymlink:
+		e.What = "link:" + hdr.Linkname
+	case tar.TypeLink:
+		e.What = "hardlink:" + path.Clean("/"+hdr.Linkname)
+	default:
+		e.What = "other"
+	}
+	return e, nil
+}
+
+func (g GoldenCondition) Check(i v1.Image) error { return check(i, g) }
+
+func (g GoldenCondition) scan(v1.Image) (scan, error) {
+	return &goldenScan{want: g.Want, got: make(map[string]GoldenEntry, len(g.Want))}, nil
+}
+
+type goldenScan struct {
+	want, got map[string]GoldenEntry
+}
+
+func (s *goldenScan) visit(hdr *tar.Header, content func() ([]byte, error)) (bool, error) {
+	want, ok := s.want[hdr.Name]
+	e, err := goldenEntry(hdr, content, ok && strings.HasPrefix(want.What, "sha256:"))
+	if err != nil {
+		return false, err
+	}
+	s.got[hdr.Name] = e
+	return false, nil
+}
+
+func (s *goldenScan) result() error {
+	var errs []error
+	for _, p := range slices.Sorted(maps.Keys(s.got)) {
+		got := s.got[p]
+		want, ok := s.want[p]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("added: %s %s", got, p))
+		case got != want:
+			errs = append(errs, fmt.Errorf("changed: %s %s, want %s", got, p, want))
+		}
+	}
+	for _, p := range slices.Sorted(maps.Keys(s.want)) {
+		if _, ok := s.got[p]; !ok {
+			errs = append(errs, fmt.Errorf("removed: %s %s", s.want[p], p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckStreaming checks every condition against i, like Check, but in a
+// single pass over the image's flattened filesystem, handing each entry to
+// every condition that reads the filesystem. Nothing is extracted to disk,
+// and only the contents of files that a condition asks for are buffered, so
+// it suits images too big to extract.
+func (c Conditions) CheckStreaming(i v1.Image) error {
+	errs := make([]error, len(c))
+	scans := map[int]scan{}
+	for idx, cond := range c {
+		if sc, ok := cond.(scanner); ok {
+			s, err := sc.scan(i)
+			if err != nil {
+				errs[idx] = err
+				continue
+			}
+			scans[idx] = s
+		} else {
+			errs[idx] = cond.Check(i)
+		}
+	}
+	if len(scans) > 0 {
+		done, remaining := make(map[int]bool, len(scans)), len(scans)
+		if err := walk(i, func(hdr *tar.Header, content func() ([]byte, error)) (bool, error) {
+			for idx, s := range scans {
+				if done[idx] {
+					continue
+				}
+				stop, err := s.visit(hdr, content)
+				if err != nil {
+					return false, err
+				}
+				if stop {
+					done[idx] = true
+					remaining--
+				}
+			}
+			return remaining == 0, nil
+		}); err != nil {
 			return err
 		}
-	} else {
-		rc = mutate.Extract(i)
+		for idx, s := range scans {
+			errs[idx] = s.result()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scanner is a condition that's checked by visiting the entries of the
+// image's flattened filesystem.
+type scanner interface {
+	// scan starts checking the condition against the filesystem of i,
+	// which it may read the config of.
+	scan(i v1.Image) (scan, error)
+}
+
+// scan is the state of checking a condition against a filesystem.
+type scan interface {
+	// visit checks an entry, calling content if it needs the entry's
+	// contents. It returns true once it doesn't need any more entries.
+	visit(hdr *tar.Header, content func() ([]byte, error)) (bool, error)
+	// result returns the outcome of the check, once the entries are visited.
+	result() error
+}
+
+// check runs sc over the flattened filesystem of i.
+func check(i v1.Image, sc scanner) error {
+	s, err := sc.scan(i)
+	if err != nil {
+		return err
+	}
+	if err := walk(i, s.visit); err != nil {
+		return err
+	}
+	return s.result()
+}
+
+// extracted is an image whose flattened filesystem has been written to the
+// tar file at path, for walk to read instead of the image's layers.
+type extracted struct {
+	v1.Image
+	path string
+}
+
+// extract writes the flattened filesystem of i to a temporary file. The
+// caller removes the file when it's done.
+func extract(i v1.Image) (*extracted, error) {
+	rc, err := flatten(i)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp("", "structure-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &extracted{Image: i, path: f.Name()}, nil
+}
+
+// flatten returns the flattened filesystem of i as a tar stream.
+func flatten(i v1.Image) (io.ReadCloser, error) {
+	if e, ok := i.(*extracted); ok {
+		return os.Open(e.path)
+	}
+	ls, err := i.Layers()
+	if err != nil {
+		return nil, err
 	}
+	// If there's only one layer, we don't need to extract it.
+	if len(ls) == 1 {
+		return ls[0].Uncompressed()
+	}
+	return mutate.Extract(i), nil
+}
 
+// walk calls fn for each entry in the flattened filesystem of i, with names
+// made absolute, until fn returns true or an error. The entry's contents are
+// read the first time content is called, and kept for later calls.
+func walk(i v1.Image, fn func(hdr *tar.Header, content func() ([]byte, error)) (bool, error)) error {
+	rc, err := flatten(i)
+	if err != nil {
+		return err
+	}
 	defer rc.Close()
+
 	tr := tar.NewReader(rc)
-	errs := []error{}
-L:
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
-			break
+			return nil
 		} else if err != nil {
 			return err
 		}
-		if !strings.HasPrefix(hdr.Name, "/") {
-			hdr.Name = "/" + hdr.Name
-		}
-
-		if _, found := f.Want[hdr.Name]; !found {
-			// We don't care about this file at all, on to the next.
-			continue
-		}
-		if f.Want[hdr.Name].Regex != "" {
-			// We care about the contents, so read and buffer them and regexp.
-			var buf bytes.Buffer
-			if _, err := io.Copy(&buf, tr); err != nil {
-				return err
-			}
-			if !regexp.MustCompile(f.Want[hdr.Name].Regex).Match(buf.Bytes()) {
-				errs = append(errs, fmt.Errorf("file %q does not match regexp %q, got:\n%s", hdr.Name, f.Want[hdr.Name].Regex, buf.String()))
+		hdr.Name = path.Clean("/" + hdr.Name)
+		var (
+			b    []byte
+			rerr error
+			read bool
+		)
+		content := func() ([]byte, error) {
+			if !read {
+				b, rerr = io.ReadAll(tr)
+				read = true
 			}
+			return b, rerr
 		}
-		// At least mark that we found this file we cared about.
-		f.Want[hdr.Name] = File{
-			Regex: f.Want[hdr.Name].Regex,
-			ran:   true,
+		if stop, err := fn(hdr, content); err != nil {
+			return err
+		} else if stop {
+			return nil
 		}
+	}
+}
 
-		// If all the checks have run, we can stop early.
-		// This might not be strictly correct, since tar files can have multiple
-		// files with the same name, and the last one wins; in practice, this is
-		// unlikely to be a problem, and the optimization is worth it.
-		for _, f := range f.Want {
-			if !f.ran {
-				continue L
-			}
-		}
-		break
+// under reports whether name is strictly beneath the directory dir.
+func under(name, dir string) bool {
+	return strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// headerMode returns the permission bits of hdr, including setuid, setgid and
+// sticky bits.
+func headerMode(hdr *tar.Header) fs.FileMode {
+	return hdr.FileInfo().Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+// ParseMode parses an octal mode string like "0755" or "4755" into permission
+// bits, including setuid, setgid and sticky bits.
+func ParseMode(s string) (fs.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q: must be octal, e.g. 0755", s)
 	}
-	for path, f := range f.Want {
-		if !f.ran {
-			errs = append(errs, fmt.Errorf("file %q not found", path))
-		}
+	mode := fs.FileMode(n) & fs.ModePerm
+	if n&0o4000 != 0 {
+		mode |= fs.ModeSetuid
 	}
-	return errors.Join(errs...)
+	if n&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if n&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode, nil
+}
+
+// FormatMode formats mode as an octal string, the inverse of ParseMode.
+func FormatMode(mode fs.FileMode) string {
+	n := uint32(mode & fs.ModePerm)
+	if mode&fs.ModeSetuid != 0 {
+		n |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		n |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		n |= 0o1000
+	}
+	return fmt.Sprintf("%04o", n)
 }
@@ -0,0 +1,510 @@
+package structure
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func testImage(t *testing.T) v1.Image {
+	t.Helper()
+	contents := map[string]string{
+		"etc/passwd":     "root:x:0:0",
+		"etc/bundle.pem": strings.Repeat("a", 1<<20+1),
+		"usr/bin/app":    "\x7fELF\x00root",
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "etc/bundle.pem", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "tmp/", Typeflag: tar.TypeDir, Mode: 0o1777},
+		{Name: "usr/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "usr/bin/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "usr/bin/su", Typeflag: tar.TypeReg, Mode: 0o4755},
+		{Name: "usr/bin/app", Typeflag: tar.TypeReg, Mode: 0o755},
+		{Name: "usr/bin/sh", Typeflag: tar.TypeSymlink, Linkname: "busybox", Mode: 0o777},
+		{Name: "usr/lib/", Typeflag: tar.TypeDir, Mode: 0o775},
+	} {
+		hdr.Size = int64(len(contents[hdr.Name]))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents[hdr.Name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func mode(t *testing.T, s string) *fs.FileMode {
+	t.Helper()
+	m, err := ParseMode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func TestConditions(t *testing.T) {
+	img := testImage(t)
+
+	for _, c := range []struct {
+		desc    string
+		cond    Condition
+		wantErr string
+	}{{
+		desc: "file regex and mode",
+		cond: FilesCondition{Want: map[string]File{
+			"/etc/passwd": {Regex: "root:.*", Mode: mode(t, "0644")},
+			"/usr/bin/su": {Mode: mode(t, "4755")},
+		}},
+	}, {
+		desc:    "file mode mismatch",
+		cond:    FilesCondition{Want: map[string]File{"/etc/passwd": {Mode: mode(t, "0600")}}},
+		wantErr: `file "/etc/passwd" has mode 0644, want 0600`,
+	}, {
+		desc: "optional file",
+		cond: FilesCondition{Want: map[string]File{"/etc/shadow": {Optional: true}}},
+	}, {
+		desc:    "missing file",
+		cond:    FilesCondition{Want: map[string]File{"/etc/shadow": {}}},
+		wantErr: `file "/etc/shadow" not found`,
+	}, {
+		desc: "large file without a size limit",
+		cond: FilesCondition{Want: map[string]File{"/etc/bundle.pem": {Regex: "^a+$"}}},
+	}, {
+		desc:    "file over the size limit",
+		cond:    FilesCondition{Want: map[string]File{"/etc/bundle.pem": {Regex: "a+", MaxSize: 1 << 20}}},
+		wantErr: `file "/etc/bundle.pem" is 1048577 bytes, larger than the 1048576 byte limit`,
+	}, {
+		desc: "file within a raised size limit",
+		cond: FilesCondition{Want: map[string]File{"/etc/bundle.pem": {Regex: "^a+$", MaxSize: 2 << 20}}},
+	}, {
+		desc: "binary file without a size limit",
+		cond: FilesCondition{Want: map[string]File{"/usr/bin/app": {Regex: "root"}}},
+	}, {
+		desc:    "binary file with a size limit",
+		cond:    FilesCondition{Want: map[string]File{"/usr/bin/app": {Regex: "root", MaxSize: 1 << 20}}},
+		wantErr: `file "/usr/bin/app" is binary`,
+	}, {
+		desc: "binary file allowed",
+		cond: FilesCondition{Want: map[string]File{"/usr/bin/app": {Regex: "root", MaxSize: 1 << 20, AllowBinary: true}}},
+	}, {
+		desc:    "binary file mismatch",
+		cond:    FilesCondition{Want: map[string]File{"/usr/bin/app": {Regex: "nonroot", AllowBinary: true}}},
+		wantErr: `file "/usr/bin/app" does not match regexp "nonroot"`,
+	}, {
+		desc: "dir mode",
+		cond: DirsCondition{Want: map[string]Dir{"/tmp": {Mode: mode(t, "1777")}}},
+	}, {
+		desc:    "recursive dir mode",
+		cond:    DirsCondition{Want: map[string]Dir{"/usr": {Mode: mode(t, "0755"), Recursive: true}}},
+		wantErr: `directory "/usr/lib" has mode 0775, want 0755`,
+	}, {
+		desc: "dir exists only",
+		cond: DirsCondition{Want: map[string]Dir{"/usr": {Mode: mode(t, "0700"), Recursive: true, ExistsOnly: true}}},
+	}, {
+		desc: "dir without mode",
+		cond: DirsCondition{Want: map[string]Dir{"/usr": {Recursive: true}}},
+	}, {
+		desc:    "not a dir",
+		cond:    DirsCondition{Want: map[string]Dir{"/etc/passwd": {}}},
+		wantErr: `"/etc/passwd" is not a directory`,
+	}, {
+		desc:    "missing dir",
+		cond:    DirsCondition{Want: map[string]Dir{"/var": {}}},
+		wantErr: `directory "/var" not found`,
+	}, {
+		desc: "permissions",
+		cond: PermissionsCondition{Want: map[string]Permission{"/etc": {}}},
+	}, {
+		desc:    "permissions violation",
+		cond:    PermissionsCondition{Want: map[string]Permission{"/usr": {}}},
+		wantErr: `"/usr/lib" is writable by group or others (mode 0775)`,
+	}, {
+		desc: "permissions override",
+		cond: PermissionsCondition{Want: map[string]Permission{"/usr": {Override: []string{"/usr/lib"}}}},
+	}, {
+		desc:    "shell",
+		cond:    ForbiddenExecutablesCondition{Names: Shells},
+		wantErr: `forbidden executable "/usr/bin/sh" found`,
+	}, {
+		desc:    "forbidden path",
+		cond:    ForbiddenExecutablesCondition{Names: []string{"/usr/bin/app"}},
+		wantErr: `forbidden executable "/usr/bin/app" found`,
+	}, {
+		desc: "forbidden name outside executable dirs",
+		cond: ForbiddenExecutablesCondition{Names: []string{"passwd", "/usr/bin/curl"}},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			for engine, check := range map[string]func(v1.Image) error{
+				"check":     c.cond.Check,
+				"streaming": Conditions{c.cond}.CheckStreaming,
+			} {
+				err := check(img)
+				switch {
+				case c.wantErr == "" && err != nil:
+					t.Errorf("%s: unexpected error: %v", engine, err)
+				case c.wantErr != "" && err == nil:
+					t.Errorf("%s: expected error %q, got nil", engine, c.wantErr)
+				case c.wantErr != "" && !strings.Contains(err.Error(), c.wantErr):
+					t.Errorf("%s: got error %q, want %q", engine, err, c.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"0755", "644", "4755", "1777", "0000"} {
+		m, err := ParseMode(s)
+		if err != nil {
+			t.Errorf("ParseMode(%q): %v", s, err)
+			continue
+		}
+		if got, want := FormatMode(m), strings.Repeat("0", 4-len(s))+s; got != want {
+			t.Errorf("FormatMode(ParseMode(%q)) = %q, want %q", s, got, want)
+		}
+	}
+	for _, s := range []string{"", "0999", "0o777", "17777", "rwx"} {
+		if _, err := ParseMode(s); err == nil {
+			t.Errorf("ParseMode(%q): expected error", s)
+		}
+	}
+}
+
+// twoLayerImage returns an image with files /a and /b in separate layers, so
+// checking its filesystem means extracting them, and a count of how many
+// times its layers have been read.
+func twoLayerImage(t *testing.T) (v1.Image, *atomic.Int32) {
+	t.Helper()
+	opens := &atomic.Int32{}
+	var img v1.Image = empty.Image
+	for _, name := range []string{"a", "b"} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			opens.Add(1)
+			return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err = mutate.AppendLayers(img, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Compute the digests up front, so only reads of the filesystem count.
+	if _, err := img.Digest(); err != nil {
+		t.Fatal(err)
+	}
+	opens.Store(0)
+	return img, opens
+}
+
+func TestConditionsParallel(t *testing.T) {
+	img, opens := twoLayerImage(t)
+
+	var conds Conditions
+	var want []string
+	for i := range 20 {
+		f := "/a"
+		if i%3 == 0 {
+			f = fmt.Sprintf("/missing-%d", i)
+			want = append(want, fmt.Sprintf("file %q not found", f))
+		}
+		conds = append(conds, FilesCondition{Want: map[string]File{f: {}}})
+	}
+
+	for _, n := range []int{1, 4, 100} {
+		opens.Store(0)
+		err := conds.CheckParallel(img, n)
+		if err == nil {
+			t.Fatalf("CheckParallel(%d): expected error", n)
+		}
+		// Errors are in the order of the conditions.
+		if got := err.Error(); got != strings.Join(want, "\n") {
+			t.Errorf("CheckParallel(%d) = %q, want %q", n, got, strings.Join(want, "\n"))
+		}
+		// Each layer is only read once, to extract it.
+		if got := opens.Load(); got != 2 {
+			t.Errorf("CheckParallel(%d) read layers %d times, want 2", n, got)
+		}
+	}
+}
+
+func TestConditionsStreaming(t *testing.T) {
+	img, opens := twoLayerImage(t)
+
+	conds := Conditions{
+		EnvCondition{Want: map[string]string{"FOO": "bar"}},
+		// Both conditions read /a, whose contents are shared.
+		FilesCondition{Want: map[string]File{"/a": {Regex: "^a$"}}},
+		FilesCondition{Want: map[string]File{"/a": {Regex: "^b$"}, "/b": {}}},
+		DirsCondition{Want: map[string]Dir{"/c": {}}},
+		PermissionsCondition{Want: map[string]Permission{"/": {}}},
+	}
+	err := conds.CheckStreaming(img)
+	if err == nil {
+		t.Fatal("CheckStreaming: expected error")
+	}
+	want := strings.Join([]string{
+		`env "FOO" does not match "bar" (got "")`,
+		"file \"/a\" does not match regexp \"^b$\", got:\na",
+		`directory "/c" not found`,
+	}, "\n")
+	if got := err.Error(); got != want {
+		t.Errorf("CheckStreaming = %q, want %q", got, want)
+	}
+	// Each layer is read once, in a single pass.
+	if got := opens.Load(); got != 2 {
+		t.Errorf("CheckStreaming read layers %d times, want 2", got)
+	}
+}
+
+func TestHealthcheckCondition(t *testing.T) {
+	withHealthcheck := func(hc *v1.HealthConfig) v1.Image {
+		img, err := mutate.Config(empty.Image, v1.Config{Healthcheck: hc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	curl := &v1.HealthConfig{Test: []string{"CMD-SHELL", "curl -f http://localhost/"}, Interval: 10 * time.Second}
+
+	for _, c := range []struct {
+		desc    string
+		img     v1.Image
+		cond    HealthcheckCondition
+		wantErr string
+	}{{
+		desc: "present",
+		img:  withHealthcheck(curl),
+		cond: HealthcheckCondition{TestRegex: "^CMD-SHELL curl ", MinInterval: 5 * time.Second, MaxInterval: time.Minute},
+	}, {
+		desc:    "missing",
+		img:     withHealthcheck(nil),
+		wantErr: "healthcheck is not set",
+	}, {
+		desc:    "disabled",
+		img:     withHealthcheck(&v1.HealthConfig{Test: []string{"NONE"}}),
+		wantErr: "healthcheck is not set",
+	}, {
+		desc: "absent",
+		img:  withHealthcheck(&v1.HealthConfig{Test: []string{"NONE"}}),
+		cond: HealthcheckCondition{Absent: true},
+	}, {
+		desc:    "forbidden",
+		img:     withHealthcheck(curl),
+		cond:    HealthcheckCondition{Absent: true},
+		wantErr: `healthcheck "CMD-SHELL curl -f http://localhost/" is set, want none`,
+	}, {
+		desc:    "test mismatch",
+		img:     withHealthcheck(curl),
+		cond:    HealthcheckCondition{TestRegex: "wget"},
+		wantErr: `healthcheck test "CMD-SHELL curl -f http://localhost/" does not match regexp "wget"`,
+	}, {
+		desc:    "interval too short",
+		img:     withHealthcheck(curl),
+		cond:    HealthcheckCondition{MinInterval: 15 * time.Second},
+		wantErr: "healthcheck interval 10s is less than 15s",
+	}, {
+		desc:    "default interval too long",
+		img:     withHealthcheck(&v1.HealthConfig{Test: []string{"CMD", "/healthz"}}),
+		cond:    HealthcheckCondition{MaxInterval: 20 * time.Second},
+		wantErr: "healthcheck interval 30s is more than 20s",
+	}, {
+		desc:    "invalid regexp",
+		img:     withHealthcheck(curl),
+		cond:    HealthcheckCondition{TestRegex: "("},
+		wantErr: "invalid healthcheck test regexp",
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			err := c.cond.Check(c.img)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case c.wantErr != "" && err == nil:
+				t.Errorf("expected error %q, got nil", c.wantErr)
+			case c.wantErr != "" && !strings.Contains(err.Error(), c.wantErr):
+				t.Errorf("got error %q, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestPortsAndVolumesConditions(t *testing.T) {
+	img, err := mutate.Config(empty.Image, v1.Config{
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}},
+		Volumes:      map[string]struct{}{"/data": {}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc    string
+		cond    Condition
+		wantErr string
+	}{{
+		desc: "exposed ports",
+		cond: ExposedPortsCondition{Want: map[string]bool{"8080": true, "53/udp": true, "53/tcp": false, "22": false}},
+	}, {
+		desc:    "port not exposed",
+		cond:    ExposedPortsCondition{Want: map[string]bool{"443": true}},
+		wantErr: `port "443/tcp" is not exposed`,
+	}, {
+		desc:    "port exposed",
+		cond:    ExposedPortsCondition{Want: map[string]bool{"8080/tcp": false}},
+		wantErr: `port "8080/tcp" is exposed`,
+	}, {
+		desc: "volumes",
+		cond: VolumesCondition{Want: map[string]bool{"/data": true, "/tmp": false}},
+	}, {
+		desc:    "volume not declared",
+		cond:    VolumesCondition{Want: map[string]bool{"/var/lib/db": true}},
+		wantErr: `volume "/var/lib/db" is not declared`,
+	}, {
+		desc:    "volume declared",
+		cond:    VolumesCondition{Want: map[string]bool{"/data": false}},
+		wantErr: `volume "/data" is declared`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			err := c.cond.Check(img)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case c.wantErr != "" && err == nil:
+				t.Errorf("expected error %q, got nil", c.wantErr)
+			case c.wantErr != "" && !strings.Contains(err.Error(), c.wantErr):
+				t.Errorf("got error %q, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestForbiddenExecutablesOnPath(t *testing.T) {
+	img, err := mutate.Config(testImage(t), v1.Config{Env: []string{"PATH=/usr/bin:/etc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ForbiddenExecutablesCondition{Names: []string{"passwd"}}.Check(img)
+	if want := `forbidden executable "/etc/passwd" found`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestGoldenCondition(t *testing.T) {
+	img := testImage(t)
+
+	listing, err := Listing(img, false)
+	if err != nil {
+		t.Fatalf("Listing: %v", err)
+	}
+	for _, want := range []string{"0755 dir /etc\n", "0644 10 /etc/passwd\n", "4755 0 /usr/bin/su\n", "0777 link:busybox /usr/bin/sh\n"} {
+		if !strings.Contains(string(listing), want) {
+			t.Errorf("listing doesn't contain %q:\n%s", want, listing)
+		}
+	}
+	hashed, err := Listing(img, true)
+	if err != nil {
+		t.Fatalf("Listing with hashes: %v", err)
+	}
+	if want := fmt.Sprintf("0644 sha256:%x /etc/passwd\n", sha256.Sum256([]byte("root:x:0:0"))); !strings.Contains(string(hashed), want) {
+		t.Errorf("hashed listing doesn't contain %q:\n%s", want, hashed)
+	}
+
+	for _, c := range []struct {
+		desc    string
+		golden  string
+		wantErr []string
+	}{{
+		desc:   "sizes",
+		golden: string(listing),
+	}, {
+		desc:   "hashes",
+		golden: "# hashes\n\n" + string(hashed),
+	}, {
+		desc:   "drift",
+		golden: strings.NewReplacer("0644 10 /etc/passwd\n", "0600 10 /etc/passwd\n", "0755 dir /tmp\n", "", "1777 dir /tmp\n", "").Replace(string(listing)) + "0644 5 /etc/hosts\n",
+		wantErr: []string{
+			"changed: 0644 10 /etc/passwd, want 0600 10",
+			"added: 1777 dir /tmp",
+			"removed: 0644 5 /etc/hosts",
+		},
+	}, {
+		desc:    "changed contents",
+		golden:  strings.Replace(string(hashed), "sha256:", "sha256:0", 1),
+		wantErr: []string{"changed: 0644 sha256:"},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			g, err := ParseGolden(strings.NewReader(c.golden))
+			if err != nil {
+				t.Fatalf("ParseGolden: %v", err)
+			}
+			for engine, check := range map[string]func(v1.Image) error{
+				"check":     g.Check,
+				"streaming": Conditions{g}.CheckStreaming,
+			} {
+				err := check(img)
+				if len(c.wantErr) == 0 {
+					if err != nil {
+						t.Errorf("%s: unexpected error: %v", engine, err)
+					}
+					continue
+				}
+				if err == nil {
+					t.Fatalf("%s: expected error, got nil", engine)
+				}
+				for _, want := range c.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("%s: got error %q, want %q", engine, err, want)
+					}
+				}
+			}
+		})
+	}
+
+	for _, bad := range []string{"0644 /etc/passwd", "0999 10 /etc/passwd", "0644 10 etc/passwd", "0644 10 /a\n0644 10 /a"} {
+		if _, err := ParseGolden(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseGolden(%q): expected error", bad)
+		}
+	}
+}
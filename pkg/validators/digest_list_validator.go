@@ -0,0 +1,55 @@
+package validators
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// DigestListValidator is a list, set or map validator that checks that each
+// string in the collection, or each value of the map, is a valid OCI
+// reference by digest.
+type DigestListValidator struct {
+	// Bare, if set, requires bare digests (e.g. "sha256:abcdef...") rather
+	// than references by digest.
+	Bare bool
+}
+
+var (
+	_ validator.List = DigestListValidator{}
+	_ validator.Set  = DigestListValidator{}
+	_ validator.Map  = DigestListValidator{}
+)
+
+func (v DigestListValidator) Description(context.Context) string {
+	if v.Bare {
+		return `each value must be a valid digest (e.g., "sha256:abcdef...")`
+	}
+	return `each value must be a valid OCI digest reference (e.g., "example.com/image@sha256:abcdef...")`
+}
+func (v DigestListValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v DigestListValidator) ValidateList(_ context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	checkList(&resp.Diagnostics, req.Path, req.ConfigValue, "Invalid OCI digest", v.check)
+}
+
+func (v DigestListValidator) ValidateSet(_ context.Context, req validator.SetRequest, resp *validator.SetResponse) {
+	checkSet(&resp.Diagnostics, req.Path, req.ConfigValue, "Invalid OCI digest", v.check)
+}
+
+func (v DigestListValidator) ValidateMap(_ context.Context, req validator.MapRequest, resp *validator.MapResponse) {
+	checkMap(&resp.Diagnostics, req.Path, req.ConfigValue, "Invalid OCI digest", v.check)
+}
+
+func (v DigestListValidator) check(s string) error {
+	if v.Bare {
+		_, err := v1.NewHash(s)
+		return err
+	}
+	_, err := name.NewDigest(s)
+	return err
+}
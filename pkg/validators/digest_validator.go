@@ -4,24 +4,46 @@ import (
 	"context"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // DigestValidator is a string validator that checks that the string is valid OCI reference by digest.
-type DigestValidator struct{}
+type DigestValidator struct {
+	// ResolveAttribute, if set, is the name of a top-level bool attribute
+	// that, when true, allows any reference, to be resolved to a digest.
+	ResolveAttribute string
+}
 
 var _ validator.String = DigestValidator{}
 
 func (v DigestValidator) Description(context.Context) string {
+	if v.ResolveAttribute != "" {
+		return `value must be a valid OCI digest reference (e.g., "example.com/image@sha256:abcdef..."), or any valid OCI reference if ` + v.ResolveAttribute + ` is true`
+	}
 	return `value must be a valid OCI digest reference (e.g., "example.com/image@sha256:abcdef...")`
 }
 func (v DigestValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
 
-func (v DigestValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+func (v DigestValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
 	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
 		return
 	}
 	val := req.ConfigValue.ValueString()
+	if v.ResolveAttribute != "" {
+		var resolve types.Bool
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(v.ResolveAttribute), &resolve)...)
+		if resolve.IsUnknown() {
+			return
+		}
+		if resolve.ValueBool() {
+			if _, err := name.ParseReference(val); err != nil {
+				resp.Diagnostics.AddError("Invalid OCI reference", err.Error())
+			}
+			return
+		}
+	}
 	if _, err := name.NewDigest(val); err != nil {
 		resp.Diagnostics.AddError("Invalid OCI digest", err.Error())
 	}
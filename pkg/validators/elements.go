@@ -0,0 +1,49 @@
+package validators
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// checkList calls check for each known string in l, adding its error as an
+// error on the element with summary.
+func checkList(diags *diag.Diagnostics, p path.Path, l basetypes.ListValue, summary string, check func(string) error) {
+	if l.IsNull() || l.IsUnknown() {
+		return
+	}
+	for i, e := range l.Elements() {
+		checkElement(diags, p.AtListIndex(i), e, summary, check)
+	}
+}
+
+// checkSet is checkList for a set.
+func checkSet(diags *diag.Diagnostics, p path.Path, s basetypes.SetValue, summary string, check func(string) error) {
+	if s.IsNull() || s.IsUnknown() {
+		return
+	}
+	for _, e := range s.Elements() {
+		checkElement(diags, p.AtSetValue(e), e, summary, check)
+	}
+}
+
+// checkMap is checkList for the values of a map.
+func checkMap(diags *diag.Diagnostics, p path.Path, m basetypes.MapValue, summary string, check func(string) error) {
+	if m.IsNull() || m.IsUnknown() {
+		return
+	}
+	for k, e := range m.Elements() {
+		checkElement(diags, p.AtMapKey(k), e, summary, check)
+	}
+}
+
+func checkElement(diags *diag.Diagnostics, p path.Path, e any, summary string, check func(string) error) {
+	s, ok := e.(types.String)
+	if !ok || s.IsNull() || s.IsUnknown() {
+		return
+	}
+	if err := check(s.ValueString()); err != nil {
+		diags.AddAttributeError(p, summary, err.Error())
+	}
+}
@@ -0,0 +1,56 @@
+package validators
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// labelKeyRE matches reverse-DNS keys, like "org.opencontainers.image.source":
+// at least two dot-separated components of lowercase letters and digits,
+// which may contain single hyphens.
+var labelKeyRE = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*(\.[a-z0-9]+(-[a-z0-9]+)*)+$`)
+
+// LabelValidator is a string validator that checks that the string is an
+// OCI annotation or label key in reverse-DNS notation (e.g.
+// "com.example.key"). As a map validator, it checks each key in the map.
+type LabelValidator struct{}
+
+var (
+	_ validator.String = LabelValidator{}
+	_ validator.Map    = LabelValidator{}
+)
+
+func (v LabelValidator) Description(context.Context) string {
+	return `value must be a label key in reverse-DNS notation (e.g., "org.opencontainers.image.source")`
+}
+func (v LabelValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+
+func (v LabelValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if err := validateLabelKey(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Invalid label key", err.Error())
+	}
+}
+
+func (v LabelValidator) ValidateMap(_ context.Context, req validator.MapRequest, resp *validator.MapResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	for k := range req.ConfigValue.Elements() {
+		if err := validateLabelKey(k); err != nil {
+			resp.Diagnostics.AddAttributeError(req.Path.AtMapKey(k), "Invalid label key", err.Error())
+		}
+	}
+}
+
+func validateLabelKey(k string) error {
+	if !labelKeyRE.MatchString(k) {
+		return fmt.Errorf("label key %q must be in reverse-DNS notation, e.g. com.example.key, with lowercase letters, digits, dots and hyphens", k)
+	}
+	return nil
+}
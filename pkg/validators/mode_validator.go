@@ -0,0 +1,29 @@
+package validators
+
+import (
+	"context"
+
+	"github.com/chainguard-dev/terraform-provider-oci/pkg/structure"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// ModeValidator is a string validator that checks that the string is an
+// octal file mode, e.g. "0755", which may set the setuid, setgid and sticky
+// bits, e.g. "4755".
+type ModeValidator struct{}
+
+var _ validator.String = ModeValidator{}
+
+func (v ModeValidator) Description(context.Context) string {
+	return `value must be an octal file mode (e.g., "0755" or "4755")`
+}
+func (v ModeValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+
+func (v ModeValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := structure.ParseMode(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid mode", err.Error())
+	}
+}
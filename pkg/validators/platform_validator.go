@@ -0,0 +1,52 @@
+package validators
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// PlatformValidator is a string validator that checks that the string is a
+// platform like "os/arch" or "os/arch/variant". As a list validator, it
+// checks each string in the list.
+type PlatformValidator struct{}
+
+var (
+	_ validator.String = PlatformValidator{}
+	_ validator.List   = PlatformValidator{}
+)
+
+func (v PlatformValidator) Description(context.Context) string {
+	return `value must be a platform (e.g., "linux/amd64" or "linux/arm/v7")`
+}
+func (v PlatformValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+
+func (v PlatformValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if err := validatePlatform(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Invalid platform", err.Error())
+	}
+}
+
+func (v PlatformValidator) ValidateList(_ context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	checkList(&resp.Diagnostics, req.Path, req.ConfigValue, "Invalid platform", validatePlatform)
+}
+
+// validatePlatform checks that s is "os/arch" or "os/arch/variant", with no
+// empty parts.
+func validatePlatform(s string) error {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("platform %q must be os/arch or os/arch/variant", s)
+	}
+	for _, p := range parts {
+		if p == "" || strings.TrimSpace(p) != p {
+			return fmt.Errorf("platform %q must be os/arch or os/arch/variant, with no empty parts or spaces", s)
+		}
+	}
+	return nil
+}
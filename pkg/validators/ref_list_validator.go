@@ -0,0 +1,59 @@
+package validators
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// RefListValidator is a list, set or map validator that checks that each
+// string in the collection, or each value of the map, is a valid OCI
+// reference.
+type RefListValidator struct {
+	// Repo, if set, requires repository names (e.g. "example.com/image")
+	// rather than references.
+	Repo bool
+}
+
+var (
+	_ validator.List = RefListValidator{}
+	_ validator.Set  = RefListValidator{}
+	_ validator.Map  = RefListValidator{}
+)
+
+func (v RefListValidator) Description(context.Context) string {
+	if v.Repo {
+		return `each value must be a valid OCI repository name (e.g., "example.com/image")`
+	}
+	return `each value must be a valid OCI reference (e.g., "example.com/image:tag" or "example.com/image@sha256:abcdef...")`
+}
+func (v RefListValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+
+func (v RefListValidator) ValidateList(_ context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	checkList(&resp.Diagnostics, req.Path, req.ConfigValue, v.summary(), v.check)
+}
+
+func (v RefListValidator) ValidateSet(_ context.Context, req validator.SetRequest, resp *validator.SetResponse) {
+	checkSet(&resp.Diagnostics, req.Path, req.ConfigValue, v.summary(), v.check)
+}
+
+func (v RefListValidator) ValidateMap(_ context.Context, req validator.MapRequest, resp *validator.MapResponse) {
+	checkMap(&resp.Diagnostics, req.Path, req.ConfigValue, v.summary(), v.check)
+}
+
+func (v RefListValidator) summary() string {
+	if v.Repo {
+		return "Invalid OCI repository"
+	}
+	return "Invalid image reference"
+}
+
+func (v RefListValidator) check(s string) error {
+	if v.Repo {
+		_, err := name.NewRepository(s)
+		return err
+	}
+	_, err := name.ParseReference(s)
+	return err
+}
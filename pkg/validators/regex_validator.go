@@ -0,0 +1,28 @@
+package validators
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// RegexValidator is a string validator that checks that the string is a
+// regular expression that compiles, in Go's RE2 syntax.
+type RegexValidator struct{}
+
+var _ validator.String = RegexValidator{}
+
+func (v RegexValidator) Description(context.Context) string {
+	return "value must be a valid regular expression"
+}
+func (v RegexValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
+
+func (v RegexValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := regexp.Compile(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Invalid regex", err.Error())
+	}
+}
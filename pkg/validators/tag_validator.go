@@ -7,10 +7,14 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 )
 
-// TagValidator is a string validator that checks that the string is valid OCI reference by digest.
+// TagValidator is a string validator that checks that the string is a valid
+// OCI tag. As a map validator, it checks each key in the map.
 type TagValidator struct{}
 
-var _ validator.String = DigestValidator{}
+var (
+	_ validator.String = TagValidator{}
+	_ validator.Map    = TagValidator{}
+)
 
 func (v TagValidator) Description(context.Context) string {
 	return `value must be a valid OCI tag element (e.g., "latest", "v1.2.3")`
@@ -21,7 +25,23 @@ func (v TagValidator) ValidateString(_ context.Context, req validator.StringRequ
 	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
 		return
 	}
-	if _, err := name.NewTag("example.com:" + req.ConfigValue.ValueString()); err != nil {
+	if err := checkTag(req.ConfigValue.ValueString()); err != nil {
 		resp.Diagnostics.AddError("Invalid OCI tag name", err.Error())
 	}
 }
+
+func (v TagValidator) ValidateMap(_ context.Context, req validator.MapRequest, resp *validator.MapResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	for k := range req.ConfigValue.Elements() {
+		if err := checkTag(k); err != nil {
+			resp.Diagnostics.AddAttributeError(req.Path.AtMapKey(k), "Invalid OCI tag name", err.Error())
+		}
+	}
+}
+
+func checkTag(s string) error {
+	_, err := name.NewTag("example.com:" + s)
+	return err
+}
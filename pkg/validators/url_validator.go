@@ -2,17 +2,33 @@ package validators
 
 import (
 	"context"
+	"fmt"
 	"net/url"
+	"slices"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 )
 
-// URLValidator is a string validator that checks that the string is a valid URL.
-type URLValidator struct{}
+// URLValidator is a string validator that checks that the string is an
+// absolute URL, with a host, in one of the allowed schemes.
+type URLValidator struct {
+	// Schemes are the allowed schemes (default http and https).
+	Schemes []string
+}
 
 var _ validator.String = URLValidator{}
 
-func (v URLValidator) Description(context.Context) string             { return "value must be a valid URL" }
+func (v URLValidator) schemes() []string {
+	if len(v.Schemes) == 0 {
+		return []string{"http", "https"}
+	}
+	return v.Schemes
+}
+
+func (v URLValidator) Description(context.Context) string {
+	return fmt.Sprintf("value must be an absolute URL with scheme %s", strings.Join(v.schemes(), " or "))
+}
 func (v URLValidator) MarkdownDescription(ctx context.Context) string { return v.Description(ctx) }
 
 func (v URLValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
@@ -20,7 +36,16 @@ func (v URLValidator) ValidateString(_ context.Context, req validator.StringRequ
 		return
 	}
 	val := req.ConfigValue.ValueString()
-	if _, err := url.Parse(val); err != nil {
+	u, err := url.Parse(val)
+	if err != nil {
 		resp.Diagnostics.AddError("Invalid url", err.Error())
+		return
+	}
+	if !u.IsAbs() || u.Host == "" {
+		resp.Diagnostics.AddError("Invalid url", fmt.Sprintf("url %q must be absolute, with a scheme and host", val))
+		return
+	}
+	if !slices.Contains(v.schemes(), strings.ToLower(u.Scheme)) {
+		resp.Diagnostics.AddError("Invalid url", fmt.Sprintf("url %q has scheme %q, want %s", val, u.Scheme, strings.Join(v.schemes(), " or ")))
 	}
 }
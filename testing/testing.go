@@ -1,19 +1,51 @@
 package testing
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 )
 
+// RegistryOption configures a registry started by SetupRegistry.
+type RegistryOption func(*registryOptions)
+
+type registryOptions struct {
+	ropts []registry.Option
+}
+
+// WithReferrers enables the OCI 1.1 referrers API in the registry. Without
+// it, clients fall back to the referrers tag scheme, with referrers listed in
+// an index tagged sha256-<digest>.
+func WithReferrers() RegistryOption {
+	return func(o *registryOptions) {
+		o.ropts = append(o.ropts, registry.WithReferrersSupport(true))
+	}
+}
+
 // SetupRegistry starts a local registry for testing.
 //
-// If TF_OCI_REGISTRY is set, it will be used instead.
-func SetupRegistry(t *testing.T) (name.Registry, func()) {
+// If TF_OCI_REGISTRY is set, it will be used instead, and opts are ignored.
+func SetupRegistry(t *testing.T, opts ...RegistryOption) (name.Registry, func()) {
 	t.Helper()
 	if got := os.Getenv("TF_OCI_REGISTRY"); got != "" {
 		reg, err := name.NewRegistry(got)
@@ -22,7 +54,11 @@ func SetupRegistry(t *testing.T) (name.Registry, func()) {
 		}
 		return reg, func() {}
 	}
-	srv := httptest.NewServer(registry.New())
+	o := &registryOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	srv := httptest.NewServer(registry.New(o.ropts...))
 	t.Logf("Started registry: %s", srv.URL)
 	reg, err := name.NewRegistry(strings.TrimPrefix(srv.URL, "http://"))
 	if err != nil {
@@ -34,8 +70,8 @@ func SetupRegistry(t *testing.T) (name.Registry, func()) {
 // SetupRegistry starts a local registry for testing and returns a repository within that registry.
 //
 // If TF_OCI_REGISTRY is set, that registry will be used instead.
-func SetupRepository(t *testing.T, repo string) (name.Repository, func()) {
-	reg, cleanup := SetupRegistry(t)
+func SetupRepository(t *testing.T, repo string, opts ...RegistryOption) (name.Repository, func()) {
+	reg, cleanup := SetupRegistry(t, opts...)
 	// TODO: use reg.Repo after https://github.com/google/go-containerregistry/pull/1671
 	r, err := name.NewRepository(reg.RegistryStr() + "/" + repo)
 	if err != nil {
@@ -43,3 +79,197 @@ func SetupRepository(t *testing.T, repo string) (name.Repository, func()) {
 	}
 	return r, cleanup
 }
+
+// SetupAuthRegistry starts a local registry for testing that requires HTTP
+// basic auth with username and password, like an htpasswd-protected registry.
+func SetupAuthRegistry(t *testing.T, username, password string) (name.Registry, func()) {
+	t.Helper()
+	h := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			http.Error(w, `{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`, http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Logf("Started registry with basic auth: %s", srv.URL)
+	reg, err := name.NewRegistry(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("failed to parse test registry: %v", err)
+	}
+	return reg, srv.Close
+}
+
+// SetupTLSRegistry starts a local registry for testing served over HTTPS, with
+// a freshly generated self-signed certificate for localhost and 127.0.0.1. It
+// returns the certificate in PEM form, for tests to trust as a CA.
+//
+// The registry is named localhost:<port>.
+func SetupTLSRegistry(t *testing.T) (name.Registry, []byte, func()) {
+	t.Helper()
+	cert, certPEM := selfSignedCert(t)
+	srv := httptest.NewUnstartedServer(registry.New())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Logf("Started TLS registry: %s", srv.URL)
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "https://"))
+	if err != nil {
+		t.Fatalf("failed to parse test registry address: %v", err)
+	}
+	reg, err := name.NewRegistry("localhost:" + port)
+	if err != nil {
+		t.Fatalf("failed to parse test registry: %v", err)
+	}
+	return reg, certPEM, srv.Close
+}
+
+// selfSignedCert returns a self-signed certificate for localhost and
+// 127.0.0.1, and the certificate in PEM form.
+func selfSignedCert(t *testing.T) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, certPEM
+}
+
+// Faults configures the faults injected by SetupFaultyRegistry. Each fraction
+// is the chance, from 0 to 1, that a request fails in that way.
+//
+// Whether a request fails depends only on Seed, its method and path, and how
+// many times it has been made before, so runs are reproducible even when
+// requests are made concurrently.
+type Faults struct {
+	// TooManyRequests is the fraction of requests that get a 429.
+	TooManyRequests float64
+	// ServerErrors is the fraction of requests that get a 500.
+	ServerErrors float64
+	// Timeouts is the fraction of requests that hang for Timeout, and then
+	// have their connection closed without a response.
+	Timeouts float64
+	// Timeout is how long timed out requests hang (default is 1s).
+	Timeout time.Duration
+
+	// Methods, if set, limits faults to requests with these methods, e.g. to
+	// only fail pulls.
+	Methods []string
+	// MaxPerRequest, if set, is the most times the same request fails, so
+	// that retries eventually succeed.
+	MaxPerRequest int
+	Seed          int64
+}
+
+// FaultStats counts the requests made to a registry from SetupFaultyRegistry.
+type FaultStats struct {
+	mu       sync.Mutex
+	attempts map[string]int
+	requests int
+	injected int
+}
+
+// Requests returns the number of requests made, including failed ones.
+func (s *FaultStats) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Injected returns the number of requests that failed with an injected fault.
+func (s *FaultStats) Injected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.injected
+}
+
+// SetupFaultyRegistry starts a local registry for testing that fails some
+// requests with 429s, 500s or timeouts, as configured by f, to exercise retries.
+func SetupFaultyRegistry(t *testing.T, f Faults) (name.Registry, *FaultStats, func()) {
+	t.Helper()
+	if f.Timeout == 0 {
+		f.Timeout = time.Second
+	}
+	stats := &FaultStats{attempts: map[string]int{}}
+	h := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch stats.fault(f, r) {
+		case http.StatusTooManyRequests:
+			http.Error(w, `{"errors":[{"code":"TOOMANYREQUESTS","message":"injected fault"}]}`, http.StatusTooManyRequests)
+		case http.StatusInternalServerError:
+			http.Error(w, `{"errors":[{"code":"UNKNOWN","message":"injected fault"}]}`, http.StatusInternalServerError)
+		case http.StatusGatewayTimeout:
+			select {
+			case <-time.After(f.Timeout):
+			case <-r.Context().Done():
+			}
+			// Close the connection without writing a response.
+			panic(http.ErrAbortHandler)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	}))
+	t.Logf("Started faulty registry: %s", srv.URL)
+	reg, err := name.NewRegistry(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("failed to parse test registry: %v", err)
+	}
+	return reg, stats, srv.Close
+}
+
+// fault returns the status of the fault to inject for r, with
+// http.StatusGatewayTimeout standing for a timeout, or 0 for none.
+func (s *FaultStats) fault(f Faults, r *http.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if len(f.Methods) > 0 && !slices.Contains(f.Methods, r.Method) {
+		return 0
+	}
+	key := r.Method + " " + r.URL.Path
+	attempt := s.attempts[key]
+	s.attempts[key]++
+	if f.MaxPerRequest > 0 && attempt >= f.MaxPerRequest {
+		return 0
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d %s %d", f.Seed, key, attempt)
+	u := float64(h.Sum64()>>11) / (1 << 53)
+
+	var status int
+	switch {
+	case u < f.TooManyRequests:
+		status = http.StatusTooManyRequests
+	case u < f.TooManyRequests+f.ServerErrors:
+		status = http.StatusInternalServerError
+	case u < f.TooManyRequests+f.ServerErrors+f.Timeouts:
+		status = http.StatusGatewayTimeout
+	default:
+		return 0
+	}
+	s.injected++
+	return status
+}